@@ -6,7 +6,6 @@ import (
 
 	"hero-emulator/database"
 	"hero-emulator/server"
-	"hero-emulator/utils"
 )
 
 func createMobs() {
@@ -22,21 +21,19 @@ func createMobs() {
 				continue
 			}
 
-			newai := &database.AI{ID: len(database.AIs), HP: npc.MaxHp, Map: npcPos.MapID, PosID: npcPos.ID, RunningSpeed: 10, Server: 98, WalkingSpeed: 5, Once: true}
+			newai := &database.AI{HP: npc.MaxHp, Map: npcPos.MapID, PosID: npcPos.ID, RunningSpeed: 10, Server: 98, WalkingSpeed: 5, Once: true}
 			server.GenerateIDForAI(newai)
 			newai.OnSightPlayers = make(map[int]interface{})
 
 			minLoc := database.ConvertPointToLocation(npcPos.MinLocation)
 			maxLoc := database.ConvertPointToLocation(npcPos.MaxLocation)
-			loc := utils.Location{X: utils.RandFloat(minLoc.X, maxLoc.X), Y: utils.RandFloat(minLoc.Y, maxLoc.Y)}
+			loc := database.RandomWalkablePoint(npcPos.MapID, minLoc, maxLoc)
 			newai.Coordinate = loc.String()
 			fmt.Println(newai.Coordinate)
 			newai.Handler = newai.AIHandler
-			database.AIsByMap[newai.Server][npcPos.MapID] = append(database.AIsByMap[newai.Server][npcPos.MapID], newai)
-			database.AIs[newai.ID] = newai
-			fmt.Println("New mob created", len(database.AIs))
+			database.RegisterAI(newai)
+			fmt.Println("New mob created", newai.ID)
 			newai.Create()
-			go newai.Handler()
 		}
 	}
 	fmt.Println("Finished")
@@ -46,14 +43,6 @@ func Init() {
 	for s := 0; s <= database.SERVER_COUNT; s++ {
 		database.AIsByMap[s] = make(map[int16][]*database.AI)
 	}
-	database.DungeonsByMap = make([]map[int16]int, database.SERVER_COUNT+1)
-	for s := 0; s <= database.SERVER_COUNT; s++ {
-		database.DungeonsByMap[s] = make(map[int16]int)
-	}
-	database.DungeonsAiByMap = make([]map[int16][]*database.AI, database.SERVER_COUNT+1)
-	for s := 0; s <= database.SERVER_COUNT; s++ {
-		database.DungeonsAiByMap[s] = make(map[int16][]*database.AI)
-	}
 	func() {
 		<-server.Init
 
@@ -76,6 +65,25 @@ func Init() {
 			log.Println(err)
 			return
 		}
+		if _, err := database.LoadEncounters(); err != nil {
+			log.Println(err)
+		}
+		if _, err := database.LoadDungeons(); err != nil {
+			log.Println(err)
+		}
+		if _, err := database.LoadBattlegrounds(); err != nil {
+			log.Println(err)
+		}
+		if _, err := database.LoadTerritories(); err != nil {
+			log.Println(err)
+		}
+		if _, err := database.LoadChatFilter(); err != nil {
+			log.Println(err)
+		}
+		if _, err := database.LoadNavGrids(); err != nil {
+			log.Println(err)
+		}
+
 		//createMobs()
 		err = database.GetAllAI()
 		if err != nil {
@@ -83,11 +91,7 @@ func Init() {
 			return
 		}
 
-		for _, ai := range database.AIs {
-			database.AIsByMap[ai.Server][ai.Map] = append(database.AIsByMap[ai.Server][ai.Map], ai)
-		}
-
-		for _, AI := range database.AIs {
+		for _, AI := range database.AllAIs() {
 			if AI.ID == 0 {
 				continue
 			}
@@ -104,15 +108,8 @@ func Init() {
 			}*/
 
 			server.GenerateIDForAI(AI)
-			if AI.ID == 55281 || AI.ID == 55283 || AI.ID == 55287 || AI.ID == 55289 || AI.ID == 55285 {
-				log.Println(fmt.Sprintf("Pseudo: %d", AI.PseudoID))
-				newStone := &database.WarStone{PseudoID: AI.PseudoID, NpcID: pos.NPCID, NearbyZuhang: 0, NearbyShao: 0, ConquereValue: 100}
-				database.WarStonesIDs = append(database.WarStonesIDs, AI.PseudoID)
-				database.WarStones[int(AI.PseudoID)] = newStone
-			}
-			if AI.WalkingSpeed > 0 {
-				go AI.Handler()
-			}
 		}
+
+		database.StartWorlds()
 	}()
 }
@@ -446,6 +446,668 @@ func (m *GetTavernResponse) GetItems() []byte {
 	return nil
 }
 
+type DungeonLeaderboardRequest struct {
+	DungeonId            int32    `protobuf:"varint,1,opt,name=dungeon_id,json=dungeonId,proto3" json:"dungeon_id,omitempty"`
+	Weekly               bool     `protobuf:"varint,2,opt,name=weekly,proto3" json:"weekly,omitempty"`
+	Limit                int32    `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DungeonLeaderboardRequest) Reset()         { *m = DungeonLeaderboardRequest{} }
+func (m *DungeonLeaderboardRequest) String() string { return proto.CompactTextString(m) }
+func (*DungeonLeaderboardRequest) ProtoMessage()    {}
+func (*DungeonLeaderboardRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{8}
+}
+
+func (m *DungeonLeaderboardRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DungeonLeaderboardRequest.Unmarshal(m, b)
+}
+func (m *DungeonLeaderboardRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DungeonLeaderboardRequest.Marshal(b, m, deterministic)
+}
+func (m *DungeonLeaderboardRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DungeonLeaderboardRequest.Merge(m, src)
+}
+func (m *DungeonLeaderboardRequest) XXX_Size() int {
+	return xxx_messageInfo_DungeonLeaderboardRequest.Size(m)
+}
+func (m *DungeonLeaderboardRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DungeonLeaderboardRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DungeonLeaderboardRequest proto.InternalMessageInfo
+
+func (m *DungeonLeaderboardRequest) GetDungeonId() int32 {
+	if m != nil {
+		return m.DungeonId
+	}
+	return 0
+}
+
+func (m *DungeonLeaderboardRequest) GetWeekly() bool {
+	if m != nil {
+		return m.Weekly
+	}
+	return false
+}
+
+func (m *DungeonLeaderboardRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type DungeonRunMember struct {
+	CharacterId          int32    `protobuf:"varint,1,opt,name=character_id,json=characterId,proto3" json:"character_id,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Kills                int32    `protobuf:"varint,3,opt,name=kills,proto3" json:"kills,omitempty"`
+	Deaths               int32    `protobuf:"varint,4,opt,name=deaths,proto3" json:"deaths,omitempty"`
+	Damage               int64    `protobuf:"varint,5,opt,name=damage,proto3" json:"damage,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DungeonRunMember) Reset()         { *m = DungeonRunMember{} }
+func (m *DungeonRunMember) String() string { return proto.CompactTextString(m) }
+func (*DungeonRunMember) ProtoMessage()    {}
+func (*DungeonRunMember) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{9}
+}
+
+func (m *DungeonRunMember) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DungeonRunMember.Unmarshal(m, b)
+}
+func (m *DungeonRunMember) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DungeonRunMember.Marshal(b, m, deterministic)
+}
+func (m *DungeonRunMember) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DungeonRunMember.Merge(m, src)
+}
+func (m *DungeonRunMember) XXX_Size() int {
+	return xxx_messageInfo_DungeonRunMember.Size(m)
+}
+func (m *DungeonRunMember) XXX_DiscardUnknown() {
+	xxx_messageInfo_DungeonRunMember.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DungeonRunMember proto.InternalMessageInfo
+
+func (m *DungeonRunMember) GetCharacterId() int32 {
+	if m != nil {
+		return m.CharacterId
+	}
+	return 0
+}
+
+func (m *DungeonRunMember) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *DungeonRunMember) GetKills() int32 {
+	if m != nil {
+		return m.Kills
+	}
+	return 0
+}
+
+func (m *DungeonRunMember) GetDeaths() int32 {
+	if m != nil {
+		return m.Deaths
+	}
+	return 0
+}
+
+func (m *DungeonRunMember) GetDamage() int64 {
+	if m != nil {
+		return m.Damage
+	}
+	return 0
+}
+
+type DungeonRun struct {
+	Id                   int32               `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	DungeonId            int32               `protobuf:"varint,2,opt,name=dungeon_id,json=dungeonId,proto3" json:"dungeon_id,omitempty"`
+	Dungeon              string              `protobuf:"bytes,3,opt,name=dungeon,proto3" json:"dungeon,omitempty"`
+	Result               string              `protobuf:"bytes,4,opt,name=result,proto3" json:"result,omitempty"`
+	StartedAt            string              `protobuf:"bytes,5,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	EndedAt              string              `protobuf:"bytes,6,opt,name=ended_at,json=endedAt,proto3" json:"ended_at,omitempty"`
+	Duration             int32               `protobuf:"varint,7,opt,name=duration,proto3" json:"duration,omitempty"`
+	Kills                int32               `protobuf:"varint,8,opt,name=kills,proto3" json:"kills,omitempty"`
+	Deaths               int32               `protobuf:"varint,9,opt,name=deaths,proto3" json:"deaths,omitempty"`
+	Damage               int64               `protobuf:"varint,10,opt,name=damage,proto3" json:"damage,omitempty"`
+	Members              []*DungeonRunMember `protobuf:"bytes,11,rep,name=members,proto3" json:"members,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *DungeonRun) Reset()         { *m = DungeonRun{} }
+func (m *DungeonRun) String() string { return proto.CompactTextString(m) }
+func (*DungeonRun) ProtoMessage()    {}
+func (*DungeonRun) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{10}
+}
+
+func (m *DungeonRun) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DungeonRun.Unmarshal(m, b)
+}
+func (m *DungeonRun) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DungeonRun.Marshal(b, m, deterministic)
+}
+func (m *DungeonRun) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DungeonRun.Merge(m, src)
+}
+func (m *DungeonRun) XXX_Size() int {
+	return xxx_messageInfo_DungeonRun.Size(m)
+}
+func (m *DungeonRun) XXX_DiscardUnknown() {
+	xxx_messageInfo_DungeonRun.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DungeonRun proto.InternalMessageInfo
+
+func (m *DungeonRun) GetId() int32 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *DungeonRun) GetDungeonId() int32 {
+	if m != nil {
+		return m.DungeonId
+	}
+	return 0
+}
+
+func (m *DungeonRun) GetDungeon() string {
+	if m != nil {
+		return m.Dungeon
+	}
+	return ""
+}
+
+func (m *DungeonRun) GetResult() string {
+	if m != nil {
+		return m.Result
+	}
+	return ""
+}
+
+func (m *DungeonRun) GetStartedAt() string {
+	if m != nil {
+		return m.StartedAt
+	}
+	return ""
+}
+
+func (m *DungeonRun) GetEndedAt() string {
+	if m != nil {
+		return m.EndedAt
+	}
+	return ""
+}
+
+func (m *DungeonRun) GetDuration() int32 {
+	if m != nil {
+		return m.Duration
+	}
+	return 0
+}
+
+func (m *DungeonRun) GetKills() int32 {
+	if m != nil {
+		return m.Kills
+	}
+	return 0
+}
+
+func (m *DungeonRun) GetDeaths() int32 {
+	if m != nil {
+		return m.Deaths
+	}
+	return 0
+}
+
+func (m *DungeonRun) GetDamage() int64 {
+	if m != nil {
+		return m.Damage
+	}
+	return 0
+}
+
+func (m *DungeonRun) GetMembers() []*DungeonRunMember {
+	if m != nil {
+		return m.Members
+	}
+	return nil
+}
+
+type DungeonLeaderboardResponse struct {
+	Runs                 []*DungeonRun `protobuf:"bytes,1,rep,name=runs,proto3" json:"runs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *DungeonLeaderboardResponse) Reset()         { *m = DungeonLeaderboardResponse{} }
+func (m *DungeonLeaderboardResponse) String() string { return proto.CompactTextString(m) }
+func (*DungeonLeaderboardResponse) ProtoMessage()    {}
+func (*DungeonLeaderboardResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{11}
+}
+
+func (m *DungeonLeaderboardResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DungeonLeaderboardResponse.Unmarshal(m, b)
+}
+func (m *DungeonLeaderboardResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DungeonLeaderboardResponse.Marshal(b, m, deterministic)
+}
+func (m *DungeonLeaderboardResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DungeonLeaderboardResponse.Merge(m, src)
+}
+func (m *DungeonLeaderboardResponse) XXX_Size() int {
+	return xxx_messageInfo_DungeonLeaderboardResponse.Size(m)
+}
+func (m *DungeonLeaderboardResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DungeonLeaderboardResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DungeonLeaderboardResponse proto.InternalMessageInfo
+
+func (m *DungeonLeaderboardResponse) GetRuns() []*DungeonRun {
+	if m != nil {
+		return m.Runs
+	}
+	return nil
+}
+
+type ChatLogEntry struct {
+	Channel              string   `protobuf:"bytes,1,opt,name=channel,proto3" json:"channel,omitempty"`
+	Sender               string   `protobuf:"bytes,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	Message              string   `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	CreatedAt            string   `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ChatLogEntry) Reset()         { *m = ChatLogEntry{} }
+func (m *ChatLogEntry) String() string { return proto.CompactTextString(m) }
+func (*ChatLogEntry) ProtoMessage()    {}
+func (*ChatLogEntry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{12}
+}
+
+func (m *ChatLogEntry) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ChatLogEntry.Unmarshal(m, b)
+}
+func (m *ChatLogEntry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ChatLogEntry.Marshal(b, m, deterministic)
+}
+func (m *ChatLogEntry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ChatLogEntry.Merge(m, src)
+}
+func (m *ChatLogEntry) XXX_Size() int {
+	return xxx_messageInfo_ChatLogEntry.Size(m)
+}
+func (m *ChatLogEntry) XXX_DiscardUnknown() {
+	xxx_messageInfo_ChatLogEntry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ChatLogEntry proto.InternalMessageInfo
+
+func (m *ChatLogEntry) GetChannel() string {
+	if m != nil {
+		return m.Channel
+	}
+	return ""
+}
+
+func (m *ChatLogEntry) GetSender() string {
+	if m != nil {
+		return m.Sender
+	}
+	return ""
+}
+
+func (m *ChatLogEntry) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *ChatLogEntry) GetCreatedAt() string {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return ""
+}
+
+type ChatReport struct {
+	Id                   int32           `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Reporter             string          `protobuf:"bytes,2,opt,name=reporter,proto3" json:"reporter,omitempty"`
+	Target               string          `protobuf:"bytes,3,opt,name=target,proto3" json:"target,omitempty"`
+	Reason               string          `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	Status               string          `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	CreatedAt            string          `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	HandledBy            string          `protobuf:"bytes,7,opt,name=handled_by,json=handledBy,proto3" json:"handled_by,omitempty"`
+	Action               string          `protobuf:"bytes,8,opt,name=action,proto3" json:"action,omitempty"`
+	Note                 string          `protobuf:"bytes,9,opt,name=note,proto3" json:"note,omitempty"`
+	Context              []*ChatLogEntry `protobuf:"bytes,10,rep,name=context,proto3" json:"context,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *ChatReport) Reset()         { *m = ChatReport{} }
+func (m *ChatReport) String() string { return proto.CompactTextString(m) }
+func (*ChatReport) ProtoMessage()    {}
+func (*ChatReport) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{13}
+}
+
+func (m *ChatReport) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ChatReport.Unmarshal(m, b)
+}
+func (m *ChatReport) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ChatReport.Marshal(b, m, deterministic)
+}
+func (m *ChatReport) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ChatReport.Merge(m, src)
+}
+func (m *ChatReport) XXX_Size() int {
+	return xxx_messageInfo_ChatReport.Size(m)
+}
+func (m *ChatReport) XXX_DiscardUnknown() {
+	xxx_messageInfo_ChatReport.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ChatReport proto.InternalMessageInfo
+
+func (m *ChatReport) GetId() int32 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *ChatReport) GetReporter() string {
+	if m != nil {
+		return m.Reporter
+	}
+	return ""
+}
+
+func (m *ChatReport) GetTarget() string {
+	if m != nil {
+		return m.Target
+	}
+	return ""
+}
+
+func (m *ChatReport) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *ChatReport) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *ChatReport) GetCreatedAt() string {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return ""
+}
+
+func (m *ChatReport) GetHandledBy() string {
+	if m != nil {
+		return m.HandledBy
+	}
+	return ""
+}
+
+func (m *ChatReport) GetAction() string {
+	if m != nil {
+		return m.Action
+	}
+	return ""
+}
+
+func (m *ChatReport) GetNote() string {
+	if m != nil {
+		return m.Note
+	}
+	return ""
+}
+
+func (m *ChatReport) GetContext() []*ChatLogEntry {
+	if m != nil {
+		return m.Context
+	}
+	return nil
+}
+
+type ChatReportsRequest struct {
+	Status               string   `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Limit                int32    `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ChatReportsRequest) Reset()         { *m = ChatReportsRequest{} }
+func (m *ChatReportsRequest) String() string { return proto.CompactTextString(m) }
+func (*ChatReportsRequest) ProtoMessage()    {}
+func (*ChatReportsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{14}
+}
+
+func (m *ChatReportsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ChatReportsRequest.Unmarshal(m, b)
+}
+func (m *ChatReportsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ChatReportsRequest.Marshal(b, m, deterministic)
+}
+func (m *ChatReportsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ChatReportsRequest.Merge(m, src)
+}
+func (m *ChatReportsRequest) XXX_Size() int {
+	return xxx_messageInfo_ChatReportsRequest.Size(m)
+}
+func (m *ChatReportsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ChatReportsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ChatReportsRequest proto.InternalMessageInfo
+
+func (m *ChatReportsRequest) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *ChatReportsRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type ChatReportsResponse struct {
+	Reports              []*ChatReport `protobuf:"bytes,1,rep,name=reports,proto3" json:"reports,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *ChatReportsResponse) Reset()         { *m = ChatReportsResponse{} }
+func (m *ChatReportsResponse) String() string { return proto.CompactTextString(m) }
+func (*ChatReportsResponse) ProtoMessage()    {}
+func (*ChatReportsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{15}
+}
+
+func (m *ChatReportsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ChatReportsResponse.Unmarshal(m, b)
+}
+func (m *ChatReportsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ChatReportsResponse.Marshal(b, m, deterministic)
+}
+func (m *ChatReportsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ChatReportsResponse.Merge(m, src)
+}
+func (m *ChatReportsResponse) XXX_Size() int {
+	return xxx_messageInfo_ChatReportsResponse.Size(m)
+}
+func (m *ChatReportsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ChatReportsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ChatReportsResponse proto.InternalMessageInfo
+
+func (m *ChatReportsResponse) GetReports() []*ChatReport {
+	if m != nil {
+		return m.Reports
+	}
+	return nil
+}
+
+type ResolveChatReportRequest struct {
+	Id                   int32    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	HandledBy            string   `protobuf:"bytes,2,opt,name=handled_by,json=handledBy,proto3" json:"handled_by,omitempty"`
+	Action               string   `protobuf:"bytes,3,opt,name=action,proto3" json:"action,omitempty"`
+	Note                 string   `protobuf:"bytes,4,opt,name=note,proto3" json:"note,omitempty"`
+	MuteMinutes          int32    `protobuf:"varint,5,opt,name=mute_minutes,json=muteMinutes,proto3" json:"mute_minutes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResolveChatReportRequest) Reset()         { *m = ResolveChatReportRequest{} }
+func (m *ResolveChatReportRequest) String() string { return proto.CompactTextString(m) }
+func (*ResolveChatReportRequest) ProtoMessage()    {}
+func (*ResolveChatReportRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{16}
+}
+
+func (m *ResolveChatReportRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResolveChatReportRequest.Unmarshal(m, b)
+}
+func (m *ResolveChatReportRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResolveChatReportRequest.Marshal(b, m, deterministic)
+}
+func (m *ResolveChatReportRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResolveChatReportRequest.Merge(m, src)
+}
+func (m *ResolveChatReportRequest) XXX_Size() int {
+	return xxx_messageInfo_ResolveChatReportRequest.Size(m)
+}
+func (m *ResolveChatReportRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResolveChatReportRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResolveChatReportRequest proto.InternalMessageInfo
+
+func (m *ResolveChatReportRequest) GetId() int32 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *ResolveChatReportRequest) GetHandledBy() string {
+	if m != nil {
+		return m.HandledBy
+	}
+	return ""
+}
+
+func (m *ResolveChatReportRequest) GetAction() string {
+	if m != nil {
+		return m.Action
+	}
+	return ""
+}
+
+func (m *ResolveChatReportRequest) GetNote() string {
+	if m != nil {
+		return m.Note
+	}
+	return ""
+}
+
+func (m *ResolveChatReportRequest) GetMuteMinutes() int32 {
+	if m != nil {
+		return m.MuteMinutes
+	}
+	return 0
+}
+
+type ResolveChatReportResponse struct {
+	Ok                   bool     `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	Message              string   `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResolveChatReportResponse) Reset()         { *m = ResolveChatReportResponse{} }
+func (m *ResolveChatReportResponse) String() string { return proto.CompactTextString(m) }
+func (*ResolveChatReportResponse) ProtoMessage()    {}
+func (*ResolveChatReportResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_00212fb1f9d3bf1c, []int{17}
+}
+
+func (m *ResolveChatReportResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResolveChatReportResponse.Unmarshal(m, b)
+}
+func (m *ResolveChatReportResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResolveChatReportResponse.Marshal(b, m, deterministic)
+}
+func (m *ResolveChatReportResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResolveChatReportResponse.Merge(m, src)
+}
+func (m *ResolveChatReportResponse) XXX_Size() int {
+	return xxx_messageInfo_ResolveChatReportResponse.Size(m)
+}
+func (m *ResolveChatReportResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResolveChatReportResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResolveChatReportResponse proto.InternalMessageInfo
+
+func (m *ResolveChatReportResponse) GetOk() bool {
+	if m != nil {
+		return m.Ok
+	}
+	return false
+}
+
+func (m *ResolveChatReportResponse) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*GetUserRequest)(nil), "api.GetUserRequest")
 	proto.RegisterType((*User)(nil), "api.User")
@@ -455,41 +1117,86 @@ func init() {
 	proto.RegisterType((*Server)(nil), "api.Server")
 	proto.RegisterType((*GetServerResponse)(nil), "api.GetServerResponse")
 	proto.RegisterType((*GetTavernResponse)(nil), "api.GetTavernResponse")
+	proto.RegisterType((*DungeonLeaderboardRequest)(nil), "api.DungeonLeaderboardRequest")
+	proto.RegisterType((*DungeonRunMember)(nil), "api.DungeonRunMember")
+	proto.RegisterType((*DungeonRun)(nil), "api.DungeonRun")
+	proto.RegisterType((*DungeonLeaderboardResponse)(nil), "api.DungeonLeaderboardResponse")
+	proto.RegisterType((*ChatLogEntry)(nil), "api.ChatLogEntry")
+	proto.RegisterType((*ChatReport)(nil), "api.ChatReport")
+	proto.RegisterType((*ChatReportsRequest)(nil), "api.ChatReportsRequest")
+	proto.RegisterType((*ChatReportsResponse)(nil), "api.ChatReportsResponse")
+	proto.RegisterType((*ResolveChatReportRequest)(nil), "api.ResolveChatReportRequest")
+	proto.RegisterType((*ResolveChatReportResponse)(nil), "api.ResolveChatReportResponse")
 }
 
 func init() { proto.RegisterFile("api.proto", fileDescriptor_00212fb1f9d3bf1c) }
 
 var fileDescriptor_00212fb1f9d3bf1c = []byte{
-	// 464 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x53, 0xc1, 0x6e, 0xd3, 0x40,
-	0x10, 0x8d, 0xed, 0x38, 0x89, 0x27, 0xa5, 0xc0, 0x50, 0xaa, 0x55, 0x24, 0x20, 0x5a, 0x09, 0x29,
-	0x5c, 0x8a, 0x1a, 0x4e, 0x54, 0x5c, 0x82, 0x8a, 0xaa, 0x5e, 0x38, 0x18, 0x38, 0x22, 0xd8, 0xd6,
-	0x23, 0x58, 0x35, 0x8e, 0x97, 0xdd, 0x6d, 0x21, 0xdf, 0xc0, 0x1f, 0x73, 0x42, 0x1e, 0xdb, 0xdb,
-	0x24, 0x42, 0x08, 0x6e, 0x3b, 0xef, 0xcd, 0x9b, 0x99, 0x7d, 0x3b, 0x0b, 0x99, 0x32, 0xfa, 0xc8,
-	0xd8, 0xca, 0x57, 0x98, 0x28, 0xa3, 0xe5, 0x2b, 0xd8, 0x3f, 0x23, 0xff, 0xc1, 0x91, 0xcd, 0xe9,
-	0xdb, 0x35, 0x39, 0x8f, 0xfb, 0x10, 0xeb, 0x42, 0x44, 0xd3, 0x68, 0x96, 0xe5, 0xb1, 0x2e, 0x70,
-	0x02, 0xa3, 0x6b, 0x47, 0x76, 0xa5, 0x4a, 0x12, 0x31, 0xa3, 0x21, 0x96, 0xbf, 0x22, 0xe8, 0xd7,
-	0xda, 0xff, 0x11, 0xd5, 0x9c, 0x51, 0xce, 0x7d, 0xaf, 0x6c, 0x21, 0x92, 0x86, 0xeb, 0xe2, 0x4e,
-	0xe7, 0xd7, 0x86, 0x44, 0x7f, 0x1a, 0xcd, 0xd2, 0x3c, 0xc4, 0xdc, 0xc3, 0x88, 0xb4, 0xed, 0x61,
-	0xf0, 0x10, 0x06, 0x8e, 0xec, 0x0d, 0x59, 0x31, 0xe0, 0xcc, 0x36, 0x42, 0x84, 0xfe, 0xa5, 0x72,
-	0x5f, 0xc5, 0x70, 0x1a, 0xcd, 0x92, 0x9c, 0xcf, 0x35, 0x56, 0x2a, 0xbd, 0x14, 0x23, 0x56, 0xf3,
-	0x19, 0x1f, 0x01, 0x5c, 0x5a, 0x52, 0x9e, 0x8a, 0x4f, 0xca, 0x8b, 0x8c, 0x99, 0xac, 0x45, 0x16,
-	0x1e, 0x9f, 0xc0, 0xb8, 0xd0, 0x4e, 0x5d, 0x2c, 0x1b, 0x1e, 0x98, 0x87, 0x0e, 0x5a, 0x78, 0xf9,
-	0x11, 0xee, 0xe6, 0xf4, 0x45, 0x3b, 0x7f, 0xeb, 0xdd, 0xe6, 0xb5, 0xa3, 0x9d, 0x6b, 0x77, 0x23,
-	0xc4, 0x1b, 0x23, 0xfc, 0xc5, 0x0a, 0x79, 0x02, 0xf7, 0x6e, 0xcb, 0x3b, 0x53, 0xad, 0x1c, 0x5b,
-	0x50, 0x5d, 0x71, 0xe5, 0x51, 0x1e, 0x57, 0x57, 0xb5, 0x05, 0x75, 0xfd, 0xf3, 0xd3, 0xb6, 0x6a,
-	0x1b, 0xc9, 0x21, 0xa4, 0x6f, 0x4a, 0xe3, 0xd7, 0xf2, 0x33, 0x0c, 0xde, 0x05, 0x57, 0x36, 0xc6,
-	0xe2, 0x33, 0x4a, 0xd8, 0xf3, 0x95, 0x57, 0x4b, 0xb3, 0x54, 0x6b, 0xb2, 0x8e, 0x8b, 0xa4, 0xf9,
-	0x16, 0x86, 0x8f, 0x01, 0x4a, 0xf5, 0xa3, 0xcb, 0x48, 0x38, 0x63, 0x03, 0x91, 0x27, 0x70, 0xff,
-	0x8c, 0x7c, 0xd3, 0x24, 0xcc, 0xf9, 0x14, 0x86, 0xcd, 0x63, 0x38, 0x11, 0x4d, 0x93, 0xd9, 0x78,
-	0x3e, 0x3e, 0xaa, 0xf7, 0xae, 0xcd, 0xea, 0x38, 0xf9, 0x8c, 0xb5, 0xef, 0xd5, 0x0d, 0xd9, 0x55,
-	0xd0, 0x1e, 0x40, 0xaa, 0x3d, 0x95, 0x8e, 0x27, 0xdd, 0xcb, 0x9b, 0x60, 0xfe, 0x33, 0x86, 0x64,
-	0x61, 0x34, 0x1e, 0xc3, 0x9d, 0x76, 0x5f, 0x5f, 0xaf, 0xdf, 0xd6, 0x77, 0x78, 0xc0, 0x95, 0xb7,
-	0x77, 0x78, 0x92, 0x31, 0x58, 0x23, 0xb2, 0x87, 0xcf, 0x61, 0x1c, 0x24, 0xe7, 0xa7, 0xff, 0x20,
-	0x78, 0x09, 0xa3, 0xce, 0x79, 0x3c, 0x60, 0x62, 0xe7, 0x9d, 0x27, 0x0f, 0x77, 0xd0, 0x66, 0x74,
-	0xd9, 0xc3, 0x39, 0x40, 0x70, 0xc3, 0x21, 0x70, 0x1a, 0xbf, 0xc4, 0xe4, 0xb0, 0x6b, 0xbb, 0x6d,
-	0x95, 0xec, 0xe1, 0x31, 0x64, 0xc1, 0x85, 0x3f, 0x4b, 0xb6, 0x1d, 0x92, 0xbd, 0x8b, 0x01, 0xff,
-	0xe0, 0x17, 0xbf, 0x03, 0x00, 0x00, 0xff, 0xff, 0xe5, 0x39, 0xfb, 0x08, 0xce, 0x03, 0x00, 0x00,
+	// 1021 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x56, 0x4d, 0x6f, 0xdb, 0x46,
+	0x13, 0xb6, 0xa8, 0x4f, 0x8e, 0xfc, 0x26, 0xf1, 0xc6, 0xce, 0x4b, 0x0b, 0xb0, 0xe3, 0x6c, 0x51,
+	0xc0, 0x41, 0x81, 0x04, 0x71, 0x4f, 0x0d, 0x7a, 0xa8, 0x5c, 0x1b, 0x86, 0x81, 0xa4, 0x87, 0x6d,
+	0x7a, 0x29, 0x50, 0xb8, 0x6b, 0x71, 0x60, 0x11, 0xe6, 0x57, 0x77, 0x97, 0x4e, 0x74, 0xef, 0xbd,
+	0xc7, 0xf6, 0xb7, 0xf4, 0xa7, 0xf5, 0x54, 0xec, 0x70, 0x49, 0x51, 0x94, 0x54, 0xb4, 0x37, 0x3e,
+	0x33, 0xbb, 0x33, 0xb3, 0xcf, 0x7c, 0x11, 0x7c, 0x99, 0x47, 0xaf, 0x72, 0x95, 0x99, 0x8c, 0x75,
+	0x65, 0x1e, 0xf1, 0xaf, 0xe1, 0xd1, 0x15, 0x9a, 0x1f, 0x34, 0x2a, 0x81, 0xbf, 0x14, 0xa8, 0x0d,
+	0x7b, 0x04, 0x5e, 0x14, 0x06, 0x9d, 0x93, 0xce, 0xa9, 0x2f, 0xbc, 0x28, 0x64, 0x13, 0x18, 0x15,
+	0x1a, 0x55, 0x2a, 0x13, 0x0c, 0x3c, 0x92, 0xd6, 0x98, 0xff, 0xd5, 0x81, 0x9e, 0xbd, 0xfb, 0x5f,
+	0x2e, 0x59, 0x5d, 0x2e, 0xb5, 0xfe, 0x98, 0xa9, 0x30, 0xe8, 0x96, 0xba, 0x0a, 0x57, 0xf7, 0xcc,
+	0x22, 0xc7, 0xa0, 0x77, 0xd2, 0x39, 0xed, 0x8b, 0x1a, 0x93, 0x8f, 0x3c, 0xe8, 0x3b, 0x1f, 0x39,
+	0x7b, 0x06, 0x03, 0x8d, 0xea, 0x01, 0x55, 0x30, 0xa0, 0x93, 0x0e, 0x31, 0x06, 0xbd, 0x99, 0xd4,
+	0xf3, 0x60, 0x78, 0xd2, 0x39, 0xed, 0x0a, 0xfa, 0xb6, 0xb2, 0x44, 0x46, 0x71, 0x30, 0xa2, 0xdb,
+	0xf4, 0xcd, 0x8e, 0x00, 0x66, 0x0a, 0xa5, 0xc1, 0xf0, 0x46, 0x9a, 0xc0, 0x27, 0x8d, 0xef, 0x24,
+	0x53, 0xc3, 0x9e, 0xc3, 0x38, 0x8c, 0xb4, 0xbc, 0x8d, 0x4b, 0x3d, 0x90, 0x1e, 0x2a, 0xd1, 0xd4,
+	0xf0, 0x9f, 0xe0, 0xb1, 0xc0, 0xbb, 0x48, 0x9b, 0x25, 0x77, 0xcd, 0x67, 0x77, 0x5a, 0xcf, 0xae,
+	0x42, 0xf0, 0x1a, 0x21, 0xfc, 0x03, 0x15, 0xfc, 0x2d, 0x3c, 0x59, 0x9a, 0xd7, 0x79, 0x96, 0x6a,
+	0xa2, 0x20, 0xbb, 0x27, 0xcb, 0x23, 0xe1, 0x65, 0xf7, 0x96, 0x02, 0x6b, 0xff, 0xfa, 0xc2, 0x59,
+	0x75, 0x88, 0x0f, 0xa1, 0x7f, 0x99, 0xe4, 0x66, 0xc1, 0x7f, 0x86, 0xc1, 0xf7, 0x35, 0x2b, 0x8d,
+	0xb0, 0xe8, 0x9b, 0x71, 0xd8, 0x35, 0x99, 0x91, 0x71, 0x1e, 0xcb, 0x05, 0x2a, 0x4d, 0x46, 0xfa,
+	0x62, 0x45, 0xc6, 0x8e, 0x01, 0x12, 0xf9, 0xa9, 0x3a, 0xd1, 0xa5, 0x13, 0x0d, 0x09, 0x7f, 0x0b,
+	0x7b, 0x57, 0x68, 0x4a, 0x27, 0x75, 0x9c, 0x9f, 0xc3, 0xb0, 0x4c, 0x86, 0x0e, 0x3a, 0x27, 0xdd,
+	0xd3, 0xf1, 0xd9, 0xf8, 0x95, 0xad, 0x3b, 0x77, 0xaa, 0xd2, 0xf1, 0x97, 0x74, 0xf7, 0x83, 0x7c,
+	0x40, 0x95, 0xd6, 0x77, 0xf7, 0xa1, 0x1f, 0x19, 0x4c, 0x34, 0x45, 0xba, 0x2b, 0x4a, 0xc0, 0xe7,
+	0x70, 0x78, 0x51, 0xa4, 0x77, 0x98, 0xa5, 0xef, 0x50, 0x86, 0xa8, 0x6e, 0x33, 0xa9, 0xc2, 0x8a,
+	0xf6, 0x23, 0x80, 0xb0, 0x54, 0xde, 0xb8, 0x2a, 0xec, 0x0b, 0xdf, 0x49, 0xae, 0x43, 0xcb, 0xd2,
+	0x47, 0xc4, 0xfb, 0x78, 0x41, 0x0f, 0x1c, 0x09, 0x87, 0xac, 0xa7, 0x38, 0x4a, 0x22, 0xe3, 0x5e,
+	0x55, 0x02, 0xfe, 0x5b, 0x07, 0x9e, 0x38, 0x57, 0xa2, 0x48, 0xdf, 0x63, 0x72, 0x8b, 0x8a, 0xbd,
+	0x80, 0xdd, 0xd9, 0x5c, 0x2a, 0x39, 0x33, 0xa8, 0x96, 0x3e, 0xc6, 0xb5, 0xec, 0x3a, 0xac, 0x09,
+	0xf6, 0x1a, 0x04, 0xef, 0x43, 0xff, 0x3e, 0x8a, 0xe3, 0x8a, 0xb7, 0x12, 0xd8, 0x78, 0x42, 0x94,
+	0x66, 0xae, 0x5d, 0x89, 0x3b, 0x44, 0x72, 0x99, 0xc8, 0x3b, 0xa4, 0x22, 0xef, 0x0a, 0x87, 0xf8,
+	0x9f, 0x1e, 0xc0, 0x32, 0xa2, 0x46, 0xaf, 0xf5, 0xa9, 0xd7, 0x56, 0x5f, 0xef, 0xb5, 0x5f, 0x1f,
+	0xc0, 0xd0, 0x01, 0x57, 0x62, 0x15, 0xb4, 0xfe, 0x14, 0xea, 0x22, 0x36, 0x14, 0x87, 0x2f, 0x1c,
+	0xb2, 0x06, 0xb5, 0x91, 0xca, 0x35, 0x46, 0xd9, 0x70, 0xbe, 0x93, 0x4c, 0x0d, 0x3b, 0x84, 0x11,
+	0xa6, 0x61, 0xa9, 0x1c, 0x94, 0x16, 0x09, 0x4f, 0xa9, 0xfe, 0xc3, 0x42, 0x49, 0x13, 0x65, 0x29,
+	0xb5, 0x5f, 0x5f, 0xd4, 0x78, 0xc9, 0xc5, 0x68, 0x33, 0x17, 0xfe, 0x16, 0x2e, 0xa0, 0xc9, 0x05,
+	0x7b, 0x0d, 0xc3, 0x84, 0x52, 0xa2, 0x83, 0x31, 0x55, 0xd6, 0x01, 0x55, 0x56, 0x3b, 0x61, 0xa2,
+	0x3a, 0xc5, 0xa7, 0x30, 0xd9, 0x54, 0x38, 0xae, 0xd8, 0x3e, 0x83, 0x9e, 0x2a, 0xd2, 0xaa, 0x4a,
+	0x1f, 0xb7, 0x6c, 0x09, 0x52, 0xf2, 0x05, 0xec, 0x7e, 0x3b, 0x97, 0xe6, 0x5d, 0x76, 0x77, 0x99,
+	0x1a, 0xb5, 0xb0, 0x8c, 0xce, 0xe6, 0x32, 0x4d, 0x31, 0x76, 0xdd, 0x54, 0xc1, 0x72, 0x24, 0xa5,
+	0x21, 0xaa, 0xaa, 0x1f, 0x4b, 0x64, 0x6f, 0x24, 0xa8, 0xb5, 0x7d, 0x8e, 0xcb, 0x81, 0x83, 0xad,
+	0x21, 0xd4, 0x6b, 0x0d, 0x21, 0xfe, 0xbb, 0x07, 0x60, 0x7d, 0x0b, 0xcc, 0x33, 0x65, 0xd6, 0x52,
+	0x3f, 0x81, 0x91, 0x22, 0x4d, 0xed, 0xb1, 0xc6, 0x36, 0x16, 0x23, 0xd5, 0x1d, 0x1a, 0xe7, 0xd2,
+	0xa1, 0x32, 0xeb, 0x52, 0x67, 0xe9, 0x32, 0xeb, 0x16, 0x51, 0xec, 0x46, 0x9a, 0x42, 0xbb, 0x8c,
+	0x3b, 0xd4, 0x8a, 0x70, 0xd0, 0x1e, 0x93, 0x47, 0x00, 0x73, 0x99, 0x86, 0x76, 0x4a, 0xde, 0x2e,
+	0x28, 0xe9, 0xbe, 0xf0, 0x9d, 0xe4, 0x7c, 0x61, 0xad, 0xca, 0x19, 0xd5, 0x43, 0x39, 0x7a, 0x1d,
+	0xa2, 0x6e, 0xc9, 0x0c, 0xba, 0xb1, 0x4b, 0xdf, 0xec, 0x0b, 0x18, 0xce, 0xb2, 0xd4, 0xe0, 0x27,
+	0x3b, 0x6d, 0x6d, 0x3e, 0xf6, 0x28, 0x1f, 0x4d, 0xee, 0x45, 0x75, 0x82, 0x9f, 0x03, 0x5b, 0x12,
+	0xa3, 0xab, 0x49, 0xb0, 0x7c, 0x44, 0x67, 0xe5, 0x11, 0x75, 0xab, 0x7b, 0xcd, 0x56, 0xff, 0x06,
+	0x9e, 0xae, 0xd8, 0x70, 0x45, 0xf1, 0x12, 0x86, 0x25, 0x8b, 0xab, 0x75, 0xb1, 0x3c, 0x2a, 0x2a,
+	0x3d, 0xff, 0xa3, 0x03, 0x81, 0x40, 0x9d, 0xc5, 0x0f, 0xd8, 0x50, 0xaf, 0x6d, 0xd2, 0xba, 0x51,
+	0x1b, 0x54, 0x79, 0xdb, 0xa9, 0xea, 0x6e, 0xa4, 0xaa, 0xd7, 0xa0, 0xea, 0x05, 0xec, 0x26, 0x85,
+	0xc1, 0x9b, 0x24, 0x4a, 0x0b, 0x83, 0x65, 0xca, 0xfa, 0x62, 0x6c, 0x65, 0xef, 0x4b, 0x11, 0xbf,
+	0x84, 0xc3, 0x0d, 0x91, 0x6d, 0x59, 0x24, 0x8d, 0x02, 0xf5, 0x56, 0x0a, 0xf4, 0xec, 0xd7, 0x1e,
+	0x74, 0xa7, 0x79, 0xc4, 0xde, 0xc0, 0xff, 0xdc, 0x8f, 0xc2, 0xf9, 0xe2, 0x3b, 0x3b, 0xdb, 0x9e,
+	0x12, 0x29, 0xab, 0x3f, 0x0f, 0x13, 0x9f, 0x84, 0x56, 0xc2, 0x77, 0xd8, 0x6b, 0x18, 0xd7, 0x57,
+	0xae, 0x2f, 0xfe, 0xc5, 0x85, 0xaf, 0x60, 0x54, 0xad, 0x3c, 0xb6, 0x4f, 0x8a, 0xd6, 0x82, 0x9d,
+	0x1c, 0xb4, 0xa4, 0xe5, 0x73, 0xf8, 0x0e, 0x3b, 0x03, 0xa8, 0xd7, 0x90, 0x66, 0x40, 0xc7, 0x68,
+	0x05, 0x4e, 0x9e, 0x55, 0x6e, 0x57, 0x77, 0x14, 0xdf, 0x61, 0x6f, 0xc0, 0xaf, 0xd7, 0xcf, 0xe6,
+	0x2b, 0xab, 0xab, 0x89, 0xef, 0xb0, 0x1f, 0xe1, 0xe0, 0x0a, 0xcd, 0xfa, 0x40, 0x61, 0xc7, 0xcd,
+	0xd1, 0xb1, 0xbe, 0xa2, 0x26, 0xcf, 0xb7, 0xea, 0x6b, 0xdb, 0x97, 0xf4, 0x2b, 0xd6, 0x28, 0x48,
+	0xf6, 0xff, 0x56, 0xdd, 0x55, 0x65, 0x3e, 0x09, 0xd6, 0x15, 0xb5, 0x99, 0x0f, 0xb0, 0xb7, 0x96,
+	0x77, 0x76, 0xe4, 0x78, 0xdb, 0x5c, 0xa9, 0x93, 0xe3, 0x6d, 0xea, 0xca, 0xea, 0xed, 0x80, 0xfe,
+	0x19, 0xbf, 0xfc, 0x7b, 0x00, 0x7c, 0x5e, 0xc0, 0x19, 0x40, 0x0a, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -509,6 +1216,9 @@ type ApiClient interface {
 	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
 	GetServers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetServerResponse, error)
 	GetTavern(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetTavernResponse, error)
+	GetDungeonLeaderboard(ctx context.Context, in *DungeonLeaderboardRequest, opts ...grpc.CallOption) (*DungeonLeaderboardResponse, error)
+	GetChatReports(ctx context.Context, in *ChatReportsRequest, opts ...grpc.CallOption) (*ChatReportsResponse, error)
+	ResolveChatReport(ctx context.Context, in *ResolveChatReportRequest, opts ...grpc.CallOption) (*ResolveChatReportResponse, error)
 }
 
 type apiClient struct {
@@ -564,6 +1274,33 @@ func (c *apiClient) GetTavern(ctx context.Context, in *Empty, opts ...grpc.CallO
 	return out, nil
 }
 
+func (c *apiClient) GetDungeonLeaderboard(ctx context.Context, in *DungeonLeaderboardRequest, opts ...grpc.CallOption) (*DungeonLeaderboardResponse, error) {
+	out := new(DungeonLeaderboardResponse)
+	err := c.cc.Invoke(ctx, "/api.Api/GetDungeonLeaderboard", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) GetChatReports(ctx context.Context, in *ChatReportsRequest, opts ...grpc.CallOption) (*ChatReportsResponse, error) {
+	out := new(ChatReportsResponse)
+	err := c.cc.Invoke(ctx, "/api.Api/GetChatReports", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) ResolveChatReport(ctx context.Context, in *ResolveChatReportRequest, opts ...grpc.CallOption) (*ResolveChatReportResponse, error) {
+	out := new(ResolveChatReportResponse)
+	err := c.cc.Invoke(ctx, "/api.Api/ResolveChatReport", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ApiServer is the server API for Api service.
 type ApiServer interface {
 	GetUserByName(context.Context, *GetUserRequest) (*User, error)
@@ -571,6 +1308,9 @@ type ApiServer interface {
 	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
 	GetServers(context.Context, *Empty) (*GetServerResponse, error)
 	GetTavern(context.Context, *Empty) (*GetTavernResponse, error)
+	GetDungeonLeaderboard(context.Context, *DungeonLeaderboardRequest) (*DungeonLeaderboardResponse, error)
+	GetChatReports(context.Context, *ChatReportsRequest) (*ChatReportsResponse, error)
+	ResolveChatReport(context.Context, *ResolveChatReportRequest) (*ResolveChatReportResponse, error)
 }
 
 func RegisterApiServer(s *grpc.Server, srv ApiServer) {
@@ -667,6 +1407,60 @@ func _Api_GetTavern_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Api_GetDungeonLeaderboard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DungeonLeaderboardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).GetDungeonLeaderboard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/GetDungeonLeaderboard",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).GetDungeonLeaderboard(ctx, req.(*DungeonLeaderboardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_GetChatReports_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChatReportsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).GetChatReports(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/GetChatReports",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).GetChatReports(ctx, req.(*ChatReportsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_ResolveChatReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResolveChatReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).ResolveChatReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/ResolveChatReport",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).ResolveChatReport(ctx, req.(*ResolveChatReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Api_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "api.Api",
 	HandlerType: (*ApiServer)(nil),
@@ -691,6 +1485,18 @@ var _Api_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetTavern",
 			Handler:    _Api_GetTavern_Handler,
 		},
+		{
+			MethodName: "GetDungeonLeaderboard",
+			Handler:    _Api_GetDungeonLeaderboard_Handler,
+		},
+		{
+			MethodName: "GetChatReports",
+			Handler:    _Api_GetChatReports_Handler,
+		},
+		{
+			MethodName: "ResolveChatReport",
+			Handler:    _Api_ResolveChatReport_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "api.proto",
@@ -165,3 +165,103 @@ func (s *ApiService) GetTavern(ctx context.Context, req *Empty) (*GetTavernRespo
 	resp := &GetTavernResponse{Items: data}
 	return resp, nil
 }
+
+func (s *ApiService) GetDungeonLeaderboard(ctx context.Context, req *DungeonLeaderboardRequest) (*DungeonLeaderboardResponse, error) {
+
+	resp := &DungeonLeaderboardResponse{Runs: []*DungeonRun{}}
+	dungeon := database.FindDungeon(int(req.DungeonId))
+	if dungeon == nil {
+		return resp, nil
+	}
+
+	since := time.Time{}
+	if req.Weekly {
+		since = database.WeekStart(time.Now())
+	}
+
+	runs, err := database.FastestClears(dungeon.ID, since, int(req.Limit))
+	if err != nil {
+		return resp, err
+	}
+
+	for _, r := range runs {
+		run := &DungeonRun{
+			Id:        int32(r.ID),
+			DungeonId: int32(r.DungeonID),
+			Dungeon:   dungeon.Name,
+			Result:    r.Result,
+			StartedAt: r.StartedAt.Time.String(),
+			EndedAt:   r.EndedAt.Time.String(),
+			Duration:  int32(r.Duration),
+			Kills:     int32(r.Kills),
+			Deaths:    int32(r.Deaths),
+			Damage:    r.Damage,
+		}
+
+		for _, m := range r.Members {
+			run.Members = append(run.Members, &DungeonRunMember{
+				CharacterId: int32(m.CharacterID),
+				Name:        m.Name,
+				Kills:       int32(m.Kills),
+				Deaths:      int32(m.Deaths),
+				Damage:      m.Damage,
+			})
+		}
+
+		resp.Runs = append(resp.Runs, run)
+	}
+
+	return resp, nil
+}
+
+func (s *ApiService) GetChatReports(ctx context.Context, req *ChatReportsRequest) (*ChatReportsResponse, error) {
+
+	resp := &ChatReportsResponse{Reports: []*ChatReport{}}
+
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = database.REPORT_LIST_LIMIT
+	}
+
+	reports, err := database.FindChatReports(req.Status, limit)
+	if err != nil {
+		return resp, err
+	}
+
+	for _, r := range reports {
+		report := &ChatReport{
+			Id:        int32(r.ID),
+			Reporter:  r.ReporterName,
+			Target:    r.TargetName,
+			Reason:    r.Reason,
+			Status:    r.Status,
+			CreatedAt: r.CreatedAt.Time.String(),
+			HandledBy: r.HandledBy.String,
+			Action:    r.Action.String,
+			Note:      r.Note.String,
+		}
+
+		for _, l := range r.Messages() {
+			report.Context = append(report.Context, &ChatLogEntry{
+				Channel:   l.Channel,
+				Sender:    l.SenderName,
+				Message:   l.Message,
+				CreatedAt: l.CreatedAt.Time.String(),
+			})
+		}
+
+		resp.Reports = append(resp.Reports, report)
+	}
+
+	return resp, nil
+}
+
+func (s *ApiService) ResolveChatReport(ctx context.Context, req *ResolveChatReportRequest) (*ResolveChatReportResponse, error) {
+
+	err := database.ResolveChatReport(int(req.Id), req.HandledBy, req.Action, req.Note, int(req.MuteMinutes))
+	if err != nil {
+		return &ResolveChatReportResponse{Ok: false, Message: err.Error()}, nil
+	}
+
+	return &ResolveChatReportResponse{Ok: true}, nil
+}
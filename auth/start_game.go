@@ -91,24 +91,20 @@ func (csh *StartGameHandler) startGame(s *database.Socket) ([]byte, error) {
 	if trade != nil {
 		trade.Delete()
 	}
-	if s.Character.IsDungeon || s.Character.Map == 229 || s.Character.Map == 120 {
-		s.Character.IsDungeon = false
-		s.Character.DungeonLevel = 1
+	if !database.RejoinInstance(s.Character) && (database.IsDungeonMap(s.Character.Map) || s.Character.Map == 229 || s.Character.Map == 120) {
 		gomap, _ := s.Character.ChangeMap(1, nil)
 		s.Conn.Write(gomap)
 	}
-	if s.Character.Map == 255 {
-		gomap, _ := s.Character.ChangeMap(1, nil)
-		s.Conn.Write(gomap)
-	}
-
-	if s.Character.Map == 230 {
+	if database.IsBattlegroundMap(s.Character.Map) {
 		gomap, _ := s.Character.ChangeMap(1, nil)
 		s.Conn.Write(gomap)
 	}
 	s.Character.HasLot = false
 	s.Character.IsOnline = true
-	s.Character.IsinWar = false
+	if err := database.SubscribeWhispers(s.Character); err != nil {
+		log.Println(err)
+	}
+	s.Character.BattlegroundID = 0
 	s.Character.Respawning = false
 	s.Character.SetInventorySlots(nil)
 	s.Character.OnSight.Drops = make(map[int]interface{})
@@ -116,19 +112,8 @@ func (csh *StartGameHandler) startGame(s *database.Socket) ([]byte, error) {
 	s.Character.OnSight.Mobs = make(map[int]interface{})
 	s.Character.OnSight.Pets = make(map[int]interface{})
 	s.Character.OnSight.Players = make(map[int]interface{})
-	s.Character.ExploreWorld = func() {
-		for {
-			if s.Character.ExploreWorld == nil {
-				break
-			} else if s.Character.IsActive {
-				exploreWorld(s)
-			}
-
-			time.Sleep(time.Second)
-		}
-	}
-
-	s.Character.HandlerCB = s.Character.Handler
+	s.Character.ExploreWorld = nil
+	s.Character.HandlerCB = nil
 	coordinate := database.ConvertPointToLocation(s.Character.Coordinate)
 	mapData, err := s.Character.ChangeMap(s.Character.Map, coordinate, true)
 	if err != nil {
@@ -236,18 +221,30 @@ func (csh *StartGameHandler) startGame(s *database.Socket) ([]byte, error) {
 		if err != nil {
 			return nil, err
 		} else if guild != nil {
+			guild.SetOnline(s.Character, true)
 			guild.InformMembers(s.Character)
 		}
 	}
 
-	time.AfterFunc(time.Second*1, func() {
-		if s.Character.ExploreWorld != nil {
-			go s.Character.ExploreWorld()
+	database.NotifyFriends(s.Character, true)
+	if msg := s.Character.OnlineFriendsMessage(); msg != nil {
+		s.Write(msg)
+	}
+	if msg := s.Character.UnreadMailMessage(); msg != nil {
+		s.Write(msg)
+	}
+
+	database.AfterTicks(s.User.ConnectedServer, time.Second, func() { // the world ticks the character from now on
+		if !s.Character.IsOnline {
+			return
 		}
 
-		if s.Character.HandlerCB != nil {
-			go s.Character.HandlerCB()
+		s.Character.ExploreWorld = func() {
+			if s.Character.IsActive {
+				exploreWorld(s)
+			}
 		}
+		s.Character.HandlerCB = s.Character.Handler
 	})
 
 	go s.Character.ActivityStatus(30)
@@ -356,12 +353,15 @@ func exploreMobs(s *database.Socket) {
 	}
 
 	for _, id := range ids {
-		mob := database.AIs[id]
-		if c.IsinWar {
-			isStone := Tester(database.WarStonesIDs, mob.PseudoID)
-			if isStone {
-				delete(c.OnSight.Mobs, id)
-			}
+		mob := database.FindAIByID(id)
+		if mob == nil { // removed since the ids were collected
+			continue
+		}
+		bg := database.FindBattleground(c.BattlegroundID)
+		if bg.Stone(mob.PseudoID) != nil {
+			c.OnSight.MobMutex.Lock()
+			delete(c.OnSight.Mobs, id)
+			c.OnSight.MobMutex.Unlock()
 		}
 		c.OnSight.MobMutex.RLock()
 		_, ok := c.OnSight.Mobs[id]
@@ -455,44 +455,21 @@ func exploreMobs(s *database.Socket) {
 			} else if err != nil && len(buffs) != 0 {
 				fmt.Println(fmt.Sprintf("LoadBuffsToMob: %s", err.Error()))
 			}
-			if c.IsinWar {
-				isStone := Tester(database.WarStonesIDs, mob.PseudoID)
-				if isStone {
-					if c.Faction == 1 {
-						if ok, _ := utils.Contains(database.WarStones[int(mob.PseudoID)].NearbyZuhangV, c.ID); !ok {
-							database.WarStones[int(mob.PseudoID)].NearbyZuhangV = append(database.WarStones[int(mob.PseudoID)].NearbyZuhangV, c.ID)
-						}
-					} else {
-						if ok, _ := utils.Contains(database.WarStones[int(mob.PseudoID)].NearbyShaoV, c.ID); !ok {
-							database.WarStones[int(mob.PseudoID)].NearbyShaoV = append(database.WarStones[int(mob.PseudoID)].NearbyShaoV, c.ID)
-						}
-					}
-					if c.Socket.Stats.HP <= 0 {
-						if c.Faction == 1 {
-							if ok, _ := utils.Contains(database.WarStones[int(mob.PseudoID)].NearbyZuhangV, c.ID); ok {
-								database.WarStones[int(mob.PseudoID)].RemoveZuhang(c.ID)
-							}
-						} else {
-							if ok, _ := utils.Contains(database.WarStones[int(mob.PseudoID)].NearbyShaoV, c.ID); ok {
-								database.WarStones[int(mob.PseudoID)].RemoveShao(c.ID)
-							}
-						}
-					}
-					resp := database.STONE_APPEARED
-					resp.Insert(utils.IntToBytes(uint64(mob.PseudoID), 2, true), 6) // mob pseudo id
-					resp.Insert(utils.IntToBytes(npcID, 4, true), 8)                // mob npc id
-					resp.Insert(utils.IntToBytes(uint64(npc.Level), 4, true), 12)   // mob level
-					resp.Insert(utils.IntToBytes(uint64(mob.HP), 8, true), 33)      // mob hp
-					resp.Insert(utils.IntToBytes(uint64(npc.MaxHp), 8, true), 41)   // mob max hp
-					resp.Insert(utils.FloatToBytes(coordinate.X, 4, true), 51)      // coordinate-x
-					resp.Insert(utils.FloatToBytes(coordinate.Y, 4, true), 55)      // coordinate-y
-					resp.Insert(utils.FloatToBytes(coordinate.X, 4, true), 63)      // coordinate-x
-					resp.Insert(utils.FloatToBytes(coordinate.Y, 4, true), 67)      // coordinate-y
-					resp.Overwrite(utils.IntToBytes(uint64(database.WarStones[int(mob.PseudoID)].ConquereValue), 1, false), 37)
-					resp.Overwrite([]byte{0xc8}, 45)
-					s.Conn.Write(resp)
-					continue
-				}
+			if value, ok := bg.StoneNear(c, mob.PseudoID); ok {
+				resp := database.STONE_APPEARED
+				resp.Insert(utils.IntToBytes(uint64(mob.PseudoID), 2, true), 6) // mob pseudo id
+				resp.Insert(utils.IntToBytes(npcID, 4, true), 8)                // mob npc id
+				resp.Insert(utils.IntToBytes(uint64(npc.Level), 4, true), 12)   // mob level
+				resp.Insert(utils.IntToBytes(uint64(mob.HP), 8, true), 33)      // mob hp
+				resp.Insert(utils.IntToBytes(uint64(npc.MaxHp), 8, true), 41)   // mob max hp
+				resp.Insert(utils.FloatToBytes(coordinate.X, 4, true), 51)      // coordinate-x
+				resp.Insert(utils.FloatToBytes(coordinate.Y, 4, true), 55)      // coordinate-y
+				resp.Insert(utils.FloatToBytes(coordinate.X, 4, true), 63)      // coordinate-x
+				resp.Insert(utils.FloatToBytes(coordinate.Y, 4, true), 67)      // coordinate-y
+				resp.Overwrite(utils.IntToBytes(uint64(value), 1, false), 37)
+				resp.Overwrite([]byte{0xc8}, 45)
+				s.Conn.Write(resp)
+				continue
 			}
 			s.Conn.Write(r)
 			//resp.Concat(r)
@@ -505,7 +482,13 @@ func exploreMobs(s *database.Socket) {
 	//losers = append(losers, utils.SliceDiff(ids, utils.Keys(c.OnSight.Mobs))...)
 
 	for _, id := range losers {
-		loser := database.AIs[id]
+		loser := database.FindAIByID(id)
+		if loser == nil { // removed mobs disappear with their register entry
+			c.OnSight.MobMutex.Lock()
+			delete(c.OnSight.Mobs, id)
+			c.OnSight.MobMutex.Unlock()
+			continue
+		}
 		coordinate := database.ConvertPointToLocation(loser.Coordinate)
 
 		r := MOB_DISAPPEARED
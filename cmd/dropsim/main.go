@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"hero-emulator/database"
+	"hero-emulator/dropsim"
+)
+
+func main() {
+
+	opts := &dropsim.Options{}
+	mapID := 0
+	flag.IntVar(&opts.NPCID, "npc", 0, "npc id to simulate")
+	flag.IntVar(&opts.Kills, "kills", 10000, "number of simulated kills")
+	flag.IntVar(&mapID, "map", 1, "map of the kills")
+	flag.Float64Var(&opts.DropRate, "rate", 0, "server drop rate, 0 uses the server default")
+	flag.Float64Var(&opts.DropMultiplier, "multiplier", 1, "claimer drop multiplier")
+	flag.BoolVar(&opts.Pickaxe, "pickaxe", false, "claimer has an activated pickaxe")
+	flag.BoolVar(&opts.Attackable, "attackable", true, "npc is an attackable mob")
+	flag.Float64Var(&opts.KillsPerHour, "kph", 600, "kills per hour for gold per hour")
+	flag.Int64Var(&opts.Seed, "seed", time.Now().UnixNano(), "random seed")
+	flag.Parse()
+
+	opts.Map = int16(mapID)
+	if opts.NPCID == 0 {
+		flag.Usage()
+		return
+	}
+
+	if err := database.InitDataDB(database.TABLE_ITEMS, database.TABLE_DROPS, database.TABLE_NPCS); err != nil {
+		log.Fatalln(err)
+	}
+
+	report, err := dropsim.Simulate(opts)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	for _, line := range report.Lines() {
+		fmt.Println(line)
+	}
+}
@@ -3,6 +3,10 @@ package config
 type config struct {
 	Database Database
 	Server   Server
+	Party    Party
+	World    World
+	Chat     Chat
+	Nats     Nats
 }
 
 type Database struct {
@@ -23,3 +27,40 @@ type Server struct {
 	IP   string
 	Port int
 }
+
+type Party struct {
+	ExpLevelWeight   float64   // 0 splits party exp evenly, 1 splits it by member level
+	ExpSizeBonus     []float64 // exp bonus by number of sharing members
+	ExpLevelGap      int
+	ExpShareRange    float64
+	NeedGreedMinPlus byte // drops with at least this upgrade are rolled in need/greed mode
+	LootRollSeconds  int
+}
+
+type World struct {
+	TickMillis   int // interval of the world tick
+	BudgetMillis int // ticks running longer are logged as overruns
+}
+
+type Chat struct {
+	RateLimits    map[string]ChatRate // by channel, a channel without a limit is not limited
+	SpamStrikes   int                 // messages over the rate limits within SpamWindow seconds which mute the sender
+	SpamWindow    int
+	SpamMutes     []int // minutes of the automatic mutes, every further mute within SpamMuteReset hours takes the next one
+	SpamMuteReset int
+
+	LogRetentionDays     int // chat logs are deleted after this many days, reports keep their context
+	ReportContextMinutes int // messages of the last minutes attached to a report
+	ReportContextLimit   int
+	ReportsPerHour       int
+}
+
+type ChatRate struct {
+	Messages int
+	Seconds  int
+}
+
+type Nats struct {
+	URL       string // server shared by the game processes, an embedded server is run when it is empty
+	AckMillis int    // whispers wait this long for the process of the receiver
+}
@@ -23,6 +23,41 @@ var Default = &config{
 		IP:   "127.0.0.1",
 		Port: 5310,
 	},
+	Party: Party{
+		ExpLevelWeight:   0,
+		ExpLevelGap:      20,
+		ExpShareRange:    100,
+		NeedGreedMinPlus: 3,
+		LootRollSeconds:  15,
+	},
+	World: World{
+		TickMillis:   1000,
+		BudgetMillis: 200,
+	},
+	Chat: Chat{
+		RateLimits: map[string]ChatRate{
+			"normal":  {Messages: 5, Seconds: 5},
+			"whisper": {Messages: 5, Seconds: 5},
+			"party":   {Messages: 8, Seconds: 5},
+			"guild":   {Messages: 8, Seconds: 5},
+			"faction": {Messages: 3, Seconds: 10},
+			"roar":    {Messages: 1, Seconds: 10},
+			"shout":   {Messages: 1, Seconds: 10},
+		},
+		SpamStrikes:   5,
+		SpamWindow:    30,
+		SpamMutes:     []int{5, 30, 120, 1440},
+		SpamMuteReset: 24,
+
+		LogRetentionDays:     30,
+		ReportContextMinutes: 15,
+		ReportContextLimit:   50,
+		ReportsPerHour:       5,
+	},
+	Nats: Nats{
+		URL:       "",
+		AckMillis: 1000,
+	},
 }
 
 func getPort() int {
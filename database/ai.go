@@ -3,7 +3,6 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"math"
 	"sort"
 	"sync"
@@ -44,14 +43,26 @@ type AI struct {
 	TargetPetID    int                 `db:"-" json:"target_pet"`
 	Handler        func()              `db:"-" json:"-"`
 	Once           bool                `db:"-"`
+	State          AIState             `db:"-" json:"state"`
+	Encounter      *EncounterRun       `db:"-" json:"-"`
+	Threat         ThreatTable         `db:"-" json:"-"`
+	InstanceID     int                 `db:"-" json:"instance_id"`
+	threatTarget   int                 `db:"-"`
+	fled           bool                `db:"-"`
+	waypoints      []utils.Location    `db:"-"`
 }
 
+const (
+	SPAWNED_AI_ID_BASE = 10000000 // mobs spawned at runtime are removed from AIs again, their ids never reuse the ids of the stored mobs
+)
+
 var (
-	AIs             = make(map[int]*AI)
-	DungeonsAiByMap []map[int16][]*AI
-	AIsByMap        []map[int16][]*AI
-	DungeonsByMap   []map[int16]int
-	eventBosses     = []int{50009, 50010}
+	AIs          = make(map[int]*AI)
+	AIsByMap     []map[int16][]*AI
+	aiMutex      sync.RWMutex // guards AIs and AIsByMap against the mobs spawned and removed at runtime
+	spawnedAISeq = SPAWNED_AI_ID_BASE
+	storedAISeq  = 0 // the id after the highest id of the stored mobs
+	eventBosses  = []int{50009, 50010}
 
 	MOB_MOVEMENT    = utils.Packet{0xAA, 0x55, 0x21, 0x00, 0x33, 0x00, 0xBC, 0xDB, 0x9F, 0x41, 0x52, 0x70, 0xA2, 0x41, 0x00, 0x55, 0xAA}
 	MOB_ATTACK      = utils.Packet{0xAA, 0x55, 0x0C, 0x00, 0x41, 0x01, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x55, 0xAA}
@@ -81,119 +92,153 @@ var (
 )
 
 func FindAIByID(ID int) *AI {
+	aiMutex.RLock()
+	defer aiMutex.RUnlock()
 	return AIs[ID]
 }
 
-func (ai *AI) SetCoordinate(coordinate *utils.Location) {
-	ai.Coordinate = fmt.Sprintf("(%.1f,%.1f)", coordinate.X, coordinate.Y)
+// MapAIs returns the mobs of the map on the server, the slice is never changed in place.
+func MapAIs(server int, mapID int16) []*AI {
+	aiMutex.RLock()
+	defer aiMutex.RUnlock()
+	if server < 0 || server >= len(AIsByMap) {
+		return nil
+	}
+	return AIsByMap[server][mapID]
 }
 
-func (ai *AI) Create() error {
-	return db.Insert(ai)
+// AllAIs returns the registered mobs.
+func AllAIs() []*AI {
+	aiMutex.RLock()
+	defer aiMutex.RUnlock()
+
+	all := make([]*AI, 0, len(AIs))
+	for _, ai := range AIs {
+		all = append(all, ai)
+	}
+	return all
 }
 
-func GetAllAI() error {
-	var arr []*AI
-	query := `select * from hops.ai order by id`
+// RegisterAI adds a stored mob to AIs and the mobs of its map, a mob without an id gets the id after the
+// stored mobs.
+func RegisterAI(ai *AI) {
+	aiMutex.Lock()
+	defer aiMutex.Unlock()
+	registerAI(ai)
+}
 
-	if _, err := db.Select(&arr, query); err != nil {
-		if err == sql.ErrNoRows {
-			return nil
-		}
-		return fmt.Errorf("GetAllAI: %s", err.Error())
+func registerAI(ai *AI) {
+	if ai.ID == 0 {
+		ai.ID = storedAISeq
 	}
-
-	for _, a := range arr {
-		AIs[a.ID] = a
+	if ai.ID >= storedAISeq && ai.ID < SPAWNED_AI_ID_BASE {
+		storedAISeq = ai.ID + 1
 	}
 
-	return nil
+	AIs[ai.ID] = ai
+	if ai.Server >= 0 && ai.Server < len(AIsByMap) {
+		AIsByMap[ai.Server][ai.Map] = append(AIsByMap[ai.Server][ai.Map], ai)
+	}
 }
-func getAllDungeon(mob int) error {
-	return nil
+
+// SpawnAI registers a mob spawned at runtime which is not stored, see addAI.
+func SpawnAI(ai *AI) {
+	addAI(ai)
 }
 
-func (ai *AI) FindTargetCharacterID() (int, error) {
+// addAI registers a mob spawned at runtime in its instance or in the mobs of its map.
+func addAI(ai *AI) {
 
-	var (
-		distance = 15.0
-	)
+	inst := FindInstance(ai.InstanceID)
 
-	if len(characters) == 0 {
-		return 0, nil
+	aiMutex.Lock()
+	spawnedAISeq++
+	ai.ID = spawnedAISeq
+	AIs[ai.ID] = ai
+	if inst == nil {
+		AIsByMap[ai.Server][ai.Map] = append(AIsByMap[ai.Server][ai.Map], ai)
 	}
+	aiMutex.Unlock()
 
-	npcPos := NPCPos[ai.PosID]
-	minCoordinate := ConvertPointToLocation(npcPos.MinLocation)
-	maxCoordinate := ConvertPointToLocation(npcPos.MaxLocation)
-	aiCoordinate := ConvertPointToLocation(ai.Coordinate)
-
-	characterMutex.RLock()
-	allChars := funk.Values(characters)
-	characterMutex.RUnlock()
-	filtered := funk.Filter(allChars, func(c *Character) bool {
-
-		if c.Socket == nil || !c.IsOnline {
-			return false
-		}
-
-		user := c.Socket.User
-		stat := c.Socket.Stats
+	if inst != nil {
+		inst.mutex.Lock()
+		inst.Mobs = append(inst.Mobs, ai)
+		inst.mutex.Unlock()
+	}
 
-		if user == nil || stat == nil {
-			return false
-		}
+	GenerateIDForAI(ai)
+}
 
-		characterCoordinate := ConvertPointToLocation(c.Coordinate)
+// removeAI takes a mob spawned at runtime out of the registers and releases its position.
+func removeAI(ai *AI) {
 
-		seed := utils.RandInt(0, 1000)
+	ai.HP = 0
+	ai.IsDead = true
+	ai.Handler = nil
+	ai.Encounter = nil
+	ai.Threat.Clear()
+	ai.stopMoving()
+	if RemoveAIFromRegister != nil {
+		RemoveAIFromRegister(ai)
+	}
 
-		return user.ConnectedServer == ai.Server && c.Map == ai.Map && stat.HP > 0 && !c.Invisible &&
-			characterCoordinate.X >= minCoordinate.X && characterCoordinate.X <= maxCoordinate.X &&
-			characterCoordinate.Y >= minCoordinate.Y && characterCoordinate.Y <= maxCoordinate.Y &&
-			utils.CalculateDistance(characterCoordinate, aiCoordinate) <= distance && seed < 500
-	})
+	if inst := FindInstance(ai.InstanceID); inst != nil {
+		inst.mutex.Lock()
+		inst.Mobs = withoutAI(inst.Mobs, ai)
+		inst.mutex.Unlock()
+	}
 
-	filtered = funk.Shuffle(filtered)
-	characters := filtered.([]*Character)
+	aiMutex.Lock()
+	delete(AIs, ai.ID)
+	if ai.Server < len(AIsByMap) {
+		AIsByMap[ai.Server][ai.Map] = withoutAI(AIsByMap[ai.Server][ai.Map], ai)
+	}
+	aiMutex.Unlock()
 
-	npc := npcPos.NPCID
-	if len(characters) > 0 {
+	ReleaseNPCPos(ai.PosID)
+}
 
-		for _, factionNPC := range ZhuangFactionMobs {
-			if factionNPC == npc && characters[0].Faction == 1 {
-				return 0, nil
-			}
-		}
-		for _, factionNPC := range ShaoFactionMobs {
-			if factionNPC == npc && characters[0].Faction == 2 {
-				return 0, nil
-			}
+// withoutAI returns a copy of the mobs without the mob, the callers may still iterate the old slice.
+func withoutAI(mobs []*AI, ai *AI) []*AI {
+	rest := make([]*AI, 0, len(mobs))
+	for _, m := range mobs {
+		if m != ai {
+			rest = append(rest, m)
 		}
-		return characters[0].ID, nil
 	}
+	return rest
+}
 
-	return 0, nil
+func (ai *AI) SetCoordinate(coordinate *utils.Location) {
+	ai.Coordinate = fmt.Sprintf("(%.1f,%.1f)", coordinate.X, coordinate.Y)
 }
 
-func (ai *AI) FindTargetPetID(characterID int) (*InventorySlot, error) {
+func (ai *AI) Create() error {
+	return db.Insert(ai)
+}
 
-	enemy, err := FindCharacterByID(characterID)
-	if err != nil || enemy == nil {
-		return nil, err
-	}
+// GetAllAI registers the stored mobs, AIsByMap must be made before.
+func GetAllAI() error {
+	var arr []*AI
+	query := `select * from hops.ai order by id`
 
-	slots, err := enemy.InventorySlots()
-	if err != nil {
-		return nil, err
+	if _, err := db.Select(&arr, query); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("GetAllAI: %s", err.Error())
 	}
 
-	pet := slots[0x0A].Pet
-	if pet == nil || !pet.IsOnline {
-		return nil, nil
+	aiMutex.Lock()
+	for _, a := range arr {
+		registerAI(a)
 	}
+	aiMutex.Unlock()
 
-	return slots[0x0A], nil
+	return nil
+}
+func getAllDungeon(mob int) error {
+	return nil
 }
 
 func (ai *AI) Move(targetLocation utils.Location, runningMode byte) []byte {
@@ -244,7 +289,7 @@ func (ai *AI) Attack() []byte {
 
 	stat := character.Socket.Stats
 
-	rawDamage := int(utils.RandInt(int64(npc.MinATK), int64(npc.MaxATK)))
+	rawDamage := int(float64(utils.RandInt(int64(npc.MinATK), int64(npc.MaxATK))) * ai.DamageMultiplier())
 	damage := int(math.Max(float64(rawDamage-stat.DEF), 3))
 
 	reqAcc := float64(stat.Dodge) + float64(character.Level-int(npc.Level))*10
@@ -295,7 +340,7 @@ func (ai *AI) CastSkill() []byte {
 
 	stat := character.Socket.Stats
 
-	rawDamage := int(utils.RandInt(int64(npc.MinArtsATK), int64(npc.MaxArtsATK)))
+	rawDamage := int(float64(utils.RandInt(int64(npc.MinArtsATK), int64(npc.MaxArtsATK))) * ai.DamageMultiplier())
 	damage := int(math.Max(float64(rawDamage-stat.ArtsDEF), 3))
 
 	reqAcc := float64(stat.Dodge) + float64(character.Level-int(npc.Level))*10
@@ -303,22 +348,29 @@ func (ai *AI) CastSkill() []byte {
 		damage = 0
 	}
 
+	resp := ai.skillPacket(character, npc.SkillID)
+
+	//time.AfterFunc(time.Second, func() {
+	resp.Concat(ai.DealDamage(damage))
+	//p := nats.CastPacket{CastNear: true, MobID: ai.ID, Data: r, Type: nats.MOB_ATTACK}
+	//p.Cast()
+	//})
+
+	return resp
+}
+
+func (ai *AI) skillPacket(character *Character, skillID int) utils.Packet {
+
 	mC := ConvertPointToLocation(ai.Coordinate)
 
 	resp := MOB_SKILL
 	resp.Insert(utils.IntToBytes(uint64(ai.PseudoID), 2, true), 7)         // mob pseudo id
-	resp.Insert(utils.IntToBytes(uint64(npc.SkillID), 4, true), 9)         // pet skill id
+	resp.Insert(utils.IntToBytes(uint64(skillID), 4, true), 9)             // pet skill id
 	resp.Insert(utils.FloatToBytes(mC.X, 4, true), 13)                     // pet-x
 	resp.Insert(utils.FloatToBytes(mC.Y, 4, true), 17)                     // pet-x
 	resp.Insert(utils.IntToBytes(uint64(character.PseudoID), 2, true), 25) // target pseudo id
 	resp.Insert(utils.IntToBytes(uint64(character.PseudoID), 2, true), 28) // target pseudo id
 
-	//time.AfterFunc(time.Second, func() {
-	resp.Concat(ai.DealDamage(damage))
-	//p := nats.CastPacket{CastNear: true, MobID: ai.ID, Data: r, Type: nats.MOB_ATTACK}
-	//p.Cast()
-	//})
-
 	return resp
 }
 
@@ -354,7 +406,7 @@ func (ai *AI) AttackPet() []byte {
 		pet.Target = int(ai.PseudoID)
 	}
 
-	rawDamage := int(utils.RandInt(int64(npc.MinATK), int64(npc.MaxATK)))
+	rawDamage := int(float64(utils.RandInt(int64(npc.MinATK), int64(npc.MaxATK))) * ai.DamageMultiplier())
 	damage := int(math.Max(float64(rawDamage-pet.DEF), 3))
 
 	reqAcc := float64(int(pet.Level)-int(npc.Level)) * 10
@@ -432,18 +484,29 @@ func (ai *AI) CastSkillToPet() []byte {
 
 func (ai *AI) DealDamage(damage int) []byte {
 
-	resp := MOB_DEAL_DAMAGE
 	character, err := FindCharacterByID(ai.TargetPlayerID)
 	if err != nil || character == nil {
 		return nil
 	}
 
+	return ai.dealDamageTo(character, damage)
+}
+
+func (ai *AI) dealDamageTo(character *Character, damage int) []byte {
+
+	resp := MOB_DEAL_DAMAGE
 	stat := character.Socket.Stats
 
+	alive := stat.HP > 0
 	stat.HP = int(math.Max(float64(stat.HP-damage), 0)) // deal damage
-	if stat.HP <= 0 {
+	if stat.HP <= 0 && ai.TargetPlayerID == character.ID {
 		ai.TargetPlayerID = 0
 	}
+	if alive && stat.HP <= 0 {
+		if inst := FindInstance(ai.InstanceID); inst != nil {
+			inst.recordDeath(character)
+		}
+	}
 
 	resp.Insert(utils.IntToBytes(uint64(character.PseudoID), 2, true), 5) // character pseudo id
 	resp.Insert(utils.IntToBytes(uint64(ai.PseudoID), 2, true), 7)        // mob pseudo id
@@ -483,6 +546,13 @@ func (ai *AI) MovementHandler(token int64, start, end *utils.Location, speed flo
 
 	if diff < 1 {
 		ai.SetCoordinate(end)
+		if token == ai.MovementToken && len(ai.waypoints) > 0 { // walk to the next waypoint of the path
+			next := ai.waypoints[0]
+			ai.waypoints = ai.waypoints[1:]
+			ai.MovementHandler(token, end, &next, speed)
+			return
+		}
+
 		ai.MovementToken = 0
 		ai.IsMoving = false
 		return
@@ -551,107 +621,12 @@ func (ai *AI) DropHandler(claimer *Character) {
 	}
 
 	isEventBoss := true
-	bossMultiplier, dropCount, count, minCount := 0.0, 0, 0, 0
+	dropCount := 0
 	baseLocation := ConvertPointToLocation(ai.Coordinate)
-	if funk.Contains(bosses, npc.ID) {
-		bossMultiplier = 2.0
-		minCount = 12
-
-	} else if funk.Contains(eventBosses, npc.ID) {
-		bossMultiplier = 7.0
-		minCount = 48
-		isEventBoss = true
 
-	} else if !npcPos.Attackable && claimer.PickaxeActivated() {
-		bossMultiplier = 0.4
-	}
-	if ai.Map == 27 {
-		bossMultiplier = 0.1
-	}
-
-BEGIN:
-	id := npc.DropID
-
-	drop, ok := Drops[id]
-	if !ok || drop == nil {
-		return
-	}
-
-	itemID := 0
-	end := false
-	for ok {
-		index := 0
-		seed := int(utils.RandInt(0, 1000))
-		items := drop.GetItems()
-
-		probabilities := drop.GetProbabilities()
-		totalDropRate := (DROP_RATE * (claimer.DropMultiplier + claimer.AdditionalDropMultiplier)) + bossMultiplier
-		if ai.Map == 27 {
-			totalDropRate = (1 * (claimer.DropMultiplier + claimer.AdditionalDropMultiplier)) + bossMultiplier
-		} else {
-			totalDropRate = (DROP_RATE * (claimer.DropMultiplier + claimer.AdditionalDropMultiplier)) + bossMultiplier
-		}
-		dropFailRate := float64(1000 - probabilities[len(probabilities)-1])
-		dropFailRate /= totalDropRate
-		newDropFailRate := 1000 - dropFailRate
-		probMultiplier := float64(probabilities[len(probabilities)-1]) / newDropFailRate
-
-		if float64(probabilities[len(probabilities)-1])*totalDropRate < 900 {
-			probMultiplier = 1
-			probabilities = funk.Map(probabilities, func(prob int) int {
-				return int(float64(prob) * totalDropRate)
-			}).([]int)
-		}
-
-		/*
-			for _, prob := range probabilities {
-				if float64(seed)*probMultiplier > float64(prob) {
-					index++
-					continue
-				}
-				break
-			}
-		*/
-		/*
-			probabilities = funk.Map(probabilities, func(prob int) int {
-				return int(float64(prob) / probMultiplier)
-			}).([]int)
-		*/
-
-		seed = int(float64(seed) * probMultiplier)
-		index = sort.SearchInts(probabilities, seed)
-		if index >= len(items) {
-			if count >= minCount {
-				end = true
-				break
-			} else {
-				drop = Drops[id]
-				continue
-			}
-		}
-
-		itemID = items[index]
-		item, exist := Items[int64(itemID)]
-
-		if exist {
-			itemType := item.GetType()
-			if itemType == QUEST_TYPE || itemType == INGREDIENTS_TYPE {
-				drop = Drops[id]
-				continue
-			}
-		}
-
-		drop, ok = Drops[itemID]
-
-		if !ok {
-		}
-	}
-
-	if itemID > 0 && !end { // can drop an item
-		count++
-		if count >= 100 {
-			return
-		}
+	roll := RollDrops(npc, NewDropModifiers(ai, claimer))
+	for _, entry := range roll.Items {
+		itemID, quantity := entry.ItemID, entry.RollQuantity()
 		go func() {
 			resp := utils.Packet{}
 			isRelic := false
@@ -676,7 +651,7 @@ BEGIN:
 				drop := NewSlot()
 				drop.ItemID = item.ID
 				drop.ItemType = 1
-				drop.Quantity = 1
+				drop.Quantity = quantity
 				drop.Plus = plus
 				if item.Timer > 0 {
 					drop.Quantity = uint(item.Timer)
@@ -735,10 +710,14 @@ BEGIN:
 					offset := dropOffsets[dropCount%len(dropOffsets)]
 					dropCount++
 
-					dr := &Drop{Server: ai.Server, Map: ai.Map, Claimer: claimer, Item: drop,
+					dr := &Drop{Server: ai.Server, Map: ai.Map, InstanceID: ai.InstanceID, Claimer: claimer, Item: drop,
 						Location: utils.Location{X: baseLocation.X + offset.X, Y: baseLocation.Y + offset.Y}}
 
-					if isEventBoss {
+					if party := FindParty(claimer); party != nil && partyOf(claimer) != "" {
+						if !party.DistributeDrop(ai, dr) {
+							return
+						}
+					} else if isEventBoss {
 						dr.Claimer = nil
 					}
 					time.AfterFunc(FREEDROP_LIFETIME, func() { //ALL PLAYER CAN PICKUP THE ITEMS
@@ -786,16 +765,6 @@ BEGIN:
 			}
 		}()
 	}
-
-	if !npcPos.Attackable {
-		end = true
-	}
-
-	if !end {
-		goto BEGIN
-	}
-
-	return
 }
 
 func (ai *AI) RemoveDrop(server int, mapID int16, dropID uint16) {
@@ -812,11 +781,7 @@ func (ai *AI) RemoveDrop(server int, mapID int16, dropID uint16) {
 
 func (ai *AI) AIHandler() {
 
-	if len(ai.OnSightPlayers) > 0 && ai.HP > 0 {
-
-		timer := fmt.Sprintf("%s", time.Now().String())
-		npcPos := NPCPos[ai.PosID]
-		npc := NPCs[npcPos.NPCID]
+	if ai.HP > 0 && (len(ai.OnSightPlayers) > 0 || ai.State != AI_STATE_IDLE) {
 
 		ai.PlayersMutex.RLock()
 		ids := funk.Keys(ai.OnSightPlayers).([]int)
@@ -844,189 +809,8 @@ func (ai *AI) AIHandler() {
 			}
 		}
 
-		if ai.TargetPetID > 0 {
-			pet, ok := GetFromRegister(ai.Server, ai.Map, uint16(ai.TargetPetID)).(*PetSlot)
-			if !ok || pet == nil || !pet.IsOnline || pet.HP <= 0 {
-				ai.TargetPetID = 0
-			}
-		}
-
-		if ai.TargetPlayerID > 0 {
-			c, err := FindCharacterByID(ai.TargetPlayerID)
-			if err != nil || c == nil || !c.IsOnline || c.Socket == nil || c.Socket.Stats.HP <= 0 {
-				ai.TargetPlayerID = 0
-				//ai.HP = npc.MaxHp
-			} else {
-				slots, _ := c.InventorySlots()
-				petSlot := slots[0x0A]
-				pet := petSlot.Pet
-				petInfo, ok := Pets[petSlot.ItemID]
-				if pet != nil && ok && pet.IsOnline && !petInfo.Combat {
-					ai.TargetPlayerID = 0
-					ai.TargetPetID = petSlot.Pet.PseudoID
-				}
-			}
-		}
-
-		var err error
-		if ai.TargetPetID == 0 && ai.TargetPlayerID == 0 { // gotta find a target
-
-			ai.TargetPlayerID, err = ai.FindTargetCharacterID() // 50% chance to trigger
-			if err != nil {
-				log.Println("AIHandler FindTargetPlayer error:", err)
-			}
-
-			petSlot, err := ai.FindTargetPetID(ai.TargetPlayerID)
-			if err != nil {
-				log.Println("AIHandler FindTargetPet error:", err)
-			}
-
-			if petSlot != nil {
-				pet := petSlot.Pet
-				petInfo, ok := Pets[petSlot.ItemID]
-
-				seed := utils.RandInt(0, 1000)
-				if pet != nil && ok && (seed > 500 || !petInfo.Combat) {
-					ai.TargetPlayerID = 0
-					ai.TargetPetID = pet.PseudoID
-				}
-			}
-		}
-
-		if ai.TargetPlayerID > 0 || ai.TargetPetID > 0 {
-			ai.IsMoving = false
-		}
-
-		if ai.IsMoving {
-			goto OUT
-		}
-
-		if ai.TargetPlayerID == 0 && ai.TargetPetID == 0 { // Idle mode
-			coordinate := ConvertPointToLocation(ai.Coordinate)
-			minCoordinate := ConvertPointToLocation(npcPos.MinLocation)
-			maxCoordinate := ConvertPointToLocation(npcPos.MaxLocation)
-
-			if utils.RandInt(0, 1000) < 750 { // 75% chance to move
-				ai.IsMoving = true
-
-				targetX := utils.RandFloat(minCoordinate.X, maxCoordinate.X)
-				targetY := utils.RandFloat(minCoordinate.Y, maxCoordinate.Y)
-				target := utils.Location{X: targetX, Y: targetY}
-				ai.TargetLocation = target
-
-				//d := ai.Move(target, 1)
-				//p := nats.CastPacket{CastNear: true, MobID: ai.ID, Data: d, Type: nats.MOB_MOVEMENT}
-				//p.Cast()
-
-				token := ai.MovementToken
-				for token == ai.MovementToken {
-					ai.MovementToken = utils.RandInt(1, math.MaxInt64)
-				}
-
-				go ai.MovementHandler(ai.MovementToken, coordinate, &target, ai.WalkingSpeed)
-
-			}
-
-		} else if ai.TargetPetID > 0 {
-			pet, ok := GetFromRegister(ai.Server, ai.Map, uint16(ai.TargetPetID)).(*PetSlot)
-			if !ok || pet == nil || !pet.IsOnline || pet.HP <= 0 {
-				ai.TargetPetID = 0
-				goto OUT
-			}
-
-			aiCoordinate := ConvertPointToLocation(ai.Coordinate)
-			distance := utils.CalculateDistance(&pet.Coordinate, aiCoordinate)
-
-			if ai.ShouldGoBack() || distance > 50 { // better to retreat
-				ai.TargetPlayerID = 0
-				ai.MovementToken = 0
-				ai.IsMoving = false
-				//ai.HP = npc.MaxHp
-			} else if distance <= 3 && pet.IsOnline && pet.HP > 0 { // attack
-				seed := utils.RandInt(1, 1000)
-				_, ok := SkillInfos[npc.SkillID]
-
-				r := utils.Packet{}
-				if seed < 400 && ok {
-					r.Concat(ai.CastSkillToPet())
-				} else {
-					r.Concat(ai.AttackPet())
-				}
-
-				p := nats.CastPacket{CastNear: true, MobID: ai.ID, Data: r, Type: nats.MOB_ATTACK}
-				p.Cast()
-
-			} else if distance > 3 && distance <= 50 { // chase
-				ai.IsMoving = true
-				target := GeneratePoint(&pet.Coordinate)
-				ai.TargetLocation = target
-
-				token := ai.MovementToken
-				for token == ai.MovementToken {
-					ai.MovementToken = utils.RandInt(1, math.MaxInt64)
-				}
-
-				go ai.MovementHandler(ai.MovementToken, aiCoordinate, &target, ai.RunningSpeed)
-
-			}
-
-		} else if ai.TargetPlayerID > 0 { // Target mode player
-			character, err := FindCharacterByID(ai.TargetPlayerID)
-			if err != nil || character == nil || (character != nil && (!character.IsOnline || character.Invisible)) {
-				//ai.HP = npc.MaxHp
-				ai.TargetPlayerID = 0
-				goto OUT
-			}
-
-			stat := character.Socket.Stats
-
-			characterCoordinate := ConvertPointToLocation(character.Coordinate)
-			aiCoordinate := ConvertPointToLocation(ai.Coordinate)
-			distance := utils.CalculateDistance(characterCoordinate, aiCoordinate)
-
-			if ai.ShouldGoBack() || distance > 50 { // better to retreat
-				ai.TargetPlayerID = 0
-				ai.MovementToken = 0
-				ai.IsMoving = false
-				//ai.HP = npc.MaxHp
-			} else if distance <= 5 && character.IsActive && stat.HP > 0 { // attack
-				seed := utils.RandInt(1, 1000)
-				_, ok := SkillInfos[npc.SkillID]
-
-				r := utils.Packet{}
-				if seed < 400 && ok {
-					r.Concat(ai.CastSkill())
-				} else {
-					r.Concat(ai.Attack())
-				}
-
-				p := nats.CastPacket{CastNear: true, MobID: ai.ID, Data: r, Type: nats.MOB_ATTACK}
-				p.Cast()
-
-			} else if distance > 5 && distance <= 50 { // chase
-				ai.IsMoving = true
-				target := GeneratePoint(characterCoordinate)
-				ai.TargetLocation = target
-
-				token := ai.MovementToken
-				for token == ai.MovementToken {
-					ai.MovementToken = utils.RandInt(1, math.MaxInt64)
-				}
-
-				go ai.MovementHandler(ai.MovementToken, aiCoordinate, &target, ai.RunningSpeed)
-
-			}
-		}
-
-		timer = fmt.Sprintf("%s -> %s", timer, time.Now().String())
-		//log.Println(timer)
+		ai.think()
 	}
-
-OUT:
-	delay := utils.RandFloat(1.0, 1.5) * 1000
-	time.AfterFunc(time.Duration(delay)*time.Millisecond, func() {
-		ai.AIHandler()
-	})
 }
 
 func (ai *AI) ShouldGoBack() bool {
@@ -1044,12 +828,20 @@ func (ai *AI) ShouldGoBack() bool {
 	return true
 }
 
-func GeneratePoint(location *utils.Location) utils.Location {
+func GeneratePoint(mapID int16, location *utils.Location) utils.Location {
 
 	r := 2.0
-	alfa := utils.RandFloat(0, 360)
-	targetX := location.X + r*float64(math.Cos(alfa*math.Pi/180))
-	targetY := location.Y + r*float64(math.Sin(alfa*math.Pi/180))
+	point := *location
+	for i := 0; i < 8; i++ {
+		alfa := utils.RandFloat(0, 360)
+		targetX := location.X + r*float64(math.Cos(alfa*math.Pi/180))
+		targetY := location.Y + r*float64(math.Sin(alfa*math.Pi/180))
+
+		point = utils.Location{X: targetX, Y: targetY}
+		if IsWalkable(mapID, &point) {
+			break
+		}
+	}
 
-	return utils.Location{X: targetX, Y: targetY}
+	return point
 }
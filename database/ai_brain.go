@@ -0,0 +1,415 @@
+package database
+
+import (
+	"math"
+
+	"hero-emulator/nats"
+	"hero-emulator/utils"
+)
+
+type AIState byte
+
+const (
+	AI_STATE_IDLE AIState = iota
+	AI_STATE_COMBAT
+	AI_STATE_RESET
+	AI_STATE_FLEE
+)
+
+const (
+	fleeDistance   = 10.0
+	meleeRange     = 5.0
+	targetSwitchAt = 1.1 // a new target needs 10% more threat than the current one
+)
+
+// AIContext is the data of a mob a state handler works with.
+type AIContext struct {
+	NPC      *NPC
+	Pos      *NpcPosition
+	Behavior *NPCBehavior
+}
+
+// AIStateHandler runs a single tick of a mob in a state and returns the state of the next tick.
+type AIStateHandler func(ai *AI, ctx *AIContext) AIState
+
+var aiStates = map[AIState]AIStateHandler{
+	AI_STATE_IDLE:   idleState,
+	AI_STATE_COMBAT: combatState,
+	AI_STATE_RESET:  resetState,
+	AI_STATE_FLEE:   fleeState,
+}
+
+// RegisterAIState replaces the handler of a state.
+func RegisterAIState(state AIState, handler AIStateHandler) {
+	aiStates[state] = handler
+}
+
+func (ai *AI) think() {
+
+	pos := NPCPos[ai.PosID]
+	if pos == nil {
+		return
+	}
+
+	npc := NPCs[pos.NPCID]
+	if npc == nil {
+		return
+	}
+
+	handler, ok := aiStates[ai.State]
+	if !ok {
+		handler = idleState
+	}
+
+	ctx := &AIContext{NPC: npc, Pos: pos, Behavior: GetNPCBehavior(npc.ID)}
+	ai.State = handler(ai, ctx)
+	ai.encounterTick(ctx)
+}
+
+// OnDamaged adds the damage of the character to the threat table and pulls an idle mob into combat.
+func (ai *AI) OnDamaged(c *Character, damage int) {
+	if ai.State == AI_STATE_RESET || ai.HP <= 0 {
+		return
+	}
+
+	ai.Threat.Add(c.ID, damage+1)
+	if ai.State != AI_STATE_IDLE {
+		return
+	}
+
+	ai.stopMoving()
+	ai.State = AI_STATE_COMBAT
+
+	if pos := NPCPos[ai.PosID]; pos != nil {
+		ai.callForHelp(pos, GetNPCBehavior(pos.NPCID), c.ID)
+	}
+}
+
+func idleState(ai *AI, ctx *AIContext) AIState {
+
+	if ai.Threat.Len() > 0 {
+		return AI_STATE_COMBAT
+	}
+
+	if ctx.Behavior.Aggressive {
+		if c := ai.findAggroTarget(ctx); c != nil {
+			ai.Threat.Add(c.ID, 1)
+			ai.callForHelp(ctx.Pos, ctx.Behavior, c.ID)
+			ai.stopMoving()
+			return AI_STATE_COMBAT
+		}
+	}
+
+	if ai.ShouldGoBack() {
+		return AI_STATE_RESET
+	}
+
+	if !ai.IsMoving && utils.RandInt(0, 1000) < 750 { // 75% chance to move
+		ai.moveTo(ai.homePoint(ctx.Pos), ai.WalkingSpeed)
+	}
+
+	return AI_STATE_IDLE
+}
+
+func combatState(ai *AI, ctx *AIContext) AIState {
+
+	b := ctx.Behavior
+	target := ai.selectTarget(ctx)
+	if target == nil {
+		return ai.reset()
+	}
+
+	if b.FleeHP > 0 && !ai.fled && ai.HP*100 < ctx.NPC.MaxHp*b.FleeHP {
+		ai.fled = true
+		ai.moveTo(awayFrom(ConvertPointToLocation(ai.Coordinate), ConvertPointToLocation(target.Coordinate), fleeDistance), ai.RunningSpeed)
+		return AI_STATE_FLEE
+	}
+
+	targetLocation := ConvertPointToLocation(target.Coordinate)
+	ai.TargetPlayerID, ai.TargetPetID = target.ID, 0
+	if pet := shieldingPet(target); pet != nil {
+		ai.TargetPlayerID, ai.TargetPetID = 0, pet.PseudoID
+		targetLocation = &pet.Coordinate
+	}
+
+	aiLocation := ConvertPointToLocation(ai.Coordinate)
+	distance := utils.CalculateDistance(targetLocation, aiLocation)
+	if distance > b.ChaseRange || ai.distanceToHome(ctx.Pos) > b.LeashRange { // better to retreat
+		return ai.reset()
+	}
+
+	ai.IsMoving = false
+	if b.KeepDistance > 0 && distance < b.KeepDistance {
+		ai.moveTo(awayFrom(aiLocation, targetLocation, b.KeepDistance-distance+1), ai.RunningSpeed)
+
+	} else if distance <= b.AttackRange { // attack
+		if ai.TargetPlayerID > 0 && !target.IsActive {
+			return AI_STATE_COMBAT
+		}
+
+		p := nats.CastPacket{CastNear: true, MobID: ai.ID, Data: ai.strike(ctx), Type: nats.MOB_ATTACK}
+		p.Cast()
+
+	} else { // chase
+		ai.moveTo(approach(ai.Map, targetLocation, aiLocation, b.AttackRange), ai.RunningSpeed)
+	}
+
+	return AI_STATE_COMBAT
+}
+
+func resetState(ai *AI, ctx *AIContext) AIState {
+
+	ai.Threat.Clear()
+	ai.TargetPlayerID, ai.TargetPetID = 0, 0
+
+	if ai.HP < ctx.NPC.MaxHp {
+		ai.HP += int(math.Max(float64(ctx.NPC.MaxHp*ctx.Behavior.RegenPercent/100), 1))
+		if ai.HP > ctx.NPC.MaxHp {
+			ai.HP = ctx.NPC.MaxHp
+		}
+	}
+
+	if ai.ShouldGoBack() {
+		if !ai.IsMoving {
+			ai.moveTo(ai.homePoint(ctx.Pos), ai.RunningSpeed)
+		}
+		return AI_STATE_RESET
+	} else if ai.HP < ctx.NPC.MaxHp {
+		return AI_STATE_RESET
+	}
+
+	ai.fled = false
+	return AI_STATE_IDLE
+}
+
+func fleeState(ai *AI, ctx *AIContext) AIState {
+	if ai.IsMoving {
+		return AI_STATE_FLEE
+	}
+	return AI_STATE_COMBAT
+}
+
+// reset drops the targets of the mob, it walks back home and regenerates its hp.
+func (ai *AI) reset() AIState {
+	ai.Threat.Clear()
+	ai.DamageDealers.Clear()
+	ai.TargetPlayerID, ai.TargetPetID = 0, 0
+	ai.stopMoving()
+	return AI_STATE_RESET
+}
+
+func (ai *AI) canTarget(c *Character, npc *NPC) bool {
+	if !ai.isPresent(c, ai.Server) || c.Invisible || c.InstanceID != ai.InstanceID {
+		return false
+	}
+
+	for _, factionNPC := range ZhuangFactionMobs {
+		if factionNPC == npc.ID && c.Faction == 1 {
+			return false
+		}
+	}
+	for _, factionNPC := range ShaoFactionMobs {
+		if factionNPC == npc.ID && c.Faction == 2 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// selectTarget returns the character with the highest threat, the current target is kept until it is
+// overtaken by targetSwitchAt.
+func (ai *AI) selectTarget(ctx *AIContext) *Character {
+
+	var top, current *Character
+	for _, id := range ai.Threat.Sorted() {
+		c, err := FindCharacterByID(id)
+		if err != nil || c == nil || !ai.canTarget(c, ctx.NPC) {
+			ai.Threat.Remove(id)
+			continue
+		}
+
+		if top == nil {
+			top = c
+		}
+		if id == ai.threatTarget {
+			current = c
+		}
+	}
+
+	if current != nil && top != nil && float64(ai.Threat.Get(current.ID))*targetSwitchAt >= float64(ai.Threat.Get(top.ID)) {
+		top = current
+	}
+
+	if top != nil {
+		ai.threatTarget = top.ID
+	} else {
+		ai.threatTarget = 0
+	}
+
+	return top
+}
+
+// findAggroTarget returns the closest character in aggro range inside the spawn area.
+func (ai *AI) findAggroTarget(ctx *AIContext) *Character {
+
+	minCoordinate := ConvertPointToLocation(ctx.Pos.MinLocation)
+	maxCoordinate := ConvertPointToLocation(ctx.Pos.MaxLocation)
+	aiCoordinate := ConvertPointToLocation(ai.Coordinate)
+
+	ai.PlayersMutex.RLock()
+	ids := make([]int, 0, len(ai.OnSightPlayers))
+	for id := range ai.OnSightPlayers {
+		ids = append(ids, id)
+	}
+	ai.PlayersMutex.RUnlock()
+
+	var target *Character
+	best := ctx.Behavior.AggroRange
+	for _, id := range ids {
+		c, err := FindCharacterByID(id)
+		if err != nil || c == nil || !ai.canTarget(c, ctx.NPC) {
+			continue
+		}
+
+		coordinate := ConvertPointToLocation(c.Coordinate)
+		if coordinate.X < minCoordinate.X || coordinate.X > maxCoordinate.X || coordinate.Y < minCoordinate.Y || coordinate.Y > maxCoordinate.Y {
+			continue
+		}
+
+		if distance := utils.CalculateDistance(coordinate, aiCoordinate); distance <= best {
+			target, best = c, distance
+		}
+	}
+
+	return target
+}
+
+// callForHelp pulls the idle mobs of the same npc or faction in assist range into the fight.
+func (ai *AI) callForHelp(pos *NpcPosition, b *NPCBehavior, characterID int) {
+	if b.AssistRange <= 0 || ai.Server >= len(AIsByMap) {
+		return
+	}
+
+	location := ConvertPointToLocation(ai.Coordinate)
+	for _, other := range ai.neighbours() {
+		if other == ai || other.HP <= 0 || other.IsDead || other.State != AI_STATE_IDLE {
+			continue
+		}
+
+		otherPos := NPCPos[other.PosID]
+		if otherPos == nil || !otherPos.Attackable || (otherPos.NPCID != pos.NPCID && (ai.Faction == 0 || other.Faction != ai.Faction)) {
+			continue
+		}
+
+		if utils.CalculateDistance(location, ConvertPointToLocation(other.Coordinate)) > b.AssistRange {
+			continue
+		}
+
+		other.Threat.Add(characterID, 1)
+		other.stopMoving()
+		other.State = AI_STATE_COMBAT
+	}
+}
+
+// strike attacks the current target with a skill or a normal attack.
+func (ai *AI) strike(ctx *AIContext) []byte {
+
+	_, ok := SkillInfos[ctx.NPC.SkillID]
+	skill := ok && int(utils.RandInt(0, 1000)) < ctx.Behavior.SkillChance
+
+	if ai.TargetPetID > 0 {
+		if skill {
+			return ai.CastSkillToPet()
+		}
+		return ai.AttackPet()
+	}
+
+	if skill {
+		return ai.CastSkill()
+	}
+	return ai.Attack()
+}
+
+// shieldingPet returns the non-combat pet of the character, mobs attack it instead of its owner.
+func shieldingPet(c *Character) *PetSlot {
+	slots, err := c.InventorySlots()
+	if err != nil {
+		return nil
+	}
+
+	petSlot := slots[0x0A]
+	pet := petSlot.Pet
+	info, ok := Pets[petSlot.ItemID]
+	if pet != nil && ok && pet.IsOnline && pet.HP > 0 && !info.Combat {
+		return pet
+	}
+
+	return nil
+}
+
+// moveTo walks the mob along the path to target, it stays in place if target cannot be reached.
+func (ai *AI) moveTo(target utils.Location, speed float64) {
+	start := ConvertPointToLocation(ai.Coordinate)
+	path := FindPath(ai.Map, start, &target)
+	if len(path) == 0 {
+		ai.stopMoving()
+		return
+	}
+
+	ai.IsMoving = true
+	ai.TargetLocation = path[len(path)-1]
+
+	token := ai.MovementToken
+	for token == ai.MovementToken {
+		ai.MovementToken = utils.RandInt(1, math.MaxInt64)
+	}
+
+	ai.waypoints = path[1:]
+	go ai.MovementHandler(ai.MovementToken, start, &path[0], speed)
+}
+
+func (ai *AI) stopMoving() {
+	ai.MovementToken = 0
+	ai.IsMoving = false
+	ai.waypoints = nil
+}
+
+func (ai *AI) homePoint(pos *NpcPosition) utils.Location {
+	minCoordinate := ConvertPointToLocation(pos.MinLocation)
+	maxCoordinate := ConvertPointToLocation(pos.MaxLocation)
+	return RandomWalkablePoint(ai.Map, minCoordinate, maxCoordinate)
+}
+
+// distanceToHome returns how far the mob is out of its spawn area.
+func (ai *AI) distanceToHome(pos *NpcPosition) float64 {
+	minCoordinate := ConvertPointToLocation(pos.MinLocation)
+	maxCoordinate := ConvertPointToLocation(pos.MaxLocation)
+	coordinate := ConvertPointToLocation(ai.Coordinate)
+
+	dx := math.Max(math.Max(minCoordinate.X-coordinate.X, 0), coordinate.X-maxCoordinate.X)
+	dy := math.Max(math.Max(minCoordinate.Y-coordinate.Y, 0), coordinate.Y-maxCoordinate.Y)
+	return math.Sqrt(dx*dx + dy*dy)
+}
+
+// awayFrom returns the point at distance from location on the opposite side of the threat.
+func awayFrom(location, threat *utils.Location, distance float64) utils.Location {
+	d := utils.CalculateDistance(location, threat)
+	if d == 0 {
+		alfa := utils.RandFloat(0, 2*math.Pi)
+		return utils.Location{X: location.X + distance*math.Cos(alfa), Y: location.Y + distance*math.Sin(alfa)}
+	}
+
+	return utils.Location{X: location.X + (location.X-threat.X)*distance/d, Y: location.Y + (location.Y-threat.Y)*distance/d}
+}
+
+// approach returns the point to chase a target from, ranged mobs stop inside their attack range.
+func approach(mapID int16, target, location *utils.Location, attackRange float64) utils.Location {
+	d := utils.CalculateDistance(location, target)
+	if attackRange <= meleeRange || d == 0 {
+		return GeneratePoint(mapID, target)
+	}
+
+	r := attackRange * 0.8
+	return utils.Location{X: target.X + (location.X-target.X)*r/d, Y: target.Y + (location.Y-target.Y)*r/d}
+}
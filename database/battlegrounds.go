@@ -0,0 +1,1240 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"hero-emulator/messaging"
+	"hero-emulator/nats"
+	"hero-emulator/utils"
+)
+
+const (
+	BG_LOBBY = iota
+	BG_READY_CHECK
+	BG_OPEN
+	BG_RUNNING
+	BG_ENDED
+	BG_CLOSED
+)
+
+const (
+	BATTLEGROUND_GREAT_WAR   = 1 // ids of the bundled battleground files
+	BATTLEGROUND_FACTION_WAR = 2
+
+	BG_PANEL_WAR     = "war"
+	BG_PANEL_FACTION = "faction"
+
+	STONE_VALUE      = 100 // a stone starts neutral at the middle of 0-200
+	STONE_CAPTURE    = 30  // a team owns a stone when the value is this close to its end
+	STONE_NEAR_TIME  = 3 * time.Second
+	BG_DEFAULT_TICKS = 1
+)
+
+var (
+	ANNOUNCEMENT = utils.Packet{0xAA, 0x55, 0x00, 0x00, 0x71, 0x06, 0x00, 0x55, 0xAA}
+	START_WAR    = utils.Packet{0xaa, 0x55, 0x23, 0x00, 0x65, 0x01, 0x00, 0x00, 0x17, 0x00, 0x00, 0x00, 0x10, 0x27, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0d, 0x00, 0x00, 0x00, 0x10, 0x27, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xb0, 0x04, 0x00, 0x00, 0x55, 0xaa}
+
+	TIMER_MENU     = utils.Packet{0xAA, 0x55, 0x08, 0x00, 0x65, 0x03, 0x00, 0x55, 0xAA}
+	WAR_SCOREPANEL = utils.Packet{0xAA, 0x55, 0x30, 0x00, 0x65, 0x06, 0x55, 0xAA}
+
+	FACTION_WAR_START = utils.Packet{
+		0xAA, 0x55, 0x23, 0x00, 0x65, 0x01, 0x0a, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x55, 0xaa}
+	FACTION_WAR_UPDATE = utils.Packet{
+		0xAA, 0x55, 0x23, 0x00, 0x65, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x55, 0xaa}
+
+	// BattlegroundDir holds one json file per battleground template.
+	BattlegroundDir       = "data/battlegrounds"
+	BattlegroundTemplates = make(map[int]*BattlegroundTemplate)
+	Battlegrounds         = make(map[int]*Battleground)
+	bgMutex               sync.RWMutex
+	battlegroundSeq       int
+)
+
+// BattlegroundTemplate describes a faction battleground, the file format is described in the README.
+type BattlegroundTemplate struct {
+	ID            int                         `json:"id"`
+	Name          string                      `json:"name"`
+	Map           int16                       `json:"map"`
+	Exit          *DungeonPoint               `json:"exit"`
+	MinLevel      int                         `json:"min_level"`
+	MaxLevel      int                         `json:"max_level"`
+	Prepare       int                         `json:"prepare"`     // seconds between the opening and the start
+	Duration      int                         `json:"duration"`    // seconds
+	TeamSize      int                         `json:"team_size"`   // queued battlegrounds start when every team has this many players
+	ReadyCheck    int                         `json:"ready_check"` // seconds the queued players have to accept
+	ScoreInterval int                         `json:"score_interval"`
+	StartScore    int                         `json:"start_score"`
+	EndAtZero     bool                        `json:"end_at_zero"` // the battleground ends when a team has no points left
+	KillScore     int                         `json:"kill_score"`  // to the team of the killer
+	DeathScore    int                         `json:"death_score"` // to the team of the killed player
+	MemberScore   int                         `json:"member_score"`
+	StoneScore    int                         `json:"stone_score"` // taken from the other teams for every owned stone
+	Stones        []int                       `json:"stones"`      // ai ids of the capturable stones
+	Mobs          []*BattlegroundMobScore     `json:"mobs"`
+	Teams         []*BattlegroundTeamTemplate `json:"teams"`
+	Panel         string                      `json:"panel"`
+	Announcement  string                      `json:"announcement"`
+	ExitDelay     int                         `json:"exit_delay"`
+	OnePerIP      bool                        `json:"one_per_ip"`
+	Rewards       *BattlegroundRewards        `json:"rewards"`
+}
+
+type BattlegroundTeamTemplate struct {
+	Faction int               `json:"faction"`
+	Name    string            `json:"name"`
+	Spawn   *DungeonPoint     `json:"spawn"`
+	Area    *BattlegroundArea `json:"area"` // the team is held inside until the start
+}
+
+type BattlegroundArea struct {
+	MinX float64 `json:"min_x"`
+	MinY float64 `json:"min_y"`
+	MaxX float64 `json:"max_x"`
+	MaxY float64 `json:"max_y"`
+}
+
+// BattlegroundMobScore gives points when a mob is hit or killed, Faction limits the killers and
+// Team is the faction which gets the points, 0 is the team of the killer.
+type BattlegroundMobScore struct {
+	NPCID   int  `json:"npc_id"`
+	Faction int  `json:"faction"`
+	Team    int  `json:"team"`
+	Points  int  `json:"points"`
+	PerHit  bool `json:"per_hit"`
+}
+
+type BattlegroundRewards struct {
+	Winner []*EncounterItem `json:"winner"`
+	Loser  []*EncounterItem `json:"loser"`
+}
+
+// Battleground is a running copy of a battleground template on a server.
+type Battleground struct {
+	ID        int
+	Template  *BattlegroundTemplate
+	Server    int
+	State     int
+	MinLevel  int
+	MaxLevel  int
+	StartsAt  time.Time
+	EndsAt    time.Time
+	Teams     map[int]*BattlegroundTeam // by faction
+	Stones    map[uint16]*WarStone      // by pseudo id
+	Announcer bool                      // makes the announcements of the opening
+
+	members    map[int]*BattlegroundMember
+	readyUntil time.Time
+	closeAt    time.Time
+	announced  int
+	ticks      int
+	mutex      sync.RWMutex
+}
+
+type BattlegroundTeam struct {
+	*BattlegroundTeamTemplate
+	Score int
+}
+
+type BattlegroundMember struct {
+	Character    *Character
+	Faction      int
+	Kills        int
+	Contribution int
+	Accepted     bool
+	Inside       bool
+}
+
+// WarStone is a capturable stone of a battleground, the value moves towards 0 while
+// Zhuang stand near it and towards 200 while Shao do.
+type WarStone struct {
+	PseudoID      uint16
+	NpcID         int
+	ConqueredID   int
+	ConquereValue int
+
+	near map[int]time.Time
+}
+
+// LoadBattlegrounds reads the battleground files of BattlegroundDir and replaces the loaded templates.
+func LoadBattlegrounds() ([]string, error) {
+
+	files, err := filepath.Glob(filepath.Join(BattlegroundDir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("LoadBattlegrounds: %s", err.Error())
+	}
+
+	templates := make(map[int]*BattlegroundTemplate)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("LoadBattlegrounds: %s", err.Error())
+		}
+
+		t := &BattlegroundTemplate{}
+		if err := json.Unmarshal(data, t); err != nil {
+			return nil, fmt.Errorf("LoadBattlegrounds %s: %s", file, err.Error())
+		}
+		templates[t.ID] = t
+	}
+
+	errs := validateBattlegrounds(templates)
+	if len(errs) > 0 {
+		return errs, fmt.Errorf("LoadBattlegrounds: %s", strings.Join(errs, ", "))
+	}
+
+	bgMutex.Lock()
+	BattlegroundTemplates = templates
+	bgMutex.Unlock()
+	return nil, nil
+}
+
+func validateBattlegrounds(templates map[int]*BattlegroundTemplate) []string {
+
+	errs := []string{}
+	for id, t := range templates {
+		if id <= 0 {
+			errs = append(errs, fmt.Sprintf("battleground %s: invalid id", t.Name))
+		}
+		if t.Map <= 0 {
+			errs = append(errs, fmt.Sprintf("battleground %d: no map", id))
+		}
+		if t.Duration <= 0 {
+			errs = append(errs, fmt.Sprintf("battleground %d: no duration", id))
+		}
+		if len(t.Teams) < 2 {
+			errs = append(errs, fmt.Sprintf("battleground %d: less than 2 teams", id))
+		}
+		if t.Panel != "" && t.Panel != BG_PANEL_WAR && t.Panel != BG_PANEL_FACTION {
+			errs = append(errs, fmt.Sprintf("battleground %d: unknown panel %s", id, t.Panel))
+		}
+
+		factions := make(map[int]bool)
+		for _, team := range t.Teams {
+			if factions[team.Faction] {
+				errs = append(errs, fmt.Sprintf("battleground %d: faction %d has two teams", id, team.Faction))
+			}
+			factions[team.Faction] = true
+			if team.Spawn == nil {
+				errs = append(errs, fmt.Sprintf("battleground %d: team %s has no spawn", id, team.Name))
+			}
+		}
+
+		for _, m := range t.Mobs {
+			if _, ok := NPCs[m.NPCID]; !ok {
+				errs = append(errs, fmt.Sprintf("battleground %d: unknown npc %d", id, m.NPCID))
+			}
+		}
+		if t.Rewards != nil {
+			for _, item := range append(t.Rewards.Winner, t.Rewards.Loser...) {
+				if _, ok := Items[item.ItemID]; !ok {
+					errs = append(errs, fmt.Sprintf("battleground %d: unknown item %d", id, item.ItemID))
+				}
+			}
+		}
+	}
+
+	sort.Strings(errs)
+	return errs
+}
+
+func FindBattlegroundTemplate(id int) *BattlegroundTemplate {
+	bgMutex.RLock()
+	defer bgMutex.RUnlock()
+	return BattlegroundTemplates[id]
+}
+
+// IsBattlegroundMap reports whether the map belongs to a battleground, characters cannot stay on it outside of one.
+func IsBattlegroundMap(mapID int16) bool {
+	bgMutex.RLock()
+	defer bgMutex.RUnlock()
+	for _, t := range BattlegroundTemplates {
+		if t.Map == mapID {
+			return true
+		}
+	}
+	return false
+}
+
+func FindBattleground(id int) *Battleground {
+	if id == 0 {
+		return nil
+	}
+
+	bgMutex.RLock()
+	defer bgMutex.RUnlock()
+	return Battlegrounds[id]
+}
+
+// findJoinable returns the battleground of the template the characters of the server can join.
+func findJoinable(templateID, server int) *Battleground {
+	bgMutex.RLock()
+	defer bgMutex.RUnlock()
+	for _, bg := range sortedBattlegrounds() {
+		if bg.Template.ID == templateID && bg.Server == server && (bg.State == BG_LOBBY || bg.State == BG_OPEN) {
+			return bg
+		}
+	}
+	return nil
+}
+
+// mapBusy reports whether another battleground is open or running on the map of the server.
+func mapBusy(bg *Battleground) bool {
+	bgMutex.RLock()
+	defer bgMutex.RUnlock()
+	for _, other := range Battlegrounds {
+		if other != bg && other.Server == bg.Server && other.Template.Map == bg.Template.Map &&
+			other.State >= BG_OPEN && other.State < BG_CLOSED {
+			return true
+		}
+	}
+	return false
+}
+
+func newBattleground(t *BattlegroundTemplate, server, minLevel, maxLevel int) *Battleground {
+	bg := &Battleground{Template: t, Server: server, MinLevel: t.MinLevel, MaxLevel: t.MaxLevel,
+		Teams: make(map[int]*BattlegroundTeam), Stones: make(map[uint16]*WarStone), members: make(map[int]*BattlegroundMember)}
+	if minLevel > 0 {
+		bg.MinLevel = minLevel
+	}
+	if maxLevel > 0 {
+		bg.MaxLevel = maxLevel
+	}
+
+	for _, team := range t.Teams {
+		bg.Teams[team.Faction] = &BattlegroundTeam{BattlegroundTeamTemplate: team, Score: t.StartScore}
+	}
+
+	for _, id := range t.Stones {
+		if ai := FindAIByID(id); ai != nil && ai.Server == server && ai.Map == t.Map {
+			bg.Stones[ai.PseudoID] = &WarStone{PseudoID: ai.PseudoID, NpcID: NPCPos[ai.PosID].NPCID,
+				ConquereValue: STONE_VALUE, near: make(map[int]time.Time)}
+		}
+	}
+
+	bgMutex.Lock()
+	battlegroundSeq++
+	bg.ID = battlegroundSeq
+	Battlegrounds[bg.ID] = bg
+	bgMutex.Unlock()
+	return bg
+}
+
+// OpenBattleground opens the sign-up of the battleground on every server, it starts after prepare
+// seconds. Zero values use the values of the template.
+func OpenBattleground(id, prepare, minLevel, maxLevel int) error {
+
+	t := FindBattlegroundTemplate(id)
+	if t == nil {
+		return fmt.Errorf("unknown battleground %d", id)
+	} else if t.TeamSize > 0 {
+		return fmt.Errorf("%s starts when its queue is full", t.Name)
+	}
+
+	bgMutex.RLock()
+	for _, bg := range Battlegrounds {
+		if bg.Template.ID == id && bg.State < BG_ENDED {
+			bgMutex.RUnlock()
+			return fmt.Errorf("%s is already open", t.Name)
+		}
+	}
+	bgMutex.RUnlock()
+
+	if prepare <= 0 {
+		prepare = t.Prepare
+	}
+
+	startsAt := time.Now().Add(time.Duration(prepare) * time.Second)
+	for s := 1; s <= SERVER_COUNT; s++ {
+		bg := newBattleground(t, s, minLevel, maxLevel)
+		bg.State = BG_OPEN
+		bg.StartsAt = startsAt
+		bg.Announcer = s == 1
+		if bg.Announcer {
+			bg.announce(prepare, true)
+		}
+		AfterTicks(s, time.Second, bg.tick)
+	}
+	return nil
+}
+
+// JoinBattleground signs the character up for the battleground of its server.
+func JoinBattleground(c *Character, id int) ([]byte, error) {
+
+	t := FindBattlegroundTemplate(id)
+	if t == nil || c.Socket == nil || c.Socket.User == nil {
+		return nil, nil
+	} else if c.BattlegroundID != 0 {
+		return messaging.InfoMessage("You already joined a battleground."), nil
+	} else if c.InstanceID != 0 {
+		return messaging.InfoMessage("You cannot join while you are in a dungeon."), nil
+	}
+
+	server := c.Socket.User.ConnectedServer
+	bg := findJoinable(id, server)
+	if bg == nil && t.TeamSize > 0 {
+		bg = newBattleground(t, server, 0, 0)
+		bg.State = BG_LOBBY
+		AfterTicks(server, time.Second, bg.tick)
+	}
+	if bg == nil {
+		return messaging.InfoMessage(fmt.Sprintf("%s is not open.", t.Name)), nil
+	}
+
+	team := bg.Teams[c.Faction]
+	if team == nil {
+		return messaging.InfoMessage("Your faction cannot join this battleground."), nil
+	} else if c.Level < bg.MinLevel || (bg.MaxLevel > 0 && c.Level > bg.MaxLevel) {
+		return messaging.InfoMessage(fmt.Sprintf("%s is for levels %d-%d.", t.Name, bg.MinLevel, bg.MaxLevel)), nil
+	}
+
+	bg.mutex.Lock()
+	if t.TeamSize > 0 && bg.teamCount(c.Faction, false) >= t.TeamSize {
+		bg.mutex.Unlock()
+		return messaging.InfoMessage("Your team is full, try again later."), nil
+	}
+	if t.OnePerIP && bg.sameIP(c) {
+		bg.mutex.Unlock()
+		return messaging.InfoMessage("You cannot enter with more than one character!"), nil
+	}
+	bg.members[c.ID] = &BattlegroundMember{Character: c, Faction: c.Faction}
+	bg.mutex.Unlock()
+	c.BattlegroundID = bg.ID
+
+	if bg.State == BG_LOBBY {
+		return messaging.InfoMessage(fmt.Sprintf("You joined the queue of %s.", t.Name)), nil
+	}
+	return bg.enter(c)
+}
+
+// LeaveBattleground takes the character out of its battleground.
+func LeaveBattleground(c *Character) ([]byte, error) {
+
+	bg := FindBattleground(c.BattlegroundID)
+	if bg == nil {
+		return messaging.InfoMessage("You are not in a battleground."), nil
+	}
+
+	inside := bg.remove(c)
+	if !inside {
+		return messaging.InfoMessage(fmt.Sprintf("You left the queue of %s.", bg.Template.Name)), nil
+	}
+	return bg.sendOut(c)
+}
+
+// AcceptBattleground accepts the ready check of the queued battleground of the character.
+func AcceptBattleground(c *Character) ([]byte, error) {
+
+	bg := FindBattleground(c.BattlegroundID)
+	if bg == nil || bg.State != BG_READY_CHECK {
+		return messaging.InfoMessage("There is nothing to accept."), nil
+	}
+
+	bg.mutex.Lock()
+	if m := bg.members[c.ID]; m != nil {
+		m.Accepted = true
+	}
+	bg.mutex.Unlock()
+	return messaging.InfoMessage("You are ready."), nil
+}
+
+func (bg *Battleground) enter(c *Character) ([]byte, error) {
+	team := bg.Teams[c.Faction]
+
+	bg.mutex.Lock()
+	if m := bg.members[c.ID]; m != nil {
+		m.Inside = true
+	}
+	bg.mutex.Unlock()
+
+	return c.ChangeMap(bg.Template.Map, team.Spawn.location())
+}
+
+// remove takes the character out of the battleground, inside is true if it was on the battleground map.
+func (bg *Battleground) remove(c *Character) bool {
+	bg.mutex.Lock()
+	defer bg.mutex.Unlock()
+
+	m := bg.members[c.ID]
+	delete(bg.members, c.ID)
+	if c.BattlegroundID == bg.ID {
+		c.BattlegroundID = 0
+	}
+	return m != nil && m.Inside
+}
+
+// Left is called when a member changes map, it leaves the battleground unless it is entering its map.
+func (bg *Battleground) Left(c *Character, mapID int16) {
+	if bg == nil || mapID == bg.Template.Map {
+		return
+	}
+	bg.remove(c)
+}
+
+func (bg *Battleground) sendOut(c *Character) ([]byte, error) {
+	exit, mapID := bg.Template.Exit.location(), int16(1)
+	if bg.Template.Exit != nil && bg.Template.Exit.Map > 0 {
+		mapID = bg.Template.Exit.Map
+	}
+	return c.ChangeMap(mapID, exit)
+}
+
+// sameIP reports whether a member is connected from the address of the character.
+func (bg *Battleground) sameIP(c *Character) bool {
+	ip := strings.Split(c.Socket.User.ConnectedIP, ":")[0]
+	for _, m := range bg.members {
+		other := m.Character
+		if other.ID != c.ID && other.Socket != nil && other.Socket.User != nil &&
+			strings.Split(other.Socket.User.ConnectedIP, ":")[0] == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// teamCount returns the number of members of the faction, only the accepted ones when accepted is true.
+func (bg *Battleground) teamCount(faction int, accepted bool) int {
+	count := 0
+	for _, m := range bg.members {
+		if m.Faction == faction && (!accepted || m.Accepted) {
+			count++
+		}
+	}
+	return count
+}
+
+// inside returns the online members on the battleground map.
+func (bg *Battleground) inside() []*BattlegroundMember {
+	bg.mutex.RLock()
+	defer bg.mutex.RUnlock()
+
+	members := []*BattlegroundMember{}
+	for _, m := range bg.members {
+		c := m.Character
+		if m.Inside && c.IsOnline && c.Socket != nil && c.BattlegroundID == bg.ID {
+			members = append(members, m)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Character.ID < members[j].Character.ID
+	})
+	return members
+}
+
+// teamMembers returns the online members of the faction on the battleground map.
+func (bg *Battleground) teamMembers(faction int) []*BattlegroundMember {
+	members := []*BattlegroundMember{}
+	for _, m := range bg.inside() {
+		if m.Faction == faction {
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+func (bg *Battleground) broadcast(data []byte) {
+	for _, m := range bg.inside() {
+		m.Character.Socket.Write(data)
+	}
+}
+
+// HoldArea returns the area the character has to stay in until the battleground starts.
+func (bg *Battleground) HoldArea(c *Character) *BattlegroundArea {
+	if bg == nil || bg.State != BG_OPEN {
+		return nil
+	}
+	if team := bg.Teams[c.Faction]; team != nil {
+		return team.Area
+	}
+	return nil
+}
+
+func (a *BattlegroundArea) Contains(location *utils.Location) bool {
+	return location.X >= a.MinX && location.X <= a.MaxX && location.Y >= a.MinY && location.Y <= a.MaxY
+}
+
+// Clamp returns the closest point of the area to the location.
+func (a *BattlegroundArea) Clamp(location *utils.Location) *utils.Location {
+	x := utils.Location{X: location.X, Y: location.Y}
+	if x.X < a.MinX {
+		x.X = a.MinX
+	} else if x.X > a.MaxX {
+		x.X = a.MaxX
+	}
+	if x.Y < a.MinY {
+		x.Y = a.MinY
+	} else if x.Y > a.MaxY {
+		x.Y = a.MaxY
+	}
+	return &x
+}
+
+// Stone returns the stone of the battleground with the pseudo id.
+func (bg *Battleground) Stone(pseudoID uint16) *WarStone {
+	if bg == nil {
+		return nil
+	}
+
+	bg.mutex.RLock()
+	defer bg.mutex.RUnlock()
+	return bg.Stones[pseudoID]
+}
+
+// StoneNear records the character standing near the stone and returns the value of the stone,
+// dead characters do not count.
+func (bg *Battleground) StoneNear(c *Character, pseudoID uint16) (int, bool) {
+	if bg == nil {
+		return 0, false
+	}
+
+	bg.mutex.Lock()
+	defer bg.mutex.Unlock()
+
+	stone := bg.Stones[pseudoID]
+	if stone == nil {
+		return 0, false
+	} else if c.Socket.Stats.HP <= 0 {
+		delete(stone.near, c.ID)
+	} else {
+		stone.near[c.ID] = time.Now()
+	}
+	return stone.ConquereValue, true
+}
+
+func (bg *Battleground) addScore(faction, points int, m *BattlegroundMember) {
+	if team := bg.Teams[faction]; team != nil && points != 0 {
+		team.Score += points
+		if m != nil && m.Faction == faction {
+			m.Contribution += points
+		}
+	}
+}
+
+// MobDamaged scores the hit of a member on a mob of the battleground.
+func (bg *Battleground) MobDamaged(c *Character, npcID int, killed bool) {
+	if bg == nil || bg.State != BG_RUNNING {
+		return
+	}
+
+	bg.mutex.Lock()
+	defer bg.mutex.Unlock()
+
+	m := bg.members[c.ID]
+	for _, rule := range bg.Template.Mobs {
+		if rule.NPCID != npcID || (rule.Faction != 0 && rule.Faction != c.Faction) || (!rule.PerHit && !killed) {
+			continue
+		}
+		team := rule.Team
+		if team == 0 {
+			team = c.Faction
+		}
+		bg.addScore(team, rule.Points, m)
+	}
+}
+
+// BattlegroundKill scores a player killed by another one in the same battleground.
+func BattlegroundKill(killer, victim *Character) {
+	bg := FindBattleground(killer.BattlegroundID)
+	if bg == nil || victim.BattlegroundID != bg.ID || bg.State != BG_RUNNING || killer.Faction == victim.Faction {
+		return
+	}
+
+	bg.mutex.Lock()
+	defer bg.mutex.Unlock()
+
+	m := bg.members[killer.ID]
+	if m != nil {
+		m.Kills++
+	}
+	bg.addScore(killer.Faction, bg.Template.KillScore, m)
+	bg.addScore(victim.Faction, bg.Template.DeathScore, nil)
+	go AddGuildActivity(killer, GUILD_KILL_POINTS, GUILD_KILL_POINTS)
+}
+
+// tick runs every second on the world of the battleground until it is closed.
+func (bg *Battleground) tick() {
+
+	if bg.State == BG_CLOSED {
+		return
+	}
+
+	now := time.Now()
+	switch bg.State {
+	case BG_LOBBY:
+		bg.checkQueue(now)
+
+	case BG_READY_CHECK:
+		bg.checkReady(now)
+
+	case BG_OPEN:
+		remaining := int(bg.StartsAt.Sub(now).Seconds())
+		if bg.Announcer {
+			bg.announce(remaining, false)
+		}
+		if remaining <= 0 {
+			bg.start(now)
+		}
+
+	case BG_RUNNING:
+		bg.ticks++
+		bg.captureStones(now)
+
+		interval := bg.Template.ScoreInterval
+		if interval <= 0 {
+			interval = BG_DEFAULT_TICKS
+		}
+		if bg.ticks%interval == 0 {
+			bg.score()
+		}
+
+		if bg.finished(now) {
+			bg.end(now)
+		}
+
+	case BG_ENDED:
+		if !now.Before(bg.closeAt) {
+			bg.close()
+		}
+	}
+
+	if bg.State != BG_CLOSED {
+		AfterTicks(bg.Server, time.Second, bg.tick)
+	}
+}
+
+// announce announces the opening at the announcement marks, now announces the remaining time without a mark.
+func (bg *Battleground) announce(remaining int, now bool) {
+	mark := 0
+	for _, m := range announcementMarks {
+		if m >= remaining && m < bg.announced && (mark == 0 || m < mark) {
+			mark = m
+		}
+	}
+	if now {
+		mark = remaining
+	} else if mark == 0 {
+		return
+	}
+	bg.announced = mark
+
+	msg := bg.Template.Announcement
+	if msg == "" {
+		msg = "{name} will start in {time}."
+	}
+	msg = strings.NewReplacer("{name}", bg.Template.Name, "{time}", durationText(mark),
+		"{min}", fmt.Sprint(bg.MinLevel), "{max}", fmt.Sprint(bg.MaxLevel)).Replace(msg)
+	makeAnnouncement(msg)
+}
+
+// checkQueue starts the ready check when every team of the queue is full, an empty queue is closed.
+func (bg *Battleground) checkQueue(now time.Time) {
+	bg.mutex.RLock()
+	if len(bg.members) == 0 {
+		bg.mutex.RUnlock()
+		bg.close()
+		return
+	}
+	full := true
+	for faction := range bg.Teams {
+		if bg.teamCount(faction, false) < bg.Template.TeamSize {
+			full = false
+		}
+	}
+	bg.mutex.RUnlock()
+	if !full || mapBusy(bg) {
+		return
+	}
+
+	bg.State = BG_READY_CHECK
+	bg.readyUntil = now.Add(time.Duration(bg.Template.ReadyCheck) * time.Second)
+
+	bg.mutex.RLock()
+	defer bg.mutex.RUnlock()
+	for _, m := range bg.members {
+		m.Accepted = bg.Template.ReadyCheck <= 0
+		if !m.Accepted && m.Character.IsOnline && m.Character.Socket != nil {
+			m.Character.Socket.Write(messaging.InfoMessage(fmt.Sprintf("%s is ready. /accept war", bg.Template.Name)))
+		}
+	}
+}
+
+// checkReady enters the battleground when everybody accepted, the ones who did not accept in time are removed.
+func (bg *Battleground) checkReady(now time.Time) {
+	bg.mutex.RLock()
+	ready := true
+	for faction := range bg.Teams {
+		if bg.teamCount(faction, true) < bg.Template.TeamSize {
+			ready = false
+		}
+	}
+	bg.mutex.RUnlock()
+
+	if ready {
+		bg.State = BG_OPEN
+		bg.StartsAt = now.Add(time.Duration(bg.Template.Prepare) * time.Second)
+		bg.mutex.RLock()
+		members := []*Character{}
+		for _, m := range bg.members {
+			members = append(members, m.Character)
+		}
+		bg.mutex.RUnlock()
+
+		for _, c := range members {
+			if data, err := bg.enter(c); err == nil && c.Socket != nil {
+				c.Socket.Write(data)
+			}
+		}
+		return
+	}
+
+	if now.Before(bg.readyUntil) {
+		return
+	}
+
+	bg.mutex.Lock()
+	for id, m := range bg.members {
+		if !m.Accepted || !m.Character.IsOnline {
+			delete(bg.members, id)
+			if m.Character.BattlegroundID == bg.ID {
+				m.Character.BattlegroundID = 0
+			}
+			if m.Character.Socket != nil {
+				m.Character.Socket.Write(messaging.InfoMessage("You missed the ready check and left the queue."))
+			}
+		}
+	}
+	bg.mutex.Unlock()
+	bg.State = BG_LOBBY
+}
+
+func (bg *Battleground) start(now time.Time) {
+	bg.State = BG_RUNNING
+	bg.EndsAt = now.Add(time.Duration(bg.Template.Duration) * time.Second)
+	if bg.Announcer {
+		makeAnnouncement(fmt.Sprintf("%s has started.", bg.Template.Name))
+	}
+
+	switch bg.Template.Panel {
+	case BG_PANEL_WAR:
+		resp := utils.Packet(append([]byte{}, START_WAR...))
+		resp.Overwrite(utils.IntToBytes(uint64(len(bg.teamMembers(1))), 4, false), 8)
+		resp.Overwrite(utils.IntToBytes(uint64(len(bg.teamMembers(2))), 4, false), 22)
+		bg.broadcast(resp)
+	case BG_PANEL_FACTION:
+		bg.broadcast(bg.factionPanel(FACTION_WAR_START, 1))
+	}
+}
+
+// captureStones moves the value of the stones towards the team with more members near it.
+func (bg *Battleground) captureStones(now time.Time) {
+	bg.mutex.Lock()
+	defer bg.mutex.Unlock()
+
+	for _, s := range bg.Stones {
+		count := map[int]int{}
+		for id, seen := range s.near {
+			m := bg.members[id]
+			if m == nil || now.Sub(seen) > STONE_NEAR_TIME {
+				delete(s.near, id)
+				continue
+			}
+			count[m.Faction]++
+		}
+
+		switch {
+		case count[1] > count[2]:
+			if s.ConqueredID == 2 {
+				s.ConqueredID = 0
+			}
+			if s.ConquereValue > 0 {
+				s.ConquereValue--
+			}
+			if s.ConquereValue <= STONE_CAPTURE {
+				s.ConqueredID = 1
+			}
+		case count[2] > count[1]:
+			if s.ConqueredID == 1 {
+				s.ConqueredID = 0
+			}
+			if s.ConquereValue < 2*STONE_VALUE {
+				s.ConquereValue++
+			}
+			if s.ConquereValue >= 2*STONE_VALUE-STONE_CAPTURE {
+				s.ConqueredID = 2
+			}
+		}
+	}
+}
+
+// score gives the points of the interval and sends the score panel.
+func (bg *Battleground) score() {
+	t := bg.Template
+	remaining := int(time.Until(bg.EndsAt).Seconds())
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	var panel []byte
+	switch t.Panel {
+	case BG_PANEL_WAR:
+		panel = bg.warPanel(remaining)
+	case BG_PANEL_FACTION:
+		panel = bg.factionPanel(FACTION_WAR_UPDATE, 0)
+		if len(panel) > 0 {
+			resp := utils.Packet(panel)
+			resp.Overwrite(utils.IntToBytes(uint64(remaining), 4, true), 34) // time
+		}
+	}
+
+	counts := map[int]int{}
+	for faction := range bg.Teams {
+		counts[faction] = len(bg.teamMembers(faction))
+	}
+
+	bg.mutex.Lock()
+	for faction := range bg.Teams {
+		bg.addScore(faction, counts[faction]*t.MemberScore, nil)
+	}
+	for _, s := range bg.Stones {
+		if s.ConqueredID == 0 {
+			continue
+		}
+		for faction := range bg.Teams {
+			if faction != s.ConqueredID {
+				bg.addScore(faction, -t.StoneScore, nil)
+			}
+		}
+	}
+	bg.mutex.Unlock()
+
+	if len(panel) > 0 {
+		bg.broadcast(panel)
+	}
+}
+
+func (bg *Battleground) finished(now time.Time) bool {
+	if !now.Before(bg.EndsAt) {
+		return true
+	}
+
+	if bg.Template.EndAtZero {
+		bg.mutex.RLock()
+		defer bg.mutex.RUnlock()
+		for _, team := range bg.Teams {
+			if team.Score <= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// winner returns the faction with the most points, 0 on a draw.
+func (bg *Battleground) winner() int {
+	bg.mutex.RLock()
+	defer bg.mutex.RUnlock()
+
+	winner, best, draw := 0, 0, false
+	for faction, team := range bg.Teams {
+		if winner == 0 || team.Score > best {
+			winner, best, draw = faction, team.Score, false
+		} else if team.Score == best {
+			draw = true
+		}
+	}
+	if draw {
+		return 0
+	}
+	return winner
+}
+
+// end gives the rewards and sends everybody out after the exit delay.
+func (bg *Battleground) end(now time.Time) {
+	bg.State = BG_ENDED
+	bg.closeAt = now.Add(time.Duration(bg.Template.ExitDelay) * time.Second)
+
+	winner := bg.winner()
+	members := bg.inside()
+	if bg.Template.Panel == BG_PANEL_WAR {
+		bg.broadcast(bg.scorePanel(winner, members))
+	}
+
+	if r := bg.Template.Rewards; r != nil {
+		for _, m := range members {
+			items := r.Loser
+			if m.Faction == winner {
+				items = r.Winner
+			}
+			if data := giveRewardItems(m.Character, items); len(data) > 0 {
+				m.Character.Socket.Write(data)
+			}
+		}
+	}
+
+	if len(members) > 0 {
+		if team := bg.Teams[winner]; team != nil {
+			bg.broadcast(messaging.InfoMessage(fmt.Sprintf("%s won the %s!", team.Name, bg.Template.Name)))
+		} else {
+			bg.broadcast(messaging.InfoMessage(fmt.Sprintf("The %s ended in a draw.", bg.Template.Name)))
+		}
+	}
+}
+
+// close sends the members out and removes the battleground.
+func (bg *Battleground) close() {
+	for _, m := range bg.inside() {
+		c := m.Character
+		bg.remove(c)
+		if data, err := bg.sendOut(c); err == nil {
+			c.Socket.Write(data)
+		}
+	}
+
+	bg.mutex.Lock()
+	for id, m := range bg.members {
+		if m.Character.BattlegroundID == bg.ID {
+			m.Character.BattlegroundID = 0
+		}
+		delete(bg.members, id)
+	}
+	bg.State = BG_CLOSED
+	bg.mutex.Unlock()
+
+	bgMutex.Lock()
+	delete(Battlegrounds, bg.ID)
+	bgMutex.Unlock()
+}
+
+// StopBattleground ends the battleground now.
+func StopBattleground(id int) error {
+	bg := FindBattleground(id)
+	if bg == nil {
+		return fmt.Errorf("unknown battleground %d", id)
+	}
+
+	switch bg.State {
+	case BG_RUNNING:
+		bg.EndsAt = time.Now()
+	case BG_ENDED:
+		bg.closeAt = time.Now()
+	default:
+		bg.State = BG_ENDED
+		bg.closeAt = time.Now()
+	}
+	return nil
+}
+
+// StopBattlegrounds ends the battlegrounds of the template on every server, the ones already ended are left
+// to close.
+func StopBattlegrounds(templateID int) {
+	bgMutex.RLock()
+	ids := []int{}
+	for _, bg := range sortedBattlegrounds() {
+		if bg.Template.ID == templateID && bg.State < BG_ENDED {
+			ids = append(ids, bg.ID)
+		}
+	}
+	bgMutex.RUnlock()
+
+	for _, id := range ids {
+		if err := StopBattleground(id); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// warPanel is the timer and score bar of the Great War, Zhuang are faction 1 and Shao faction 2.
+func (bg *Battleground) warPanel(remaining int) []byte {
+
+	zhuang, shao := bg.teamMembers(1), bg.teamMembers(2)
+
+	bg.mutex.RLock()
+	defer bg.mutex.RUnlock()
+
+	stones := map[int][]int{}
+	for _, s := range bg.Stones {
+		if s.ConqueredID != 0 {
+			stones[s.ConqueredID] = append(stones[s.ConqueredID], s.NpcID)
+		}
+	}
+	for _, ids := range stones {
+		sort.Ints(ids)
+	}
+
+	score := func(faction int) int {
+		if team := bg.Teams[faction]; team != nil && team.Score > 0 {
+			return team.Score
+		}
+		return 0
+	}
+
+	index := 7
+	resp := TIMER_MENU
+	resp.Insert(utils.IntToBytes(uint64(len(zhuang)), 4, true), index)
+	index += 4
+	resp.Insert(utils.IntToBytes(uint64(score(1)), 4, true), index)
+	index += 4
+	resp.Insert([]byte{0x00, 0x00, 0x00, 0x00}, index)
+	index += 4
+	if len(stones[1]) > 0 {
+		resp.Insert(utils.IntToBytes(uint64(len(stones[1])), 1, false), index)
+		index++
+		for _, id := range stones[1] {
+			resp.Insert(utils.IntToBytes(uint64(id), 4, true), index)
+			index += 4
+		}
+		resp.Insert([]byte{0x00}, index)
+		index++
+	} else {
+		resp.Insert([]byte{0x00, 0x00}, index)
+		index += 2
+	}
+	resp.Insert(utils.IntToBytes(uint64(len(shao)), 4, true), index)
+	index += 4
+	resp.Insert(utils.IntToBytes(uint64(score(2)), 4, true), index)
+	index += 4
+	resp.Insert([]byte{0x00, 0x00, 0x00, 0x00}, index)
+	index += 4
+	if len(stones[2]) > 0 {
+		resp.Insert(utils.IntToBytes(uint64(len(stones[2])), 1, false), index)
+		index++
+		for _, id := range stones[2] {
+			resp.Insert(utils.IntToBytes(uint64(id), 4, true), index)
+			index += 4
+		}
+	} else {
+		resp.Insert([]byte{0x00}, index-2)
+		index++
+	}
+	resp.Insert(utils.IntToBytes(uint64(remaining), 4, true), index)
+	index += 4
+
+	resp.SetLength(int16(index - 4))
+	return resp
+}
+
+// scorePanel lists the kills and the contribution of the members at the end of the Great War.
+func (bg *Battleground) scorePanel(winner int, members []*BattlegroundMember) []byte {
+
+	resp := WAR_SCOREPANEL
+	index := 6
+	if winner == 1 {
+		resp.Insert([]byte{0x00, 0x28, 0x00}, index)
+	} else {
+		resp.Insert([]byte{0x01, 0x28, 0x00}, index)
+	}
+	index += 3
+
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].Faction < members[j].Faction
+	})
+
+	bg.mutex.RLock()
+	defer bg.mutex.RUnlock()
+	for _, m := range members {
+		c := m.Character
+		resp.Insert(utils.IntToBytes(uint64(len(c.Name)), 1, false), index)
+		index++
+		resp.Insert([]byte(c.Name), index)
+		index += len(c.Name)
+		resp.Insert(utils.IntToBytes(uint64(c.Faction), 1, false), index)
+		index++
+		contribution := m.Contribution
+		if contribution < 0 {
+			contribution = 0
+		}
+		resp.Insert(utils.IntToBytes(uint64(contribution), 2, true), index)
+		index += 2
+		resp.Insert([]byte{0x00, 0x00}, index)
+		index += 2
+		resp.Insert(utils.IntToBytes(uint64(m.Kills), 2, true), index)
+		index += 2
+		resp.Insert([]byte{0x00, 0x00}, index)
+		index += 2
+	}
+
+	resp.SetLength(int16(index - 4))
+	return resp
+}
+
+// factionPanel fills the member counts and points of the Faction War bar, offset is 1 for the start packet.
+func (bg *Battleground) factionPanel(packet utils.Packet, offset int) []byte {
+
+	zhuang, shao := len(bg.teamMembers(1)), len(bg.teamMembers(2))
+
+	bg.mutex.RLock()
+	defer bg.mutex.RUnlock()
+
+	points := func(faction int) int {
+		if team := bg.Teams[faction]; team != nil && team.Score > 0 {
+			return team.Score
+		}
+		return 0
+	}
+
+	// the battlegrounds run at the same time, so they cannot overwrite the shared packet
+	resp := utils.Packet(append([]byte{}, packet...))
+	resp.Overwrite(utils.IntToBytes(uint64(zhuang), 4, true), 7+offset)     // Zhuang numbers
+	resp.Overwrite(utils.IntToBytes(uint64(points(1)), 4, true), 11+offset) // Zhuang points
+	resp.Overwrite(utils.IntToBytes(uint64(shao), 4, true), 21+offset)      // Shao numbers
+	resp.Overwrite(utils.IntToBytes(uint64(points(2)), 4, true), 25+offset) // Shao points
+	resp.Overwrite(utils.IntToBytes(uint64(bg.Template.Duration), 4, true), 34+offset)
+	return resp
+}
+
+func makeAnnouncement(msg string) {
+	length := int16(len(msg) + 3)
+
+	resp := ANNOUNCEMENT
+	resp.SetLength(length)
+	resp[6] = byte(len(msg))
+	resp.Insert([]byte(msg), 7)
+
+	p := nats.CastPacket{CastNear: false, Data: resp}
+	p.Cast()
+}
+
+// BattlegroundLines describes the battlegrounds.
+func BattlegroundLines() []string {
+	bgMutex.RLock()
+	battlegrounds := sortedBattlegrounds()
+	bgMutex.RUnlock()
+
+	states := []string{"queue", "ready check", "open", "running", "ended", "closed"}
+	lines := []string{}
+	for _, bg := range battlegrounds {
+		bg.mutex.RLock()
+		scores := []string{}
+		for _, team := range bg.Template.Teams {
+			scores = append(scores, fmt.Sprintf("%s %d", team.Name, bg.Teams[team.Faction].Score))
+		}
+		members := len(bg.members)
+		bg.mutex.RUnlock()
+
+		lines = append(lines, fmt.Sprintf("Battleground %d: %s, server %d, %s, %d players, %s",
+			bg.ID, bg.Template.Name, bg.Server, states[bg.State], members, strings.Join(scores, " - ")))
+	}
+	return lines
+}
+
+func sortedBattlegrounds() []*Battleground {
+	battlegrounds := make([]*Battleground, 0, len(Battlegrounds))
+	for _, bg := range Battlegrounds {
+		battlegrounds = append(battlegrounds, bg)
+	}
+	sort.Slice(battlegrounds, func(i, j int) bool {
+		return battlegrounds[i].ID < battlegrounds[j].ID
+	})
+	return battlegrounds
+}
@@ -114,21 +114,3 @@ func getBuffInfections() error {
 
 	return nil
 }
-
-func RefreshBuffInfections() error {
-	var buffInfections []*BuffInfection
-	query := `select * from data.buff_infections`
-
-	if _, err := db.Select(&buffInfections, query); err != nil {
-		if err == sql.ErrNoRows {
-			return nil
-		}
-		return fmt.Errorf("getBuffInfections: %s", err.Error())
-	}
-
-	for _, b := range buffInfections {
-		BuffInfections[b.ID] = b
-	}
-
-	return nil
-}
@@ -56,8 +56,6 @@ var (
 	characterMutex        sync.RWMutex
 	GenerateID            func(*Character) error
 	GeneratePetID         func(*Character, *PetSlot)
-	challengerGuild       = &Guild{}
-	enemyGuild            = &Guild{}
 	Beast_King_Infections = []int16{277, 307, 368, 283, 319, 382, 291, 333, 398, 297, 351, 418}
 	Empress_Infections    = []int16{280, 313, 375, 287, 326, 390, 294, 342, 408, 302, 359, 429}
 	DEAL_DAMAGE           = utils.Packet{0xAA, 0x55, 0x18, 0x00, 0x16, 0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x55, 0xAA}
@@ -198,6 +196,7 @@ type Character struct {
 	AddingExp                sync.Mutex `db:"-" json:"-"`
 	AddingGold               sync.Mutex `db:"-" json:"-"`
 	Looting                  sync.Mutex `db:"-" json:"-"`
+	QuestMutex               sync.Mutex `db:"-" json:"-"`
 	AdditionalRunningSpeed   float64    `db:"-" json:"-"`
 	InvMutex                 sync.Mutex `db:"-"`
 	Socket                   *Socket    `db:"-" json:"-"`
@@ -231,18 +230,12 @@ type Character struct {
 	VisitedSaleID   uint16          `db:"-" json:"-"`
 	DuelID          int             `db:"-" json:"-"`
 	DuelStarted     bool            `db:"-" json:"-"`
-	IsinWar         bool            `db:"-" json:"-"`
-	WarKillCount    int             `db:"-" json:"-"`
-	WarContribution int             `db:"-" json:"-"`
-	IsAcceptedWar   bool            `db:"-" json:"-"`
+	BattlegroundID  int             `db:"-" json:"-"`
 	Respawning      bool            `db:"-" json:"-"`
 	SkillHistory    utils.SMap      `db:"-" json:"-"`
 	Morphed         bool            `db:"-" json:"-"`
-	IsDungeon       bool            `db:"-" json:"-"`
-	DungeonLevel    int16           `db:"-" json:"-"`
-	CanTip          int16           `db:"-" json:"-"`
+	InstanceID      int             `db:"-" json:"-"`
 	PacketSended    bool            `db:"-" json:"-"`
-	GeneratedNumber int             `db:"-" json:"-"`
 	HandlerCB       func()          `db:"-"`
 	PetHandlerCB    func()          `db:"-"`
 
@@ -559,7 +552,8 @@ func (c *Character) GetEquipedItemSlots() []int {
 func (c *Character) Logout() {
 	c.IsOnline = false
 	c.IsActive = false
-	c.IsDungeon = false
+	c.InstanceID = 0
+	c.BattlegroundID = 0
 	c.OnSight.Drops = map[int]interface{}{}
 	c.OnSight.Mobs = map[int]interface{}{}
 	c.OnSight.NPCs = map[int]interface{}{}
@@ -584,12 +578,18 @@ func (c *Character) Logout() {
 	if c.GuildID > 0 {
 		guild, err := FindGuildByID(c.GuildID)
 		if err == nil && guild != nil {
+			guild.SetOnline(c, false)
 			guild.InformMembers(c)
 		}
 	}
 
+	NotifyFriends(c, false)
+	DeleteRelationsFromCache(c.ID)
+	DeleteChatRatesFromCache(c.ID)
+	UnsubscribeWhispers(c)
 	RemoveFromRegister(c)
 	RemovePetFromRegister(c)
+	DeleteQuestsFromCache(c.ID)
 	//DeleteCharacterFromCache(c.ID)
 	//DeleteStatFromCache(c.ID)
 }
@@ -636,7 +636,7 @@ func (c *Character) GetNearbyCharacters() ([]*Character, error) {
 
 		characterCoordinate := ConvertPointToLocation(character.Coordinate)
 
-		return character.IsOnline && user.ConnectedServer == u.ConnectedServer && character.Map == c.Map &&
+		return character.IsOnline && user.ConnectedServer == u.ConnectedServer && character.Map == c.Map && character.InstanceID == c.InstanceID &&
 			(!character.Invisible || c.DetectionMode) && utils.CalculateDistance(characterCoordinate, myCoordinate) <= distance
 	}).([]*Character)
 
@@ -649,10 +649,10 @@ func (c *Character) GetNearbyAIIDs() ([]int, error) {
 		distance = 64.0
 		ids      []int
 	)
-	if funk.Contains(DungeonZones, c.Map) {
+	if funk.Contains(DungeonZones, c.Map) || c.InstanceID != 0 {
 		distance = 150.0
 	}
-	if c.IsinWar {
+	if c.BattlegroundID != 0 {
 		distance = 25.0
 	}
 
@@ -663,7 +663,14 @@ func (c *Character) GetNearbyAIIDs() ([]int, error) {
 		return nil, nil
 	}
 
-	candidates := AIsByMap[user.ConnectedServer][c.Map]
+	candidates := MapAIs(user.ConnectedServer, c.Map)
+	if c.InstanceID != 0 {
+		candidates = nil
+		if inst := FindInstance(c.InstanceID); inst != nil {
+			candidates = inst.MobsOn(c.Map)
+		}
+	}
+
 	filtered := funk.Filter(candidates, func(ai *AI) bool {
 
 		characterCoordinate := ConvertPointToLocation(c.Coordinate)
@@ -730,7 +737,7 @@ func (c *Character) GetNearbyDrops() ([]int, error) {
 
 		characterCoordinate := ConvertPointToLocation(c.Coordinate)
 
-		return utils.CalculateDistance(characterCoordinate, &drop.Location) <= distance
+		return drop.InstanceID == c.InstanceID && utils.CalculateDistance(characterCoordinate, &drop.Location) <= distance
 	})
 
 	for _, d := range filtered.([]*Drop) {
@@ -1138,30 +1145,28 @@ func (c *Character) DecrementItem(slotID int16, amount uint) *utils.Packet {
 
 func (c *Character) FindFreeSlot() (int16, error) {
 
-	slotID := 11
 	slots, err := c.InventorySlots()
 	if err != nil {
 		return -1, err
 	}
 
-	for ; slotID <= 66; slotID++ {
-		slot := slots[slotID]
-		if slot.ItemID == 0 {
-			return int16(slotID), nil
-		}
+	if slotID := firstFreeSlot(slots, 11, 66); slotID != -1 {
+		return slotID, nil
+	} else if c.DoesInventoryExpanded() {
+		return firstFreeSlot(slots, 341, 396), nil
 	}
 
-	if c.DoesInventoryExpanded() {
-		slotID = 341
-		for ; slotID <= 396; slotID++ {
-			slot := slots[slotID]
-			if slot.ItemID == 0 {
-				return int16(slotID), nil
-			}
+	return -1, nil
+}
+
+// firstFreeSlot returns the first empty slot between from and to, -1 if they are all taken.
+func firstFreeSlot(slots []*InventorySlot, from, to int16) int16 {
+	for slotID := from; slotID <= to && int(slotID) < len(slots); slotID++ {
+		if slots[slotID].ItemID == 0 {
+			return slotID
 		}
 	}
-
-	return -1, nil
+	return -1
 }
 
 func (c *Character) FindFreeSlots(count int) ([]int16, error) {
@@ -1352,6 +1357,7 @@ func (c *Character) AddItem(itemToAdd *InventorySlot, slotID int16, lootingDrop
 
 	InventoryItems.Add(slot.ID, slot)
 	resp.Concat(slot.GetData(slotID))
+	resp.Concat(c.QuestProgress(QUEST_OBJECTIVE_COLLECT, int(slot.ItemID), 0))
 	return &resp, slotID, nil
 }
 
@@ -1811,16 +1817,10 @@ func (c *Character) Handler() {
 		c.UpdatePartyStatus()
 	}
 
-	c.HandleBuffs()
 	c.HandleLimitedItems()
 
 	go c.Update()
 	go st.Update()
-	time.AfterFunc(time.Second, func() {
-		if c.HandlerCB != nil {
-			c.HandlerCB()
-		}
-	})
 }
 
 func (c *Character) PetHandler() {
@@ -1904,7 +1904,6 @@ func (c *Character) PetHandler() {
 			pet.Target = 0
 		}
 
-	BEGIN:
 		if pet.PetCombatMode == 2 {
 			pet.Target = c.Selection
 		}
@@ -1914,29 +1913,20 @@ func (c *Character) PetHandler() {
 			distance := utils.CalculateDistance(ownerPos, &pet.Coordinate)
 
 			if distance > 10 { // Pet is so far from his owner
-				pet.IsMoving = true
-				targetX := utils.RandFloat(ownerPos.X-5, ownerPos.X+5)
-				targetY := utils.RandFloat(ownerPos.Y-5, ownerPos.Y+5)
-
-				target := utils.Location{X: targetX, Y: targetY}
-				pet.TargetLocation = target
+				minLocation := &utils.Location{X: ownerPos.X - 5, Y: ownerPos.Y - 5}
+				maxLocation := &utils.Location{X: ownerPos.X + 5, Y: ownerPos.Y + 5}
+				target := RandomWalkablePoint(c.Map, minLocation, maxLocation)
 				speed := float64(10.0)
 
-				token := pet.MovementToken
-				for token == pet.MovementToken {
-					pet.MovementToken = utils.RandInt(1, math.MaxInt64)
-				}
-
-				go pet.MovementHandler(pet.MovementToken, &pet.Coordinate, &target, speed)
+				pet.moveTo(c.Map, target, speed)
 			}
 
 		} else { // Target mode
 			if pet.PetCombatMode == 2 {
 				mob := FindCharacterByPseudoID(c.Socket.User.ConnectedServer, uint16(pet.Target))
-				if mob.Socket.Stats.HP <= 0 || !c.CanAttack(mob) {
+				if mob == nil || mob.Socket == nil || mob.Socket.Stats.HP <= 0 || !c.CanAttack(mob) {
 					pet.Target = 0
-					time.Sleep(time.Second)
-					goto BEGIN
+					goto OUT // the pet goes on on the next tick, the tick must not wait
 				}
 				aiCoordinate := ConvertPointToLocation(mob.Coordinate)
 				distance := utils.CalculateDistance(&pet.Coordinate, aiCoordinate)
@@ -1956,17 +1946,10 @@ func (c *Character) PetHandler() {
 					pet.LastHit++
 
 				} else if distance > 3 && distance <= 50 { // chase
-					pet.IsMoving = true
-					target := GeneratePoint(aiCoordinate)
-					pet.TargetLocation = target
+					target := GeneratePoint(c.Map, aiCoordinate)
 					speed := float64(10.0)
 
-					token := pet.MovementToken
-					for token == pet.MovementToken {
-						pet.MovementToken = utils.RandInt(1, math.MaxInt64)
-					}
-
-					go pet.MovementHandler(pet.MovementToken, &pet.Coordinate, &target, speed)
+					pet.moveTo(c.Map, target, speed)
 					pet.LastHit = 0
 
 				} else {
@@ -1980,8 +1963,7 @@ func (c *Character) PetHandler() {
 
 				} else if mob.HP <= 0 {
 					pet.Target = 0
-					time.Sleep(time.Second)
-					goto BEGIN
+					goto OUT // the pet goes on on the next tick, the tick must not wait
 				}
 				aiCoordinate := ConvertPointToLocation(mob.Coordinate)
 				distance := utils.CalculateDistance(&pet.Coordinate, aiCoordinate)
@@ -2001,17 +1983,10 @@ func (c *Character) PetHandler() {
 					pet.LastHit++
 
 				} else if distance > 3 && distance <= 50 { // chase
-					pet.IsMoving = true
-					target := GeneratePoint(aiCoordinate)
-					pet.TargetLocation = target
+					target := GeneratePoint(c.Map, aiCoordinate)
 					speed := float64(10.0)
 
-					token := pet.MovementToken
-					for token == pet.MovementToken {
-						pet.MovementToken = utils.RandInt(1, math.MaxInt64)
-					}
-
-					go pet.MovementHandler(pet.MovementToken, &pet.Coordinate, &target, speed)
+					pet.moveTo(c.Map, target, speed)
 					pet.LastHit = 0
 
 				} else {
@@ -2021,15 +1996,10 @@ func (c *Character) PetHandler() {
 
 		}
 
-		petSlot.Update()
+		go petSlot.Update()
 	}
 
 OUT:
-	time.AfterFunc(time.Second, func() {
-		if c.PetHandlerCB != nil {
-			c.PetHandlerCB()
-		}
-	})
 }
 
 func (c *Character) HandleBuffs() {
@@ -2684,6 +2654,20 @@ func (c *Character) LootGold(amount uint64) []byte {
 	return resp
 }
 
+// SpendGold takes the amount from the gold of the character if it has enough, the check and the subtraction are made
+// under the gold lock so parallel spends cannot take more than the character has.
+func (c *Character) SpendGold(amount uint64) bool {
+
+	c.AddingGold.Lock()
+	defer c.AddingGold.Unlock()
+
+	if c.Gold < amount {
+		return false
+	}
+	c.Gold -= amount
+	return true
+}
+
 func (c *Character) AddExp(amount int64) ([]byte, bool) {
 
 	c.AddingExp.Lock()
@@ -2862,23 +2846,7 @@ func (c *Character) ChangeMap(mapID int16, coordinate *utils.Location, args ...i
 	resp, r := MAP_CHANGED, utils.Packet{}
 	c.Map = mapID
 	c.EndPvP()
-	if !c.IsinWar {
-		if coordinate == nil { // if no coordinate then teleport home
-			d := SavePoints[uint8(mapID)]
-			if d == nil {
-				d = &SavePoint{Point: "(100.0,100.0)"}
-			}
-			coordinate = ConvertPointToLocation(d.Point)
-		}
-	} else {
-		if c.Faction == 1 && c.Map != 230 {
-			delete(OrderCharacters, c.ID)
-			c.IsinWar = false
-		} else if c.Faction == 2 && c.Map != 230 {
-			delete(ShaoCharacters, c.ID)
-			c.IsinWar = false
-		}
-	}
+	FindBattleground(c.BattlegroundID).Left(c, mapID)
 	if coordinate == nil { // if no coordinate then teleport home
 		d := SavePoints[uint8(mapID)]
 		if d == nil {
@@ -4185,8 +4153,16 @@ func (c *Character) BuyConsignmentItem(consignmentID int) ([]byte, error) {
 	}
 
 	logger.Log(logging.ACTION_BUY_CONS_ITEM, c.ID, fmt.Sprintf("Bought consignment item (%d) with %d gold from (%d)", newItem.ID, consignmentItem.Price, seller.ID), c.UserID)
-	consignmentItem.IsSold = true
-	go consignmentItem.Update()
+	consignmentItem.Price -= CollectTerritoryTax(c, consignmentItem.Price) // the seller gets the price after the tax
+	text := fmt.Sprintf("%s was sold for %d gold.", consignmentItem.ItemName, consignmentItem.Price)
+	if err := SendSystemMail(seller.ID, "Consignment", text, consignmentItem.Price, nil); err != nil {
+		log.Println(err)
+		consignmentItem.IsSold = true // the seller claims it at the consignment
+		go consignmentItem.Update()
+		return resp, nil
+	}
+
+	go consignmentItem.Delete()
 	return resp, nil
 }
 
@@ -4629,8 +4605,10 @@ func (c *Character) UseConsumable(item *InventorySlot, slotID int16) ([]byte, er
 			chiRec = 50000
 		}
 
+		healed := stat.HP
 		stat.HP = int(math.Min(float64(stat.HP+hpRec), float64(stat.MaxHP)))
 		stat.CHI = int(math.Min(float64(stat.CHI+chiRec), float64(stat.MaxCHI)))
+		AddHealThreat(c, c, stat.HP-healed)
 		resp.Concat(c.GetHPandChi())
 
 	case FILLER_POTION_TYPE:
@@ -4646,6 +4624,7 @@ func (c *Character) UseConsumable(item *InventorySlot, slotID int16) ([]byte, er
 
 		stat.HP = int(math.Min(float64(stat.HP)+hpRecovery, float64(stat.MaxHP)))
 		stat.CHI = int(math.Min(float64(stat.CHI)+chiRecovery, float64(stat.MaxCHI)))
+		AddHealThreat(c, c, int(hpRecovery))
 		resp.Concat(c.GetHPandChi())
 		resp.Concat(*c.DecrementItem(slotID, uint(hpRecovery+chiRecovery)))
 		resp.Concat(item.GetData(slotID))
@@ -5530,7 +5509,7 @@ func (c *Character) CastSkill(attackCounter, skillID, targetID int, cX, cY, cZ f
 					}
 				}
 				castRange := skillInfo.BaseRadius + skillInfo.AdditionalRadius*float64(plus+0) + (float64(plusRange) * float64(divinePlus))
-				candidates := AIsByMap[ai.Server][ai.Map]
+				candidates := MapAIs(ai.Server, ai.Map)
 
 				candidates = funk.Filter(candidates, func(cand *AI) bool {
 					nPos := NPCPos[cand.PosID]
@@ -6264,7 +6243,6 @@ func (c *Character) TogglePet() []byte {
 			GeneratePetID(c, pet)
 			pet.PetCombatMode = 0
 			c.PetHandlerCB = c.PetHandler
-			go c.PetHandlerCB()
 
 			resp := utils.Packet{
 				0xAA, 0x55, 0x0B, 0x00, 0x75, 0x00, 0x01, 0x00, 0x80, 0xA1, 0x43, 0x00, 0x00, 0x3D, 0x43, 0x55, 0xAA,
@@ -6323,7 +6301,6 @@ func (c *Character) ToggleMountPet() []byte {
 			GeneratePetID(c, pet)
 			pet.PetCombatMode = 0
 			c.PetHandlerCB = c.PetHandler
-			go c.PetHandlerCB()
 
 			resp := utils.Packet{
 				0xAA, 0x55, 0x0B, 0x00, 0x75, 0x00, 0x01, 0x00, 0x80, 0xA1, 0x43, 0x00, 0x00, 0x3D, 0x43, 0x55, 0xAA,
@@ -6435,6 +6412,10 @@ func (c *Character) DealDamage(ai *AI, dmg int) {
 		ai.DamageDealers.Add(c.ID, d)
 	}
 
+	if inst := FindInstance(ai.InstanceID); inst != nil {
+		inst.recordDamage(c, dmg, dmg > 0 && ai.HP <= 0)
+	}
+
 	if c.Invisible {
 		buff, _ := FindBuffByID(241, c.ID)
 		if buff != nil {
@@ -6477,57 +6458,14 @@ func (c *Character) DealDamage(ai *AI, dmg int) {
 	if !npcPos.Attackable {
 		go ai.DropHandler(c)
 	}
-	if c.Map == 255 && IsFactionWarStarted() { //faction war
-		if c.Faction == 1 {
-			if npcPos.NPCID == 425506 {
-				AddPointsToFactionWarFaction(5, 1)
-			}
-			if npcPos.NPCID == 425505 {
-				AddPointsToFactionWarFaction(50, 1)
-			}
-			if npcPos.NPCID == 425507 {
-				AddPointsToFactionWarFaction(7, 1)
-			}
-			if npcPos.NPCID == 425508 {
-				AddPointsToFactionWarFaction(500, 1)
-			}
-		}
-		if c.Faction == 2 {
-			if npcPos.NPCID == 425501 {
-				AddPointsToFactionWarFaction(5, 2)
-			}
-			if npcPos.NPCID == 425502 {
-				AddPointsToFactionWarFaction(50, 2)
-			}
-			if npcPos.NPCID == 425503 {
-				AddPointsToFactionWarFaction(7, 2)
-			}
-			if npcPos.NPCID == 425504 {
-				AddPointsToFactionWarFaction(500, 2)
-			}
-		}
-	}
+	FindBattleground(c.BattlegroundID).MobDamaged(c, npcPos.NPCID, ai.HP <= 0)
 
 	if ai.HP <= 0 { // ai died
 		DeleteBuffsByAiPseudoID(ai.PseudoID)
 		if ai.Once {
 			ai.Handler = nil
-			if funk.Contains(DungeonZones, ai.Map) {
-				//	for i, action := range DungeonsByMap[ai.Server][ai.Map] {
-				//if action.ID == ai.ID {
-				//s2 := RemoveIndex(DungeonsByMap[ai.Server][ai.Map], i)
-				//DungeonsByMap[ai.Server][ai.Map] = s2
-				fmt.Println("Mobs: ", DungeonsByMap[c.Socket.User.ConnectedServer][c.Map])
-				DungeonsByMap[c.Socket.User.ConnectedServer][c.Map]--
-				/*mobsCount := DungeonsByMap[c.Socket.User.ConnectedServer][c.Map]
-				if DungeonsByMap[c.Socket.User.ConnectedServer][c.Map] <= 10 {
-					s.Conn.Write(messaging.InfoMessage(fmt.Sprintf("%d mobs remaining", mobsCount)))
-				}*/
-				//}
-				//}
-			}
 		} else {
-			time.AfterFunc(time.Duration(npcPos.RespawnTime)*time.Second/2, func() { // respawn mob n secs later
+			AfterTicks(ai.Server, time.Duration(npcPos.RespawnTime)*time.Second/2, func() { // respawn mob n secs later
 				curCoordinate := ConvertPointToLocation(ai.Coordinate)
 				minCoordinate := ConvertPointToLocation(npcPos.MinLocation)
 				maxCoordinate := ConvertPointToLocation(npcPos.MaxLocation)
@@ -6539,6 +6477,10 @@ func (c *Character) DealDamage(ai *AI, dmg int) {
 				Y = (Y / 3) + 2*curCoordinate.Y/3
 
 				coordinate := &utils.Location{X: X, Y: Y}
+				if !IsWalkable(ai.Map, coordinate) {
+					point := RandomWalkablePoint(ai.Map, minCoordinate, maxCoordinate)
+					coordinate = &point
+				}
 				ai.TargetLocation = *coordinate
 				ai.SetCoordinate(coordinate)
 
@@ -6546,77 +6488,26 @@ func (c *Character) DealDamage(ai *AI, dmg int) {
 				ai.IsDead = false
 			})
 		}
-		if npc.ID == 424201 && WarStarted {
-			OrderPoints -= 200
-		} else if npc.ID == 424202 && WarStarted {
-			ShaoPoints -= 200
-		}
-		exp := int64(0)
-		if c.Level <= 100 {
-			exp = npc.Exp
-		} else if c.Level <= 200 {
-			exp = npc.DivineExp
-		} else {
-			exp = npc.DarknessExp
-		}
-
-		// EXP gained
-		r, levelUp := c.AddExp(exp)
-		if levelUp {
-			statData, err := c.GetStats()
-			if err == nil {
-				s.Conn.Write(statData)
+		// EXP gained by damage share
+		result := ResolveKill(ai.Contributors(c), npc.ExpFor)
+		ai.CompleteEncounter(c, npc)
+		for id, exp := range result.Exp {
+			m, err := FindCharacterByID(id)
+			if err != nil || m == nil || m.Socket == nil || exp <= 0 {
+				continue
 			}
-		}
-		s.Conn.Write(r)
-
-		// EXP gain for party members
-		party := FindParty(c)
-		if party != nil {
-			members := funk.Filter(party.GetMembers(), func(m *PartyMember) bool {
-				return m.Accepted || m.ID == c.ID
-			}).([]*PartyMember)
-			members = append(members, &PartyMember{Character: party.Leader, Accepted: true})
 
-			coordinate := ConvertPointToLocation(c.Coordinate)
-			for _, m := range members {
-				user, err := FindUserByID(m.UserID)
-				if err != nil || user == nil || (c.Level-m.Level) > 20 {
-					continue
-				}
-
-				memberCoordinate := ConvertPointToLocation(m.Coordinate)
-
-				if m.ID == c.ID && !m.Accepted {
-					break
-				}
-
-				if m.ID == c.ID || m.Map != c.Map || s.User.ConnectedServer != user.ConnectedServer ||
-					utils.CalculateDistance(coordinate, memberCoordinate) > 100 || m.Socket.Stats.HP <= 0 {
-					continue
-				}
-
-				exp := int64(0)
-				if m.Level <= 100 {
-					exp = npc.Exp
-				} else if m.Level <= 200 {
-					exp = npc.DivineExp
-				} else {
-					exp = npc.DarknessExp
+			r, levelUp := m.AddExp(exp)
+			if levelUp {
+				statData, err := m.GetStats()
+				if err == nil {
+					m.Socket.Write(statData)
 				}
-
-				exp /= int64(len(members))
-
-				r, levelUp := m.AddExp(exp)
-				if levelUp {
-					statData, err := m.GetStats()
-					if err == nil {
-						m.Socket.Write(statData)
-					}
-				}
-				m.Socket.Write(r)
 			}
+			m.Socket.Write(r)
 		}
+		s.Conn.Write(c.QuestProgress(QUEST_OBJECTIVE_KILL, npc.ID, 1))
+
 		//GIVE 200+ ataraxia ITEM
 		if npc.ID == 43401 {
 			if c.Exp >= 544951059310 && c.Level == 200 {
@@ -6633,48 +6524,9 @@ func (c *Character) DealDamage(ai *AI, dmg int) {
 				s.Conn.Write(messaging.InfoMessage(fmt.Sprintf("You kill the Wyrm, now you can make the transformation.")))
 			}
 		}
-		//60006	Underground(EXP)
-		//60011	Waterfall(EXP)
-		//60016	Forest(EXP)
-		//60021	Sky Garden(EXP)
-		//FIVE ELEMENT CASTLE
 		claimer, err := ai.FindClaimer()
-		if err == nil || claimer != nil {
-			//exptime := time.Now().UTC().Add(time.Hour * 2)
-			if npc.ID == 423308 { //HWARANG GUARDIAN STATUE //SOUTHERN WOOD TEMPLE
-				if claimer.GuildID > 0 {
-					/*					FiveClans[1].ClanID = claimer.GuildID
-										FiveClans[1].ExpiresAt = null.TimeFrom(exptime)
-										FiveClans[1].Update()*/
-					CaptureFive(1, c)
-				}
-			} else if npc.ID == 423310 { //SUGUN GUARDIAN STATUE //LIGHTNING HILL TEMPLE
-				if c.GuildID > 0 {
-					/*FiveClans[2].ClanID = claimer.GuildID
-					FiveClans[2].ExpiresAt = null.TimeFrom(exptime)
-					FiveClans[2].Update()*/
-					CaptureFive(2, c)
-				}
-			} else if npc.ID == 423312 { //CHUNKYUNG GUARDIAN STATUE //OCEAN ARMY TEMPLE
-				if claimer.GuildID > 0 {
-					/*FiveClans[3].ClanID = claimer.GuildID
-					FiveClans[3].ExpiresAt = null.TimeFrom(exptime)
-					FiveClans[3].Update()*/
-					CaptureFive(3, c)
-				}
-			} else if npc.ID == 423314 { //MOKNAM GUARDIAN STATUE //FLAME WOLF TEMPLE
-				if c.GuildID > 0 {
-					/*FiveClans[4].ClanID = claimer.GuildID
-					FiveClans[4].ExpiresAt = null.TimeFrom(exptime)
-					FiveClans[4].Update()*/
-					CaptureFive(4, c)
-				}
-			} else if npc.ID == 423316 { //JISU GUARDIAN STATUE //WESTERN LAND TEMPLE
-				/*FiveClans[5].ClanID = claimer.GuildID
-				FiveClans[5].ExpiresAt = null.TimeFrom(exptime)
-				FiveClans[5].Update()*/
-				CaptureFive(5, c)
-			}
+		if err == nil && claimer != nil {
+			CaptureTerritory(npc.ID, claimer)
 		}
 
 		// PTS gained LOOT
@@ -6686,16 +6538,14 @@ func (c *Character) DealDamage(ai *AI, dmg int) {
 		}
 
 		// Gold dropped
-		goldDrop := int64(npc.GoldDrop)
-		if goldDrop > 0 {
-			amount := uint64(utils.RandInt(goldDrop/2, goldDrop))
+		if amount := RollGold(npc); amount > 0 {
 			r = c.LootGold(amount)
 			s.Conn.Write(r)
 		}
 
 		//Item dropped
 		go func() {
-			claimer, err := ai.FindClaimer()
+			claimer, err := FindCharacterByID(result.LootOwner)
 			if err != nil || claimer == nil {
 				return
 			}
@@ -6709,18 +6559,16 @@ func (c *Character) DealDamage(ai *AI, dmg int) {
 			})
 		}()
 
-		time.AfterFunc(time.Second, func() { // disappear mob 1 sec later
+		AfterTicks(ai.Server, time.Second, func() { // disappear mob 1 sec later
 			ai.TargetPlayerID = 0
 			ai.TargetPetID = 0
+			ai.Threat.Clear()
+			ai.State = AI_STATE_IDLE
+			ai.fled = false
 			ai.IsDead = true
 		})
-	} else if ai.TargetPlayerID == 0 {
-		ai.IsMoving = false
-		ai.MovementToken = 0
-		ai.TargetPlayerID = c.ID
 	} else {
-		ai.IsMoving = false
-		ai.MovementToken = 0
+		ai.OnDamaged(c, dmg)
 	}
 
 }
@@ -6764,7 +6612,8 @@ func (c *Character) StartPvP(timeLeft int) {
 
 func (c *Character) CanAttack(enemy *Character) bool {
 	servers, _ := GetServers()
-	return (c.DuelID == enemy.ID && c.DuelStarted) || funk.Contains(PvPZones, c.Map) || servers[int16(c.Socket.User.ConnectedServer)-1].IsPVPServer
+	return (c.DuelID == enemy.ID && c.DuelStarted) || funk.Contains(PvPZones, c.Map) || servers[int16(c.Socket.User.ConnectedServer)-1].IsPVPServer ||
+		AtGuildWar(c, enemy)
 }
 
 func (c *Character) OnDuelStarted() []byte {
@@ -71,7 +71,10 @@ func FindBuffsByCharacterID(characterID int) ([]*Buff, error) {
 		return nil, fmt.Errorf("FindBuffsByCharacterID: %s", err.Error())
 	}
 
-	sort.Slice(buffs, func(i, j int) bool {
+	sort.Slice(buffs, func(i, j int) bool { // the buffs which cannot expire, like the house perks, come after the others
+		if buffs[i].CanExpire != buffs[j].CanExpire {
+			return buffs[i].CanExpire
+		}
 		return buffs[i].StartedAt+buffs[i].Duration <= buffs[j].StartedAt+buffs[j].Duration
 	})
 
@@ -92,3 +95,34 @@ func FindBuffByID(buffID, characterID int) (*Buff, error) {
 
 	return buff, nil
 }
+
+// GrantBuff applies a buff infection for the given seconds, an existing buff of the same infection is refreshed.
+func (c *Character) GrantBuff(infectionID int, seconds int64) ([]byte, error) {
+
+	infection, ok := BuffInfections[infectionID]
+	if !ok {
+		return nil, fmt.Errorf("GrantBuff: unknown infection %d", infectionID)
+	}
+
+	buff, err := FindBuffByID(infectionID, c.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	isNew := buff == nil
+	buff = &Buff{ID: infection.ID, CharacterID: c.ID, Name: infection.Name, StartedAt: c.Epoch, Duration: seconds, CanExpire: seconds > 0,
+		ATK: infection.BaseATK, ArtsATK: infection.BaseArtsATK, ArtsDEF: infection.ArtsDEF, ConfusionDEF: infection.ConfusionDef,
+		DEF: infection.BaseDef, DEX: infection.DEX, HPRecoveryRate: infection.HPRecoveryRate, INT: infection.INT, MaxHP: infection.MaxHP,
+		ParalysisDEF: infection.ParalysisDef, PoisonDEF: infection.PoisonDef, STR: infection.STR, Accuracy: infection.Accuracy, Dodge: infection.DodgeRate}
+
+	if isNew {
+		err = buff.Create()
+	} else {
+		err = buff.Update()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetStats()
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"hero-emulator/config"
+
+	null "gopkg.in/guregu/null.v3"
+)
+
+const (
+	CHAT_LOG_QUEUE = 5000 // messages waiting for the database, further ones are dropped
+)
+
+var (
+	// broadcastChannels reach every character of the server or faction, their receivers are not logged.
+	broadcastChannels = map[string]bool{"roar": true, "shout": true, "faction": true}
+
+	pendingChatLogs []interface{}
+	chatLogMutex    sync.Mutex
+	flushMutex      sync.Mutex
+)
+
+// ChatLog is a delivered chat message, Receivers holds the character ids of the targeted channels.
+type ChatLog struct {
+	ID         int       `db:"id" json:"id"`
+	Channel    string    `db:"channel" json:"channel"`
+	Server     int       `db:"server" json:"server"`
+	Map        int16     `db:"map" json:"map"`
+	SenderID   int       `db:"sender_id" json:"sender_id"`
+	SenderName string    `db:"sender_name" json:"sender_name"`
+	Receivers  string    `db:"receivers" json:"receivers"`
+	Message    string    `db:"message" json:"message"`
+	CreatedAt  null.Time `db:"created_at" json:"created_at"`
+}
+
+// LogChat queues the message for the chat log, the queue is written by RunChatLogger.
+func LogChat(c *Character, chatType int64, message string, receivers []int) {
+
+	channel, ok := ChatChannels[chatType]
+	if !ok || c == nil {
+		return
+	}
+
+	ids := []string{}
+	if !broadcastChannels[channel] {
+		for _, id := range receivers {
+			ids = append(ids, fmt.Sprint(id))
+		}
+	}
+
+	server := 0
+	if c.Socket != nil && c.Socket.User != nil {
+		server = c.Socket.User.ConnectedServer
+	}
+
+	entry := &ChatLog{Channel: channel, Server: server, Map: c.Map, SenderID: c.ID, SenderName: c.Name,
+		Receivers: "{" + strings.Join(ids, ",") + "}", Message: message, CreatedAt: null.TimeFrom(time.Now())}
+
+	chatLogMutex.Lock()
+	defer chatLogMutex.Unlock()
+	if len(pendingChatLogs) >= CHAT_LOG_QUEUE {
+		return
+	}
+	pendingChatLogs = append(pendingChatLogs, entry)
+}
+
+// flushChatLogs writes the queued messages, it returns after a flush running meanwhile is done.
+func flushChatLogs() {
+	flushMutex.Lock()
+	defer flushMutex.Unlock()
+
+	chatLogMutex.Lock()
+	logs := pendingChatLogs
+	pendingChatLogs = nil
+	chatLogMutex.Unlock()
+
+	if len(logs) == 0 {
+		return
+	}
+
+	if err := db.Insert(logs...); err != nil {
+		log.Println(fmt.Errorf("flushChatLogs: %s", err.Error()))
+	}
+}
+
+// purgeChatLogs deletes the messages older than the retention.
+func purgeChatLogs() {
+	days := config.Default.Chat.LogRetentionDays
+	if days <= 0 {
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	if _, err := db.Exec(`delete from hops.chat_logs where created_at < $1`, since); err != nil {
+		log.Println(fmt.Errorf("purgeChatLogs: %s", err.Error()))
+	}
+}
+
+// RunChatLogger writes the queued chat messages every second and deletes the expired ones every hour, it never returns.
+func RunChatLogger() {
+	purgeChatLogs()
+	lastPurge := time.Now()
+
+	for now := range time.Tick(time.Second) {
+		flushChatLogs()
+		if now.Sub(lastPurge) >= time.Hour {
+			purgeChatLogs()
+			lastPurge = now
+		}
+	}
+}
+
+// FindChatContext returns the latest messages the character sent and the ones the other character sent to it.
+func FindChatContext(characterID, otherID int, since time.Time, limit int) ([]*ChatLog, error) {
+
+	flushChatLogs()
+
+	var logs []*ChatLog
+	query := `select * from hops.chat_logs where created_at >= $1 and (sender_id = $2 or (sender_id = $3 and $2 = any(receivers)))
+		order by created_at desc limit $4`
+
+	if _, err := db.Select(&logs, query, since, characterID, otherID, limit); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("FindChatContext: %s", err.Error())
+	}
+
+	for i, j := 0, len(logs)-1; i < j; i, j = i+1, j-1 { // oldest first
+		logs[i], logs[j] = logs[j], logs[i]
+	}
+	return logs, nil
+}
@@ -0,0 +1,373 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"hero-emulator/config"
+	"hero-emulator/messaging"
+
+	null "gopkg.in/guregu/null.v3"
+)
+
+const (
+	MUTED_BY_SYSTEM = "SYSTEM"
+)
+
+var (
+	// ChatChannels names the chat types for the rate limits.
+	ChatChannels = map[int64]string{
+		28929: "normal",
+		28930: "whisper",
+		28931: "party",
+		28932: "guild",
+		28933: "roar",
+		28946: "roar",
+		28942: "shout",
+		28945: "faction",
+	}
+
+	// ChatFilterFile holds the words and the patterns masked in the chat.
+	ChatFilterFile  = "data/chat/filter.json"
+	chatFilter      = &ChatFilter{}
+	chatFilterMutex sync.RWMutex
+
+	chatMutes     = make(map[string]*ChatMute) // active mutes by user id
+	chatMuteMutex sync.RWMutex
+
+	chatRates     = make(map[int]*chatRate) // by character id
+	chatRateMutex sync.Mutex
+)
+
+// ChatFilter masks the listed words and every match of the patterns, the file format is described in the README.
+type ChatFilter struct {
+	Mask     string   `json:"mask"`
+	Words    []string `json:"words"`
+	Patterns []string `json:"patterns"`
+
+	compiled []*regexp.Regexp
+}
+
+// ChatMute keeps an account from chatting until it expires or a game master lifts it, it never expires without
+// an expiry time.
+type ChatMute struct {
+	ID            int         `db:"id" json:"id"`
+	UserID        string      `db:"user_id" json:"user_id"`
+	CharacterName string      `db:"character_name" json:"character_name"`
+	Reason        string      `db:"reason" json:"reason"`
+	IssuedBy      string      `db:"issued_by" json:"issued_by"`
+	Automatic     bool        `db:"automatic" json:"automatic"`
+	CreatedAt     null.Time   `db:"created_at" json:"created_at"`
+	ExpiresAt     null.Time   `db:"expires_at" json:"expires_at"`
+	LiftedAt      null.Time   `db:"lifted_at" json:"lifted_at"`
+	LiftedBy      null.String `db:"lifted_by" json:"lifted_by"`
+}
+
+type chatRate struct {
+	sent    map[string][]time.Time
+	strikes []time.Time
+}
+
+func (m *ChatMute) Create() error {
+	return db.Insert(m)
+}
+
+func (m *ChatMute) Update() error {
+	_, err := db.Update(m)
+	return err
+}
+
+func (m *ChatMute) active(now time.Time) bool {
+	return !m.LiftedAt.Valid && (!m.ExpiresAt.Valid || now.Before(m.ExpiresAt.Time))
+}
+
+// Message tells the muted account why it cannot chat.
+func (m *ChatMute) Message() []byte {
+	msg := "Chatting with this account is prohibited."
+	if m.ExpiresAt.Valid {
+		msg = fmt.Sprintf("Chatting with this account is prohibited until %s.", m.ExpiresAt.Time.Format("2006-01-02 15:04"))
+	}
+	if m.Reason != "" {
+		msg = fmt.Sprintf("%s Reason: %s", msg, m.Reason)
+	}
+	return messaging.InfoMessage(msg)
+}
+
+func getChatMutes() error {
+	var mutes []*ChatMute
+	query := `select * from hops.chat_mutes where lifted_at is null and (expires_at is null or expires_at > $1) order by created_at asc`
+
+	if _, err := db.Select(&mutes, query, time.Now()); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("getChatMutes: %s", err.Error())
+	}
+
+	chatMuteMutex.Lock()
+	defer chatMuteMutex.Unlock()
+	for _, m := range mutes {
+		chatMutes[m.UserID] = m
+	}
+	return nil
+}
+
+// LoadChatFilter reads the chat filter, the old filter stays when the file is invalid.
+func LoadChatFilter() ([]string, error) {
+
+	data, err := ioutil.ReadFile(ChatFilterFile)
+	if err != nil {
+		return nil, fmt.Errorf("LoadChatFilter: %s", err.Error())
+	}
+
+	filter := &ChatFilter{}
+	if err := json.Unmarshal(data, filter); err != nil {
+		return nil, fmt.Errorf("LoadChatFilter %s: %s", ChatFilterFile, err.Error())
+	}
+
+	errs := []string{}
+	if utf8.RuneCountInString(filter.Mask) != 1 {
+		errs = append(errs, "chat filter: mask must be a single character")
+	}
+	for _, w := range filter.Words {
+		if strings.TrimSpace(w) == "" {
+			errs = append(errs, "chat filter: empty word")
+			continue
+		}
+		filter.compiled = append(filter.compiled, regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(w)+`\b`))
+	}
+	for _, p := range filter.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("chat filter: pattern %q: %s", p, err.Error()))
+			continue
+		}
+		filter.compiled = append(filter.compiled, re)
+	}
+
+	if len(errs) > 0 {
+		return errs, fmt.Errorf("LoadChatFilter: %s", strings.Join(errs, ", "))
+	}
+
+	chatFilterMutex.Lock()
+	chatFilter = filter
+	chatFilterMutex.Unlock()
+	return nil, nil
+}
+
+// ChatFilterSize returns the number of the words and the patterns of the chat filter.
+func ChatFilterSize() int {
+	chatFilterMutex.RLock()
+	defer chatFilterMutex.RUnlock()
+	return len(chatFilter.compiled)
+}
+
+// FilterChat masks the filtered words of the message.
+func FilterChat(message string) string {
+	chatFilterMutex.RLock()
+	defer chatFilterMutex.RUnlock()
+
+	for _, re := range chatFilter.compiled {
+		message = re.ReplaceAllStringFunc(message, func(match string) string {
+			return strings.Repeat(chatFilter.Mask, utf8.RuneCountInString(match))
+		})
+	}
+	return message
+}
+
+// FindChatMute returns the active mute of the account, nil when it can chat.
+func FindChatMute(userID string) *ChatMute {
+	chatMuteMutex.Lock()
+	defer chatMuteMutex.Unlock()
+
+	m, ok := chatMutes[userID]
+	if !ok {
+		return nil
+	} else if !m.active(time.Now()) {
+		delete(chatMutes, userID)
+		return nil
+	}
+	return m
+}
+
+// MuteUser mutes the account of the character for the duration, 0 mutes it until it is lifted. A mute replaces the
+// earlier one of the account.
+func MuteUser(c *Character, by, reason string, duration time.Duration, automatic bool) (*ChatMute, error) {
+
+	now := time.Now()
+	m := &ChatMute{UserID: c.UserID, CharacterName: c.Name, Reason: reason, IssuedBy: by, Automatic: automatic,
+		CreatedAt: null.TimeFrom(now)}
+	if duration > 0 {
+		m.ExpiresAt = null.TimeFrom(now.Add(duration))
+	}
+
+	chatMuteMutex.Lock() // held until the new mute is in place so parallel mutes do not both stay active
+	if old, ok := chatMutes[c.UserID]; ok && old.active(now) {
+		old.LiftedAt = null.TimeFrom(now)
+		old.LiftedBy = null.StringFrom(by)
+		if err := old.Update(); err != nil {
+			chatMuteMutex.Unlock()
+			return nil, fmt.Errorf("MuteUser: %s", err.Error())
+		}
+	}
+
+	if err := m.Create(); err != nil {
+		delete(chatMutes, c.UserID) // the old mute is lifted already
+		chatMuteMutex.Unlock()
+		return nil, fmt.Errorf("MuteUser: %s", err.Error())
+	}
+	chatMutes[c.UserID] = m
+	chatMuteMutex.Unlock()
+
+	if s := GetSocket(c.UserID); s != nil {
+		s.Write(m.Message())
+	}
+	return m, nil
+}
+
+// UnmuteUser lifts the mute of the account, it reports whether there was one.
+func UnmuteUser(userID, by string) (bool, error) {
+
+	m := FindChatMute(userID)
+	if m == nil {
+		return false, nil
+	}
+
+	m.LiftedAt = null.TimeFrom(time.Now())
+	m.LiftedBy = null.StringFrom(by)
+	if err := m.Update(); err != nil {
+		return false, fmt.Errorf("UnmuteUser: %s", err.Error())
+	}
+
+	chatMuteMutex.Lock()
+	delete(chatMutes, userID)
+	chatMuteMutex.Unlock()
+
+	if s := GetSocket(userID); s != nil {
+		s.Write(messaging.InfoMessage("You can chat again."))
+	}
+	return true, nil
+}
+
+// MuteLines lists the active mutes.
+func MuteLines() []string {
+	chatMuteMutex.RLock()
+	mutes := []*ChatMute{}
+	for _, m := range chatMutes {
+		mutes = append(mutes, m)
+	}
+	chatMuteMutex.RUnlock()
+
+	sort.Slice(mutes, func(i, j int) bool {
+		return mutes[i].CreatedAt.Time.Before(mutes[j].CreatedAt.Time)
+	})
+
+	now := time.Now()
+	lines := []string{}
+	for _, m := range mutes {
+		if !m.active(now) {
+			continue
+		}
+
+		until := "permanent"
+		if m.ExpiresAt.Valid {
+			until = "until " + m.ExpiresAt.Time.Format("2006-01-02 15:04")
+		}
+		lines = append(lines, fmt.Sprintf("%s by %s, %s: %s", m.CharacterName, m.IssuedBy, until, m.Reason))
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, "Nobody is muted.")
+	}
+	return lines
+}
+
+func recent(times []time.Time, since time.Time) []time.Time {
+	kept := times[:0]
+	for _, t := range times {
+		if t.After(since) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// CheckChatRate counts the message of the character on the channel and reports whether it is within the rate limit,
+// the character is muted when it keeps going over the limits.
+func CheckChatRate(c *Character, channel string) bool {
+
+	rule, ok := config.Default.Chat.RateLimits[channel]
+	if !ok || rule.Messages <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	chatRateMutex.Lock()
+	rates, ok := chatRates[c.ID]
+	if !ok {
+		rates = &chatRate{sent: make(map[string][]time.Time)}
+		chatRates[c.ID] = rates
+	}
+
+	sent := recent(rates.sent[channel], now.Add(-time.Duration(rule.Seconds)*time.Second))
+	if len(sent) < rule.Messages {
+		rates.sent[channel] = append(sent, now)
+		chatRateMutex.Unlock()
+		return true
+	}
+	rates.sent[channel] = sent
+
+	spam := config.Default.Chat
+	rates.strikes = append(recent(rates.strikes, now.Add(-time.Duration(spam.SpamWindow)*time.Second)), now)
+	escalate := spam.SpamStrikes > 0 && len(rates.strikes) >= spam.SpamStrikes
+	if escalate {
+		rates.strikes = nil
+	}
+	chatRateMutex.Unlock()
+
+	if escalate {
+		muteSpammer(c)
+	}
+	return false
+}
+
+// muteSpammer mutes the character for spam, every automatic mute since the reset takes the next longer duration.
+func muteSpammer(c *Character) {
+
+	spam := config.Default.Chat
+	if len(spam.SpamMutes) == 0 {
+		return
+	}
+
+	since := time.Now().Add(-time.Duration(spam.SpamMuteReset) * time.Hour)
+	query := `select count(*) from hops.chat_mutes where user_id = $1 and automatic = true and created_at >= $2`
+	count, err := db.SelectInt(query, c.UserID, since)
+	if err != nil {
+		log.Println(fmt.Errorf("muteSpammer: %s", err.Error()))
+		return
+	}
+
+	if count >= int64(len(spam.SpamMutes)) {
+		count = int64(len(spam.SpamMutes) - 1)
+	}
+
+	duration := time.Duration(spam.SpamMutes[count]) * time.Minute
+	if _, err := MuteUser(c, MUTED_BY_SYSTEM, "spam", duration, true); err != nil {
+		log.Println(err)
+	}
+}
+
+func DeleteChatRatesFromCache(characterID int) {
+	chatRateMutex.Lock()
+	defer chatRateMutex.Unlock()
+	delete(chatRates, characterID)
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hero-emulator/config"
+)
+
+func loadTestFilter(t *testing.T, data string) ([]string, error) {
+	dir, err := ioutil.TempDir("", "chatfilter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "filter.json")
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(old string) { ChatFilterFile = old }(ChatFilterFile)
+	ChatFilterFile = file
+	return LoadChatFilter()
+}
+
+func TestFilterChat(t *testing.T) {
+	defer func(old *ChatFilter) { chatFilter = old }(chatFilter)
+
+	if _, err := loadTestFilter(t, `{"mask": "*", "words": ["darn", "héck"], "patterns": ["g[o0]ld\\s*shop"]}`); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"hello there", "hello there"},
+		{"darn it", "**** it"},
+		{"DARN it", "**** it"},
+		{"darned", "darned"},
+		{"what the héck", "what the ****"},
+		{"visit g0ld shop now", "visit ********* now"},
+	}
+
+	for _, test := range tests {
+		if got := FilterChat(test.message); got != test.want {
+			t.Errorf("FilterChat(%q) = %q, want %q", test.message, got, test.want)
+		}
+	}
+}
+
+func TestLoadChatFilterKeepsOldFilter(t *testing.T) {
+	defer func(old *ChatFilter) { chatFilter = old }(chatFilter)
+
+	if _, err := loadTestFilter(t, `{"mask": "#", "words": ["darn"]}`); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	tests := []struct {
+		name string
+		data string
+		errs int
+	}{
+		{"invalid json", `{"mask": `, 0},
+		{"invalid pattern", `{"mask": "*", "patterns": ["(unclosed"]}`, 1},
+		{"long mask", `{"mask": "**", "words": ["heck"]}`, 1},
+		{"empty word", `{"mask": "*", "words": [" "]}`, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errs, err := loadTestFilter(t, test.data)
+			if err == nil {
+				t.Fatalf("expected the filter to be rejected")
+			}
+			if len(errs) != test.errs {
+				t.Fatalf("got %d errors, want %d: %v", len(errs), test.errs, errs)
+			}
+			if got := FilterChat("darn heck"); got != "#### heck" {
+				t.Fatalf("old filter replaced, got %q", got)
+			}
+		})
+	}
+}
+
+func TestCheckChatRate(t *testing.T) {
+	defer func(old config.Chat) { config.Default.Chat = old }(config.Default.Chat)
+
+	config.Default.Chat.RateLimits = map[string]config.ChatRate{"normal": {Messages: 3, Seconds: 10}}
+	config.Default.Chat.SpamStrikes = 2
+	config.Default.Chat.SpamWindow = 30
+	config.Default.Chat.SpamMutes = nil // the mute itself needs the database
+
+	tests := []struct {
+		name    string
+		channel string
+		sends   int
+		allowed int
+		strikes int
+	}{
+		{"within the limit", "normal", 3, 3, 0},
+		{"over the limit", "normal", 4, 3, 1},
+		{"escalates", "normal", 5, 3, 0}, // the second strike mutes and resets the strikes
+		{"unlimited channel", "roar", 10, 10, 0},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Character{ID: 1000 + i}
+			defer DeleteChatRatesFromCache(c.ID)
+
+			allowed := 0
+			for n := 0; n < test.sends; n++ {
+				if CheckChatRate(c, test.channel) {
+					allowed++
+				}
+			}
+
+			if allowed != test.allowed {
+				t.Fatalf("%d messages allowed, want %d", allowed, test.allowed)
+			}
+
+			strikes := 0
+			chatRateMutex.Lock()
+			if rates, ok := chatRates[c.ID]; ok {
+				strikes = len(rates.strikes)
+			}
+			chatRateMutex.Unlock()
+			if strikes != test.strikes {
+				t.Fatalf("%d strikes, want %d", strikes, test.strikes)
+			}
+		})
+	}
+}
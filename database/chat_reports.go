@@ -0,0 +1,230 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"hero-emulator/config"
+	"hero-emulator/messaging"
+
+	null "gopkg.in/guregu/null.v3"
+)
+
+const (
+	REPORT_OPEN   = "open"
+	REPORT_CLOSED = "closed"
+
+	REPORT_DISMISS = "dismiss"
+	REPORT_MUTE    = "mute"
+	REPORT_RESOLVE = "resolve" // acted on outside of the report
+
+	REPORT_LIST_LIMIT = 20
+)
+
+// ChatReport is a complaint of a player about another one, Context holds the chat messages around it.
+type ChatReport struct {
+	ID           int             `db:"id" json:"id"`
+	ReporterID   int             `db:"reporter_id" json:"reporter_id"`
+	ReporterName string          `db:"reporter_name" json:"reporter_name"`
+	TargetID     int             `db:"target_id" json:"target_id"`
+	TargetName   string          `db:"target_name" json:"target_name"`
+	Reason       string          `db:"reason" json:"reason"`
+	Context      json.RawMessage `db:"context" json:"context"`
+	Status       string          `db:"status" json:"status"`
+	HandledBy    null.String     `db:"handled_by" json:"handled_by"`
+	Action       null.String     `db:"action" json:"action"`
+	Note         null.String     `db:"note" json:"note"`
+	CreatedAt    null.Time       `db:"created_at" json:"created_at"`
+	HandledAt    null.Time       `db:"handled_at" json:"handled_at"`
+}
+
+func (r *ChatReport) Create() error {
+	return db.Insert(r)
+}
+
+func (r *ChatReport) Update() error {
+	_, err := db.Update(r)
+	return err
+}
+
+// Messages returns the chat messages attached to the report.
+func (r *ChatReport) Messages() []*ChatLog {
+	logs := []*ChatLog{}
+	if err := json.Unmarshal(r.Context, &logs); err != nil {
+		log.Println(err)
+	}
+	return logs
+}
+
+func FindChatReportByID(id int) (*ChatReport, error) {
+
+	r := &ChatReport{}
+	query := `select * from hops.chat_reports where id = $1`
+
+	if err := db.SelectOne(r, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("FindChatReportByID: %s", err.Error())
+	}
+
+	return r, nil
+}
+
+// FindChatReports returns the latest reports with the status, every report when the status is empty.
+func FindChatReports(status string, limit int) ([]*ChatReport, error) {
+
+	var reports []*ChatReport
+	query := `select * from hops.chat_reports where $1 = '' or status = $1 order by created_at desc limit $2`
+
+	if _, err := db.Select(&reports, query, status, limit); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("FindChatReports: %s", err.Error())
+	}
+
+	return reports, nil
+}
+
+// ReportCharacter files a report of the character about the named one with the recent chat between them.
+func (c *Character) ReportCharacter(name, reason string) ([]byte, error) {
+
+	target, err := FindCharacterByName(name)
+	if err != nil {
+		return nil, err
+	} else if target == nil {
+		return messaging.InfoMessage(fmt.Sprintf("%s does not exist.", name)), nil
+	} else if target.ID == c.ID {
+		return messaging.InfoMessage("You cannot report yourself."), nil
+	} else if reason == "" {
+		return messaging.InfoMessage("Tell the reason of the report."), nil
+	}
+
+	rules := config.Default.Chat
+	query := `select count(*) from hops.chat_reports where reporter_id = $1 and created_at >= $2`
+	count, err := db.SelectInt(query, c.ID, time.Now().Add(-time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("ReportCharacter: %s", err.Error())
+	} else if count >= int64(rules.ReportsPerHour) {
+		return messaging.InfoMessage("You have sent too many reports, try again later."), nil
+	}
+
+	since := time.Now().Add(-time.Duration(rules.ReportContextMinutes) * time.Minute)
+	logs, err := FindChatContext(target.ID, c.ID, since, rules.ReportContextLimit)
+	if err != nil {
+		return nil, err
+	}
+	if logs == nil {
+		logs = []*ChatLog{}
+	}
+
+	context, err := json.Marshal(logs)
+	if err != nil {
+		return nil, fmt.Errorf("ReportCharacter: %s", err.Error())
+	}
+
+	r := &ChatReport{ReporterID: c.ID, ReporterName: c.Name, TargetID: target.ID, TargetName: target.Name, Reason: reason,
+		Context: context, Status: REPORT_OPEN, CreatedAt: null.TimeFrom(time.Now())}
+	if err := r.Create(); err != nil {
+		return nil, fmt.Errorf("ReportCharacter: %s", err.Error())
+	}
+
+	return messaging.InfoMessage(fmt.Sprintf("Your report #%d about %s was sent to the game masters.", r.ID, target.Name)), nil
+}
+
+// ResolveChatReport closes the open report with the action of the game master, a mute of 0 minutes lasts until it
+// is lifted.
+func ResolveChatReport(id int, by, action, note string, muteMinutes int) error {
+
+	r, err := FindChatReportByID(id)
+	if err != nil {
+		return err
+	} else if r == nil || r.Status != REPORT_OPEN {
+		return fmt.Errorf("report %d is not open", id)
+	}
+
+	var target *Character
+	switch action {
+	case REPORT_DISMISS, REPORT_RESOLVE:
+	case REPORT_MUTE:
+		target, err = FindCharacterByID(r.TargetID)
+		if err != nil {
+			return err
+		} else if target == nil {
+			return fmt.Errorf("character %d does not exist", r.TargetID)
+		}
+	default:
+		return fmt.Errorf("unknown action %s", action)
+	}
+
+	r.Status = REPORT_CLOSED
+	r.HandledBy = null.StringFrom(by)
+	r.Action = null.StringFrom(action)
+	r.Note = null.StringFrom(note)
+	r.HandledAt = null.TimeFrom(time.Now())
+
+	// the report is closed before the action, like a claimed mail, so two game masters never both act on it
+	query := `update hops.chat_reports set status = $1, handled_by = $2, action = $3, note = $4, handled_at = $5
+		where id = $6 and status = $7`
+	res, err := db.Exec(query, r.Status, r.HandledBy, r.Action, r.Note, r.HandledAt, r.ID, REPORT_OPEN)
+	if err != nil {
+		return fmt.Errorf("ResolveChatReport: %s", err.Error())
+	} else if n, err := res.RowsAffected(); err != nil || n == 0 { // resolved by another game master
+		return fmt.Errorf("report %d is not open", id)
+	}
+
+	if target != nil {
+		reason := fmt.Sprintf("report #%d: %s", r.ID, r.Reason)
+		if _, err := MuteUser(target, by, reason, time.Duration(muteMinutes)*time.Minute, false); err != nil {
+			db.Exec(`update hops.chat_reports set status = $1, handled_by = null, action = null, note = null, handled_at = null where id = $2`,
+				REPORT_OPEN, r.ID)
+			return err
+		}
+	}
+
+	characterMutex.RLock()
+	reporter, ok := characters[r.ReporterID]
+	characterMutex.RUnlock()
+	if ok && reporter.IsOnline && reporter.Socket != nil {
+		reporter.Socket.Write(messaging.InfoMessage(fmt.Sprintf("Your report #%d about %s was reviewed.", r.ID, r.TargetName)))
+	}
+	return nil
+}
+
+// ChatReportLines lists the open reports.
+func ChatReportLines() ([]string, error) {
+
+	reports, err := FindChatReports(REPORT_OPEN, REPORT_LIST_LIMIT)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{}
+	for _, r := range reports {
+		lines = append(lines, fmt.Sprintf("#%d %s about %s, %s: %s", r.ID, r.ReporterName, r.TargetName,
+			r.CreatedAt.Time.Format("2006-01-02 15:04"), r.Reason))
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, "There are no open reports.")
+	}
+	return lines, nil
+}
+
+// Lines describes the report with its chat messages.
+func (r *ChatReport) Lines() []string {
+
+	lines := []string{fmt.Sprintf("#%d %s about %s (%s): %s", r.ID, r.ReporterName, r.TargetName, r.Status, r.Reason)}
+	if r.Status == REPORT_CLOSED {
+		lines = append(lines, fmt.Sprintf("%s by %s: %s", r.Action.String, r.HandledBy.String, r.Note.String))
+	}
+
+	for _, l := range r.Messages() {
+		lines = append(lines, fmt.Sprintf("[%s] %s %s: %s", l.CreatedAt.Time.Format("15:04:05"), l.Channel, l.SenderName, l.Message))
+	}
+	return lines
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"hero-emulator/nats"
+
+	NATS "github.com/nats-io/nats.go"
+)
+
+var (
+	whisperSubs     = make(map[int]*NATS.Subscription) // the subjects of the whispers of the characters online in this process
+	whisperSubMutex sync.Mutex
+)
+
+// chatRoutes decide which characters of this process receive the messages of the broadcast subjects.
+var chatRoutes = map[string]func(m *nats.ChatMessage, c *Character) bool{
+	nats.CHAT_GUILDS: func(m *nats.ChatMessage, c *Character) bool {
+		return c.GuildID == m.GuildID
+	},
+	nats.CHAT_FACTIONS: func(m *nats.ChatMessage, c *Character) bool {
+		return c.Socket.User != nil && c.Socket.User.ConnectedServer == m.Server && c.Faction == m.Faction
+	},
+	nats.CHAT_SERVERS: func(m *nats.ChatMessage, c *Character) bool {
+		return c.Socket.User != nil && c.Socket.User.ConnectedServer == m.Server
+	},
+	nats.CHAT_GLOBAL: func(m *nats.ChatMessage, c *Character) bool {
+		return true
+	},
+}
+
+// SubscribeChat delivers the chat messages of every game process to the characters online in this one, whispers are
+// subscribed per character by SubscribeWhispers.
+func SubscribeChat() error {
+
+	for subject, route := range chatRoutes {
+		route := route
+		_, err := nats.Connection().Subscribe(subject, func(msg *NATS.Msg) {
+			receiveChat(msg, route)
+		})
+		if err != nil {
+			return fmt.Errorf("SubscribeChat: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// SubscribeWhispers delivers the whispers sent to the name of the character to it, a former subscription of the
+// character is replaced so it follows renames.
+func SubscribeWhispers(c *Character) error {
+
+	sub, err := nats.Connection().Subscribe(nats.CharacterSubject(c.Name), func(msg *NATS.Msg) {
+		receiveWhisper(msg, c)
+	})
+	if err != nil {
+		return fmt.Errorf("SubscribeWhispers: %s", err.Error())
+	}
+
+	whisperSubMutex.Lock()
+	old := whisperSubs[c.ID]
+	whisperSubs[c.ID] = sub
+	whisperSubMutex.Unlock()
+
+	if old != nil {
+		old.Unsubscribe()
+	}
+	return nil
+}
+
+// UnsubscribeWhispers stops the whispers of the character when it leaves the game.
+func UnsubscribeWhispers(c *Character) {
+
+	whisperSubMutex.Lock()
+	sub := whisperSubs[c.ID]
+	delete(whisperSubs, c.ID)
+	whisperSubMutex.Unlock()
+
+	if sub != nil {
+		if err := sub.Unsubscribe(); err != nil {
+			log.Println(fmt.Errorf("UnsubscribeWhispers: %s", err.Error()))
+		}
+	}
+}
+
+// localCharacters returns the characters online in this process which pass the filter.
+func localCharacters(filter func(c *Character) bool) []*Character {
+	characterMutex.RLock()
+	defer characterMutex.RUnlock()
+
+	online := []*Character{}
+	for _, c := range characters {
+		if c.IsOnline && c.Socket != nil && filter(c) {
+			online = append(online, c)
+		}
+	}
+	return online
+}
+
+func decodeChat(msg *NATS.Msg) *nats.ChatMessage {
+	m := &nats.ChatMessage{}
+	if err := json.Unmarshal(msg.Data, m); err != nil {
+		log.Println(fmt.Errorf("decodeChat %s: %s", msg.Subject, err.Error()))
+		return nil
+	}
+	return m
+}
+
+// receiveWhisper writes the whisper to the character and acknowledges it, only the process of the character replies
+// so the sender times out when it is offline. The write is bounded by the deadline of the socket, the ack needs
+// its result.
+func receiveWhisper(msg *NATS.Msg, c *Character) {
+
+	m := decodeChat(msg)
+	if m == nil {
+		return
+	}
+
+	socket := c.Socket
+	if !c.IsOnline || socket == nil {
+		return
+	}
+
+	ack := &nats.ChatAck{CharacterID: c.ID}
+	if r := c.findRelation(m.SenderID); r == nil || r.Type != RELATION_BLOCK {
+		ack.Delivered = socket.Write(m.Data) == nil
+	}
+
+	if err := ack.Ack(msg); err != nil {
+		log.Println(fmt.Errorf("receiveWhisper: %s", err.Error()))
+	}
+}
+
+// receiveChat writes the broadcast message to the characters of the route, each in its own goroutine so a stalled
+// client does not hold the subscription.
+func receiveChat(msg *NATS.Msg, route func(m *nats.ChatMessage, c *Character) bool) {
+
+	m := decodeChat(msg)
+	if m == nil {
+		return
+	}
+
+	receivers := localCharacters(func(c *Character) bool {
+		return c.ID != m.ExcludeID && route(m, c)
+	})
+	for _, c := range receivers {
+		go func(s *Socket) {
+			if err := s.Write(m.Data); err != nil {
+				log.Println(err)
+			}
+		}(c.Socket)
+	}
+}
@@ -96,21 +96,3 @@ func getCraftItem() error {
 
 	return nil
 }
-
-func RefreshCraftItem() error {
-	var crafts []*CraftItem
-	query := `select * from data.craft_items`
-
-	if _, err := db.Select(&crafts, query); err != nil {
-		if err == sql.ErrNoRows {
-			return nil
-		}
-		return fmt.Errorf("getCraftItems: %s", err.Error())
-	}
-
-	for _, cr := range crafts {
-		CraftItems[cr.ID] = cr
-	}
-
-	return nil
-}
@@ -9,12 +9,13 @@ import (
 )
 
 type Drop struct {
-	ID       int
-	Server   int
-	Map      int16
-	Location utils.Location
-	Item     *InventorySlot
-	Claimer  *Character
+	ID         int
+	Server     int
+	Map        int16
+	InstanceID int
+	Location   utils.Location
+	Item       *InventorySlot
+	Claimer    *Character
 }
 
 func (drop *Drop) GenerateIDForDrop(server int, mapID int16) {
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"sort"
+
+	"hero-emulator/utils"
+
+	"github.com/thoas/go-funk"
+)
+
+const (
+	maxDropCount = 100
+)
+
+// DropModifiers holds everything besides the drop tables which changes the outcome of a drop roll.
+type DropModifiers struct {
+	Map            int16
+	Attackable     bool
+	DropMultiplier float64
+	Pickaxe        bool
+}
+
+// DropRoll is the outcome of a single kill.
+type DropRoll struct {
+	Items []*DropEntry
+	Depth int // deepest nested drop table reached
+}
+
+func NewDropModifiers(ai *AI, claimer *Character) *DropModifiers {
+	mod := &DropModifiers{Map: ai.Map, Attackable: true, DropMultiplier: claimer.DropMultiplier + claimer.AdditionalDropMultiplier,
+		Pickaxe: claimer.PickaxeActivated()}
+
+	if npcPos := NPCPos[ai.PosID]; npcPos != nil {
+		mod.Attackable = npcPos.Attackable
+	}
+
+	return mod
+}
+
+func dropBonus(npc *NPC, mod *DropModifiers) (float64, int) {
+	bossMultiplier, minCount := 0.0, 0
+	if enc := findEncounter(npc.ID); enc != nil && enc.Rewards != nil && enc.Rewards.DropMultiplier > 0 {
+		bossMultiplier = enc.Rewards.DropMultiplier
+		minCount = enc.Rewards.MinDrops
+
+	} else if funk.Contains(bosses, npc.ID) {
+		bossMultiplier = 2.0
+		minCount = 12
+
+	} else if funk.Contains(eventBosses, npc.ID) {
+		bossMultiplier = 7.0
+		minCount = 48
+
+	} else if !mod.Attackable && mod.Pickaxe {
+		bossMultiplier = 0.4
+	}
+	if mod.Map == 27 {
+		bossMultiplier = 0.1
+	}
+
+	return bossMultiplier, minCount
+}
+
+// RollDrops picks the dropped items of a kill, it is shared by the live drop handler and the drop simulator.
+func RollDrops(npc *NPC, mod *DropModifiers) *DropRoll {
+
+	roll := &DropRoll{}
+	bossMultiplier, minCount := dropBonus(npc, mod)
+
+	totalDropRate := (DROP_RATE * mod.DropMultiplier) + bossMultiplier
+	if mod.Map == 27 {
+		totalDropRate = (1 * mod.DropMultiplier) + bossMultiplier
+	}
+
+	for {
+		id := npc.DropID
+		drop, ok := Drops[id]
+		if !ok || drop == nil {
+			return roll
+		}
+
+		var dropped *DropEntry
+		depth := 0
+		end := false
+		for dropped == nil {
+			if len(drop.Entries) == 0 {
+				return roll
+			}
+
+			seed := int(utils.RandInt(0, 1000))
+			probabilities := drop.GetProbabilities()
+			dropFailRate := float64(1000 - probabilities[len(probabilities)-1])
+			dropFailRate /= totalDropRate
+			newDropFailRate := 1000 - dropFailRate
+			probMultiplier := float64(probabilities[len(probabilities)-1]) / newDropFailRate
+
+			if float64(probabilities[len(probabilities)-1])*totalDropRate < 900 {
+				probMultiplier = 1
+				probabilities = funk.Map(probabilities, func(prob int) int {
+					return int(float64(prob) * totalDropRate)
+				}).([]int)
+			}
+
+			seed = int(float64(seed) * probMultiplier)
+			index := sort.SearchInts(probabilities, seed)
+			if index >= len(drop.Entries) {
+				if len(roll.Items) >= minCount {
+					end = true
+					break
+				}
+				drop, depth = Drops[id], 0
+				continue
+			}
+
+			entry := drop.Entries[index]
+			if entry.NestedDrop > 0 {
+				if drop, ok = Drops[entry.NestedDrop]; !ok {
+					return roll
+				}
+				depth++
+				continue
+			}
+
+			if item, exist := Items[int64(entry.ItemID)]; exist {
+				itemType := item.GetType()
+				if itemType == QUEST_TYPE || itemType == INGREDIENTS_TYPE {
+					drop, depth = Drops[id], 0
+					continue
+				}
+			}
+
+			dropped = entry
+		}
+
+		if depth > roll.Depth {
+			roll.Depth = depth
+		}
+
+		if !end && dropped.ItemID > 0 { // can drop an item
+			if len(roll.Items)+1 >= maxDropCount {
+				return roll
+			}
+			roll.Items = append(roll.Items, dropped)
+		}
+
+		if end || !mod.Attackable {
+			return roll
+		}
+	}
+}
+
+// RollGold returns the gold looted from a kill.
+func RollGold(npc *NPC) uint64 {
+	goldDrop := int64(npc.GoldDrop)
+	if goldDrop <= 0 {
+		return 0
+	}
+	return uint64(utils.RandInt(goldDrop/2, goldDrop))
+}
@@ -3,10 +3,10 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"strconv"
-	"strings"
+	"sort"
+
+	"hero-emulator/utils"
 
-	"github.com/thoas/go-funk"
 	gorp "gopkg.in/gorp.v1"
 )
 
@@ -15,47 +15,51 @@ var (
 )
 
 type DropInfo struct {
-	ID            int    `db:"id"`
-	Items         string `db:"items"`
-	Probabilities string `db:"probabilities"`
+	ID int `db:"id"`
+
+	Entries       []*DropEntry `db:"-"` // ordered by weight for the rolls
+	Items         []int        `db:"-"`
+	Probabilities []int        `db:"-"`
+
+	rows []*DropEntry `db:"-"` // in the order of their ids, the weights are validated in this order
 }
 
-func (e *DropInfo) New(id int, items, probabilities []int) {
-	e.ID = id
-	items = funk.FilterInt(items, func(item int) bool {
-		return item > 0
-	})
-	probabilities = funk.FilterInt(probabilities, func(prob int) bool {
-		return prob > 0
-	})
-	e.Items = strings.Trim(strings.Join(strings.Fields(fmt.Sprint(items)), ","), "[]")
-	e.Items = fmt.Sprintf("{%s}", e.Items)
-	e.Probabilities = strings.Trim(strings.Join(strings.Fields(fmt.Sprint(probabilities)), ","), "[]")
-	e.Probabilities = fmt.Sprintf("{%s}", e.Probabilities)
+// DropEntry is a single row of a drop table, Weight is cumulative over the entries of the drop.
+type DropEntry struct {
+	ID          int  `db:"id"`
+	DropID      int  `db:"drop_id"`
+	ItemID      int  `db:"item_id"`
+	Weight      int  `db:"weight"`
+	NestedDrop  int  `db:"nested_drop"`
+	MinQuantity uint `db:"min_quantity"`
+	MaxQuantity uint `db:"max_quantity"`
 }
 
-func (e *DropInfo) GetItems() []int {
-	items := strings.Trim(e.Items, "{}")
-	sItems := strings.Split(items, ",")
+// build sorts the entries and prepares the arrays used by the drop rolls.
+func (e *DropInfo) build() {
+	e.Entries = append([]*DropEntry{}, e.rows...)
+	sort.SliceStable(e.Entries, func(i, j int) bool {
+		return e.Entries[i].Weight < e.Entries[j].Weight
+	})
 
-	var arr []int
-	for _, sItem := range sItems {
-		d, _ := strconv.Atoi(sItem)
-		arr = append(arr, d)
+	e.Items = make([]int, len(e.Entries))
+	e.Probabilities = make([]int, len(e.Entries))
+	for i, entry := range e.Entries {
+		e.Items[i] = entry.ItemID
+		if entry.NestedDrop > 0 {
+			e.Items[i] = entry.NestedDrop
+		}
+		e.Probabilities[i] = entry.Weight
 	}
-	return arr
 }
 
-func (e *DropInfo) GetProbabilities() []int {
-	probs := strings.Trim(e.Probabilities, "{}")
-	sProbs := strings.Split(probs, ",")
+// GetItems returns the item of each entry, or the id of the nested drop table.
+func (e *DropInfo) GetItems() []int {
+	return e.Items
+}
 
-	var arr []int
-	for _, sProb := range sProbs {
-		d, _ := strconv.Atoi(sProb)
-		arr = append(arr, d)
-	}
-	return arr
+func (e *DropInfo) GetProbabilities() []int {
+	return e.Probabilities
 }
 
 func (e *DropInfo) Create() error {
@@ -76,37 +80,65 @@ func (e *DropInfo) Delete() error {
 	return err
 }
 
-func getAllDrops() error {
+func (e *DropEntry) Create() error {
+	return db.Insert(e)
+}
+
+func (e *DropEntry) Update() error {
+	_, err := db.Update(e)
+	return err
+}
+
+func (e *DropEntry) Delete() error {
+	_, err := db.Delete(e)
+	return err
+}
+
+// RollQuantity returns a random quantity between the limits of the entry.
+func (e *DropEntry) RollQuantity() uint {
+	if e.MinQuantity == 0 {
+		return 1
+	} else if e.MaxQuantity <= e.MinQuantity {
+		return e.MinQuantity
+	}
+	return uint(utils.RandInt(int64(e.MinQuantity), int64(e.MaxQuantity)+1))
+}
+
+func selectDrops() (map[int]*DropInfo, error) {
 	var drops []*DropInfo
-	query := `select * from data.drops`
+	if _, err := db.Select(&drops, `select * from data.drops`); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
 
-	if _, err := db.Select(&drops, query); err != nil {
-		if err == sql.ErrNoRows {
-			return nil
-		}
-		return fmt.Errorf("getAllDrops: %s", err.Error())
+	var entries []*DropEntry
+	if _, err := db.Select(&entries, `select * from data.drop_items order by drop_id, id`); err != nil && err != sql.ErrNoRows {
+		return nil, err
 	}
 
+	m := make(map[int]*DropInfo)
 	for _, d := range drops {
-		Drops[d.ID] = d
+		m[d.ID] = d
 	}
 
-	return nil
-}
-func RefreshAllDrops() error {
-	var drops []*DropInfo
-	query := `select * from data.drops`
-
-	if _, err := db.Select(&drops, query); err != nil {
-		if err == sql.ErrNoRows {
-			return nil
+	for _, entry := range entries {
+		if d, ok := m[entry.DropID]; ok {
+			d.rows = append(d.rows, entry)
 		}
-		return fmt.Errorf("getAllDrops: %s", err.Error())
 	}
 
-	for _, d := range drops {
-		Drops[d.ID] = d
+	for _, d := range m {
+		d.build()
+	}
+
+	return m, nil
+}
+
+func getAllDrops() error {
+	drops, err := selectDrops()
+	if err != nil {
+		return fmt.Errorf("getAllDrops: %s", err.Error())
 	}
 
+	Drops = drops
 	return nil
 }
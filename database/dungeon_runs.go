@@ -0,0 +1,193 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	null "gopkg.in/guregu/null.v3"
+)
+
+const (
+	RUN_COMPLETED = "completed"
+	RUN_FAILED    = "failed"
+	RUN_ABANDONED = "abandoned"
+
+	LEADERBOARD_LIMIT = 10
+)
+
+// DungeonRun is the record of a finished instance.
+type DungeonRun struct {
+	ID        int       `db:"id" json:"id"`
+	DungeonID int       `db:"dungeon_id" json:"dungeon_id"`
+	Server    int       `db:"server" json:"server"`
+	Result    string    `db:"result" json:"result"`
+	StartedAt null.Time `db:"started_at" json:"started_at"`
+	EndedAt   null.Time `db:"ended_at" json:"ended_at"`
+	Duration  int       `db:"duration" json:"duration"` // seconds
+	Kills     int       `db:"kills" json:"kills"`
+	Deaths    int       `db:"deaths" json:"deaths"`
+	Damage    int64     `db:"damage" json:"damage"`
+
+	Members []*DungeonRunMember `db:"-" json:"members"`
+}
+
+type DungeonRunMember struct {
+	RunID       int    `db:"run_id" json:"run_id"`
+	CharacterID int    `db:"character_id" json:"character_id"`
+	Name        string `db:"name" json:"name"`
+	Kills       int    `db:"kills" json:"kills"`
+	Deaths      int    `db:"deaths" json:"deaths"`
+	Damage      int64  `db:"damage" json:"damage"`
+}
+
+// Create inserts the run and its members.
+func (r *DungeonRun) Create() error {
+
+	tr, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("CreateDungeonRun: %s", err.Error())
+	}
+
+	if err := tr.Insert(r); err != nil {
+		tr.Rollback()
+		return fmt.Errorf("CreateDungeonRun: %s", err.Error())
+	}
+
+	for _, m := range r.Members {
+		m.RunID = r.ID
+		if err := tr.Insert(m); err != nil {
+			tr.Rollback()
+			return fmt.Errorf("CreateDungeonRun: %s", err.Error())
+		}
+	}
+
+	return tr.Commit()
+}
+
+// FastestClears returns the fastest completed runs of the dungeon which ended after since.
+func FastestClears(dungeonID int, since time.Time, limit int) ([]*DungeonRun, error) {
+
+	if limit <= 0 || limit > LEADERBOARD_LIMIT {
+		limit = LEADERBOARD_LIMIT
+	}
+
+	var runs []*DungeonRun
+	query := `select * from hops.dungeon_runs where dungeon_id = $1 and "result" = $2 and ended_at >= $3
+		order by duration, ended_at limit $4`
+
+	if _, err := db.Select(&runs, query, dungeonID, RUN_COMPLETED, since, limit); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("FastestClears: %s", err.Error())
+	}
+
+	for _, r := range runs {
+		query = `select * from hops.dungeon_run_members where run_id = $1 order by damage desc`
+		if _, err := db.Select(&r.Members, query, r.ID); err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("FastestClears: %s", err.Error())
+		}
+	}
+
+	return runs, nil
+}
+
+// WeekStart returns the start of the week of t, weeks start on Monday at 00:00.
+func WeekStart(t time.Time) time.Time {
+	day := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.AddDate(0, 0, -day).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// LeaderboardLines describes the fastest clears of the dungeon, of this week when weekly is true.
+func LeaderboardLines(dungeonID int, weekly bool) ([]string, error) {
+
+	d := FindDungeon(dungeonID)
+	if d == nil {
+		return nil, fmt.Errorf("unknown dungeon %d", dungeonID)
+	}
+
+	since, title := time.Time{}, "all time"
+	if weekly {
+		since, title = WeekStart(time.Now()), "this week"
+	}
+
+	runs, err := FastestClears(dungeonID, since, LEADERBOARD_LIMIT)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{fmt.Sprintf("%s fastest clears (%s):", d.Name, title)}
+	if len(runs) == 0 {
+		lines = append(lines, "No clears yet.")
+	}
+	for i, r := range runs {
+		names := ""
+		for j, m := range r.Members {
+			if j > 0 {
+				names += ", "
+			}
+			names += m.Name
+		}
+		lines = append(lines, fmt.Sprintf("%d. %02d:%02d - %s", i+1, r.Duration/60, r.Duration%60, names))
+	}
+	return lines, nil
+}
+
+// recordDamage adds the damage dealt by the character to a mob of the instance.
+func (inst *Instance) recordDamage(c *Character, damage int, killed bool) {
+	inst.mutex.Lock()
+	defer inst.mutex.Unlock()
+
+	m := inst.stats[c.ID]
+	if m == nil {
+		return
+	}
+
+	m.Damage += int64(damage)
+	if killed {
+		m.Kills++
+	}
+}
+
+// recordDeath counts a death of the character inside the instance.
+func (inst *Instance) recordDeath(c *Character) {
+	inst.mutex.Lock()
+	defer inst.mutex.Unlock()
+
+	if m := inst.stats[c.ID]; m != nil {
+		m.Deaths++
+	}
+}
+
+// record saves the run of the instance with the result.
+func (inst *Instance) record(result string) {
+
+	now := time.Now()
+	run := &DungeonRun{DungeonID: inst.Dungeon.ID, Server: inst.Server, Result: result,
+		StartedAt: null.TimeFrom(inst.StartedAt), EndedAt: null.TimeFrom(now),
+		Duration: int(now.Sub(inst.StartedAt).Seconds())}
+
+	inst.mutex.RLock()
+	for _, m := range inst.stats {
+		member := *m
+		run.Members = append(run.Members, &member)
+		run.Kills += m.Kills
+		run.Deaths += m.Deaths
+		run.Damage += m.Damage
+	}
+	inst.mutex.RUnlock()
+
+	sort.Slice(run.Members, func(i, j int) bool {
+		return run.Members[i].CharacterID < run.Members[j].CharacterID
+	})
+
+	go func() {
+		if err := run.Create(); err != nil {
+			log.Println(err)
+		}
+	}()
+}
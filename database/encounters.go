@@ -0,0 +1,421 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"hero-emulator/nats"
+	"hero-emulator/utils"
+)
+
+var (
+	// EncounterDir holds one json file per boss encounter.
+	EncounterDir = "data/encounters"
+	Encounters   = make(map[int]*Encounter)
+	encMutex     sync.Mutex
+)
+
+// Encounter is a scripted boss fight, the file format is described in the README.
+type Encounter struct {
+	NPCID         int               `json:"npc_id"`
+	Name          string            `json:"name"`
+	AnnounceRange float64           `json:"announce_range"` // 0 announces to the whole map
+	StartMessage  string            `json:"start_message"`
+	ResetMessage  string            `json:"reset_message"`
+	Phases        []*EncounterPhase `json:"phases"`
+	Enrage        *EncounterEnrage  `json:"enrage"`
+	Rewards       *EncounterReward  `json:"rewards"`
+}
+
+// EncounterPhase starts when the hp of the boss drops to HPPercent.
+type EncounterPhase struct {
+	HPPercent        int               `json:"hp_percent"`
+	Message          string            `json:"message"`
+	DamageMultiplier float64           `json:"damage_multiplier"`
+	Rotation         []*EncounterSkill `json:"rotation"`
+	Adds             []*EncounterAdd   `json:"adds"`
+}
+
+// EncounterSkill is a skill of SkillInfos cast every Interval seconds, the radius of the skill is used if Radius is 0.
+type EncounterSkill struct {
+	SkillID    int     `json:"skill_id"`
+	Interval   float64 `json:"interval"`
+	Multiplier float64 `json:"multiplier"`
+	Radius     float64 `json:"radius"`
+	Message    string  `json:"message"`
+}
+
+type EncounterAdd struct {
+	NPCID  int     `json:"npc_id"`
+	Count  int     `json:"count"`
+	Radius float64 `json:"radius"`
+}
+
+type EncounterEnrage struct {
+	Seconds          int     `json:"seconds"`
+	DamageMultiplier float64 `json:"damage_multiplier"`
+	Message          string  `json:"message"`
+}
+
+// EncounterReward is given to every present contributor besides the regular drops.
+type EncounterReward struct {
+	Gold           uint64           `json:"gold"`
+	Items          []*EncounterItem `json:"items"`
+	DropMultiplier float64          `json:"drop_multiplier"`
+	MinDrops       int              `json:"min_drops"`
+	Message        string           `json:"message"`
+}
+
+type EncounterItem struct {
+	ItemID   int64 `json:"item_id"`
+	Quantity uint  `json:"quantity"`
+	Chance   int   `json:"chance"` // per mille, 0 always drops
+}
+
+// EncounterRun is the state of an encounter in progress.
+type EncounterRun struct {
+	Encounter *Encounter
+	Phase     int
+	StartedAt time.Time
+	Enraged   bool
+	Adds      []*AI
+	lastCasts map[int]time.Time
+}
+
+// LoadEncounters reads the encounter files of EncounterDir and replaces the loaded encounters.
+func LoadEncounters() ([]string, error) {
+
+	files, err := filepath.Glob(filepath.Join(EncounterDir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("LoadEncounters: %s", err.Error())
+	}
+
+	encounters := make(map[int]*Encounter)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("LoadEncounters: %s", err.Error())
+		}
+
+		enc := &Encounter{}
+		if err := json.Unmarshal(data, enc); err != nil {
+			return nil, fmt.Errorf("LoadEncounters %s: %s", file, err.Error())
+		}
+
+		sort.SliceStable(enc.Phases, func(i, j int) bool {
+			return enc.Phases[i].HPPercent > enc.Phases[j].HPPercent
+		})
+		encounters[enc.NPCID] = enc
+	}
+
+	errs := validateEncounters(encounters)
+	if len(errs) > 0 {
+		return errs, fmt.Errorf("LoadEncounters: %s", strings.Join(errs, ", "))
+	}
+
+	encMutex.Lock()
+	Encounters = encounters
+	encMutex.Unlock()
+	return nil, nil
+}
+
+func validateEncounters(encounters map[int]*Encounter) []string {
+
+	errs := []string{}
+	for id, enc := range encounters {
+		if _, ok := NPCs[id]; !ok {
+			errs = append(errs, fmt.Sprintf("encounter %d: unknown npc", id))
+		}
+		if len(enc.Phases) == 0 {
+			errs = append(errs, fmt.Sprintf("encounter %d: no phases", id))
+		}
+
+		for _, phase := range enc.Phases {
+			for _, skill := range phase.Rotation {
+				if _, ok := SkillInfos[skill.SkillID]; !ok {
+					errs = append(errs, fmt.Sprintf("encounter %d: unknown skill %d", id, skill.SkillID))
+				}
+			}
+			for _, add := range phase.Adds {
+				if _, ok := NPCs[add.NPCID]; !ok {
+					errs = append(errs, fmt.Sprintf("encounter %d: unknown add %d", id, add.NPCID))
+				}
+			}
+		}
+
+		if enc.Rewards != nil {
+			for _, item := range enc.Rewards.Items {
+				if _, ok := Items[item.ItemID]; !ok {
+					errs = append(errs, fmt.Sprintf("encounter %d: unknown reward item %d", id, item.ItemID))
+				}
+			}
+		}
+	}
+
+	sort.Strings(errs)
+	return errs
+}
+
+func findEncounter(npcID int) *Encounter {
+	encMutex.Lock()
+	defer encMutex.Unlock()
+	return Encounters[npcID]
+}
+
+// DamageMultiplier returns the damage multiplier of the current phase and enrage of the mob.
+func (ai *AI) DamageMultiplier() float64 {
+	run := ai.Encounter
+	if run == nil || run.Phase < 0 {
+		return 1
+	}
+
+	multiplier := 1.0
+	if phase := run.Encounter.Phases[run.Phase]; phase.DamageMultiplier > 0 {
+		multiplier = phase.DamageMultiplier
+	}
+	if run.Enraged && run.Encounter.Enrage.DamageMultiplier > 0 {
+		multiplier *= run.Encounter.Enrage.DamageMultiplier
+	}
+
+	return multiplier
+}
+
+// encounterTick advances the encounter of a boss, it is called by the AI after each state change.
+func (ai *AI) encounterTick(ctx *AIContext) {
+
+	enc := findEncounter(ctx.NPC.ID)
+	if enc == nil {
+		return
+	}
+
+	run := ai.Encounter
+	if run == nil {
+		if ai.State != AI_STATE_COMBAT {
+			return
+		}
+
+		run = &EncounterRun{Encounter: enc, Phase: -1, StartedAt: time.Now(), lastCasts: make(map[int]time.Time)}
+		ai.Encounter = run
+		ai.announce(enc, enc.StartMessage)
+	}
+
+	if ai.State == AI_STATE_RESET || ai.State == AI_STATE_IDLE {
+		ai.endEncounter()
+		ai.announce(enc, enc.ResetMessage)
+		return
+	}
+
+	hp := ai.HP * 100 / int(math.Max(float64(ctx.NPC.MaxHp), 1))
+	for run.Phase+1 < len(enc.Phases) && (run.Phase < 0 || hp <= enc.Phases[run.Phase+1].HPPercent) {
+		run.Phase++
+		phase := enc.Phases[run.Phase]
+		ai.announce(enc, phase.Message)
+		for _, skill := range phase.Rotation { // the rotation starts after the first interval
+			run.lastCasts[skill.SkillID] = time.Now()
+		}
+		for _, add := range phase.Adds {
+			ai.spawnAdds(ctx.Pos, add)
+		}
+	}
+
+	if enc.Enrage != nil && !run.Enraged && enc.Enrage.Seconds > 0 && time.Since(run.StartedAt) >= time.Duration(enc.Enrage.Seconds)*time.Second {
+		run.Enraged = true
+		ai.announce(enc, enc.Enrage.Message)
+	}
+
+	if ai.State != AI_STATE_COMBAT {
+		return
+	}
+
+	for _, skill := range enc.Phases[run.Phase].Rotation {
+		interval := skill.Interval
+		if info, ok := SkillInfos[skill.SkillID]; ok && interval == 0 {
+			interval = info.Cooldown
+		}
+
+		if time.Since(run.lastCasts[skill.SkillID]) < time.Duration(interval*float64(time.Second)) {
+			continue
+		}
+
+		run.lastCasts[skill.SkillID] = time.Now()
+		ai.castEncounterSkill(ctx, enc, skill)
+		break // a single skill per tick
+	}
+}
+
+// castEncounterSkill hits the target of the boss and the characters on its threat table inside the skill radius.
+func (ai *AI) castEncounterSkill(ctx *AIContext, enc *Encounter, skill *EncounterSkill) {
+
+	target, err := FindCharacterByID(ai.threatTarget)
+	if err != nil || target == nil || !ai.canTarget(target, ctx.NPC) {
+		return
+	}
+
+	radius := skill.Radius
+	if info, ok := SkillInfos[skill.SkillID]; ok && radius == 0 {
+		radius = info.BaseRadius
+	}
+
+	multiplier := skill.Multiplier
+	if multiplier == 0 {
+		multiplier = 1
+	}
+
+	targets := []*Character{target}
+	if radius > 0 {
+		center := ConvertPointToLocation(target.Coordinate)
+		for _, id := range ai.Threat.Sorted() {
+			c, err := FindCharacterByID(id)
+			if err != nil || c == nil || c.ID == target.ID || !ai.canTarget(c, ctx.NPC) {
+				continue
+			}
+			if utils.CalculateDistance(center, ConvertPointToLocation(c.Coordinate)) <= radius {
+				targets = append(targets, c)
+			}
+		}
+	}
+
+	resp := ai.skillPacket(target, skill.SkillID)
+	for _, c := range targets {
+		rawDamage := float64(utils.RandInt(int64(ctx.NPC.MinArtsATK), int64(ctx.NPC.MaxArtsATK))) * multiplier * ai.DamageMultiplier()
+		damage := int(math.Max(rawDamage-float64(c.Socket.Stats.ArtsDEF), 3))
+		resp.Concat(ai.dealDamageTo(c, damage))
+	}
+
+	p := nats.CastPacket{CastNear: true, MobID: ai.ID, Data: resp, Type: nats.MOB_ATTACK}
+	p.Cast()
+
+	ai.announce(enc, skill.Message)
+}
+
+// spawnAdds spawns the adds of a phase around the boss and sends them to the targets of the boss.
+func (ai *AI) spawnAdds(bossPos *NpcPosition, add *EncounterAdd) {
+
+	npc, ok := NPCs[add.NPCID]
+	if !ok || GenerateIDForAI == nil || ai.Server >= len(AIsByMap) {
+		return
+	}
+
+	center := ConvertPointToLocation(ai.Coordinate)
+	threat := ai.Threat.Sorted()
+
+	for i := 0; i < add.Count; i++ {
+		alfa := utils.RandFloat(0, 2*math.Pi)
+		loc := utils.Location{X: center.X + add.Radius*math.Cos(alfa), Y: center.Y + add.Radius*math.Sin(alfa)}
+
+		npcPos := &NpcPosition{NPCID: npc.ID, MapID: ai.Map, Attackable: true, IsNPC: false, RespawnTime: 30, Count: 1,
+			MinLocation: bossPos.MinLocation, MaxLocation: bossPos.MaxLocation}
+		AddNPCPos(npcPos)
+
+		newai := &AI{HP: npc.MaxHp, Map: ai.Map, PosID: npcPos.ID, RunningSpeed: ai.RunningSpeed, Server: ai.Server,
+			WalkingSpeed: ai.WalkingSpeed, Once: true, InstanceID: ai.InstanceID}
+		newai.OnSightPlayers = make(map[int]interface{})
+		newai.Coordinate = loc.String()
+		newai.TargetLocation = loc
+		newai.Handler = newai.AIHandler
+		for _, id := range threat {
+			newai.Threat.Add(id, 1)
+		}
+		if len(threat) > 0 {
+			newai.State = AI_STATE_COMBAT
+		}
+
+		addAI(newai)
+
+		ai.Encounter.Adds = append(ai.Encounter.Adds, newai)
+	}
+}
+
+// endEncounter removes the adds of the encounter and releases their positions.
+func (ai *AI) endEncounter() {
+	run := ai.Encounter
+	if run == nil {
+		return
+	}
+
+	for _, add := range run.Adds {
+		removeAI(add)
+	}
+
+	ai.Encounter = nil
+}
+
+// CompleteEncounter gives the encounter rewards to the present contributors of the boss.
+func (ai *AI) CompleteEncounter(killer *Character, npc *NPC) {
+
+	enc := findEncounter(npc.ID)
+	ai.endEncounter()
+	if enc == nil || enc.Rewards == nil {
+		return
+	}
+
+	for _, contributor := range ai.Contributors(killer) {
+		c, err := FindCharacterByID(contributor.CharacterID)
+		if err != nil || c == nil || !contributor.Present {
+			continue
+		}
+
+		resp := utils.Packet{}
+		if enc.Rewards.Gold > 0 {
+			resp.Concat(c.LootGold(enc.Rewards.Gold))
+		}
+		resp.Concat(giveRewardItems(c, enc.Rewards.Items))
+
+		c.Socket.Write(resp)
+	}
+
+	ai.announce(enc, strings.Replace(enc.Rewards.Message, "{killer}", killer.Name, -1))
+}
+
+// giveRewardItems adds the items which pass their chance to the inventory of the character.
+func giveRewardItems(c *Character, items []*EncounterItem) []byte {
+	resp := utils.Packet{}
+	for _, item := range items {
+		if item.Chance > 0 && int(utils.RandInt(0, 1000)) >= item.Chance {
+			continue
+		}
+
+		reward := NewSlot()
+		reward.ItemID = item.ItemID
+		reward.Quantity = item.Quantity
+		if reward.Quantity == 0 {
+			reward.Quantity = 1
+		}
+
+		data, _, err := c.AddItem(reward, -1, false)
+		if err == nil && data != nil {
+			resp.Concat(*data)
+		}
+	}
+	return resp
+}
+
+// announce sends the message to the characters near the boss.
+func (ai *AI) announce(enc *Encounter, msg string) {
+	if msg == "" {
+		return
+	}
+
+	resp := ANNOUNCEMENT
+	resp.SetLength(int16(len(msg) + 3))
+	resp[6] = byte(len(msg))
+	resp.Insert([]byte(msg), 7)
+
+	center := ConvertPointToLocation(ai.Coordinate)
+	for _, c := range FindCharactersInMap(ai.Map) {
+		if c.Socket.User == nil || c.Socket.User.ConnectedServer != ai.Server || c.InstanceID != ai.InstanceID {
+			continue
+		}
+		if enc.AnnounceRange > 0 && utils.CalculateDistance(center, ConvertPointToLocation(c.Coordinate)) > enc.AnnounceRange {
+			continue
+		}
+		c.Socket.Write(resp)
+	}
+}
@@ -0,0 +1,594 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron"
+	gorp "gopkg.in/gorp.v1"
+	null "gopkg.in/guregu/null.v3"
+)
+
+const (
+	EVENT_GREAT_WAR    = "great-war"
+	EVENT_FACTION_WAR  = "faction-war"
+	EVENT_EXP_RATE     = "exp-rate"
+	EVENT_DROP_RATE    = "drop-rate"
+	EVENT_MAINTENANCE  = "maintenance"
+	EVENT_ANNOUNCEMENT = "announcement"
+	EVENT_BATTLEGROUND = "battleground"
+)
+
+var (
+	ScheduledEvents = make(map[int]*ScheduledEvent)
+	eventMutex      sync.RWMutex
+
+	// announcementMarks are the remaining seconds the upcoming events are announced at,
+	// only the marks within the announce time of the event are used.
+	announcementMarks = []int{3600, 1800, 900, 600, 300, 120, 60, 30, 10}
+
+	eventTypes = map[string]*EventType{
+		EVENT_GREAT_WAR:    {Start: startGreatWarEvent, Stop: stopGreatWarEvent},
+		EVENT_FACTION_WAR:  {Start: startFactionWarEvent, Stop: stopFactionWarEvent},
+		EVENT_EXP_RATE:     {Start: startExpRateEvent, Stop: stopExpRateEvent},
+		EVENT_DROP_RATE:    {Start: startDropRateEvent, Stop: stopDropRateEvent},
+		EVENT_MAINTENANCE:  {Start: startMaintenanceEvent},
+		EVENT_ANNOUNCEMENT: {Start: startAnnouncementEvent},
+		EVENT_BATTLEGROUND: {Start: startBattlegroundEvent, Stop: stopBattlegroundEvent},
+	}
+)
+
+// EventType holds the hooks of an event type, Stop is called when the duration of the event is over or a GM
+// stops it. The hooks run without eventMutex held.
+type EventType struct {
+	Start func(e *ScheduledEvent) error
+	Stop  func(e *ScheduledEvent)
+}
+
+// ScheduledEvent is an event started by its cron schedule, the schedule has a seconds field
+// ("0 0 20 * * *") or is a descriptor like "@every 5h".
+type ScheduledEvent struct {
+	ID       int       `db:"id"`
+	Type     string    `db:"type"`
+	Name     string    `db:"name"`
+	Schedule string    `db:"schedule"`
+	Duration int       `db:"duration"` // seconds, 0 when the event ends by itself
+	Announce int       `db:"announce"` // seconds before the start the event is announced from
+	Params   string    `db:"params"`
+	Paused   bool      `db:"paused"`
+	LastRun  null.Time `db:"last_run"`
+
+	schedule  cron.Schedule `db:"-"`
+	next      time.Time     `db:"-"`
+	stopAt    time.Time     `db:"-"`
+	running   bool          `db:"-"`
+	starting  bool          `db:"-"` // the Start hook is running
+	announced int           `db:"-"`
+}
+
+type eventParams struct {
+	Announcement string  `json:"announcement"` // replaces the pre-announcement, {time} is the time left
+	Message      string  `json:"message"`
+	Prepare      int     `json:"prepare"`
+	MinLevel     int     `json:"min_level"`
+	MaxLevel     int     `json:"max_level"`
+	Rate         float64 `json:"rate"`
+	Battleground int     `json:"battleground"`
+}
+
+// RegisterEventType adds or replaces the hooks of an event type.
+func RegisterEventType(name string, t *EventType) {
+	eventTypes[name] = t
+}
+
+func (e *ScheduledEvent) Create() error {
+	return db.Insert(e)
+}
+
+func (e *ScheduledEvent) CreateWithTransaction(tr *gorp.Transaction) error {
+	return tr.Insert(e)
+}
+
+func (e *ScheduledEvent) Update() error {
+	_, err := db.Update(e)
+	return err
+}
+
+func (e *ScheduledEvent) Delete() error {
+	_, err := db.Delete(e)
+	return err
+}
+
+// GetParams decodes the type specific parameters of the event into v.
+func (e *ScheduledEvent) GetParams(v interface{}) error {
+	if e.Params == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(e.Params), v)
+}
+
+// prepare parses the schedule and the parameters of the event.
+func (e *ScheduledEvent) prepare() error {
+	if _, ok := eventTypes[e.Type]; !ok {
+		return fmt.Errorf("event %d: unknown type %s", e.ID, e.Type)
+	}
+
+	schedule, err := cron.Parse(e.Schedule)
+	if err != nil {
+		return fmt.Errorf("event %d: %s", e.ID, err.Error())
+	}
+
+	if e.Params == "" {
+		e.Params = "{}"
+	}
+	if err := e.GetParams(&eventParams{}); err != nil {
+		return fmt.Errorf("event %d: invalid params: %s", e.ID, err.Error())
+	}
+
+	e.schedule = schedule
+	e.next = schedule.Next(time.Now())
+	e.announced = e.Announce + 1
+	return nil
+}
+
+func getScheduledEvents() error {
+	var events []*ScheduledEvent
+	query := `select * from hops.scheduled_events order by id`
+
+	if _, err := db.Select(&events, query); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("getScheduledEvents: %s", err.Error())
+	}
+
+	loaded := make(map[int]*ScheduledEvent)
+	for _, e := range events {
+		if err := e.prepare(); err != nil {
+			log.Printf("getScheduledEvents: %s", err.Error())
+			continue
+		}
+		loaded[e.ID] = e
+	}
+
+	eventMutex.Lock()
+	for id, e := range ScheduledEvents { // keep the running events running
+		if l, ok := loaded[id]; ok && e.running {
+			l.running, l.stopAt = true, e.stopAt
+		}
+	}
+	ScheduledEvents = loaded
+	eventMutex.Unlock()
+	return nil
+}
+
+// ReloadScheduledEvents reads the schedule from the database again.
+func ReloadScheduledEvents() error {
+	return getScheduledEvents()
+}
+
+func FindScheduledEvent(id int) *ScheduledEvent {
+	eventMutex.RLock()
+	defer eventMutex.RUnlock()
+	return ScheduledEvents[id]
+}
+
+// AddScheduledEvent saves a new event and adds it to the schedule.
+func AddScheduledEvent(e *ScheduledEvent) error {
+	if err := e.prepare(); err != nil {
+		return err
+	}
+	if err := e.Create(); err != nil {
+		return fmt.Errorf("AddScheduledEvent: %s", err.Error())
+	}
+
+	eventMutex.Lock()
+	ScheduledEvents[e.ID] = e
+	eventMutex.Unlock()
+	return nil
+}
+
+// RemoveScheduledEvent deletes the event, a running event is stopped first.
+func RemoveScheduledEvent(id int) error {
+	e := FindScheduledEvent(id)
+	if e == nil {
+		return fmt.Errorf("unknown event %d", id)
+	}
+
+	eventMutex.Lock()
+	stop := e.stop()
+	delete(ScheduledEvents, id)
+	eventMutex.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+	return e.Delete()
+}
+
+// PauseScheduledEvent stops or resumes the schedule of the event, a running event is not stopped.
+func PauseScheduledEvent(id int, paused bool) error {
+	e := FindScheduledEvent(id)
+	if e == nil {
+		return fmt.Errorf("unknown event %d", id)
+	}
+
+	eventMutex.Lock()
+	e.Paused = paused
+	e.next = e.schedule.Next(time.Now())
+	e.announced = e.Announce + 1
+	eventMutex.Unlock()
+	return e.Update()
+}
+
+// RunScheduledEvent starts the event now without announcing it.
+func RunScheduledEvent(id int) error {
+	e := FindScheduledEvent(id)
+	if e == nil {
+		return fmt.Errorf("unknown event %d", id)
+	}
+
+	eventMutex.Lock()
+	err := e.begin()
+	eventMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return e.launch(time.Now())
+}
+
+// StopScheduledEvent ends the running event before its duration is over, the events without a duration
+// are ended by their Stop hook.
+func StopScheduledEvent(id int) error {
+	e := FindScheduledEvent(id)
+	if e == nil {
+		return fmt.Errorf("unknown event %d", id)
+	}
+
+	eventMutex.Lock()
+	if !e.running && (e.Duration > 0 || eventTypes[e.Type].Stop == nil) {
+		eventMutex.Unlock()
+		return fmt.Errorf("event %d is not running", id)
+	}
+	e.running = true
+	stop := e.stop()
+	eventMutex.Unlock()
+
+	stop()
+	return nil
+}
+
+// RunEventScheduler announces, starts and stops the scheduled events, it never returns.
+func RunEventScheduler() {
+	for now := range time.Tick(time.Second) {
+		starting, stops := []*ScheduledEvent{}, []func(){}
+		eventMutex.Lock()
+		for _, e := range sortedEvents() {
+			start, stop := e.update(now)
+			if start {
+				starting = append(starting, e)
+			}
+			if stop != nil {
+				stops = append(stops, stop)
+			}
+		}
+		eventMutex.Unlock()
+
+		for _, stop := range stops {
+			stop()
+		}
+		for _, e := range starting {
+			if err := e.launch(now); err != nil {
+				log.Printf("event %d: %s", e.ID, err.Error())
+			}
+		}
+	}
+}
+
+// update announces the event and returns whether it starts now and the Stop hook to run when it is over.
+func (e *ScheduledEvent) update(now time.Time) (bool, func()) {
+
+	var stop func()
+	if e.running && !e.stopAt.IsZero() && !now.Before(e.stopAt) {
+		stop = e.stop()
+	}
+
+	if e.Paused {
+		return false, stop
+	}
+
+	remaining := int(e.next.Sub(now).Seconds())
+	if remaining > 0 {
+		e.announce(remaining)
+		return false, stop
+	}
+
+	start := false
+	if err := e.begin(); err != nil {
+		log.Printf("event %d: %s is still running, skipped", e.ID, e.Name)
+	} else {
+		start = true
+	}
+
+	e.next = e.schedule.Next(now)
+	e.announced = e.Announce + 1
+	return start, stop
+}
+
+// announce makes the announcement of the latest mark the remaining time reached.
+func (e *ScheduledEvent) announce(remaining int) {
+	if remaining > e.Announce {
+		return
+	}
+
+	mark := 0
+	for _, m := range append([]int{e.Announce}, announcementMarks...) {
+		if m <= e.Announce && m >= remaining && m < e.announced && (mark == 0 || m < mark) {
+			mark = m
+		}
+	}
+	if mark == 0 {
+		return
+	}
+	e.announced = mark
+
+	params := &eventParams{}
+	e.GetParams(params)
+	if params.Announcement != "" {
+		makeAnnouncement(strings.ReplaceAll(params.Announcement, "{time}", durationText(mark)))
+	} else {
+		makeAnnouncement(fmt.Sprintf("%s will start in %s.", e.Name, durationText(mark)))
+	}
+}
+
+// begin marks the event as starting, it is called with eventMutex held.
+func (e *ScheduledEvent) begin() error {
+	if e.running || e.starting {
+		return fmt.Errorf("event %d is already running", e.ID)
+	}
+
+	e.starting = true
+	return nil
+}
+
+// launch runs the Start hook of the event marked by begin, it is called without eventMutex held.
+func (e *ScheduledEvent) launch(now time.Time) error {
+
+	err := eventTypes[e.Type].Start(e)
+
+	eventMutex.Lock()
+	defer eventMutex.Unlock()
+	e.starting = false
+	if err != nil {
+		return err
+	}
+
+	e.running = e.Duration > 0
+	if e.running {
+		e.stopAt = now.Add(time.Duration(e.Duration) * time.Second)
+	}
+
+	e.LastRun = null.TimeFrom(now)
+	go func() {
+		if err := e.Update(); err != nil {
+			log.Println(err)
+		}
+	}()
+	return nil
+}
+
+// stop marks the event as stopped and returns its Stop hook, the caller runs it after releasing eventMutex.
+func (e *ScheduledEvent) stop() func() {
+	if !e.running {
+		return nil
+	}
+
+	e.running = false
+	e.stopAt = time.Time{}
+	if stop := eventTypes[e.Type].Stop; stop != nil {
+		return func() {
+			stop(e)
+		}
+	}
+	return func() {}
+}
+
+func durationText(seconds int) string {
+	switch {
+	case seconds >= 3600 && seconds%3600 == 0:
+		return fmt.Sprintf("%d hours", seconds/3600)
+	case seconds >= 60 && seconds%60 == 0:
+		return fmt.Sprintf("%d minutes", seconds/60)
+	}
+	return fmt.Sprintf("%d seconds", seconds)
+}
+
+// EventLines describes the scheduled events.
+func EventLines() []string {
+	eventMutex.RLock()
+	defer eventMutex.RUnlock()
+
+	lines := []string{}
+	for _, e := range sortedEvents() {
+		state := "next " + e.next.Format("2006-01-02 15:04:05")
+		if e.running {
+			state = "running until " + e.stopAt.Format("15:04:05")
+		} else if e.Paused {
+			state = "paused"
+		}
+		lines = append(lines, fmt.Sprintf("Event %d: %s (%s) \"%s\", %s", e.ID, e.Name, e.Type, e.Schedule, state))
+	}
+	return lines
+}
+
+func sortedEvents() []*ScheduledEvent {
+	events := make([]*ScheduledEvent, 0, len(ScheduledEvents))
+	for _, e := range ScheduledEvents {
+		events = append(events, e)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+	return events
+}
+
+// EventTypes returns the names of the registered event types.
+func EventTypes() []string {
+	types := []string{}
+	for name := range eventTypes {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func startGreatWarEvent(e *ScheduledEvent) error {
+	params := &eventParams{}
+	if err := e.GetParams(params); err != nil {
+		return err
+	}
+
+	return OpenBattleground(BATTLEGROUND_GREAT_WAR, params.Prepare, 0, 0)
+}
+
+func startFactionWarEvent(e *ScheduledEvent) error {
+	params := &eventParams{}
+	if err := e.GetParams(params); err != nil {
+		return err
+	}
+
+	return OpenBattleground(BATTLEGROUND_FACTION_WAR, params.Prepare, params.MinLevel, params.MaxLevel)
+}
+
+func startBattlegroundEvent(e *ScheduledEvent) error {
+	params := &eventParams{}
+	if err := e.GetParams(params); err != nil {
+		return err
+	}
+
+	return OpenBattleground(params.Battleground, params.Prepare, params.MinLevel, params.MaxLevel)
+}
+
+func stopGreatWarEvent(e *ScheduledEvent) {
+	StopBattlegrounds(BATTLEGROUND_GREAT_WAR)
+}
+
+func stopFactionWarEvent(e *ScheduledEvent) {
+	StopBattlegrounds(BATTLEGROUND_FACTION_WAR)
+}
+
+func stopBattlegroundEvent(e *ScheduledEvent) {
+	params := &eventParams{}
+	if err := e.GetParams(params); err != nil {
+		log.Printf("event %d: %s", e.ID, err.Error())
+		return
+	}
+
+	StopBattlegrounds(params.Battleground)
+}
+
+func startExpRateEvent(e *ScheduledEvent) error {
+	params := &eventParams{}
+	if err := e.GetParams(params); err != nil {
+		return err
+	} else if params.Rate <= 0 {
+		return fmt.Errorf("event %d: no rate", e.ID)
+	}
+
+	EXP_RATE = params.Rate
+	makeAnnouncement(fmt.Sprintf("%s has started, exp rate is now %.1f.", e.Name, EXP_RATE))
+	return nil
+}
+
+func stopExpRateEvent(e *ScheduledEvent) {
+	EXP_RATE = DEFAULT_EXP_RATE
+	makeAnnouncement(fmt.Sprintf("%s has ended.", e.Name))
+}
+
+func startDropRateEvent(e *ScheduledEvent) error {
+	params := &eventParams{}
+	if err := e.GetParams(params); err != nil {
+		return err
+	} else if params.Rate <= 0 {
+		return fmt.Errorf("event %d: no rate", e.ID)
+	}
+
+	DROP_RATE = params.Rate
+	makeAnnouncement(fmt.Sprintf("%s has started, drop rate is now %.1f.", e.Name, DROP_RATE))
+	return nil
+}
+
+func stopDropRateEvent(e *ScheduledEvent) {
+	DROP_RATE = DEFAULT_DROP_RATE
+	makeAnnouncement(fmt.Sprintf("%s has ended.", e.Name))
+}
+
+func startMaintenanceEvent(e *ScheduledEvent) error {
+	params := &eventParams{Prepare: 60}
+	if err := e.GetParams(params); err != nil {
+		return err
+	}
+
+	CountMaintenance(params.Prepare)
+	return nil
+}
+
+func startAnnouncementEvent(e *ScheduledEvent) error {
+	params := &eventParams{}
+	if err := e.GetParams(params); err != nil {
+		return err
+	} else if params.Message == "" {
+		return fmt.Errorf("event %d: no message", e.ID)
+	}
+
+	makeAnnouncement(params.Message)
+	return nil
+}
+
+// CountMaintenance announces the maintenance every 10 seconds until it starts.
+func CountMaintenance(cd int) {
+	msg := fmt.Sprintf("There will be maintenance after %d seconds. Please log out in order to prevent any inconvenience.", cd)
+	makeAnnouncement(msg)
+
+	if cd > 0 {
+		time.AfterFunc(time.Second*10, func() {
+			CountMaintenance(cd - 10)
+		})
+	}
+}
+
+// SetParams reads "key=value" arguments into the parameters of the event, name sets the name of
+// the event. Values which are not json are text with underscores standing for spaces.
+func (e *ScheduledEvent) SetParams(args []string) error {
+	params := make(map[string]interface{})
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("invalid parameter %s", arg)
+		}
+
+		var v interface{}
+		if err := json.Unmarshal([]byte(kv[1]), &v); err != nil {
+			v = strings.ReplaceAll(kv[1], "_", " ")
+		}
+
+		if kv[0] == "name" {
+			e.Name = fmt.Sprint(v)
+		} else {
+			params[kv[0]] = v
+		}
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	e.Params = string(data)
+	return nil
+}
@@ -56,21 +56,3 @@ func getExps() error {
 	}
 	return nil
 }
-
-func GetExps() error {
-
-	var arr []*ExpInfo
-	query := `select * from data.exp_table`
-
-	if _, err := db.Select(&arr, query); err != nil {
-		if err == sql.ErrNoRows {
-			return nil
-		}
-		return nil
-	}
-
-	for _, e := range arr {
-		EXPs[e.Level] = e
-	}
-	return nil
-}
@@ -2,19 +2,64 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"hero-emulator/messaging"
 
 	null "gopkg.in/guregu/null.v3"
 )
 
 var (
 	FiveClans = make(map[int]*FiveClan)
+
+	// TerritoryDir holds one json file per Five Clan area.
+	TerritoryDir   = "data/territories"
+	Territories    = make(map[int]*Territory)
+	territoryMutex sync.RWMutex
 )
 
 type FiveClan struct {
-	AreaID    int       `db:"id"`
-	ClanID    int       `db:"clanid"`
-	ExpiresAt null.Time `db:"expires_at" json:"expires_at"`
+	AreaID     int       `db:"id"`
+	ClanID     int       `db:"clanid"`
+	ExpiresAt  null.Time `db:"expires_at" json:"expires_at"`
+	CapturedAt null.Time `db:"captured_at" json:"captured_at"`
+}
+
+// Territory is the data of a Five Clan area: the statue which captures it, the region it taxes and what its owner gets.
+type Territory struct {
+	ID            int               `json:"id"`
+	Name          string            `json:"name"`
+	StatueID      int               `json:"statue_id"`
+	Slot          int               `json:"slot"`     // the area of the clan castle menu and of the travel requests
+	MenuID        int               `json:"menu_id"`  // sent with the slot in the clan castle menu
+	Teleport      string            `json:"teleport"` // where the members of the owner travel to
+	Map           int16             `json:"map"`
+	Area          *BattlegroundArea `json:"area"`
+	Buff          *TerritoryBuff    `json:"buff"`
+	Ownership     int64             `json:"ownership"`
+	Protection    int64             `json:"protection"`
+	MinGuildLevel int16             `json:"min_guild_level"`
+	Tax           int               `json:"tax"`
+}
+
+// TerritoryBuff is given to every member of the house which captures the area.
+type TerritoryBuff struct {
+	InfectionID int   `json:"infection_id"`
+	ExpRate     int   `json:"exp_rate"`
+	DropRate    int   `json:"drop_rate"`
+	Duration    int64 `json:"duration"`
+}
+
+func (b *FiveClan) Create() error {
+	return db.Insert(b)
 }
 
 func (b *FiveClan) Update() error {
@@ -33,109 +78,356 @@ func getFiveAreas() error {
 		return fmt.Errorf("getFiveAreas: %s", err.Error())
 	}
 
+	territoryMutex.Lock()
 	for _, cr := range areas {
 		FiveClans[cr.AreaID] = cr
 	}
+	territoryMutex.Unlock()
 
+	for _, cr := range areas {
+		cr.scheduleExpiry()
+	}
 	return nil
 }
 
-func CaptureFive(number int, char *Character) {
-	switch number {
-	case 1:
-		guild, err := FindGuildByID(char.GuildID)
+// LoadTerritories reads the Five Clan areas, every area gets its ownership row on the first load.
+func LoadTerritories() ([]string, error) {
+
+	files, err := filepath.Glob(filepath.Join(TerritoryDir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("LoadTerritories: %s", err.Error())
+	}
+
+	territories := make(map[int]*Territory)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
 		if err != nil {
-			return
+			return nil, fmt.Errorf("LoadTerritories: %s", err.Error())
 		}
-		allmembers, _ := guild.GetMembers()
-		for _, m := range allmembers {
-			c, err := FindCharacterByID(m.ID)
-			if err != nil || c == nil {
-				continue
-			}
-			infection := BuffInfections[60006]
-			makeAnnouncement("[" + guild.Name + "] captured [Southern Wood Temple]")
-			buff := &Buff{ID: infection.ID, CharacterID: c.ID, Name: infection.Name, EXPMultiplier: 200, StartedAt: c.Epoch, Duration: 7200, CanExpire: true}
-			err = buff.Create()
-			if err != nil {
-				continue
-			}
+
+		t := &Territory{}
+		if err := json.Unmarshal(data, t); err != nil {
+			return nil, fmt.Errorf("LoadTerritories %s: %s", file, err.Error())
 		}
-	case 2:
-		guild, err := FindGuildByID(char.GuildID)
-		if err != nil {
-			return
+		territories[t.ID] = t
+	}
+
+	errs := validateTerritories(territories)
+	if len(errs) > 0 {
+		return errs, fmt.Errorf("LoadTerritories: %s", strings.Join(errs, ", "))
+	}
+
+	territoryMutex.Lock()
+	Territories = territories
+	for id := range territories {
+		if _, ok := FiveClans[id]; ok {
+			continue
 		}
-		allmembers, _ := guild.GetMembers()
-		for _, m := range allmembers {
-			c, err := FindCharacterByID(m.ID)
-			if err != nil || c == nil {
-				continue
-			}
-			infection := BuffInfections[60011]
-			buff := &Buff{ID: infection.ID, CharacterID: c.ID, Name: infection.Name, EXPMultiplier: 200, StartedAt: c.Epoch, Duration: 7200, CanExpire: true}
-			makeAnnouncement("[" + guild.Name + "] captured [Lightning Hill Temple]")
-			err = buff.Create()
-			if err != nil {
-				continue
-			}
+		area := &FiveClan{AreaID: id}
+		if err := area.Create(); err != nil {
+			log.Println(err)
+			continue
 		}
-	case 3:
-		guild, err := FindGuildByID(char.GuildID)
-		if err != nil {
-			return
+		FiveClans[id] = area
+	}
+	territoryMutex.Unlock()
+	return nil, nil
+}
+
+func validateTerritories(territories map[int]*Territory) []string {
+
+	errs := []string{}
+	statues, slots := make(map[int]int), make(map[int]int)
+	for id, t := range territories {
+		if id <= 0 {
+			errs = append(errs, fmt.Sprintf("territory %s: invalid id", t.Name))
 		}
-		allmembers, _ := guild.GetMembers()
-		for _, m := range allmembers {
-			c, err := FindCharacterByID(m.ID)
-			if err != nil || c == nil {
-				continue
-			}
-			infection := BuffInfections[60016]
-			buff := &Buff{ID: infection.ID, CharacterID: c.ID, Name: infection.Name, EXPMultiplier: 200, StartedAt: c.Epoch, Duration: 7200, CanExpire: true}
-			makeAnnouncement("[" + guild.Name + "] captured [Ocean Army Temple]")
-			err = buff.Create()
-			if err != nil {
-				continue
-			}
+		if t.StatueID <= 0 {
+			errs = append(errs, fmt.Sprintf("territory %d: no statue", id))
+		} else if other, ok := statues[t.StatueID]; ok {
+			errs = append(errs, fmt.Sprintf("territory %d: statue %d already captures territory %d", id, t.StatueID, other))
 		}
-	case 4:
-		guild, err := FindGuildByID(char.GuildID)
-		if err != nil {
-			return
+		statues[t.StatueID] = id
+		if t.Slot <= 0 {
+			errs = append(errs, fmt.Sprintf("territory %d: no slot", id))
+		} else if other, ok := slots[t.Slot]; ok {
+			errs = append(errs, fmt.Sprintf("territory %d: slot %d is used by territory %d", id, t.Slot, other))
 		}
-		allmembers, _ := guild.GetMembers()
-		for _, m := range allmembers {
-			c, err := FindCharacterByID(m.ID)
-			if err != nil || c == nil {
-				continue
-			}
-			infection := BuffInfections[60021]
-			buff := &Buff{ID: infection.ID, CharacterID: c.ID, Name: infection.Name, EXPMultiplier: 200, StartedAt: c.Epoch, Duration: 7200, CanExpire: true}
-			makeAnnouncement("[" + guild.Name + "] captured [Flame Wolf Temple]")
-			err = buff.Create()
-			if err != nil {
-				continue
+		slots[t.Slot] = id
+		if len(strings.Split(t.Teleport, ",")) != 2 {
+			errs = append(errs, fmt.Sprintf("territory %d: invalid teleport %q", id, t.Teleport))
+		}
+		if t.Ownership <= 0 {
+			errs = append(errs, fmt.Sprintf("territory %d: no ownership time", id))
+		}
+		if t.Tax < 0 || t.Tax > 100 {
+			errs = append(errs, fmt.Sprintf("territory %d: tax out of 0-100", id))
+		}
+		if t.Buff != nil {
+			if _, ok := BuffInfections[t.Buff.InfectionID]; !ok {
+				errs = append(errs, fmt.Sprintf("territory %d: unknown buff infection %d", id, t.Buff.InfectionID))
 			}
 		}
-	case 5:
-		guild, err := FindGuildByID(char.GuildID)
-		if err != nil {
+	}
+
+	sort.Strings(errs)
+	return errs
+}
+
+func FindTerritory(id int) *Territory {
+	territoryMutex.RLock()
+	defer territoryMutex.RUnlock()
+	return Territories[id]
+}
+
+// FindTerritoryBySlot returns the territory of the clan castle menu slot.
+func FindTerritoryBySlot(slot int) *Territory {
+	territoryMutex.RLock()
+	defer territoryMutex.RUnlock()
+	for _, t := range Territories {
+		if t.Slot == slot {
+			return t
+		}
+	}
+	return nil
+}
+
+// TerritoriesBySlot returns the territories in the order of the clan castle menu.
+func TerritoriesBySlot() []*Territory {
+	territoryMutex.RLock()
+	defer territoryMutex.RUnlock()
+
+	territories := make([]*Territory, 0, len(Territories))
+	for _, t := range Territories {
+		territories = append(territories, t)
+	}
+	sort.Slice(territories, func(i, j int) bool {
+		return territories[i].Slot < territories[j].Slot
+	})
+	return territories
+}
+
+func findTerritoryByStatue(npcID int) *Territory {
+	territoryMutex.RLock()
+	defer territoryMutex.RUnlock()
+	for _, t := range Territories {
+		if t.StatueID == npcID {
+			return t
+		}
+	}
+	return nil
+}
+
+// TerritoryOwner returns the guild id which owns the area, 0 when nobody does.
+func TerritoryOwner(areaID int) int {
+	territoryMutex.RLock()
+	defer territoryMutex.RUnlock()
+
+	area, ok := FiveClans[areaID]
+	if !ok || (area.ExpiresAt.Valid && area.ExpiresAt.Time.Before(time.Now())) {
+		return 0
+	}
+	return area.ClanID
+}
+
+// scheduleExpiry gives the area back when its ownership runs out.
+func (b *FiveClan) scheduleExpiry() {
+	if b.ClanID == 0 || !b.ExpiresAt.Valid {
+		return
+	}
+
+	expiresAt := b.ExpiresAt.Time
+	time.AfterFunc(time.Until(expiresAt), func() {
+		territoryMutex.Lock()
+		if b.ClanID == 0 || !b.ExpiresAt.Valid || !b.ExpiresAt.Time.Equal(expiresAt) { // captured again since
+			territoryMutex.Unlock()
 			return
 		}
-		allmembers, _ := guild.GetMembers()
-		for _, m := range allmembers {
-			c, err := FindCharacterByID(m.ID)
-			if err != nil || c == nil {
-				continue
-			}
-			infection := BuffInfections[60026]
-			buff := &Buff{ID: infection.ID, CharacterID: c.ID, Name: infection.Name, EXPMultiplier: 200, StartedAt: c.Epoch, Duration: 7200, CanExpire: true}
-			makeAnnouncement("[" + guild.Name + "] captured [Western Land Temple]")
-			err = buff.Create()
-			if err != nil {
-				continue
+
+		guildID := b.ClanID
+		b.ClanID = 0
+		b.ExpiresAt = null.TimeFromPtr(nil)
+		territoryMutex.Unlock()
+
+		if err := b.Update(); err != nil {
+			log.Println(err)
+		}
+
+		t := FindTerritory(b.AreaID)
+		if guild, err := FindGuildByID(guildID); err == nil && guild != nil && t != nil {
+			makeAnnouncement(fmt.Sprintf("[%s] no longer holds [%s]", guild.Name, t.Name))
+		}
+	})
+}
+
+// CaptureTerritory gives the area of the statue to the house of the character which destroyed it.
+func CaptureTerritory(npcID int, c *Character) {
+	t := findTerritoryByStatue(npcID)
+	if t == nil || c == nil || c.GuildID <= 0 {
+		return
+	}
+
+	guild, err := FindGuildByID(c.GuildID)
+	if err != nil || guild == nil {
+		return
+	} else if guild.Level().Level < t.MinGuildLevel {
+		if c.Socket != nil {
+			c.Socket.Write(messaging.InfoMessage(fmt.Sprintf("Your house needs level %d to hold [%s].", t.MinGuildLevel, t.Name)))
+		}
+		return
+	}
+
+	now := time.Now()
+	territoryMutex.Lock()
+	area, ok := FiveClans[t.ID]
+	if !ok {
+		territoryMutex.Unlock()
+		return
+	}
+
+	protected := area.ClanID != 0 && area.ClanID != guild.ID && area.CapturedAt.Valid &&
+		now.Before(area.CapturedAt.Time.Add(time.Duration(t.Protection)*time.Second)) &&
+		area.ExpiresAt.Valid && now.Before(area.ExpiresAt.Time)
+	if protected {
+		territoryMutex.Unlock()
+		if c.Socket != nil {
+			c.Socket.Write(messaging.InfoMessage(fmt.Sprintf("[%s] cannot be captured yet.", t.Name)))
+		}
+		return
+	}
+
+	area.ClanID = guild.ID
+	area.CapturedAt = null.TimeFrom(now)
+	area.ExpiresAt = null.TimeFrom(now.Add(time.Duration(t.Ownership) * time.Second))
+	territoryMutex.Unlock()
+
+	if err := area.Update(); err != nil {
+		log.Println(err)
+	}
+	area.scheduleExpiry()
+
+	makeAnnouncement(fmt.Sprintf("[%s] captured [%s]", guild.Name, t.Name))
+	AddGuildActivity(c, GUILD_CAPTURE_POINTS, 0)
+
+	if t.Buff == nil {
+		return
+	}
+
+	members, _ := guild.GetMembers()
+	for _, m := range members {
+		member, err := FindCharacterByID(m.ID)
+		if err != nil || member == nil {
+			continue
+		}
+		t.Buff.give(member)
+	}
+}
+
+// give applies the buff to the character, a buff of an earlier capture is renewed.
+func (b *TerritoryBuff) give(c *Character) {
+	infection, ok := BuffInfections[b.InfectionID]
+	if !ok {
+		return
+	}
+
+	buff, err := FindBuffByID(infection.ID, c.ID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	isNew := buff == nil
+	buff = &Buff{ID: infection.ID, CharacterID: c.ID, Name: infection.Name, EXPMultiplier: b.ExpRate, DropMultiplier: b.DropRate,
+		StartedAt: c.Epoch, Duration: b.Duration, CanExpire: true}
+	if isNew {
+		err = buff.Create()
+	} else {
+		err = buff.Update()
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// TerritoryAt returns the taxed area whose region the character stands in.
+func TerritoryAt(c *Character) *Territory {
+	territoryMutex.RLock()
+	defer territoryMutex.RUnlock()
+
+	location := ConvertPointToLocation(c.Coordinate)
+	for _, t := range Territories {
+		if t.Map != c.Map || t.Tax == 0 {
+			continue
+		}
+		if t.Area == nil || t.Area.Contains(location) {
+			return t
+		}
+	}
+	return nil
+}
+
+// CollectTerritoryTax moves the tax share of the gold spent by the character in a held region into the warehouse of
+// the owning house and returns the share.
+func CollectTerritoryTax(c *Character, gold uint64) uint64 {
+	t := TerritoryAt(c)
+	if t == nil || gold == 0 {
+		return 0
+	}
+
+	owner := TerritoryOwner(t.ID)
+	if owner == 0 {
+		return 0
+	}
+
+	guild, err := FindGuildByID(owner)
+	if err != nil || guild == nil {
+		return 0
+	}
+
+	tax := gold * uint64(t.Tax) / 100
+	if tax == 0 {
+		return 0
+	}
+
+	guild.bankMutex.Lock()
+	guild.BankGold += tax
+	guild.bankMutex.Unlock()
+
+	go func() {
+		guild.recordBank(c, GUILD_BANK_TAX, 0, 0, tax)
+		if err := guild.Update(); err != nil {
+			log.Println(err)
+		}
+	}()
+	return tax
+}
+
+// TerritoryLines describes the owners of the Five Clan areas.
+func TerritoryLines() []string {
+	territoryMutex.RLock()
+	ids := []int{}
+	for id := range Territories {
+		ids = append(ids, id)
+	}
+	territoryMutex.RUnlock()
+	sort.Ints(ids)
+
+	lines := []string{}
+	for _, id := range ids {
+		t := FindTerritory(id)
+		owner := "nobody"
+		if guildID := TerritoryOwner(id); guildID > 0 {
+			if g, err := FindGuildByID(guildID); err == nil && g != nil {
+				territoryMutex.RLock()
+				owner = fmt.Sprintf("%s until %s", g.Name, FiveClans[id].ExpiresAt.Time.Format("2006-01-02 15:04"))
+				territoryMutex.RUnlock()
 			}
 		}
+		lines = append(lines, fmt.Sprintf("%s (%d%% tax): %s", t.Name, t.Tax, owner))
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, "No territories loaded.")
 	}
+	return lines
 }
@@ -0,0 +1,467 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"hero-emulator/messaging"
+	"hero-emulator/utils"
+
+	null "gopkg.in/guregu/null.v3"
+)
+
+const (
+	GUILD_BANK_SLOTS = 100
+
+	GUILD_BANK_DEPOSIT  = "deposit"
+	GUILD_BANK_WITHDRAW = "withdraw"
+	GUILD_BANK_TAX      = "tax"
+)
+
+var (
+	// GuildBankRules are the warehouse permissions of the guild roles, the daily limits count the
+	// withdrawn item stacks and gold since midnight.
+	GuildBankRules = map[GuildRole]*GuildBankRule{
+		GROLE_MEMBER:    {Deposit: true},
+		GROLE_BODYGUARD: {Deposit: true, Withdraw: true, DailyItems: 3, DailyGold: 1000000},
+		GROLE_SAGE:      {Deposit: true, Withdraw: true, DailyItems: 5, DailyGold: 10000000},
+		GROLE_SOLDIER:   {Deposit: true, Withdraw: true, DailyItems: 10, DailyGold: 50000000},
+		GROLE_LEADER:    {Deposit: true, Withdraw: true, Unlimited: true},
+	}
+)
+
+type GuildBankRule struct {
+	Deposit    bool
+	Withdraw   bool
+	DailyItems int
+	DailyGold  uint64
+	Unlimited  bool
+}
+
+// GuildBankLog is a transaction of a guild warehouse.
+type GuildBankLog struct {
+	ID          int       `db:"id" json:"id"`
+	GuildID     int       `db:"guild_id" json:"guild_id"`
+	CharacterID int       `db:"character_id" json:"character_id"`
+	Name        string    `db:"name" json:"name"`
+	Action      string    `db:"action" json:"action"`
+	ItemID      int64     `db:"item_id" json:"item_id"`
+	Quantity    uint      `db:"quantity" json:"quantity"`
+	Gold        uint64    `db:"gold" json:"gold"`
+	CreatedAt   null.Time `db:"created_at" json:"created_at"`
+}
+
+func (l *GuildBankLog) Create() error {
+	return db.Insert(l)
+}
+
+func FindGuildBankSlots(guildID int) ([]*InventorySlot, error) {
+
+	var arr []*InventorySlot
+	query := `select * from hops.items_characters where guild_id = $1 and slot_id >= 0 order by slot_id asc`
+	if _, err := db.Select(&arr, query, guildID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("FindGuildBankSlots: %s", err.Error())
+	}
+
+	for _, s := range arr {
+		InventoryItems.Add(s.ID, s)
+	}
+
+	return arr, nil
+}
+
+// bankSlots returns the warehouse of the guild, the caller holds the bank lock of the guild.
+func (g *Guild) bankSlots() ([]*InventorySlot, error) {
+	if g.bank != nil {
+		return g.bank, nil
+	}
+
+	slots, err := FindGuildBankSlots(g.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	bank := make([]*InventorySlot, GUILD_BANK_SLOTS)
+	for i := range bank {
+		bank[i] = NewSlot()
+	}
+	for _, s := range slots {
+		if s.SlotID < GUILD_BANK_SLOTS {
+			bank[s.SlotID] = s
+		}
+	}
+
+	g.bank = bank
+	return bank, nil
+}
+
+// BankEmpty reports whether the warehouse of the guild holds no items and no gold.
+func (g *Guild) BankEmpty() (bool, error) {
+	g.bankMutex.Lock()
+	defer g.bankMutex.Unlock()
+
+	slots, err := g.bankSlots()
+	if err != nil {
+		return false, err
+	}
+
+	for _, s := range slots {
+		if s.ItemID != 0 {
+			return false, nil
+		}
+	}
+	return g.BankGold == 0, nil
+}
+
+// bankRule returns the warehouse rule of the character in the guild.
+func (g *Guild) bankRule(c *Character) (*GuildBankRule, error) {
+	m, err := g.GetMember(c.ID)
+	if err != nil {
+		return nil, err
+	} else if m == nil {
+		return nil, nil
+	}
+	return GuildBankRules[m.Role], nil
+}
+
+// withdrawnToday returns the item stacks and the gold the character withdrew from the warehouse since midnight.
+func (g *Guild) withdrawnToday(c *Character) (int, uint64, error) {
+	y, m, d := time.Now().Date()
+	since := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+
+	query := `select count(*) from hops.guild_bank_logs where guild_id = $1 and character_id = $2 and "action" = $3 and item_id <> 0 and created_at >= $4`
+	items, err := db.SelectInt(query, g.ID, c.ID, GUILD_BANK_WITHDRAW, since)
+	if err != nil {
+		return 0, 0, fmt.Errorf("withdrawnToday: %s", err.Error())
+	}
+
+	query = `select coalesce(sum(gold), 0) from hops.guild_bank_logs where guild_id = $1 and character_id = $2 and "action" = $3 and created_at >= $4`
+	gold, err := db.SelectInt(query, g.ID, c.ID, GUILD_BANK_WITHDRAW, since)
+	if err != nil {
+		return 0, 0, fmt.Errorf("withdrawnToday: %s", err.Error())
+	}
+
+	return int(items), uint64(gold), nil
+}
+
+func (g *Guild) recordBank(c *Character, action string, itemID int64, quantity uint, gold uint64) {
+	entry := &GuildBankLog{GuildID: g.ID, CharacterID: c.ID, Name: c.Name, Action: action, ItemID: itemID,
+		Quantity: quantity, Gold: gold, CreatedAt: null.TimeFrom(time.Now())}
+	if err := entry.Create(); err != nil {
+		log.Println(err)
+	}
+}
+
+// isBagSlot reports whether the slot belongs to the bags of the character.
+func isBagSlot(slotID int16) bool {
+	return (slotID >= 11 && slotID <= 66) || (slotID >= 341 && slotID <= 396)
+}
+
+// DepositItem moves the item of the inventory slot into the first free slot of the warehouse.
+func (g *Guild) DepositItem(c *Character, slotID int16) ([]byte, error) {
+
+	rule, err := g.bankRule(c)
+	if err != nil {
+		return nil, err
+	} else if rule == nil || !rule.Deposit {
+		return messaging.InfoMessage("You cannot use the house warehouse."), nil
+	} else if c.TradeID != "" || FindSale(c.PseudoID) != nil {
+		return messaging.InfoMessage("You cannot use the house warehouse while trading."), nil
+	} else if !isBagSlot(slotID) {
+		return nil, nil
+	}
+
+	c.InvMutex.Lock()
+	defer c.InvMutex.Unlock()
+	g.bankMutex.Lock()
+	defer g.bankMutex.Unlock()
+
+	slots, err := c.InventorySlots()
+	if err != nil {
+		return nil, err
+	}
+	bank, err := g.bankSlots()
+	if err != nil {
+		return nil, err
+	}
+
+	item := slots[slotID]
+	if item.ItemID == 0 || item.Activated || item.InUse || item.Pet != nil {
+		return messaging.InfoMessage("This item cannot be stored."), nil
+	} else if info, ok := Items[item.ItemID]; !ok || !info.Tradable {
+		return messaging.InfoMessage("This item cannot be stored."), nil
+	}
+
+	free := int16(-1)
+	for i, s := range bank[:g.Level().BankSlots] {
+		if s.ItemID == 0 {
+			free = int16(i)
+			break
+		}
+	}
+	if free == -1 {
+		return messaging.InfoMessage("The house warehouse is full."), nil
+	}
+
+	newItem := NewSlot()
+	*newItem = *item
+	newItem.SlotID = free
+	newItem.UserID = null.StringFromPtr(nil)
+	newItem.CharacterID = null.IntFromPtr(nil)
+	newItem.GuildID = null.IntFrom(int64(g.ID))
+	if err := newItem.Update(); err != nil {
+		return nil, err
+	}
+
+	bank[free] = newItem
+	InventoryItems.Add(newItem.ID, newItem)
+	g.recordBank(c, GUILD_BANK_DEPOSIT, newItem.ItemID, newItem.Quantity, 0)
+
+	*item = *NewSlot()
+	resp := utils.Packet{}
+	resp.Concat(item.GetData(slotID))
+	resp.Concat(messaging.InfoMessage(fmt.Sprintf("%s stored in slot %d of the house warehouse.", itemText(newItem.ItemID, newItem.Quantity, newItem.Plus), free+1)))
+	return resp, nil
+}
+
+// WithdrawItem moves the item of the warehouse slot into a free slot of the inventory.
+func (g *Guild) WithdrawItem(c *Character, bankSlot int) ([]byte, error) {
+
+	rule, err := g.bankRule(c)
+	if err != nil {
+		return nil, err
+	} else if rule == nil || !rule.Withdraw {
+		return messaging.InfoMessage("Your role cannot take items from the house warehouse."), nil
+	} else if c.TradeID != "" || FindSale(c.PseudoID) != nil {
+		return messaging.InfoMessage("You cannot use the house warehouse while trading."), nil
+	} else if bankSlot < 0 || bankSlot >= GUILD_BANK_SLOTS {
+		return nil, nil
+	}
+
+	freeSlot, err := c.FindFreeSlot()
+	if err != nil {
+		return nil, err
+	} else if freeSlot == -1 {
+		return messaging.InfoMessage("Not enough inventory space."), nil
+	}
+
+	c.InvMutex.Lock()
+	defer c.InvMutex.Unlock()
+	g.bankMutex.Lock()
+	defer g.bankMutex.Unlock()
+
+	if !rule.Unlimited { // checked under the lock so parallel withdrawals see each other
+		items, _, err := g.withdrawnToday(c)
+		if err != nil {
+			return nil, err
+		} else if items >= rule.DailyItems {
+			return messaging.InfoMessage(fmt.Sprintf("You can take %d items a day from the house warehouse.", rule.DailyItems)), nil
+		}
+	}
+
+	slots, err := c.InventorySlots()
+	if err != nil {
+		return nil, err
+	}
+	bank, err := g.bankSlots()
+	if err != nil {
+		return nil, err
+	}
+
+	item := bank[bankSlot]
+	if item.ItemID == 0 || slots[freeSlot].ItemID != 0 {
+		return nil, nil
+	}
+
+	newItem := NewSlot()
+	*newItem = *item
+	newItem.SlotID = freeSlot
+	newItem.UserID = null.StringFrom(c.UserID)
+	newItem.CharacterID = null.IntFrom(int64(c.ID))
+	newItem.GuildID = null.IntFromPtr(nil)
+	if err := newItem.Update(); err != nil {
+		return nil, err
+	}
+
+	*slots[freeSlot] = *newItem
+	InventoryItems.Add(newItem.ID, slots[freeSlot])
+	bank[bankSlot] = NewSlot()
+	g.recordBank(c, GUILD_BANK_WITHDRAW, newItem.ItemID, newItem.Quantity, 0)
+
+	return newItem.GetData(freeSlot), nil
+}
+
+// DepositGold moves gold of the character into the warehouse.
+func (g *Guild) DepositGold(c *Character, amount uint64) ([]byte, error) {
+
+	rule, err := g.bankRule(c)
+	if err != nil {
+		return nil, err
+	} else if rule == nil || !rule.Deposit {
+		return messaging.InfoMessage("You cannot use the house warehouse."), nil
+	} else if c.TradeID != "" || FindSale(c.PseudoID) != nil {
+		return messaging.InfoMessage("You cannot use the house warehouse while trading."), nil
+	} else if amount == 0 {
+		return nil, nil
+	} else if !c.SpendGold(amount) {
+		return messaging.InfoMessage("You do not have enough gold."), nil
+	}
+
+	g.bankMutex.Lock()
+	g.BankGold += amount
+	g.bankMutex.Unlock()
+
+	g.recordBank(c, GUILD_BANK_DEPOSIT, 0, 0, amount)
+	go g.Update()
+	return c.GetGold(), nil
+}
+
+// WithdrawGold moves gold of the warehouse to the character within the daily limit of its role.
+func (g *Guild) WithdrawGold(c *Character, amount uint64) ([]byte, error) {
+
+	rule, err := g.bankRule(c)
+	if err != nil {
+		return nil, err
+	} else if rule == nil || !rule.Withdraw {
+		return messaging.InfoMessage("Your role cannot take gold from the house warehouse."), nil
+	} else if c.TradeID != "" || FindSale(c.PseudoID) != nil {
+		return messaging.InfoMessage("You cannot use the house warehouse while trading."), nil
+	} else if amount == 0 {
+		return nil, nil
+	}
+
+	g.bankMutex.Lock()
+	defer g.bankMutex.Unlock()
+
+	if !rule.Unlimited { // checked under the lock so parallel withdrawals see each other
+		_, gold, err := g.withdrawnToday(c)
+		if err != nil {
+			return nil, err
+		} else if gold+amount > rule.DailyGold {
+			left := uint64(0)
+			if gold < rule.DailyGold {
+				left = rule.DailyGold - gold
+			}
+			return messaging.InfoMessage(fmt.Sprintf("You can take %d more gold from the house warehouse today.", left)), nil
+		}
+	}
+
+	if g.BankGold < amount {
+		return messaging.InfoMessage("There is not enough gold in the house warehouse."), nil
+	}
+	g.BankGold -= amount
+	c.LootGold(amount)
+
+	g.recordBank(c, GUILD_BANK_WITHDRAW, 0, 0, amount)
+	go g.Update()
+	return c.GetGold(), nil
+}
+
+// BankLines describes the warehouse of the guild.
+func (g *Guild) BankLines() ([]string, error) {
+	g.bankMutex.Lock()
+	defer g.bankMutex.Unlock()
+
+	bank, err := g.bankSlots()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{fmt.Sprintf("%s warehouse: %d gold", g.Name, g.BankGold)}
+	for i, s := range bank {
+		if s.ItemID == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s", i+1, itemText(s.ItemID, s.Quantity, s.Plus)))
+	}
+	return lines, nil
+}
+
+// BankLimitLines describes the warehouse rule of the character and what it took today.
+func (g *Guild) BankLimitLines(c *Character) ([]string, error) {
+	rule, err := g.bankRule(c)
+	if err != nil || rule == nil {
+		return nil, err
+	}
+
+	switch {
+	case rule.Unlimited:
+		return []string{"You can use the house warehouse without limits."}, nil
+	case !rule.Withdraw:
+		return []string{"You can store items and gold in the house warehouse."}, nil
+	}
+
+	items, gold, err := g.withdrawnToday(c)
+	if err != nil {
+		return nil, err
+	}
+	return []string{fmt.Sprintf("Taken today: %d/%d items, %d/%d gold.", items, rule.DailyItems, gold, rule.DailyGold)}, nil
+}
+
+// FindGuildBankLogs returns the latest transactions of the warehouse of the guild.
+func FindGuildBankLogs(guildID, limit int) ([]*GuildBankLog, error) {
+
+	if limit <= 0 {
+		limit = GUILD_LOG_LIMIT
+	}
+
+	var logs []*GuildBankLog
+	query := `select * from hops.guild_bank_logs where guild_id = $1 order by created_at desc, id desc limit $2`
+
+	if _, err := db.Select(&logs, query, guildID, limit); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("FindGuildBankLogs: %s", err.Error())
+	}
+
+	return logs, nil
+}
+
+// BankLogLines describes the latest transactions of the warehouse of the guild.
+func (g *Guild) BankLogLines(limit int) ([]string, error) {
+
+	logs, err := FindGuildBankLogs(g.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{fmt.Sprintf("%s warehouse log:", g.Name)}
+	if len(logs) == 0 {
+		lines = append(lines, "No transactions yet.")
+	}
+	for _, l := range logs {
+		what := fmt.Sprintf("%d gold", l.Gold)
+		if l.ItemID != 0 {
+			what = itemText(l.ItemID, l.Quantity, 0)
+		}
+		verb := "stored"
+		switch l.Action {
+		case GUILD_BANK_WITHDRAW:
+			verb = "took"
+		case GUILD_BANK_TAX:
+			verb = "paid tax of"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s %s", l.CreatedAt.Time.Format("2006-01-02 15:04"), l.Name, verb, what))
+	}
+	return lines, nil
+}
+
+func itemText(itemID int64, quantity uint, plus uint8) string {
+	name := fmt.Sprint(itemID)
+	if info, ok := Items[itemID]; ok {
+		name = info.Name
+	}
+	if plus > 0 {
+		name = fmt.Sprintf("%s +%d", name, plus)
+	}
+	if quantity > 1 {
+		name = fmt.Sprintf("%s x%d", name, quantity)
+	}
+	return name
+}
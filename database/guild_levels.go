@@ -0,0 +1,242 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"hero-emulator/messaging"
+	"hero-emulator/utils"
+)
+
+const (
+	// GUILD_PERK_BUFF is the buff infection which carries the exp and drop perks of the house level.
+	GUILD_PERK_BUFF = 99001
+
+	GUILD_GOLD_PER_POINT = 10000
+	GUILD_KILL_POINTS    = 10
+	GUILD_CAPTURE_POINTS = 500
+)
+
+var (
+	// GuildLevels are the levels of the houses by the recognition they need, the first level is where every house starts.
+	GuildLevels = []*GuildLevel{
+		{Level: 1, Recognition: 0, MaxMembers: 50, BankSlots: 30},
+		{Level: 2, Recognition: 10000, MaxMembers: 60, BankSlots: 40, ExpRate: 5},
+		{Level: 3, Recognition: 30000, MaxMembers: 70, BankSlots: 50, ExpRate: 10, DropRate: 5},
+		{Level: 4, Recognition: 70000, MaxMembers: 80, BankSlots: 60, ExpRate: 15, DropRate: 10},
+		{Level: 5, Recognition: 150000, MaxMembers: 90, BankSlots: 80, ExpRate: 20, DropRate: 15},
+		{Level: 6, Recognition: 300000, MaxMembers: 100, BankSlots: GUILD_BANK_SLOTS, ExpRate: 30, DropRate: 20},
+	}
+)
+
+type GuildLevel struct {
+	Level       int16
+	Recognition uint64
+	MaxMembers  int16
+	BankSlots   int
+	ExpRate     int
+	DropRate    int
+}
+
+// Level returns the level the house reached with its recognition.
+func (g *Guild) Level() *GuildLevel {
+	level := GuildLevels[0]
+	for _, l := range GuildLevels {
+		if g.Recognition >= l.Recognition {
+			level = l
+		}
+	}
+	return level
+}
+
+// progress returns the percent of the recognition the house has towards its next level, 100 at the last level.
+func (g *Guild) progress() int {
+	level, next := g.Level(), g.NextLevel()
+	if next == nil {
+		return 100
+	}
+	return int((g.Recognition - level.Recognition) * 100 / (next.Recognition - level.Recognition))
+}
+
+// NextLevel returns the level after the current one of the house, nil at the last level.
+func (g *Guild) NextLevel() *GuildLevel {
+	for _, l := range GuildLevels {
+		if l.Recognition > g.Recognition {
+			return l
+		}
+	}
+	return nil
+}
+
+// AddRecognition credits the points and the honor to the house and the contribution of the member, the perks are
+// renewed for the online members when the house levels up.
+func (g *Guild) AddRecognition(c *Character, points, honor uint64) {
+	if points == 0 && honor == 0 {
+		return
+	}
+
+	g.mutex.Lock()
+	old := g.Level()
+	g.Recognition += points
+	g.HonorDonation += honor
+	if err := g.loadMembers(); err == nil {
+		if m, ok := g.members[c.ID]; ok {
+			m.Contribution += int64(points)
+			member := *m
+			go func() {
+				if err := member.Update(); err != nil {
+					log.Println(err)
+				}
+			}()
+		}
+	}
+	level := g.Level()
+	g.mutex.Unlock()
+
+	go g.Update()
+	if level.Level == old.Level {
+		return
+	}
+
+	msg := messaging.InfoMessage(fmt.Sprintf("%s reached level %d.", g.Name, level.Level))
+	for _, member := range g.OnlineMembers() {
+		member.Socket.Write(msg)
+		g.ApplyPerks(member)
+	}
+}
+
+// DonateGold takes the gold from the character and turns it into recognition of the house.
+func (g *Guild) DonateGold(c *Character, amount uint64) ([]byte, error) {
+
+	if amount < GUILD_GOLD_PER_POINT {
+		return messaging.InfoMessage(fmt.Sprintf("Donate at least %d gold.", GUILD_GOLD_PER_POINT)), nil
+	} else if c.TradeID != "" || FindSale(c.PseudoID) != nil {
+		return messaging.InfoMessage("You cannot donate while trading."), nil
+	} else if !c.SpendGold(amount) {
+		return messaging.InfoMessage("You do not have enough gold."), nil
+	}
+
+	g.mutex.Lock()
+	g.GoldDonation += amount
+	g.mutex.Unlock()
+	g.AddRecognition(c, amount/GUILD_GOLD_PER_POINT, 0)
+
+	resp := utils.Packet{}
+	resp.Concat(c.GetGold())
+	resp.Concat(messaging.InfoMessage(fmt.Sprintf("You donated %d gold to %s.", amount, g.Name)))
+	return resp, nil
+}
+
+// AddGuildActivity credits the points of an activity such as a war kill or a captured territory to the house of the character.
+func AddGuildActivity(c *Character, points, honor uint64) {
+	if c == nil || c.GuildID <= 0 {
+		return
+	}
+
+	guild, err := FindGuildByID(c.GuildID)
+	if err != nil || guild == nil {
+		return
+	}
+	guild.AddRecognition(c, points, honor)
+}
+
+// ApplyPerks gives the buff of the house level to the character or removes it when the level has none.
+func (g *Guild) ApplyPerks(c *Character) {
+
+	level := g.Level()
+	if level.ExpRate == 0 && level.DropRate == 0 {
+		RemoveGuildPerks(c)
+		return
+	}
+
+	buff, err := FindBuffByID(GUILD_PERK_BUFF, c.ID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	name := fmt.Sprintf("%s Lv.%d", g.Name, level.Level)
+	if buff != nil && buff.EXPMultiplier == level.ExpRate && buff.DropMultiplier == level.DropRate && buff.Name == name {
+		return
+	}
+
+	isNew := buff == nil
+	buff = &Buff{ID: GUILD_PERK_BUFF, CharacterID: c.ID, Name: name, EXPMultiplier: level.ExpRate, DropMultiplier: level.DropRate,
+		StartedAt: c.Epoch, CanExpire: false}
+	if isNew {
+		err = buff.Create()
+	} else {
+		err = buff.Update()
+	}
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if c.IsOnline && c.Socket != nil {
+		if data, err := c.GetStats(); err == nil {
+			c.Socket.Write(data)
+		}
+	}
+}
+
+// RemoveGuildPerks takes the buff of the house level from the character.
+func RemoveGuildPerks(c *Character) {
+
+	buff, err := FindBuffByID(GUILD_PERK_BUFF, c.ID)
+	if err != nil || buff == nil {
+		return
+	}
+
+	if err := buff.Delete(); err != nil {
+		log.Println(err)
+		return
+	}
+
+	if c.IsOnline && c.Socket != nil {
+		r := BUFF_EXPIRED
+		r.Insert(utils.IntToBytes(uint64(buff.ID), 4, true), 6) // buff infection id
+		if data, err := c.GetStats(); err == nil {
+			r.Concat(data)
+		}
+		c.Socket.Write(r)
+	}
+}
+
+// GuildEffects adds the exp and drop perks of the house level to the multipliers of the character, the level is read
+// from the cached house so the stats are calculated without a query.
+func (c *Character) GuildEffects() {
+	if c.GuildID <= 0 {
+		return
+	}
+
+	guild, err := FindGuildByID(c.GuildID)
+	if err != nil || guild == nil {
+		return
+	}
+
+	guild.mutex.RLock()
+	level := guild.Level()
+	guild.mutex.RUnlock()
+
+	c.AdditionalExpMultiplier += float64(level.ExpRate) / 100
+	c.AdditionalDropMultiplier += float64(level.DropRate) / 100
+}
+
+// LevelLines describes the level of the house, its progress and its perks.
+func (g *Guild) LevelLines() []string {
+
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	level := g.Level()
+	lines := []string{fmt.Sprintf("%s level %d, %d/%d members", g.Name, level.Level, g.MemberCount, level.MaxMembers)}
+	if next := g.NextLevel(); next != nil {
+		lines = append(lines, fmt.Sprintf("Recognition: %d/%d", g.Recognition, next.Recognition))
+	} else {
+		lines = append(lines, fmt.Sprintf("Recognition: %d (last level)", g.Recognition))
+	}
+	lines = append(lines, fmt.Sprintf("Donated: %d gold, %d honor", g.GoldDonation, g.HonorDonation))
+	lines = append(lines, fmt.Sprintf("Perks: %d warehouse slots, +%d%% exp, +%d%% drop", level.BankSlots, level.ExpRate, level.DropRate))
+	return lines
+}
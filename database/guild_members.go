@@ -0,0 +1,362 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	gorp "gopkg.in/gorp.v1"
+	null "gopkg.in/guregu/null.v3"
+)
+
+const (
+	GUILD_LOG_CREATE = "create"
+	GUILD_LOG_JOIN   = "join"
+	GUILD_LOG_LEAVE  = "leave"
+	GUILD_LOG_EXPEL  = "expel"
+	GUILD_LOG_ROLE   = "role"
+
+	GUILD_LOG_LIMIT = 20
+)
+
+var (
+	// guildRosters holds the online members of the guilds by guild id and character id.
+	guildRosters = make(map[int]map[int]*Character)
+	rosterMutex  sync.RWMutex
+
+	guildRoleNames = map[GuildRole]string{GROLE_MEMBER: "Member", GROLE_BODYGUARD: "Bodyguard", GROLE_SAGE: "Sage",
+		GROLE_SOLDIER: "Soldier", GROLE_LEADER: "Leader"}
+)
+
+type GuildMember struct {
+	GuildID      int       `db:"guild_id" json:"guild_id"`
+	ID           int       `db:"character_id" json:"id"`
+	Role         GuildRole `db:"role" json:"role"`
+	JoinedAt     null.Time `db:"joined_at" json:"joined_at"`
+	Contribution int64     `db:"contribution" json:"contribution"`
+	LastOnline   null.Time `db:"last_online" json:"last_online"`
+}
+
+// GuildLog is an entry of the membership history of a guild.
+type GuildLog struct {
+	ID          int       `db:"id" json:"id"`
+	GuildID     int       `db:"guild_id" json:"guild_id"`
+	CharacterID int       `db:"character_id" json:"character_id"`
+	Name        string    `db:"name" json:"name"`
+	ActorID     int       `db:"actor_id" json:"actor_id"`
+	ActorName   string    `db:"actor_name" json:"actor_name"`
+	Action      string    `db:"action" json:"action"`
+	Role        GuildRole `db:"role" json:"role"`
+	CreatedAt   null.Time `db:"created_at" json:"created_at"`
+}
+
+func (r GuildRole) String() string {
+	if name, ok := guildRoleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Role %d", r)
+}
+
+func (m *GuildMember) Create() error {
+	return db.Insert(m)
+}
+
+func (m *GuildMember) CreateWithTransaction(tr *gorp.Transaction) error {
+	return tr.Insert(m)
+}
+
+func (m *GuildMember) Update() error {
+	_, err := db.Update(m)
+	return err
+}
+
+func (m *GuildMember) Delete() error {
+	_, err := db.Delete(m)
+	return err
+}
+
+func (l *GuildLog) Create() error {
+	return db.Insert(l)
+}
+
+// loadMembers reads the members of the guild once, the caller holds the lock of the guild.
+func (g *Guild) loadMembers() error {
+	if g.members != nil {
+		return nil
+	}
+
+	var members []*GuildMember
+	query := `select * from hops.guild_members where guild_id = $1`
+	if _, err := db.Select(&members, query, g.ID); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("loadMembers: %s", err.Error())
+	}
+
+	g.members = make(map[int]*GuildMember)
+	for _, m := range members {
+		g.members[m.ID] = m
+	}
+	return nil
+}
+
+// GetMembers returns the members of the guild by rank, the leader first.
+func (g *Guild) GetMembers() ([]*GuildMember, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if err := g.loadMembers(); err != nil {
+		return nil, err
+	}
+
+	members := make([]*GuildMember, 0, len(g.members))
+	for _, m := range g.members {
+		member := *m
+		members = append(members, &member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Role != members[j].Role {
+			return members[i].Role > members[j].Role
+		}
+		return members[i].ID < members[j].ID
+	})
+	return members, nil
+}
+
+func (g *Guild) GetMember(id int) (*GuildMember, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if err := g.loadMembers(); err != nil {
+		return nil, err
+	}
+
+	if m, ok := g.members[id]; ok {
+		member := *m
+		return &member, nil
+	}
+	return nil, nil
+}
+
+// AddMember inserts the member into the guild and records the join.
+func (g *Guild) AddMember(member *GuildMember) error {
+	g.mutex.Lock()
+	if err := g.loadMembers(); err != nil {
+		g.mutex.Unlock()
+		return err
+	}
+
+	member.GuildID = g.ID
+	member.JoinedAt = null.TimeFrom(time.Now())
+	if err := member.Create(); err != nil {
+		g.mutex.Unlock()
+		return fmt.Errorf("AddMember: %s", err.Error())
+	}
+
+	g.members[member.ID] = member
+	g.MemberCount = int16(len(g.members))
+
+	action := GUILD_LOG_JOIN
+	if member.Role == GROLE_LEADER {
+		action = GUILD_LOG_CREATE
+	}
+	g.record(action, member.ID, 0, member.Role)
+	g.mutex.Unlock()
+
+	if c, err := FindCharacterByID(member.ID); err == nil && c != nil && c.IsOnline {
+		g.SetOnline(c, true)
+	}
+	return nil
+}
+
+// RemoveMember takes the member out of the guild, the character left by itself.
+func (g *Guild) RemoveMember(id int) error {
+	return g.removeMember(id, 0, GUILD_LOG_LEAVE)
+}
+
+// ExpelMember takes the member out of the guild on the order of the actor.
+func (g *Guild) ExpelMember(id, actorID int) error {
+	return g.removeMember(id, actorID, GUILD_LOG_EXPEL)
+}
+
+func (g *Guild) removeMember(id, actorID int, action string) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if err := g.loadMembers(); err != nil {
+		return err
+	}
+
+	m, ok := g.members[id]
+	if !ok {
+		return nil
+	}
+
+	if err := m.Delete(); err != nil {
+		return fmt.Errorf("RemoveMember: %s", err.Error())
+	}
+
+	delete(g.members, id)
+	g.MemberCount = int16(len(g.members))
+	g.record(action, id, actorID, m.Role)
+
+	rosterMutex.Lock()
+	delete(guildRosters[g.ID], id)
+	rosterMutex.Unlock()
+
+	if c, err := FindCharacterByID(id); err == nil && c != nil {
+		RemoveGuildPerks(c)
+	}
+	return nil
+}
+
+// ChangeRole gives the role to the member on the order of the actor.
+func (g *Guild) ChangeRole(id int, role GuildRole, actorID int) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if err := g.loadMembers(); err != nil {
+		return err
+	}
+
+	m, ok := g.members[id]
+	if !ok || m.Role == role {
+		return nil
+	}
+
+	old := m.Role
+	m.Role = role
+	if err := m.Update(); err != nil {
+		m.Role = old
+		return fmt.Errorf("ChangeRole: %s", err.Error())
+	}
+
+	g.record(GUILD_LOG_ROLE, id, actorID, role)
+	return nil
+}
+
+// SetOnline adds the character to the online roster of the guild or removes it, and saves the time it was last seen.
+func (g *Guild) SetOnline(c *Character, online bool) {
+
+	rosterMutex.Lock()
+	if online {
+		if guildRosters[g.ID] == nil {
+			guildRosters[g.ID] = make(map[int]*Character)
+		}
+		guildRosters[g.ID][c.ID] = c
+	} else {
+		delete(guildRosters[g.ID], c.ID)
+	}
+	rosterMutex.Unlock()
+
+	if online {
+		g.ApplyPerks(c)
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if err := g.loadMembers(); err != nil {
+		log.Println(err)
+		return
+	}
+
+	if m, ok := g.members[c.ID]; ok {
+		m.LastOnline = null.TimeFrom(time.Now())
+		member := *m
+		go func() {
+			if err := member.Update(); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+}
+
+// OnlineMembers returns the members of the guild which are online.
+func (g *Guild) OnlineMembers() []*Character {
+	rosterMutex.RLock()
+	defer rosterMutex.RUnlock()
+
+	members := []*Character{}
+	for _, c := range guildRosters[g.ID] {
+		if c.IsOnline && c.Socket != nil {
+			members = append(members, c)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].ID < members[j].ID
+	})
+	return members
+}
+
+// record saves an entry of the membership history.
+func (g *Guild) record(action string, characterID, actorID int, role GuildRole) {
+
+	entry := &GuildLog{GuildID: g.ID, CharacterID: characterID, ActorID: actorID, Action: action, Role: role,
+		CreatedAt: null.TimeFrom(time.Now())}
+	if c, err := FindCharacterByID(characterID); err == nil && c != nil {
+		entry.Name = c.Name
+	}
+	if actor, err := FindCharacterByID(actorID); err == nil && actor != nil {
+		entry.ActorName = actor.Name
+	}
+
+	go func() {
+		if err := entry.Create(); err != nil {
+			log.Println(err)
+		}
+	}()
+}
+
+// FindGuildLogs returns the latest entries of the membership history of the guild.
+func FindGuildLogs(guildID, limit int) ([]*GuildLog, error) {
+
+	if limit <= 0 {
+		limit = GUILD_LOG_LIMIT
+	}
+
+	var logs []*GuildLog
+	query := `select * from hops.guild_logs where guild_id = $1 order by created_at desc, id desc limit $2`
+
+	if _, err := db.Select(&logs, query, guildID, limit); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("FindGuildLogs: %s", err.Error())
+	}
+
+	return logs, nil
+}
+
+// HistoryLines describes the latest entries of the membership history of the guild.
+func (g *Guild) HistoryLines(limit int) ([]string, error) {
+
+	logs, err := FindGuildLogs(g.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{fmt.Sprintf("%s history:", g.Name)}
+	if len(logs) == 0 {
+		lines = append(lines, "Nothing happened yet.")
+	}
+	for _, l := range logs {
+		text := ""
+		switch l.Action {
+		case GUILD_LOG_CREATE:
+			text = fmt.Sprintf("%s founded the house", l.Name)
+		case GUILD_LOG_JOIN:
+			text = fmt.Sprintf("%s joined", l.Name)
+		case GUILD_LOG_LEAVE:
+			text = fmt.Sprintf("%s left", l.Name)
+		case GUILD_LOG_EXPEL:
+			text = fmt.Sprintf("%s was expelled by %s", l.Name, l.ActorName)
+		case GUILD_LOG_ROLE:
+			text = fmt.Sprintf("%s was made %s by %s", l.Name, l.Role, l.ActorName)
+		default:
+			text = fmt.Sprintf("%s: %s", l.Name, l.Action)
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", l.CreatedAt.Time.Format("2006-01-02 15:04"), text))
+	}
+	return lines, nil
+}
@@ -0,0 +1,687 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"hero-emulator/messaging"
+
+	null "gopkg.in/guregu/null.v3"
+)
+
+const (
+	GUILD_WAR_DECLARED = "declared"
+	GUILD_WAR_ACTIVE   = "active"
+	GUILD_WAR_DECLINED = "declined"
+	GUILD_WAR_FINISHED = "finished"
+
+	GUILD_WAR_MIN_DURATION = 10   // minutes
+	GUILD_WAR_MAX_DURATION = 1440 // minutes
+	GUILD_WAR_KILL_SCORE   = 1
+	GUILD_WAR_HISTORY      = 10
+	GUILD_MAX_ALLIES       = 3
+
+	guildWarAnswerTime = 10 * time.Minute
+)
+
+var (
+	// GuildWars holds the declared and the running wars between the houses by war id.
+	GuildWars     = make(map[int]*GuildWar)
+	guildWarMutex sync.RWMutex
+
+	// guildAlliances holds the allies of the houses by guild id, alliance invites are kept until the server restarts.
+	guildAlliances  = make(map[int]map[int]bool)
+	allianceInvites = make(map[int]map[int]bool)
+	allianceMutex   sync.RWMutex
+)
+
+// GuildWar is the persisted record of a war between two houses.
+type GuildWar struct {
+	ID              int       `db:"id" json:"id"`
+	ChallengerID    int       `db:"challenger_id" json:"challenger_id"`
+	EnemyID         int       `db:"enemy_id" json:"enemy_id"`
+	State           string    `db:"state" json:"state"`
+	Duration        int       `db:"duration" json:"duration"`
+	Stakes          uint64    `db:"stakes" json:"stakes"`
+	ChallengerScore int       `db:"challenger_score" json:"challenger_score"`
+	EnemyScore      int       `db:"enemy_score" json:"enemy_score"`
+	WinnerID        null.Int  `db:"winner_id" json:"winner_id"`
+	DeclaredAt      null.Time `db:"declared_at" json:"declared_at"`
+	StartedAt       null.Time `db:"started_at" json:"started_at"`
+	EndsAt          null.Time `db:"ends_at" json:"ends_at"`
+	FinishedAt      null.Time `db:"finished_at" json:"finished_at"`
+
+	mutex sync.Mutex `db:"-"`
+}
+
+// GuildAlliance is an alliance between two houses, the lower guild id is stored first.
+type GuildAlliance struct {
+	GuildID   int       `db:"guild_id" json:"guild_id"`
+	AllyID    int       `db:"ally_id" json:"ally_id"`
+	CreatedAt null.Time `db:"created_at" json:"created_at"`
+}
+
+func (w *GuildWar) Create() error {
+	return db.Insert(w)
+}
+
+func (w *GuildWar) Update() error {
+	_, err := db.Update(w)
+	return err
+}
+
+func (a *GuildAlliance) Create() error {
+	return db.Insert(a)
+}
+
+func (a *GuildAlliance) Delete() error {
+	_, err := db.Delete(a)
+	return err
+}
+
+func getGuildWars() error {
+	var wars []*GuildWar
+	query := `select * from hops.guild_wars where state in ($1, $2)`
+
+	if _, err := db.Select(&wars, query, GUILD_WAR_DECLARED, GUILD_WAR_ACTIVE); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("getGuildWars: %s", err.Error())
+	}
+
+	guildWarMutex.Lock()
+	for _, w := range wars {
+		GuildWars[w.ID] = w
+	}
+	guildWarMutex.Unlock()
+
+	for _, w := range wars {
+		w.schedule()
+	}
+	return nil
+}
+
+func getGuildAlliances() error {
+	var alliances []*GuildAlliance
+	query := `select * from hops.guild_alliances`
+
+	if _, err := db.Select(&alliances, query); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("getGuildAlliances: %s", err.Error())
+	}
+
+	allianceMutex.Lock()
+	defer allianceMutex.Unlock()
+	for _, a := range alliances {
+		addAlly(a.GuildID, a.AllyID)
+		addAlly(a.AllyID, a.GuildID)
+	}
+	return nil
+}
+
+// schedule ends the war when its time is up, or drops the declaration when it is not answered in time.
+func (w *GuildWar) schedule() {
+	switch w.State {
+	case GUILD_WAR_DECLARED:
+		time.AfterFunc(time.Until(w.DeclaredAt.Time.Add(guildWarAnswerTime)), func() {
+			w.mutex.Lock()
+			defer w.mutex.Unlock()
+			if w.State == GUILD_WAR_DECLARED {
+				w.decline()
+			}
+		})
+	case GUILD_WAR_ACTIVE:
+		time.AfterFunc(time.Until(w.EndsAt.Time), w.Finish)
+	}
+}
+
+// leaderGuild returns the guild the character leads.
+func leaderGuild(c *Character) (*Guild, []byte, error) {
+	if c.GuildID <= 0 {
+		return nil, messaging.InfoMessage("You are not in a house."), nil
+	}
+
+	guild, err := FindGuildByID(c.GuildID)
+	if err != nil {
+		return nil, nil, err
+	} else if guild == nil || guild.LeaderID != c.ID {
+		return nil, messaging.InfoMessage("Only the leader of the house can do this."), nil
+	}
+	return guild, nil, nil
+}
+
+// notifyLeader sends the message to the leader of the guild when it is online.
+func (g *Guild) notifyLeader(msg string) {
+	leader, err := FindCharacterByID(g.LeaderID)
+	if err == nil && leader != nil && leader.IsOnline && leader.Socket != nil {
+		leader.Socket.Write(messaging.InfoMessage(msg))
+	}
+}
+
+// findGuildWar returns the declared or running war between the two guilds.
+func findGuildWar(a, b int) *GuildWar {
+	guildWarMutex.RLock()
+	defer guildWarMutex.RUnlock()
+
+	for _, w := range GuildWars {
+		if (w.ChallengerID == a && w.EnemyID == b) || (w.ChallengerID == b && w.EnemyID == a) {
+			return w
+		}
+	}
+	return nil
+}
+
+// AtGuildWar reports whether the houses of the characters are fighting a war.
+func AtGuildWar(c, enemy *Character) bool {
+	if c.GuildID <= 0 || enemy.GuildID <= 0 || c.GuildID == enemy.GuildID {
+		return false
+	}
+
+	w := findGuildWar(c.GuildID, enemy.GuildID)
+	return w != nil && w.State == GUILD_WAR_ACTIVE
+}
+
+// DeclareGuildWar makes the house of the character challenge the enemy house for the minutes and the gold stakes.
+func DeclareGuildWar(c *Character, enemyName string, minutes int, stakes uint64) ([]byte, error) {
+
+	guild, msg, err := leaderGuild(c)
+	if guild == nil {
+		return msg, err
+	}
+
+	enemy, err := FindGuildByName(enemyName)
+	if err != nil {
+		return nil, err
+	} else if enemy == nil || enemy.ID == guild.ID {
+		return messaging.InfoMessage("There is no such house."), nil
+	} else if enemy, err = FindGuildByID(enemy.ID); err != nil || enemy == nil {
+		return nil, err
+	}
+
+	if minutes < GUILD_WAR_MIN_DURATION || minutes > GUILD_WAR_MAX_DURATION {
+		return messaging.InfoMessage(fmt.Sprintf("A war lasts %d to %d minutes.", GUILD_WAR_MIN_DURATION, GUILD_WAR_MAX_DURATION)), nil
+	} else if AreAllied(guild.ID, enemy.ID) {
+		return messaging.InfoMessage("You cannot declare war on an ally."), nil
+	} else if findGuildWar(guild.ID, enemy.ID) != nil {
+		return messaging.InfoMessage(fmt.Sprintf("There is already a war with %s.", enemy.Name)), nil
+	} else if guild.BankGold < stakes {
+		return messaging.InfoMessage("The house warehouse does not hold the stakes."), nil
+	}
+
+	w := &GuildWar{ChallengerID: guild.ID, EnemyID: enemy.ID, State: GUILD_WAR_DECLARED, Duration: minutes, Stakes: stakes,
+		DeclaredAt: null.TimeFrom(time.Now())}
+	if err := w.Create(); err != nil {
+		return nil, fmt.Errorf("DeclareGuildWar: %s", err.Error())
+	}
+
+	guildWarMutex.Lock()
+	GuildWars[w.ID] = w
+	guildWarMutex.Unlock()
+	w.schedule()
+
+	enemy.notifyLeader(fmt.Sprintf("%s declared war on your house for %d minutes with %d gold at stake. Answer with /war accept %s or /war decline %s.",
+		guild.Name, minutes, stakes, guild.Name, guild.Name))
+	return messaging.InfoMessage(fmt.Sprintf("You declared war on %s.", enemy.Name)), nil
+}
+
+// RespondGuildWar accepts or declines the war the challenger house declared on the house of the character.
+func RespondGuildWar(c *Character, challengerName string, accept bool) ([]byte, error) {
+
+	guild, msg, err := leaderGuild(c)
+	if guild == nil {
+		return msg, err
+	}
+
+	challenger, err := FindGuildByName(challengerName)
+	if err != nil {
+		return nil, err
+	} else if challenger == nil {
+		return messaging.InfoMessage("There is no such house."), nil
+	} else if challenger, err = FindGuildByID(challenger.ID); err != nil || challenger == nil {
+		return nil, err
+	}
+
+	w := findGuildWar(guild.ID, challenger.ID)
+	if w == nil || w.EnemyID != guild.ID {
+		return messaging.InfoMessage(fmt.Sprintf("%s has not declared war on your house.", challenger.Name)), nil
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.State != GUILD_WAR_DECLARED {
+		return nil, nil
+	}
+
+	if !accept {
+		w.decline()
+		return messaging.InfoMessage(fmt.Sprintf("You declined the war with %s.", challenger.Name)), nil
+	}
+
+	if !takeStakes(challenger, guild, w.Stakes) {
+		return messaging.InfoMessage("One of the house warehouses does not hold the stakes."), nil
+	}
+
+	now := time.Now()
+	w.State = GUILD_WAR_ACTIVE
+	w.StartedAt = null.TimeFrom(now)
+	w.EndsAt = null.TimeFrom(now.Add(time.Duration(w.Duration) * time.Minute))
+	if err := w.Update(); err != nil {
+		log.Println(err)
+	}
+	w.schedule()
+
+	makeAnnouncement(fmt.Sprintf("%s and %s are at war for %d minutes!", challenger.Name, guild.Name, w.Duration))
+	return nil, nil
+}
+
+// takeStakes takes the stakes from the warehouses of both guilds, or nothing when one of them cannot pay.
+func takeStakes(a, b *Guild, stakes uint64) bool {
+	if stakes == 0 {
+		return true
+	}
+
+	first, second := a, b
+	if first.ID > second.ID { // lock the warehouses in the same order everywhere
+		first, second = b, a
+	}
+	first.bankMutex.Lock()
+	defer first.bankMutex.Unlock()
+	second.bankMutex.Lock()
+	defer second.bankMutex.Unlock()
+
+	if a.BankGold < stakes || b.BankGold < stakes {
+		return false
+	}
+
+	a.BankGold -= stakes
+	b.BankGold -= stakes
+	go a.Update()
+	go b.Update()
+	return true
+}
+
+// decline drops the declaration, the caller holds the lock of the war.
+func (w *GuildWar) decline() {
+	w.State = GUILD_WAR_DECLINED
+	w.FinishedAt = null.TimeFrom(time.Now())
+	if err := w.Update(); err != nil {
+		log.Println(err)
+	}
+
+	guildWarMutex.Lock()
+	delete(GuildWars, w.ID)
+	guildWarMutex.Unlock()
+
+	challenger, err := FindGuildByID(w.ChallengerID)
+	enemy, err2 := FindGuildByID(w.EnemyID)
+	if err == nil && err2 == nil && challenger != nil && enemy != nil {
+		challenger.notifyLeader(fmt.Sprintf("%s did not accept your war.", enemy.Name))
+	}
+}
+
+// GuildWarKill scores the kill when the houses of the killer and the victim are at war.
+func GuildWarKill(killer, victim *Character) {
+	if !AtGuildWar(killer, victim) {
+		return
+	}
+
+	w := findGuildWar(killer.GuildID, victim.GuildID)
+	if w == nil {
+		return
+	}
+
+	w.mutex.Lock()
+	if w.State != GUILD_WAR_ACTIVE {
+		w.mutex.Unlock()
+		return
+	}
+	if w.ChallengerID == killer.GuildID {
+		w.ChallengerScore += GUILD_WAR_KILL_SCORE
+	} else {
+		w.EnemyScore += GUILD_WAR_KILL_SCORE
+	}
+	if err := w.Update(); err != nil {
+		log.Println(err)
+	}
+	w.mutex.Unlock()
+
+	go AddGuildActivity(killer, GUILD_KILL_POINTS, GUILD_KILL_POINTS)
+}
+
+// Finish ends the war, the winner takes the stakes of both houses and a draw gives them back.
+func (w *GuildWar) Finish() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.State != GUILD_WAR_ACTIVE {
+		return
+	}
+
+	w.State = GUILD_WAR_FINISHED
+	w.FinishedAt = null.TimeFrom(time.Now())
+	switch {
+	case w.ChallengerScore > w.EnemyScore:
+		w.WinnerID = null.IntFrom(int64(w.ChallengerID))
+	case w.EnemyScore > w.ChallengerScore:
+		w.WinnerID = null.IntFrom(int64(w.EnemyID))
+	}
+	if err := w.Update(); err != nil {
+		log.Println(err)
+	}
+
+	guildWarMutex.Lock()
+	delete(GuildWars, w.ID)
+	guildWarMutex.Unlock()
+
+	challenger, err := FindGuildByID(w.ChallengerID)
+	if err != nil || challenger == nil {
+		return
+	}
+	enemy, err := FindGuildByID(w.EnemyID)
+	if err != nil || enemy == nil {
+		return
+	}
+
+	if !w.WinnerID.Valid {
+		payStakes(challenger, w.Stakes)
+		payStakes(enemy, w.Stakes)
+		makeAnnouncement(fmt.Sprintf("The war between %s and %s ended in a draw %d:%d.", challenger.Name, enemy.Name, w.ChallengerScore, w.EnemyScore))
+		return
+	}
+
+	winner, loser, score := challenger, enemy, fmt.Sprintf("%d:%d", w.ChallengerScore, w.EnemyScore)
+	if int(w.WinnerID.Int64) == enemy.ID {
+		winner, loser, score = enemy, challenger, fmt.Sprintf("%d:%d", w.EnemyScore, w.ChallengerScore)
+	}
+	payStakes(winner, 2*w.Stakes)
+	makeAnnouncement(fmt.Sprintf("%s won the war against %s %s!", winner.Name, loser.Name, score))
+}
+
+func payStakes(g *Guild, gold uint64) {
+	if gold == 0 {
+		return
+	}
+
+	g.bankMutex.Lock()
+	g.BankGold += gold
+	g.bankMutex.Unlock()
+	go g.Update()
+}
+
+// GuildWarLines describes the declared and the running wars of the guild.
+func GuildWarLines(guildID int) []string {
+	guildWarMutex.RLock()
+	wars := []*GuildWar{}
+	for _, w := range GuildWars {
+		if w.ChallengerID == guildID || w.EnemyID == guildID {
+			wars = append(wars, w)
+		}
+	}
+	guildWarMutex.RUnlock()
+
+	sort.Slice(wars, func(i, j int) bool {
+		return wars[i].ID < wars[j].ID
+	})
+
+	lines := []string{}
+	for _, w := range wars {
+		challenger, _ := FindGuildByID(w.ChallengerID)
+		enemy, _ := FindGuildByID(w.EnemyID)
+		if challenger == nil || enemy == nil {
+			continue
+		}
+
+		if w.State == GUILD_WAR_DECLARED {
+			lines = append(lines, fmt.Sprintf("%s declared war on %s (%d minutes, %d gold)", challenger.Name, enemy.Name, w.Duration, w.Stakes))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %d:%d %s, %s left, %d gold", challenger.Name, w.ChallengerScore, w.EnemyScore, enemy.Name,
+			durationText(int(time.Until(w.EndsAt.Time).Seconds())), w.Stakes))
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, "Your house is at peace.")
+	}
+	return lines
+}
+
+// FindGuildWarHistory returns the latest finished wars of the guild.
+func FindGuildWarHistory(guildID, limit int) ([]*GuildWar, error) {
+
+	var wars []*GuildWar
+	query := `select * from hops.guild_wars where (challenger_id = $1 or enemy_id = $1) and state = $2 order by finished_at desc limit $3`
+
+	if _, err := db.Select(&wars, query, guildID, GUILD_WAR_FINISHED, limit); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("FindGuildWarHistory: %s", err.Error())
+	}
+
+	return wars, nil
+}
+
+// GuildWarHistoryLines describes the latest finished wars of the guild.
+func GuildWarHistoryLines(guildID int) ([]string, error) {
+
+	wars, err := FindGuildWarHistory(guildID, GUILD_WAR_HISTORY)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{"War history:"}
+	if len(wars) == 0 {
+		lines = append(lines, "No wars fought yet.")
+	}
+	for _, w := range wars {
+		challenger, enemy := fmt.Sprint(w.ChallengerID), fmt.Sprint(w.EnemyID)
+		if g, err := FindGuildByID(w.ChallengerID); err == nil && g != nil {
+			challenger = g.Name
+		}
+		if g, err := FindGuildByID(w.EnemyID); err == nil && g != nil {
+			enemy = g.Name
+		}
+
+		result := "draw"
+		if w.WinnerID.Valid && int(w.WinnerID.Int64) == guildID {
+			result = "won"
+		} else if w.WinnerID.Valid {
+			result = "lost"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %d:%d %s, %s", w.FinishedAt.Time.Format("2006-01-02 15:04"), challenger, w.ChallengerScore,
+			w.EnemyScore, enemy, result))
+	}
+	return lines, nil
+}
+
+// addAlly records the alliance in memory, the caller holds the alliance lock.
+func addAlly(guildID, allyID int) {
+	if guildAlliances[guildID] == nil {
+		guildAlliances[guildID] = make(map[int]bool)
+	}
+	guildAlliances[guildID][allyID] = true
+}
+
+// AreAllied reports whether the two houses are allies.
+func AreAllied(a, b int) bool {
+	allianceMutex.RLock()
+	defer allianceMutex.RUnlock()
+	return guildAlliances[a][b]
+}
+
+// AllyIDs returns the guild ids of the allies of the guild.
+func AllyIDs(guildID int) []int {
+	allianceMutex.RLock()
+	defer allianceMutex.RUnlock()
+
+	ids := []int{}
+	for id := range guildAlliances[guildID] {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// InviteAlliance offers an alliance to the other house on behalf of the house of the character.
+func InviteAlliance(c *Character, name string) ([]byte, error) {
+
+	guild, msg, err := leaderGuild(c)
+	if guild == nil {
+		return msg, err
+	}
+
+	ally, err := FindGuildByName(name)
+	if err != nil {
+		return nil, err
+	} else if ally == nil || ally.ID == guild.ID {
+		return messaging.InfoMessage("There is no such house."), nil
+	} else if ally, err = FindGuildByID(ally.ID); err != nil || ally == nil {
+		return nil, err
+	}
+
+	if AreAllied(guild.ID, ally.ID) {
+		return messaging.InfoMessage(fmt.Sprintf("%s is already your ally.", ally.Name)), nil
+	} else if findGuildWar(guild.ID, ally.ID) != nil {
+		return messaging.InfoMessage(fmt.Sprintf("You are at war with %s.", ally.Name)), nil
+	} else if len(AllyIDs(guild.ID)) >= GUILD_MAX_ALLIES {
+		return messaging.InfoMessage(fmt.Sprintf("A house can have %d allies.", GUILD_MAX_ALLIES)), nil
+	}
+
+	allianceMutex.Lock()
+	if allianceInvites[ally.ID] == nil {
+		allianceInvites[ally.ID] = make(map[int]bool)
+	}
+	allianceInvites[ally.ID][guild.ID] = true
+	allianceMutex.Unlock()
+
+	ally.notifyLeader(fmt.Sprintf("%s offers your house an alliance. Answer with /alliance accept %s.", guild.Name, guild.Name))
+	return messaging.InfoMessage(fmt.Sprintf("You offered an alliance to %s.", ally.Name)), nil
+}
+
+// AcceptAlliance accepts the alliance the other house offered to the house of the character.
+func AcceptAlliance(c *Character, name string) ([]byte, error) {
+
+	guild, msg, err := leaderGuild(c)
+	if guild == nil {
+		return msg, err
+	}
+
+	inviter, err := FindGuildByName(name)
+	if err != nil {
+		return nil, err
+	} else if inviter == nil {
+		return messaging.InfoMessage("There is no such house."), nil
+	} else if inviter, err = FindGuildByID(inviter.ID); err != nil || inviter == nil {
+		return nil, err
+	}
+
+	allianceMutex.Lock()
+	defer allianceMutex.Unlock()
+
+	if !allianceInvites[guild.ID][inviter.ID] {
+		return messaging.InfoMessage(fmt.Sprintf("%s has not offered you an alliance.", inviter.Name)), nil
+	} else if len(guildAlliances[guild.ID]) >= GUILD_MAX_ALLIES || len(guildAlliances[inviter.ID]) >= GUILD_MAX_ALLIES {
+		return messaging.InfoMessage(fmt.Sprintf("A house can have %d allies.", GUILD_MAX_ALLIES)), nil
+	}
+	delete(allianceInvites[guild.ID], inviter.ID)
+
+	a := &GuildAlliance{GuildID: guild.ID, AllyID: inviter.ID, CreatedAt: null.TimeFrom(time.Now())}
+	if a.GuildID > a.AllyID {
+		a.GuildID, a.AllyID = a.AllyID, a.GuildID
+	}
+	if err := a.Create(); err != nil {
+		return nil, fmt.Errorf("AcceptAlliance: %s", err.Error())
+	}
+
+	addAlly(guild.ID, inviter.ID)
+	addAlly(inviter.ID, guild.ID)
+	makeAnnouncement(fmt.Sprintf("%s and %s formed an alliance.", inviter.Name, guild.Name))
+	return nil, nil
+}
+
+// BreakAlliance ends the alliance of the house of the character with the other house.
+func BreakAlliance(c *Character, name string) ([]byte, error) {
+
+	guild, msg, err := leaderGuild(c)
+	if guild == nil {
+		return msg, err
+	}
+
+	ally, err := FindGuildByName(name)
+	if err != nil {
+		return nil, err
+	} else if ally == nil || !AreAllied(guild.ID, ally.ID) {
+		return messaging.InfoMessage(fmt.Sprintf("%s is not your ally.", name)), nil
+	}
+
+	a := &GuildAlliance{GuildID: guild.ID, AllyID: ally.ID}
+	if a.GuildID > a.AllyID {
+		a.GuildID, a.AllyID = a.AllyID, a.GuildID
+	}
+	if err := a.Delete(); err != nil {
+		return nil, fmt.Errorf("BreakAlliance: %s", err.Error())
+	}
+
+	allianceMutex.Lock()
+	delete(guildAlliances[guild.ID], ally.ID)
+	delete(guildAlliances[ally.ID], guild.ID)
+	allianceMutex.Unlock()
+
+	if a, err := FindGuildByID(ally.ID); err == nil && a != nil {
+		a.notifyLeader(fmt.Sprintf("%s ended the alliance with your house.", guild.Name))
+	}
+	return messaging.InfoMessage(fmt.Sprintf("You ended the alliance with %s.", ally.Name)), nil
+}
+
+// AllianceMembers returns the online members of the guild and of its allies.
+func AllianceMembers(guildID int) []*Character {
+
+	members := []*Character{}
+	for _, id := range append([]int{guildID}, AllyIDs(guildID)...) {
+		g, err := FindGuildByID(id)
+		if err != nil || g == nil {
+			continue
+		}
+		members = append(members, g.OnlineMembers()...)
+	}
+	return members
+}
+
+// dropDiplomacy forgets the wars and the alliances of the dissolved guild, the database drops their rows with it. The
+// other side of a running war gets its stakes back.
+func dropDiplomacy(guildID int) {
+	guildWarMutex.Lock()
+	refunds := []*GuildWar{}
+	for id, w := range GuildWars {
+		if w.ChallengerID == guildID || w.EnemyID == guildID {
+			delete(GuildWars, id)
+			if w.State == GUILD_WAR_ACTIVE {
+				refunds = append(refunds, w)
+			}
+		}
+	}
+	guildWarMutex.Unlock()
+
+	for _, w := range refunds {
+		w.mutex.Lock()
+		w.State = GUILD_WAR_FINISHED
+		other := w.ChallengerID
+		if other == guildID {
+			other = w.EnemyID
+		}
+		w.mutex.Unlock()
+
+		if g, err := FindGuildByID(other); err == nil && g != nil {
+			payStakes(g, w.Stakes)
+		}
+	}
+
+	allianceMutex.Lock()
+	for id := range guildAlliances[guildID] {
+		delete(guildAlliances[id], guildID)
+	}
+	delete(guildAlliances, guildID)
+	delete(allianceInvites, guildID)
+	allianceMutex.Unlock()
+}
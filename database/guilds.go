@@ -2,20 +2,18 @@ package database
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
+	"math"
 	"sync"
 
 	"hero-emulator/utils"
 
-	"github.com/thoas/go-funk"
 	gorp "gopkg.in/gorp.v1"
 )
 
 var (
-	Guilds    = make(map[int]*Guild)
-	GuildWars = make(map[int]*Guild)
-	gMutex    sync.RWMutex
+	Guilds = make(map[int]*Guild)
+	gMutex sync.RWMutex
 
 	GUILD_INFO = utils.Packet{0xAA, 0x55, 0x00, 0x00, 0x83, 0x09, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x55, 0xAA}
@@ -39,29 +37,22 @@ const (
 )
 
 type Guild struct {
-	ID              int             `db:"id" json:"id"`
-	LeaderID        int             `db:"leader_id" json:"leader_id"`
-	Name            string          `db:"name" json:"name"`
-	MemberCount     int16           `db:"member_count" json:"member_count"`
-	Members         json.RawMessage `db:"members" json:"members"`
-	Logo            []byte          `db:"logo" json:"logo"`
-	Description     string          `db:"description" json:"description"`
-	Announcement    string          `db:"announcement" json:"announcement"`
-	Faction         int16           `db:"faction" json:"faction"`
-	GoldDonation    uint64          `db:"gold_donation" json:"gold_donation"`
-	HonorDonation   uint64          `db:"honor_donation" json:"honor_donation"`
-	Recognition     uint64          `db:"recognition" json:"recognition"`
-	challengerGuild *GuildWar       `db:"-" json:"-"`
-	EnemyGuild      *GuildWar       `db:"-" json:"-"`
-	mutex           sync.RWMutex    `db:"-"`
-}
-
-type GuildMember struct {
-	ID   int       `json:"id"`
-	Role GuildRole `json:"role"`
-}
-type GuildWar struct {
-	ID int `json:"id"`
+	ID            int                  `db:"id" json:"id"`
+	LeaderID      int                  `db:"leader_id" json:"leader_id"`
+	Name          string               `db:"name" json:"name"`
+	MemberCount   int16                `db:"member_count" json:"member_count"`
+	Logo          []byte               `db:"logo" json:"logo"`
+	Description   string               `db:"description" json:"description"`
+	Announcement  string               `db:"announcement" json:"announcement"`
+	Faction       int16                `db:"faction" json:"faction"`
+	GoldDonation  uint64               `db:"gold_donation" json:"gold_donation"`
+	HonorDonation uint64               `db:"honor_donation" json:"honor_donation"`
+	Recognition   uint64               `db:"recognition" json:"recognition"`
+	BankGold      uint64               `db:"bank_gold" json:"bank_gold"`
+	members       map[int]*GuildMember `db:"-"`
+	mutex         sync.RWMutex         `db:"-"`
+	bank          []*InventorySlot     `db:"-"`
+	bankMutex     sync.Mutex           `db:"-"`
 }
 
 func (g *Guild) Create() error {
@@ -78,113 +69,24 @@ func (g *Guild) Update() error {
 }
 
 func (g *Guild) Delete() error {
-	gMutex.Lock()
-	defer gMutex.Unlock()
-	delete(Guilds, g.ID)
-
-	_, err := db.Delete(g)
-	return err
-}
-
-func (g *Guild) ClanWar(enemyG, sourceG *Guild) {
-	GuildWars[0] = sourceG
-	GuildWars[1] = enemyG
-}
-
-func (g *Guild) GetMembers() ([]*GuildMember, error) {
-	members := []*GuildMember{}
-	if g.Members == nil {
-		return members, nil
-	}
-
-	err := json.Unmarshal(g.Members, &members)
-	if err != nil {
-		return nil, err
-	}
-
-	return members, nil
-}
-
-func (g *Guild) GetMember(id int) (*GuildMember, error) {
-	members, err := g.GetMembers()
-	if err != nil {
-		return nil, err
-	}
-
+	members, _ := g.GetMembers()
 	for _, m := range members {
-		if m.ID == id {
-			return m, nil
+		if c, err := FindCharacterByID(m.ID); err == nil && c != nil {
+			RemoveGuildPerks(c)
 		}
 	}
 
-	return nil, nil
-}
-
-func (g *Guild) SetMember(member *GuildMember) error {
-	members, err := g.GetMembers()
-	if err != nil {
-		return err
-	}
-
-	for _, m := range members {
-		if m.ID == member.ID {
-			*m = *member
-			break
-		}
-	}
-
-	return g.SetMembers(members)
-}
-
-func (g *Guild) SetMembers(members []*GuildMember) error {
-	data, err := json.Marshal(members)
-	if err != nil {
-		return err
-	}
-
-	g.Members = data
-	return nil
-}
-
-func (g *Guild) AddMember(member *GuildMember) error {
-	g.mutex.Lock()
-	defer g.mutex.Unlock()
-
-	members, err := g.GetMembers()
-	if err != nil {
-		return err
-	}
-
-	members = append(members, member)
-	err = g.SetMembers(members)
-	if err != nil {
-		return err
-	}
-
-	g.MemberCount = int16(len(members))
-	return nil
-}
-
-func (g *Guild) RemoveMember(id int) error {
-	g.mutex.Lock()
-	defer g.mutex.Unlock()
-
-	members, err := g.GetMembers()
-	if err != nil {
-		return err
-	}
-
-	members = funk.Filter(members, func(m *GuildMember) bool {
-		return m.ID != id
-	}).([]*GuildMember)
+	gMutex.Lock()
+	defer gMutex.Unlock()
+	delete(Guilds, g.ID)
 
-	err = g.SetMembers(members)
-	if err != nil {
-		return err
-	}
+	rosterMutex.Lock()
+	delete(guildRosters, g.ID)
+	rosterMutex.Unlock()
+	dropDiplomacy(g.ID)
 
-	g.MemberCount = int16(len(members))
-	return nil
+	_, err := db.Delete(g)
+	return err
 }
 
 func (g *Guild) GetInfo() []byte {
@@ -199,6 +101,17 @@ func (g *Guild) GetInfo() []byte {
 	data.Insert([]byte(g.Name), 18)                         // guild name
 	data.Insert(g.Logo[:], 18+len(g.Name))                  // guild logo
 
+	g.mutex.RLock()
+	index := 18 + len(g.Name) + len(g.Logo)
+	recognition := g.Recognition
+	if recognition > math.MaxUint32 {
+		recognition = math.MaxUint32
+	}
+	data.Overwrite(utils.IntToBytes(uint64(g.Level().Level), 2, true), index) // guild level
+	data.Overwrite(utils.IntToBytes(recognition, 4, true), index+2)           // guild recognition
+	data.Overwrite(utils.IntToBytes(uint64(g.progress()), 2, true), index+6)  // progress to the next level in percent
+	g.mutex.RUnlock()
+
 	return data
 }
 
@@ -214,17 +127,14 @@ func (g *Guild) GetMemberInfo(member *Character) []byte {
 	data.Insert(utils.IntToBytes(uint64(member.Level), 4, true), index2) // character level
 	index2 += 4
 
-	members, err := g.GetMembers()
+	m, err := g.GetMember(member.ID)
 	if err != nil {
 		return nil
 	}
 
 	role := GROLE_MEMBER
-	for _, m := range members {
-		if m.ID == member.ID {
-			role = m.Role
-			break
-		}
+	if m != nil {
+		role = m.Role
 	}
 
 	data[index2] = byte(role)
@@ -308,9 +218,14 @@ func (g *Guild) GetData(issuer *Character) ([]byte, error) {
 		index += 4
 		data.Insert(utils.IntToBytes(uint64(c.Map), 1, true), index) // member map id
 		index++
-		data.Insert([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0A,
-			0x32, 0x30, 0x31, 0x38, 0x2D, 0x30, 0x38, 0x2D, 0x32, 0x35, 0x00}, index) // last seen
-		index += 24
+		lastSeen := []byte("2018-08-25")
+		if member.LastOnline.Valid {
+			lastSeen = []byte(member.LastOnline.Time.Format("2006-01-02"))
+		}
+		data.Insert([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0A}, index)
+		index += 13
+		data.Insert(append(lastSeen, 0x00), index) // last seen
+		index += 11
 		length += int16(0x36 + len(c.Name))
 	}
 
@@ -358,28 +273,8 @@ func FindGuildByID(id int) (*Guild, error) {
 
 func (g *Guild) InformMembers(m *Character) {
 
-	g.mutex.Lock()
-	members, err := g.GetMembers()
-	g.mutex.Unlock()
-	if err != nil {
-		return
-	}
-
-	members = funk.Filter(members, func(m *GuildMember) bool {
-		c, err := FindCharacterByID(m.ID)
-		if err != nil || c == nil {
-			return false
-		}
-		return c.IsOnline
-	}).([]*GuildMember)
-
 	data := g.GetMemberInfo(m)
-	for _, member := range members {
-		c, err := FindCharacterByID(member.ID)
-		if err != nil || c == nil {
-			continue
-		}
-
+	for _, c := range g.OnlineMembers() {
 		c.Socket.Write(data)
 	}
 }
@@ -31,6 +31,8 @@ var (
 	RemoveFromRegister      func(*Character)
 	RemovePetFromRegister   func(c *Character)
 	FindCharacterByPseudoID func(server int, ID uint16) *Character
+	GenerateIDForAI         func(*AI)
+	RemoveAIFromRegister    func(*AI)
 
 	AccUpgrades    []byte
 	ArmorUpgrades  []byte
@@ -41,6 +43,46 @@ var (
 
 func InitDB() error {
 
+	if err := connect(); err != nil {
+		return err
+	}
+
+	if err := resetDB(); err != nil {
+		return err
+	}
+
+	if err := getAll(); err != nil {
+		return err
+	}
+
+	Init <- true
+	return nil
+}
+
+// InitDataDB connects to the database and loads only the given data tables,
+// it leaves the player tables untouched so offline tools can use it next to a running server.
+func InitDataDB(tables ...string) error {
+
+	if err := connect(); err != nil {
+		return err
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	old := currentSnapshot()
+	snapshot := *old
+	for _, table := range tables {
+		if err := snapshot.load(table); err != nil {
+			return err
+		}
+	}
+
+	return snapshot.swap(old)
+}
+
+func connect() error {
+
 	var (
 		cfg = config.Default
 		//drv         = cfg.Database.Driver
@@ -87,6 +129,7 @@ func InitDB() error {
 	db.AddTableWithNameAndSchema(ItemMelting{}, "data", "item_meltings")
 	db.AddTableWithNameAndSchema(Gate{}, "data", "gates")
 	db.AddTableWithNameAndSchema(DropInfo{}, "data", "drops").SetKeys(false, "id")
+	db.AddTableWithNameAndSchema(DropEntry{}, "data", "drop_items").SetKeys(true, "id")
 	db.AddTableWithNameAndSchema(HtItem{}, "data", "ht_shop").SetKeys(false, "id")
 	db.AddTableWithNameAndSchema(NPCScript{}, "data", "npc_scripts")
 	db.AddTableWithNameAndSchema(Fusion{}, "data", "advanced_fusion")
@@ -101,15 +144,32 @@ func InitDB() error {
 	db.AddTableWithNameAndSchema(Rank{}, "data", "reborn_system").SetKeys(false, "id")
 	db.AddTableWithNameAndSchema(ItemJudgement{}, "data", "item_judgement")
 	db.AddTableWithNameAndSchema(FiveClan{}, "data", "fiveclan_war").SetKeys(false, "id")
+	db.AddTableWithNameAndSchema(QuestInfo{}, "data", "quests").SetKeys(false, "id")
+	db.AddTableWithNameAndSchema(NPCAction{}, "data", "npc_actions").SetKeys(false, "id", "npc_id")
+	db.AddTableWithNameAndSchema(NPCBehavior{}, "data", "npc_behaviors").SetKeys(false, "npc_id")
 
 	db.AddTableWithNameAndSchema(AI{}, "hops", "ai").SetKeys(true, "id")
 	db.AddTableWithNameAndSchema(AiBuff{}, "hops", "ai_buffs").SetKeys(false, "id")
 	db.AddTableWithNameAndSchema(Character{}, "hops", "characters").SetKeys(true, "id")
 	db.AddTableWithNameAndSchema(Buff{}, "hops", "characters_buffs").SetKeys(false, "id", "character_id")
+	db.AddTableWithNameAndSchema(CharacterQuest{}, "hops", "characters_quests").SetKeys(true, "id")
+	db.AddTableWithNameAndSchema(CharacterRelation{}, "hops", "character_relations").SetKeys(false, "character_id", "target_id")
+	db.AddTableWithNameAndSchema(ChatLog{}, "hops", "chat_logs").SetKeys(true, "id")
+	db.AddTableWithNameAndSchema(ChatMute{}, "hops", "chat_mutes").SetKeys(true, "id")
+	db.AddTableWithNameAndSchema(ChatReport{}, "hops", "chat_reports").SetKeys(true, "id")
 	db.AddTableWithNameAndSchema(ConsignmentItem{}, "hops", "consignment").SetKeys(false, "id")
+	db.AddTableWithNameAndSchema(DungeonRun{}, "hops", "dungeon_runs").SetKeys(true, "id")
+	db.AddTableWithNameAndSchema(DungeonRunMember{}, "hops", "dungeon_run_members").SetKeys(false, "run_id", "character_id")
 	db.AddTableWithNameAndSchema(Guild{}, "hops", "guilds").SetKeys(true, "id")
+	db.AddTableWithNameAndSchema(GuildMember{}, "hops", "guild_members").SetKeys(false, "character_id")
+	db.AddTableWithNameAndSchema(GuildLog{}, "hops", "guild_logs").SetKeys(true, "id")
+	db.AddTableWithNameAndSchema(GuildBankLog{}, "hops", "guild_bank_logs").SetKeys(true, "id")
+	db.AddTableWithNameAndSchema(GuildWar{}, "hops", "guild_wars").SetKeys(true, "id")
+	db.AddTableWithNameAndSchema(GuildAlliance{}, "hops", "guild_alliances").SetKeys(false, "guild_id", "ally_id")
 	db.AddTableWithNameAndSchema(InventorySlot{}, "hops", "items_characters").SetKeys(true, "id")
+	db.AddTableWithNameAndSchema(Mail{}, "hops", "mails").SetKeys(true, "id")
 	db.AddTableWithNameAndSchema(Relic{}, "hops", "relics")
+	db.AddTableWithNameAndSchema(ScheduledEvent{}, "hops", "scheduled_events").SetKeys(true, "id")
 	db.AddTableWithNameAndSchema(Server{}, "hops", "servers").SetKeys(true, "id")
 	db.AddTableWithNameAndSchema(Skills{}, "hops", "skills").SetKeys(false, "id")
 	db.AddTableWithNameAndSchema(Stat{}, "hops", "stats").SetKeys(false, "id")
@@ -119,15 +179,6 @@ func InitDB() error {
 		db.TraceOn("[gorp]", log.New(os.Stdout, "myapp:", log.Lmicroseconds))
 	}
 
-	if err = resetDB(); err != nil {
-		return err
-	}
-
-	if err = getAll(); err != nil {
-		return err
-	}
-
-	Init <- err == nil
 	return nil
 }
 
@@ -156,7 +207,8 @@ func getAll() error {
 
 	callBacks := []func() error{getAllDrops, getScripts, getHaxCodes, getHTItems, getProductions, getCraftItem, getAdvancedFusions, getItemMeltings, getGates,
 		getStackables, getAllItems, getSkillInfos, getGamblingItems, getJobPassives, getItemJudgements, getItemSet, getBuffIcons, getBuffInfections, getExps, getAllSavePoints,
-		getRelics, getRelicLog, GetAllPetExps, GetAllPets, getAllShops, getAllShopItems}
+		getRelics, getRelicLog, GetAllPetExps, GetAllPets, getAllShops, getAllShopItems, getQuests, getNPCActions, getNPCBehaviors, getScheduledEvents,
+		getGuildWars, getGuildAlliances, getFiveAreas, getChatMutes}
 
 	for _, cb := range callBacks {
 		if err := cb(); err != nil {
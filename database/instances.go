@@ -0,0 +1,711 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"hero-emulator/messaging"
+	"hero-emulator/utils"
+
+	"github.com/thoas/go-funk"
+)
+
+const (
+	INSTANCE_RUNNING = iota
+	INSTANCE_COMPLETED
+	INSTANCE_FAILED
+	INSTANCE_CLOSED
+)
+
+const (
+	INSTANCE_ENTRY_RANGE  = 30.0
+	INSTANCE_EXIT_SECONDS = 30
+	INSTANCE_IDLE_SECONDS = 300 // an instance nobody is inside of is closed after, disconnected members can return until then
+)
+
+var (
+	// DungeonDir holds one json file per dungeon template.
+	DungeonDir = "data/dungeons"
+	Dungeons   = make(map[int]*DungeonTemplate)
+	Instances  = make(map[int]*Instance)
+	dgMutex    sync.RWMutex
+
+	instanceSeq int
+
+	INSTANCE_TIMER = utils.Packet{0xAA, 0x55, 0x08, 0x00, 0x65, 0x03, 0x00, 0x00, 0x55, 0xAA}
+)
+
+// DungeonTemplate describes an instanced dungeon, the file format is described in the README.
+type DungeonTemplate struct {
+	ID             int             `json:"id"`
+	Name           string          `json:"name"`
+	Map            int16           `json:"map"`
+	Entry          *DungeonPoint   `json:"entry"` // nil enters at the save point of the map
+	Exit           *DungeonPoint   `json:"exit"`
+	TimeLimit      int             `json:"time_limit"` // seconds
+	MinLevel       int             `json:"min_level"`
+	MaxLevel       int             `json:"max_level"`
+	MinPlayers     int             `json:"min_players"`
+	MaxPlayers     int             `json:"max_players"`
+	RequiredItem   int64           `json:"required_item"` // taken from the leader on entry
+	WelcomeMessage string          `json:"welcome_message"`
+	Waves          []*DungeonWave  `json:"waves"`
+	Bosses         []*DungeonSpawn `json:"bosses"`
+	Rewards        *DungeonReward  `json:"rewards"`
+}
+
+type DungeonPoint struct {
+	Map int16   `json:"map"`
+	X   float64 `json:"x"`
+	Y   float64 `json:"y"`
+}
+
+// DungeonWave is spawned when every mob of the previous wave is dead, the bosses come after the last wave.
+type DungeonWave struct {
+	Message string          `json:"message"`
+	Spawns  []*DungeonSpawn `json:"spawns"`
+}
+
+type DungeonSpawn struct {
+	NPCID  int     `json:"npc_id"`
+	Count  int     `json:"count"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Radius float64 `json:"radius"`
+}
+
+// DungeonReward is given to every member inside the instance when the last boss dies.
+type DungeonReward struct {
+	Gold    uint64           `json:"gold"`
+	Exp     int64            `json:"exp"`
+	Items   []*EncounterItem `json:"items"`
+	Message string           `json:"message"`
+}
+
+// Instance is a running copy of a dungeon. Its mobs are kept out of AIsByMap and are only
+// seen by the characters of the instance, Server is the world the instance runs on.
+type Instance struct {
+	ID        int
+	Dungeon   *DungeonTemplate
+	Server    int
+	Leader    int
+	Members   map[int]bool // character ids, false once the member left
+	Mobs      []*AI
+	Wave      int
+	State     int
+	StartedAt time.Time
+	ExpiresAt time.Time
+
+	closeAt    time.Time
+	emptySince time.Time
+	stats      map[int]*DungeonRunMember
+	mutex      sync.RWMutex
+}
+
+// LoadDungeons reads the dungeon files of DungeonDir and replaces the loaded templates.
+func LoadDungeons() ([]string, error) {
+
+	files, err := filepath.Glob(filepath.Join(DungeonDir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("LoadDungeons: %s", err.Error())
+	}
+
+	dungeons := make(map[int]*DungeonTemplate)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("LoadDungeons: %s", err.Error())
+		}
+
+		d := &DungeonTemplate{}
+		if err := json.Unmarshal(data, d); err != nil {
+			return nil, fmt.Errorf("LoadDungeons %s: %s", file, err.Error())
+		}
+		dungeons[d.ID] = d
+	}
+
+	errs := validateDungeons(dungeons)
+	if len(errs) > 0 {
+		return errs, fmt.Errorf("LoadDungeons: %s", strings.Join(errs, ", "))
+	}
+
+	dgMutex.Lock()
+	Dungeons = dungeons
+	dgMutex.Unlock()
+	return nil, nil
+}
+
+func validateDungeons(dungeons map[int]*DungeonTemplate) []string {
+
+	errs := []string{}
+	checkSpawn := func(id int, s *DungeonSpawn) {
+		if _, ok := NPCs[s.NPCID]; !ok {
+			errs = append(errs, fmt.Sprintf("dungeon %d: unknown npc %d", id, s.NPCID))
+		}
+		if s.Count <= 0 {
+			errs = append(errs, fmt.Sprintf("dungeon %d: npc %d has no count", id, s.NPCID))
+		}
+	}
+
+	for id, d := range dungeons {
+		if id <= 0 {
+			errs = append(errs, fmt.Sprintf("dungeon %s: invalid id", d.Name))
+		}
+		if d.Map <= 0 {
+			errs = append(errs, fmt.Sprintf("dungeon %d: no map", id))
+		}
+		if d.TimeLimit <= 0 {
+			errs = append(errs, fmt.Sprintf("dungeon %d: no time limit", id))
+		}
+		if len(d.Waves) == 0 && len(d.Bosses) == 0 {
+			errs = append(errs, fmt.Sprintf("dungeon %d: no waves", id))
+		}
+		if d.MaxPlayers > 0 && d.MinPlayers > d.MaxPlayers {
+			errs = append(errs, fmt.Sprintf("dungeon %d: min players above max players", id))
+		}
+		if d.RequiredItem > 0 {
+			if _, ok := Items[d.RequiredItem]; !ok {
+				errs = append(errs, fmt.Sprintf("dungeon %d: unknown item %d", id, d.RequiredItem))
+			}
+		}
+
+		for _, wave := range d.Waves {
+			for _, s := range wave.Spawns {
+				checkSpawn(id, s)
+			}
+		}
+		for _, s := range d.Bosses {
+			checkSpawn(id, s)
+		}
+	}
+
+	sort.Strings(errs)
+	return errs
+}
+
+func FindDungeon(id int) *DungeonTemplate {
+	dgMutex.RLock()
+	defer dgMutex.RUnlock()
+	return Dungeons[id]
+}
+
+// IsDungeonMap reports whether the map belongs to a dungeon, characters cannot stay on it outside of an instance.
+func IsDungeonMap(mapID int16) bool {
+	dgMutex.RLock()
+	defer dgMutex.RUnlock()
+	for _, d := range Dungeons {
+		if d.Map == mapID {
+			return true
+		}
+	}
+	return false
+}
+
+func FindInstance(id int) *Instance {
+	if id == 0 {
+		return nil
+	}
+
+	dgMutex.RLock()
+	defer dgMutex.RUnlock()
+	return Instances[id]
+}
+
+// findInstanceOf returns the open instance the character is a member of.
+func findInstanceOf(characterID int) *Instance {
+	dgMutex.RLock()
+	defer dgMutex.RUnlock()
+	for _, inst := range Instances {
+		inst.mutex.RLock()
+		inside := inst.Members[characterID]
+		inst.mutex.RUnlock()
+		if inside && inst.State != INSTANCE_CLOSED {
+			return inst
+		}
+	}
+	return nil
+}
+
+// EnterDungeon creates an instance of the dungeon for the character and the party members near it,
+// ok is false when the entry requirements are not met.
+func EnterDungeon(c *Character, dungeonID int) ([]byte, bool, error) {
+
+	d := FindDungeon(dungeonID)
+	if d == nil {
+		return messaging.InfoMessage("This dungeon is not available."), false, nil
+	} else if c.InstanceID != 0 {
+		return messaging.InfoMessage("You are already in a dungeon."), false, nil
+	}
+
+	server := c.Socket.User.ConnectedServer
+	leaderLocation := ConvertPointToLocation(c.Coordinate)
+	members := []*Character{c}
+	if party := FindParty(c); party != nil {
+		if party.Leader.ID != c.ID {
+			return messaging.InfoMessage("Only the party leader can enter the dungeon."), false, nil
+		}
+
+		members = party.presentMembers(func(m *Character) bool {
+			return m.IsOnline && m.Map == c.Map && m.Socket.User != nil && m.Socket.User.ConnectedServer == server &&
+				utils.CalculateDistance(leaderLocation, ConvertPointToLocation(m.Coordinate)) <= INSTANCE_ENTRY_RANGE
+		})
+	}
+
+	if len(members) < d.MinPlayers {
+		return messaging.InfoMessage(fmt.Sprintf("At least %d players are needed to enter.", d.MinPlayers)), false, nil
+	} else if d.MaxPlayers > 0 && len(members) > d.MaxPlayers {
+		return messaging.InfoMessage(fmt.Sprintf("At most %d players can enter.", d.MaxPlayers)), false, nil
+	}
+
+	if !funk.Contains(unlockedMaps, d.Map) {
+		return messaging.InfoMessage("This dungeon is not available."), false, nil
+	}
+
+	for _, m := range members {
+		if !m.IsOnline || m.Socket == nil || m.Socket.User == nil {
+			return messaging.InfoMessage(fmt.Sprintf("%s is not online.", m.Name)), false, nil
+		} else if m.Level < d.MinLevel || (d.MaxLevel > 0 && m.Level > d.MaxLevel) {
+			return messaging.InfoMessage(fmt.Sprintf("%s does not meet the level requirement.", m.Name)), false, nil
+		} else if m.InstanceID != 0 {
+			return messaging.InfoMessage(fmt.Sprintf("%s is already in a dungeon.", m.Name)), false, nil
+		}
+	}
+
+	slotID := int16(-1)
+	if d.RequiredItem > 0 {
+		var err error
+		slotID, _, err = c.FindItemInInventory(nil, d.RequiredItem)
+		if err != nil {
+			return nil, false, err
+		} else if slotID == -1 {
+			return messaging.InfoMessage("You do not have the item to enter the dungeon."), false, nil
+		}
+	}
+
+	now := time.Now()
+	inst := &Instance{Dungeon: d, Leader: c.ID, Members: make(map[int]bool), StartedAt: now,
+		ExpiresAt: now.Add(time.Duration(d.TimeLimit) * time.Second), stats: make(map[int]*DungeonRunMember)}
+
+	dgMutex.Lock()
+	instanceSeq++
+	inst.ID = instanceSeq
+	Instances[inst.ID] = inst
+	dgMutex.Unlock()
+
+	inst.mutex.Lock()
+	for _, m := range members {
+		inst.Members[m.ID] = true
+		inst.stats[m.ID] = &DungeonRunMember{CharacterID: m.ID, Name: m.Name}
+		m.InstanceID = inst.ID
+	}
+	inst.mutex.Unlock()
+
+	// the leader enters first, nothing is taken and the instance is dropped when it cannot
+	resp, err := c.ChangeMap(d.Map, d.Entry.location())
+	if err != nil || resp == nil {
+		for _, m := range members {
+			m.InstanceID = 0
+		}
+		dgMutex.Lock()
+		delete(Instances, inst.ID)
+		dgMutex.Unlock()
+		return nil, false, err
+	}
+
+	inst.Server = c.Socket.User.ConnectedServer     // entering a shared map moves the characters to the first server
+	AfterTicks(inst.Server, time.Second, inst.tick) // the first tick spawns the first wave
+
+	if slotID >= 0 {
+		resp = append(resp, *c.DecrementItem(slotID, 1)...)
+	}
+	if d.WelcomeMessage != "" {
+		resp = append(resp, messaging.InfoMessage(d.WelcomeMessage)...)
+	}
+
+	for _, m := range members {
+		if m.ID == c.ID {
+			continue
+		}
+
+		data, err := m.ChangeMap(d.Map, d.Entry.location())
+		if err != nil || data == nil { // the member stays outside, the others go on
+			log.Printf("EnterDungeon: %s could not enter instance %d: %v", m.Name, inst.ID, err)
+			inst.mutex.Lock()
+			inst.Members[m.ID] = false
+			inst.mutex.Unlock()
+			m.InstanceID = 0
+			continue
+		}
+
+		if d.WelcomeMessage != "" {
+			data = append(data, messaging.InfoMessage(d.WelcomeMessage)...)
+		}
+		m.Socket.Write(data)
+	}
+
+	return resp, true, nil
+}
+
+func (p *DungeonPoint) location() *utils.Location {
+	if p == nil || (p.X == 0 && p.Y == 0) {
+		return nil
+	}
+	return &utils.Location{X: p.X, Y: p.Y}
+}
+
+// LeaveDungeon takes the character out of its instance.
+func LeaveDungeon(c *Character) ([]byte, error) {
+
+	inst := FindInstance(c.InstanceID)
+	if inst == nil {
+		return messaging.InfoMessage("You are not in a dungeon."), nil
+	}
+
+	inst.mutex.Lock()
+	inst.Members[c.ID] = false
+	inst.mutex.Unlock()
+
+	return inst.sendOut(c)
+}
+
+// RejoinInstance puts a reconnecting character back into the instance it left the game in.
+func RejoinInstance(c *Character) bool {
+
+	inst := findInstanceOf(c.ID)
+	if inst == nil {
+		return false
+	}
+
+	if inst.State != INSTANCE_RUNNING && inst.State != INSTANCE_COMPLETED || c.Map != inst.Dungeon.Map ||
+		c.Socket.User.ConnectedServer != inst.Server {
+		inst.mutex.Lock()
+		inst.Members[c.ID] = false
+		inst.mutex.Unlock()
+		return false
+	}
+
+	c.InstanceID = inst.ID
+	return true
+}
+
+// sendOut teleports the character to the exit of the dungeon.
+func (inst *Instance) sendOut(c *Character) ([]byte, error) {
+	c.InstanceID = 0
+
+	exit, mapID := inst.Dungeon.Exit.location(), int16(1)
+	if inst.Dungeon.Exit != nil && inst.Dungeon.Exit.Map > 0 {
+		mapID = inst.Dungeon.Exit.Map
+	}
+	return c.ChangeMap(mapID, exit)
+}
+
+// inside returns the online members which are in the instance.
+func (inst *Instance) inside() []*Character {
+	inst.mutex.RLock()
+	ids := []int{}
+	for id, in := range inst.Members {
+		if in {
+			ids = append(ids, id)
+		}
+	}
+	inst.mutex.RUnlock()
+	sort.Ints(ids)
+
+	members := []*Character{}
+	for _, id := range ids {
+		c, err := FindCharacterByID(id)
+		if err != nil || c == nil || !c.IsOnline || c.Socket == nil || c.InstanceID != inst.ID {
+			continue
+		}
+		members = append(members, c)
+	}
+	return members
+}
+
+// MobsOn returns the mobs of the instance on the map.
+func (inst *Instance) MobsOn(mapID int16) []*AI {
+	inst.mutex.RLock()
+	defer inst.mutex.RUnlock()
+
+	mobs := []*AI{}
+	for _, ai := range inst.Mobs {
+		if ai.Map == mapID {
+			mobs = append(mobs, ai)
+		}
+	}
+	return mobs
+}
+
+func (inst *Instance) alive() int {
+	inst.mutex.RLock()
+	defer inst.mutex.RUnlock()
+
+	count := 0
+	for _, ai := range inst.Mobs {
+		if ai.HP > 0 && !ai.IsDead {
+			count++
+		}
+	}
+	return count
+}
+
+// nextWave spawns the next wave or the bosses, it completes the instance when everything is dead.
+func (inst *Instance) nextWave() {
+	d := inst.Dungeon
+	switch {
+	case inst.Wave < len(d.Waves):
+		wave := d.Waves[inst.Wave]
+		inst.Wave++
+		inst.spawn(wave.Spawns)
+		if wave.Message != "" {
+			inst.broadcast(messaging.InfoMessage(wave.Message))
+		}
+
+	case inst.Wave == len(d.Waves) && len(d.Bosses) > 0:
+		inst.Wave++
+		inst.spawn(d.Bosses)
+
+	default:
+		inst.complete()
+	}
+}
+
+func (inst *Instance) spawn(spawns []*DungeonSpawn) {
+	if GenerateIDForAI == nil {
+		return
+	}
+
+	for _, s := range spawns {
+		npc, ok := NPCs[s.NPCID]
+		if !ok {
+			continue
+		}
+
+		minLocation := &utils.Location{X: s.X - s.Radius, Y: s.Y - s.Radius}
+		maxLocation := &utils.Location{X: s.X + s.Radius, Y: s.Y + s.Radius}
+
+		for i := 0; i < s.Count; i++ { // every mob owns its position, it is released when the mob is removed
+			npcPos := &NpcPosition{NPCID: npc.ID, MapID: inst.Dungeon.Map, Attackable: true, IsNPC: false, RespawnTime: 30, Count: 1,
+				MinLocation: fmt.Sprintf("%.1f,%.1f", minLocation.X, minLocation.Y),
+				MaxLocation: fmt.Sprintf("%.1f,%.1f", maxLocation.X, maxLocation.Y)}
+			AddNPCPos(npcPos)
+
+			loc := RandomWalkablePoint(npcPos.MapID, minLocation, maxLocation)
+			newai := &AI{HP: npc.MaxHp, Map: npcPos.MapID, PosID: npcPos.ID, RunningSpeed: 10, Server: inst.Server,
+				WalkingSpeed: 5, Once: true, InstanceID: inst.ID}
+			newai.OnSightPlayers = make(map[int]interface{})
+			newai.Coordinate = loc.String()
+			newai.TargetLocation = loc
+			newai.Handler = newai.AIHandler
+			addAI(newai)
+		}
+	}
+}
+
+// neighbours returns the mobs the mob shares its map with.
+func (ai *AI) neighbours() []*AI {
+	if ai.InstanceID != 0 {
+		if inst := FindInstance(ai.InstanceID); inst != nil {
+			return inst.MobsOn(ai.Map)
+		}
+		return nil
+	}
+
+	return MapAIs(ai.Server, ai.Map)
+}
+
+// instanceMobs returns the mobs of the instances running on the server.
+func instanceMobs(server int) []*AI {
+	dgMutex.RLock()
+	instances := sortedInstances()
+	dgMutex.RUnlock()
+
+	mobs := []*AI{}
+	for _, inst := range instances {
+		if inst.Server != server {
+			continue
+		}
+
+		inst.mutex.RLock()
+		for _, ai := range inst.Mobs {
+			if ai.Handler != nil && ai.WalkingSpeed > 0 {
+				mobs = append(mobs, ai)
+			}
+		}
+		inst.mutex.RUnlock()
+	}
+	return mobs
+}
+
+func (inst *Instance) broadcast(data []byte) {
+	for _, c := range inst.inside() {
+		c.Socket.Write(data)
+	}
+}
+
+func instanceTimer(seconds int) []byte {
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	resp := INSTANCE_TIMER
+	resp.Insert(utils.IntToBytes(uint64(seconds), 4, true), 7) // remaining seconds
+	return resp
+}
+
+// tick runs every second on the world of the instance until the instance is closed.
+func (inst *Instance) tick() {
+
+	if inst.State == INSTANCE_CLOSED {
+		return
+	}
+
+	now := time.Now()
+	inst.mutex.Lock()
+	for id, in := range inst.Members {
+		c, err := FindCharacterByID(id)
+		if in && err == nil && c != nil && c.IsOnline && c.Map != inst.Dungeon.Map { // left by teleport or portal
+			inst.Members[id] = false
+			if c.InstanceID == inst.ID {
+				c.InstanceID = 0
+			}
+		}
+	}
+	inst.mutex.Unlock()
+
+	members := inst.inside()
+	if len(members) == 0 {
+		if inst.emptySince.IsZero() {
+			inst.emptySince = now
+		}
+	} else {
+		inst.emptySince = time.Time{}
+	}
+
+	switch inst.State {
+	case INSTANCE_RUNNING:
+		remaining := int(inst.ExpiresAt.Sub(now).Seconds())
+		inst.broadcast(instanceTimer(remaining))
+
+		if remaining <= 0 {
+			inst.record(RUN_FAILED)
+			inst.fail("The time is up. Come again when you are stronger.")
+		} else if !inst.emptySince.IsZero() && now.Sub(inst.emptySince) >= INSTANCE_IDLE_SECONDS*time.Second {
+			inst.record(RUN_ABANDONED)
+			inst.fail("")
+		} else if inst.alive() == 0 {
+			inst.nextWave()
+		}
+
+	case INSTANCE_COMPLETED:
+		if now.After(inst.closeAt) || len(members) == 0 {
+			inst.close("")
+		}
+	}
+
+	if inst.State != INSTANCE_CLOSED {
+		AfterTicks(inst.Server, time.Second, inst.tick)
+	}
+}
+
+// complete gives the rewards to the members inside and closes the instance after INSTANCE_EXIT_SECONDS.
+func (inst *Instance) complete() {
+
+	inst.State = INSTANCE_COMPLETED
+	inst.closeAt = time.Now().Add(INSTANCE_EXIT_SECONDS * time.Second)
+	inst.record(RUN_COMPLETED)
+
+	members := inst.inside()
+	if r := inst.Dungeon.Rewards; r != nil {
+		for _, c := range members {
+			resp := utils.Packet{}
+			if r.Exp > 0 {
+				data, levelUp := c.AddExp(r.Exp)
+				if levelUp {
+					if statData, err := c.GetStats(); err == nil {
+						resp.Concat(statData)
+					}
+				}
+				resp.Concat(data)
+			}
+			if r.Gold > 0 {
+				resp.Concat(c.LootGold(r.Gold))
+			}
+			resp.Concat(giveRewardItems(c, r.Items))
+			if r.Message != "" {
+				resp.Concat(messaging.InfoMessage(r.Message))
+			}
+			c.Socket.Write(resp)
+		}
+	}
+
+	inst.broadcast(messaging.InfoMessage(fmt.Sprintf("%s cleared! You will leave the dungeon in %d seconds.",
+		inst.Dungeon.Name, INSTANCE_EXIT_SECONDS)))
+}
+
+func (inst *Instance) fail(msg string) {
+	inst.State = INSTANCE_FAILED
+	inst.close(msg)
+}
+
+// close sends the members inside out of the dungeon and removes the mobs of the instance together with their
+// positions.
+func (inst *Instance) close(msg string) {
+
+	for _, c := range inst.inside() {
+		resp := utils.Packet{}
+		if msg != "" {
+			resp.Concat(messaging.InfoMessage(msg))
+		}
+		data, _ := inst.sendOut(c)
+		resp.Concat(data)
+		c.Socket.Write(resp)
+	}
+
+	inst.mutex.Lock()
+	mobs := inst.Mobs
+	inst.Mobs = nil
+	inst.State = INSTANCE_CLOSED
+	inst.mutex.Unlock()
+
+	for _, ai := range mobs {
+		removeAI(ai)
+	}
+
+	dgMutex.Lock()
+	delete(Instances, inst.ID)
+	dgMutex.Unlock()
+}
+
+// InstanceLines describes the running instances.
+func InstanceLines() []string {
+	dgMutex.RLock()
+	instances := sortedInstances()
+	dgMutex.RUnlock()
+
+	lines := []string{}
+	for _, inst := range instances {
+		remaining := int(time.Until(inst.ExpiresAt).Seconds())
+		lines = append(lines, fmt.Sprintf("Instance %d: %s, server %d, wave %d, %d mobs alive, %d players, %ds left",
+			inst.ID, inst.Dungeon.Name, inst.Server, inst.Wave, inst.alive(), len(inst.inside()), remaining))
+	}
+	return lines
+}
+
+func sortedInstances() []*Instance {
+	instances := make([]*Instance, 0, len(Instances))
+	for _, inst := range Instances {
+		instances = append(instances, inst)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].ID < instances[j].ID
+	})
+	return instances
+}
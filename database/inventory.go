@@ -26,6 +26,8 @@ type InventorySlot struct {
 	ID            int             `db:"id"`
 	UserID        null.String     `db:"user_id"`
 	CharacterID   null.Int        `db:"character_id"`
+	GuildID       null.Int        `db:"guild_id"`
+	MailID        null.Int        `db:"mail_id"`
 	ItemID        int64           `db:"item_id"`
 	SlotID        int16           `db:"slot_id"`
 	Quantity      uint            `db:"quantity"`
@@ -74,6 +76,7 @@ type PetSlot struct {
 	PetCombatMode  int16          `db:"-" json:"-"`
 	Target         int            `db:"-" json:"-"`
 	TargetLocation utils.Location `db:"-" json:"-"`
+	waypoints      []utils.Location
 }
 
 var (
@@ -558,7 +561,7 @@ func (pet *PetSlot) FindTargetMobID(owner *Character) (int, error) {
 	}
 
 	user := owner.Socket.User
-	allMobs := AIsByMap[user.ConnectedServer][owner.Map]
+	allMobs := MapAIs(user.ConnectedServer, owner.Map)
 	filtered := funk.Filter(allMobs, func(ai *AI) bool {
 
 		pos := NPCPos[ai.PosID]
@@ -582,12 +585,39 @@ func (pet *PetSlot) FindTargetMobID(owner *Character) (int, error) {
 	return 0, nil
 }
 
+// moveTo walks the pet along the path to target on the map of its owner.
+func (pet *PetSlot) moveTo(mapID int16, target utils.Location, speed float64) {
+	start := pet.Coordinate
+	path := FindPath(mapID, &start, &target)
+	if len(path) == 0 {
+		return
+	}
+
+	pet.IsMoving = true
+	pet.TargetLocation = path[len(path)-1]
+
+	token := pet.MovementToken
+	for token == pet.MovementToken {
+		pet.MovementToken = utils.RandInt(1, math.MaxInt64)
+	}
+
+	pet.waypoints = path[1:]
+	go pet.MovementHandler(pet.MovementToken, &pet.Coordinate, &path[0], speed)
+}
+
 func (pet *PetSlot) MovementHandler(token int64, start, end *utils.Location, speed float64) {
 
 	diff := utils.CalculateDistance(start, end)
 
 	if diff < 1 {
 		pet.Coordinate = *end
+		if token == pet.MovementToken && len(pet.waypoints) > 0 { // walk to the next waypoint of the path
+			next := pet.waypoints[0]
+			pet.waypoints = pet.waypoints[1:]
+			pet.MovementHandler(token, start, &next, speed)
+			return
+		}
+
 		pet.MovementToken = 0
 		pet.IsMoving = false
 		return
@@ -744,7 +774,7 @@ func (pet *PetSlot) CastSkill(owner *Character) []byte {
 	}
 
 	castRange := skillInfo.BaseRadius
-	candidates := AIsByMap[mob.Server][mob.Map]
+	candidates := MapAIs(mob.Server, mob.Map)
 	candidates = funk.Filter(candidates, func(cand *AI) bool {
 		nPos := NPCPos[cand.PosID]
 		if nPos == nil {
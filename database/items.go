@@ -223,26 +223,6 @@ func getAllItems() error {
 	return nil
 }
 
-func RefreshAllItems() error {
-
-	query := `select * from data.items`
-
-	items := []*Item{}
-
-	if _, err := db.Select(&items, query); err != nil {
-		if err == sql.ErrNoRows {
-			return nil
-		}
-		return fmt.Errorf("getAllItems: %s", err.Error())
-	}
-
-	for _, item := range items {
-		Items[item.ID] = item
-	}
-
-	return nil
-}
-
 // Determines if a weapon item can use an action with specified type
 func (item *Item) CanUse(t byte) bool {
 	if item.Type == int16(t) || t == 0 {
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+
+	"hero-emulator/config"
+	"hero-emulator/utils"
+)
+
+// Contributor is a character taking part in a kill, either a damage dealer
+// or a party member of a damage dealer who stayed near the mob.
+type Contributor struct {
+	CharacterID int
+	PartyID     string
+	Level       int
+	Damage      int
+	Present     bool // still on the map of the mob and alive
+}
+
+type KillResult struct {
+	Exp       map[int]int64
+	LootOwner int // 0 when no present contributor dealt damage
+	LootParty string
+}
+
+type killGroup struct {
+	key     string
+	damage  int
+	members []*Contributor
+}
+
+func (c *Contributor) groupKey() string {
+	if c.PartyID != "" {
+		return c.PartyID
+	}
+	return fmt.Sprintf("#%d", c.CharacterID)
+}
+
+// ResolveKill splits the kill exp by damage share. Parties pool the share of their members and divide it
+// between the members near the mob with the party exp rules, contributors who are not present any more get nothing and their damage
+// is not counted. The loot goes to the top dealer of the group with the most damage.
+func ResolveKill(contributors []*Contributor, expFor func(level int) int64) *KillResult {
+
+	result := &KillResult{Exp: make(map[int]int64)}
+
+	total := 0
+	groups := make(map[string]*killGroup)
+	for _, c := range contributors {
+		if !c.Present {
+			continue
+		}
+
+		key := c.groupKey()
+		g, ok := groups[key]
+		if !ok {
+			g = &killGroup{key: key}
+			groups[key] = g
+		}
+
+		g.damage += c.Damage
+		g.members = append(g.members, c)
+		total += c.Damage
+	}
+
+	if total == 0 {
+		return result
+	}
+
+	var owner *Contributor
+	var ownerGroup *killGroup
+	for _, g := range groups {
+		if g.damage == 0 {
+			continue
+		}
+
+		shares := partyExpShares(g.members)
+		for i, m := range g.members {
+			result.Exp[m.CharacterID] += int64(float64(expFor(m.Level)) * float64(g.damage) * shares[i] / float64(total))
+		}
+
+		sort.Slice(g.members, func(i, j int) bool {
+			if g.members[i].Damage == g.members[j].Damage {
+				return g.members[i].CharacterID < g.members[j].CharacterID
+			}
+			return g.members[i].Damage > g.members[j].Damage
+		})
+
+		top := g.members[0]
+		if ownerGroup == nil || g.damage > ownerGroup.damage || (g.damage == ownerGroup.damage &&
+			(top.Damage > owner.Damage || (top.Damage == owner.Damage && top.CharacterID < owner.CharacterID))) {
+			owner, ownerGroup = top, g
+		}
+	}
+
+	result.LootOwner, result.LootParty = owner.CharacterID, owner.PartyID
+
+	return result
+}
+
+// partyOf returns the party id of the character if the character is the leader or an accepted member.
+func partyOf(c *Character) string {
+	party := FindParty(c)
+	if party == nil {
+		return ""
+	}
+
+	if party.Leader.ID == c.ID {
+		return c.PartyID
+	}
+
+	if m := party.GetMember(c.ID); m != nil && m.Accepted {
+		return c.PartyID
+	}
+
+	return ""
+}
+
+func (ai *AI) isPresent(c *Character, server int) bool {
+	if c == nil || !c.IsOnline || c.Map != ai.Map || c.Socket == nil || c.Socket.User == nil || c.Socket.Stats == nil {
+		return false
+	}
+	return c.Socket.User.ConnectedServer == server && c.Socket.Stats.HP > 0
+}
+
+// Contributors collects the damage dealers of the mob and the party members sharing their exp.
+func (ai *AI) Contributors(killer *Character) []*Contributor {
+
+	rules := config.Default.Party
+	server := killer.Socket.User.ConnectedServer
+	location := ConvertPointToLocation(ai.Coordinate)
+
+	contributors := []*Contributor{}
+	seen := make(map[int]struct{})
+	parties := make(map[string]*Party)
+
+	for _, v := range ai.DamageDealers.Values() {
+		d := v.(*Damage)
+		seen[d.DealerID] = struct{}{}
+
+		c, err := FindCharacterByID(d.DealerID)
+		if err != nil || c == nil {
+			contributors = append(contributors, &Contributor{CharacterID: d.DealerID, Damage: d.Damage})
+			continue
+		}
+
+		partyID := partyOf(c)
+		if partyID != "" {
+			parties[partyID] = FindParty(c)
+		}
+
+		contributors = append(contributors, &Contributor{CharacterID: c.ID, PartyID: partyID, Level: c.Level,
+			Damage: d.Damage, Present: ai.isPresent(c, server)})
+	}
+
+	for partyID, party := range parties {
+		members := []*Character{party.Leader}
+		for _, m := range party.GetMembers() {
+			if m.Accepted {
+				members = append(members, m.Character)
+			}
+		}
+
+		for _, m := range members {
+			if _, ok := seen[m.ID]; ok || !ai.isPresent(m, server) || killer.Level-m.Level > rules.ExpLevelGap {
+				continue
+			}
+
+			if utils.CalculateDistance(location, ConvertPointToLocation(m.Coordinate)) > rules.ExpShareRange {
+				continue
+			}
+
+			seen[m.ID] = struct{}{}
+			contributors = append(contributors, &Contributor{CharacterID: m.ID, PartyID: partyID, Level: m.Level, Present: true})
+		}
+	}
+
+	return contributors
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"testing"
+
+	"hero-emulator/config"
+)
+
+func flatExp(level int) int64 {
+	return 1000
+}
+
+func TestResolveKillSplitsExpByDamage(t *testing.T) {
+	result := ResolveKill([]*Contributor{
+		{CharacterID: 1, Damage: 750, Present: true},
+		{CharacterID: 2, Damage: 250, Present: true},
+	}, flatExp)
+
+	if result.Exp[1] != 750 || result.Exp[2] != 250 {
+		t.Fatalf("unexpected exp split: %v", result.Exp)
+	}
+	if result.LootOwner != 1 || result.LootParty != "" {
+		t.Fatalf("unexpected loot owner %d (%q)", result.LootOwner, result.LootParty)
+	}
+}
+
+func TestResolveKillLastHitDoesNotMatter(t *testing.T) {
+	result := ResolveKill([]*Contributor{
+		{CharacterID: 1, Damage: 9900, Present: true},
+		{CharacterID: 2, Damage: 100, Present: true}, // landed the last hit
+	}, flatExp)
+
+	if result.LootOwner != 1 {
+		t.Fatalf("loot owner should be the top contributor, got %d", result.LootOwner)
+	}
+	if result.Exp[2] != 10 {
+		t.Fatalf("last hitter should get its damage share, got %d", result.Exp[2])
+	}
+}
+
+func TestResolveKillPoolsPartyShare(t *testing.T) {
+	result := ResolveKill([]*Contributor{
+		{CharacterID: 1, PartyID: "p", Damage: 300, Present: true},
+		{CharacterID: 2, PartyID: "p", Damage: 0, Present: true}, // healer near the mob
+		{CharacterID: 3, PartyID: "p", Damage: 300, Present: true},
+		{CharacterID: 4, Damage: 400, Present: true},
+	}, flatExp)
+
+	for _, id := range []int{1, 2, 3} {
+		if result.Exp[id] != 200 {
+			t.Fatalf("party member %d should get 200 exp, got %d", id, result.Exp[id])
+		}
+	}
+	if result.Exp[4] != 400 {
+		t.Fatalf("solo dealer should get 400 exp, got %d", result.Exp[4])
+	}
+
+	// the party out-damaged the solo dealer, its top dealer with the lowest id owns the loot
+	if result.LootOwner != 1 || result.LootParty != "p" {
+		t.Fatalf("unexpected loot owner %d (%q)", result.LootOwner, result.LootParty)
+	}
+}
+
+func TestResolveKillIgnoresAbsentContributors(t *testing.T) {
+	result := ResolveKill([]*Contributor{
+		{CharacterID: 1, Damage: 800}, // left the map
+		{CharacterID: 2, Damage: 200, Present: true},
+	}, flatExp)
+
+	if _, ok := result.Exp[1]; ok {
+		t.Fatalf("absent contributor should not get exp: %v", result.Exp)
+	}
+	if result.Exp[2] != 1000 || result.LootOwner != 2 {
+		t.Fatalf("remaining contributor should get the whole kill, got %v owner %d", result.Exp, result.LootOwner)
+	}
+}
+
+func TestResolveKillUsesLevelExp(t *testing.T) {
+	npc := &NPC{Exp: 100, DivineExp: 1000, DarknessExp: 10000}
+	result := ResolveKill([]*Contributor{
+		{CharacterID: 1, Level: 50, Damage: 1, Present: true},
+		{CharacterID: 2, Level: 150, Damage: 1, Present: true},
+		{CharacterID: 3, Level: 250, Damage: 2, Present: true},
+	}, npc.ExpFor)
+
+	if result.Exp[1] != 25 || result.Exp[2] != 250 || result.Exp[3] != 5000 {
+		t.Fatalf("unexpected exp by level: %v", result.Exp)
+	}
+}
+
+func TestResolveKillWithoutDamage(t *testing.T) {
+	result := ResolveKill([]*Contributor{{CharacterID: 1, Present: true}}, flatExp)
+	if len(result.Exp) != 0 || result.LootOwner != 0 {
+		t.Fatalf("kill without damage should give nothing, got %v owner %d", result.Exp, result.LootOwner)
+	}
+}
+
+func TestResolveKillPartyExpRules(t *testing.T) {
+	rules := config.Default.Party
+	defer func() { config.Default.Party = rules }()
+
+	config.Default.Party.ExpLevelWeight = 1
+	config.Default.Party.ExpSizeBonus = []float64{0, 0, 0.5}
+
+	result := ResolveKill([]*Contributor{
+		{CharacterID: 1, PartyID: "p", Level: 30, Damage: 500, Present: true},
+		{CharacterID: 2, PartyID: "p", Level: 90, Damage: 500, Present: true},
+	}, flatExp)
+
+	// level 30 and 90 weigh 0.5 and 1.5 of the even share, the pair gets a 50% bonus
+	if result.Exp[1] != 375 || result.Exp[2] != 1125 {
+		t.Fatalf("unexpected weighted exp: %v", result.Exp)
+	}
+}
+
+func rollValues(values ...int64) func() int64 {
+	return func() int64 {
+		v := values[0]
+		values = values[1:]
+		return v
+	}
+}
+
+func TestRollWinnerGreedOnly(t *testing.T) {
+	members := []*Character{{ID: 1}, {ID: 2}, {ID: 3}}
+	choices := map[int]byte{1: ROLL_GREED, 2: ROLL_PASS, 3: ROLL_GREED}
+
+	winner, value := rollWinner(members, choices, rollValues(40, 70))
+	if winner == nil || winner.ID != 3 || value != 70 {
+		t.Fatalf("the higher greed should win, got %v with %d", winner, value)
+	}
+}
+
+func TestRollWinnerNeedBeatsGreed(t *testing.T) {
+	members := []*Character{{ID: 1}, {ID: 2}, {ID: 3}}
+	choices := map[int]byte{1: ROLL_GREED, 2: ROLL_NEED, 3: ROLL_NEED}
+
+	winner, value := rollWinner(members, choices, rollValues(100, 10, 30))
+	if winner == nil || winner.ID != 3 || value != 30 {
+		t.Fatalf("the higher need should win over a greed, got %v with %d", winner, value)
+	}
+}
+
+func TestRollWinnerEveryonePassed(t *testing.T) {
+	members := []*Character{{ID: 1}, {ID: 2}}
+	if winner, _ := rollWinner(members, map[int]byte{1: ROLL_PASS}, rollValues()); winner != nil {
+		t.Fatalf("nobody should win when everyone passed, got %d", winner.ID)
+	}
+}
@@ -0,0 +1,506 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"hero-emulator/messaging"
+	"hero-emulator/utils"
+
+	null "gopkg.in/guregu/null.v3"
+)
+
+const (
+	MAIL_POSTAGE      = 500
+	MAIL_ITEM_POSTAGE = 5000
+	MAIL_BOX_SIZE     = 100
+	MAIL_LIST_LIMIT   = 20
+	MAIL_TEXT_LIMIT   = 200
+
+	MAIL_EXPIRY = time.Hour * 24 * 30
+)
+
+// Mail is a letter in the mailbox of a character, it may carry gold and one item until they are claimed.
+type Mail struct {
+	ID         int       `db:"id" json:"id"`
+	SenderID   null.Int  `db:"sender_id" json:"sender_id"`
+	SenderName string    `db:"sender_name" json:"sender_name"`
+	ReceiverID int       `db:"receiver_id" json:"receiver_id"`
+	Text       string    `db:"text" json:"text"`
+	Gold       uint64    `db:"gold" json:"gold"`
+	HasItem    bool      `db:"has_item" json:"has_item"`
+	IsRead     bool      `db:"is_read" json:"is_read"`
+	IsReturned bool      `db:"is_returned" json:"is_returned"`
+	ClaimedAt  null.Time `db:"claimed_at" json:"claimed_at"`
+	SentAt     null.Time `db:"sent_at" json:"sent_at"`
+	ExpiresAt  null.Time `db:"expires_at" json:"expires_at"`
+}
+
+func (m *Mail) Create() error {
+	return db.Insert(m)
+}
+
+func (m *Mail) Update() error {
+	_, err := db.Update(m)
+	return err
+}
+
+func (m *Mail) Delete() error {
+	_, err := db.Delete(m)
+	return err
+}
+
+// hasAttachments reports whether the gold or the item of the mail are still to be claimed.
+func (m *Mail) hasAttachments() bool {
+	return !m.ClaimedAt.Valid && (m.Gold > 0 || m.HasItem)
+}
+
+func FindMailsByReceiverID(receiverID int) ([]*Mail, error) {
+
+	var arr []*Mail
+	query := `select * from hops.mails where receiver_id = $1 order by sent_at desc`
+
+	if _, err := db.Select(&arr, query, receiverID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("FindMailsByReceiverID: %s", err.Error())
+	}
+
+	return arr, nil
+}
+
+// FindMail returns the mail if it is in the mailbox of the receiver.
+func FindMail(id, receiverID int) (*Mail, error) {
+
+	m := &Mail{}
+	query := `select * from hops.mails where id = $1 and receiver_id = $2`
+
+	if err := db.SelectOne(m, query, id, receiverID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("FindMail: %s", err.Error())
+	}
+
+	return m, nil
+}
+
+// FindMailItem returns the item attached to the mail.
+func FindMailItem(mailID int) (*InventorySlot, error) {
+
+	slot := NewSlot()
+	query := `select * from hops.items_characters where mail_id = $1`
+
+	if err := db.SelectOne(slot, query, mailID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("FindMailItem: %s", err.Error())
+	}
+
+	if slot.PetInfo != nil {
+		json.Unmarshal(slot.PetInfo, &slot.Pet)
+	}
+	return slot, nil
+}
+
+func countMails(receiverID int) (int64, error) {
+	query := `select count(*) from hops.mails where receiver_id = $1`
+	count, err := db.SelectInt(query, receiverID)
+	if err != nil {
+		return 0, fmt.Errorf("countMails: %s", err.Error())
+	}
+	return count, nil
+}
+
+func notifyMail(receiverID int, from string) {
+	characterMutex.RLock()
+	receiver, ok := characters[receiverID]
+	characterMutex.RUnlock()
+
+	if ok && receiver.IsOnline && receiver.Socket != nil {
+		receiver.Socket.Write(messaging.InfoMessage(fmt.Sprintf("You have new mail from %s.", from)))
+	}
+}
+
+// SendSystemMail puts a letter with the gold and the item into the mailbox of the character, it reaches offline
+// characters too and is used for event rewards and compensation.
+func SendSystemMail(receiverID int, from, text string, gold uint64, item *InventorySlot) error {
+
+	now := time.Now()
+	m := &Mail{SenderName: from, ReceiverID: receiverID, Text: text, Gold: gold, HasItem: item != nil,
+		SentAt: null.TimeFrom(now), ExpiresAt: null.TimeFrom(now.Add(MAIL_EXPIRY))}
+	if err := m.Create(); err != nil {
+		return fmt.Errorf("SendSystemMail: %s", err.Error())
+	}
+
+	if item != nil {
+		item.ID = 0
+		item.SlotID = 0
+		item.UserID = null.StringFromPtr(nil)
+		item.CharacterID = null.IntFromPtr(nil)
+		item.GuildID = null.IntFromPtr(nil)
+		item.MailID = null.IntFrom(int64(m.ID))
+		if err := item.Insert(); err != nil {
+			m.Delete()
+			return fmt.Errorf("SendSystemMail: %s", err.Error())
+		}
+	}
+
+	notifyMail(receiverID, from)
+	return nil
+}
+
+// SendMail sends a letter from the character to the named one, the gold and the item of the inventory slot are taken
+// from the sender together with the postage.
+func (c *Character) SendMail(name, text string, gold uint64, slotID int16) ([]byte, error) {
+
+	if len(text) > MAIL_TEXT_LIMIT {
+		return messaging.InfoMessage(fmt.Sprintf("A letter can hold %d characters.", MAIL_TEXT_LIMIT)), nil
+	} else if c.TradeID != "" || FindSale(c.PseudoID) != nil {
+		return messaging.InfoMessage("You cannot send mail while trading."), nil
+	}
+
+	receiver, err := FindCharacterByName(name)
+	if err != nil {
+		return nil, err
+	} else if receiver == nil {
+		return messaging.InfoMessage(fmt.Sprintf("%s does not exist.", name)), nil
+	} else if receiver.ID == c.ID {
+		return messaging.InfoMessage("You cannot send mail to yourself."), nil
+	} else if receiver.IsBlocking(c) {
+		return messaging.InfoMessage(fmt.Sprintf("%s does not accept your mail.", receiver.Name)), nil
+	}
+
+	count, err := countMails(receiver.ID)
+	if err != nil {
+		return nil, err
+	} else if count >= MAIL_BOX_SIZE {
+		return messaging.InfoMessage(fmt.Sprintf("The mailbox of %s is full.", receiver.Name)), nil
+	}
+
+	postage := uint64(MAIL_POSTAGE)
+	if slotID >= 0 {
+		postage += MAIL_ITEM_POSTAGE
+	}
+	if gold > math.MaxInt64-postage { // the sum would overflow and the gold column is a bigint
+		return messaging.InfoMessage("You cannot mail that much gold."), nil
+	}
+
+	c.InvMutex.Lock()
+	defer c.InvMutex.Unlock()
+
+	var item *InventorySlot
+	if slotID >= 0 {
+		if !isBagSlot(slotID) {
+			return nil, nil
+		}
+
+		slots, err := c.InventorySlots()
+		if err != nil {
+			return nil, err
+		}
+
+		item = slots[slotID]
+		if item.ItemID == 0 || item.Activated || item.InUse || item.Pet != nil {
+			return messaging.InfoMessage("This item cannot be mailed."), nil
+		} else if info, ok := Items[item.ItemID]; !ok || !info.Tradable {
+			return messaging.InfoMessage("This item cannot be mailed."), nil
+		}
+	}
+
+	// the gold is taken first and given back if the letter cannot be stored, so it is never sent without being paid
+	if !c.SpendGold(gold + postage) {
+		return messaging.InfoMessage(fmt.Sprintf("You need %d gold for the postage.", postage)), nil
+	}
+
+	now := time.Now()
+	m := &Mail{SenderID: null.IntFrom(int64(c.ID)), SenderName: c.Name, ReceiverID: receiver.ID, Text: FilterChat(text), Gold: gold,
+		HasItem: item != nil, SentAt: null.TimeFrom(now), ExpiresAt: null.TimeFrom(now.Add(MAIL_EXPIRY))}
+	if err := m.Create(); err != nil {
+		c.LootGold(gold + postage)
+		return nil, fmt.Errorf("SendMail: %s", err.Error())
+	}
+
+	resp := utils.Packet{}
+	if item != nil {
+		newItem := NewSlot()
+		*newItem = *item
+		newItem.SlotID = 0
+		newItem.UserID = null.StringFromPtr(nil)
+		newItem.CharacterID = null.IntFromPtr(nil)
+		newItem.MailID = null.IntFrom(int64(m.ID))
+		if err := newItem.Update(); err != nil {
+			m.Delete()
+			c.LootGold(gold + postage)
+			return nil, fmt.Errorf("SendMail: %s", err.Error())
+		}
+
+		InventoryItems.Add(newItem.ID, newItem)
+		*item = *NewSlot()
+		resp.Concat(item.GetData(slotID))
+	}
+
+	resp.Concat(c.GetGold())
+	resp.Concat(messaging.InfoMessage(fmt.Sprintf("Your letter was sent to %s.", receiver.Name)))
+
+	notifyMail(receiver.ID, c.Name)
+	return resp, nil
+}
+
+// ClaimMail moves the gold and the item of the mail to the character, a mail is claimed only once.
+func (c *Character) ClaimMail(id int) ([]byte, error) {
+
+	m, err := FindMail(id, c.ID)
+	if err != nil {
+		return nil, err
+	} else if m == nil {
+		return messaging.InfoMessage("There is no such mail."), nil
+	} else if !m.hasAttachments() {
+		return messaging.InfoMessage("The mail has nothing to claim."), nil
+	} else if c.TradeID != "" || FindSale(c.PseudoID) != nil {
+		return messaging.InfoMessage("You cannot claim mail while trading."), nil
+	}
+
+	c.InvMutex.Lock()
+	defer c.InvMutex.Unlock()
+
+	var item *InventorySlot
+	if m.HasItem {
+		item, err = FindMailItem(m.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if item != nil {
+			if slotID, err := c.FindFreeSlot(); err != nil { // checked before the claim so a full inventory keeps the mail
+				return nil, err
+			} else if slotID == -1 {
+				return messaging.InfoMessage("Not enough inventory space."), nil
+			}
+		}
+	}
+
+	now := time.Now()
+	query := `update hops.mails set claimed_at = $1, is_read = true where id = $2 and claimed_at is null`
+	res, err := db.Exec(query, now, m.ID)
+	if err != nil {
+		return nil, fmt.Errorf("ClaimMail: %s", err.Error())
+	} else if n, err := res.RowsAffected(); err != nil || n == 0 { // claimed by another session
+		return nil, nil
+	}
+
+	resp := utils.Packet{}
+	if item != nil {
+		newItem := NewSlot()
+		*newItem = *item
+		newItem.ID = 0
+		newItem.MailID = null.IntFromPtr(nil)
+
+		r, _, err := c.AddItem(newItem, -1, false)
+		if err != nil || r == nil {
+			db.Exec(`update hops.mails set claimed_at = null where id = $1`, m.ID)
+			if err != nil {
+				return nil, err
+			}
+			return messaging.InfoMessage("Not enough inventory space."), nil
+		}
+
+		item.Delete()
+		resp.Concat(*r)
+	}
+
+	if m.Gold > 0 {
+		c.LootGold(m.Gold)
+		resp.Concat(c.GetGold())
+	}
+
+	resp.Concat(messaging.InfoMessage(fmt.Sprintf("You claimed the mail from %s.", m.SenderName)))
+	return resp, nil
+}
+
+// ReadMail shows the mail and marks it read.
+func (c *Character) ReadMail(id int) ([]byte, error) {
+
+	m, err := FindMail(id, c.ID)
+	if err != nil {
+		return nil, err
+	} else if m == nil {
+		return messaging.InfoMessage("There is no such mail."), nil
+	}
+
+	if !m.IsRead {
+		m.IsRead = true
+		go m.Update()
+	}
+
+	resp := utils.Packet{}
+	resp.Concat(messaging.InfoMessage(fmt.Sprintf("From %s, %s: %s", m.SenderName, m.SentAt.Time.Format("2006-01-02 15:04"), m.Text)))
+	if m.hasAttachments() {
+		attached := []string{}
+		if m.HasItem {
+			if item, err := FindMailItem(m.ID); err == nil && item != nil {
+				attached = append(attached, itemText(item.ItemID, item.Quantity, item.Plus))
+			}
+		}
+		if m.Gold > 0 {
+			attached = append(attached, fmt.Sprintf("%d gold", m.Gold))
+		}
+		for _, a := range attached {
+			resp.Concat(messaging.InfoMessage(fmt.Sprintf("Attached: %s", a)))
+		}
+	}
+	return resp, nil
+}
+
+// DeleteMail throws the mail away, mails with unclaimed attachments are kept.
+func (c *Character) DeleteMail(id int) ([]byte, error) {
+
+	m, err := FindMail(id, c.ID)
+	if err != nil {
+		return nil, err
+	} else if m == nil {
+		return messaging.InfoMessage("There is no such mail."), nil
+	} else if m.hasAttachments() {
+		return messaging.InfoMessage("Claim the attachments of the mail first."), nil
+	}
+
+	if err := m.Delete(); err != nil {
+		return nil, fmt.Errorf("DeleteMail: %s", err.Error())
+	}
+	return messaging.InfoMessage("The mail was deleted."), nil
+}
+
+// MailLines lists the latest mails of the character.
+func (c *Character) MailLines() ([]string, error) {
+
+	mails, err := FindMailsByReceiverID(c.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{}
+	for i, m := range mails {
+		if i == MAIL_LIST_LIMIT {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(mails)-i))
+			break
+		}
+
+		flags := ""
+		if !m.IsRead {
+			flags += " (new)"
+		}
+		if m.IsReturned {
+			flags += " (returned)"
+		}
+		if m.hasAttachments() {
+			flags += " (attachments)"
+		}
+		lines = append(lines, fmt.Sprintf("#%d from %s%s", m.ID, m.SenderName, flags))
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, "Your mailbox is empty.")
+	}
+	return lines, nil
+}
+
+// UnreadMailMessage tells the character how many unread mails it has, nil when there is none.
+func (c *Character) UnreadMailMessage() []byte {
+
+	query := `select count(*) from hops.mails where receiver_id = $1 and is_read = false`
+	count, err := db.SelectInt(query, c.ID)
+	if err != nil {
+		log.Println(fmt.Errorf("UnreadMailMessage: %s", err.Error()))
+		return nil
+	} else if count == 0 {
+		return nil
+	}
+	return messaging.InfoMessage(fmt.Sprintf("You have %d unread mails.", count))
+}
+
+// ExpireMails returns the expired mails with unclaimed attachments to their sender and deletes the rest, it repeats
+// every minute.
+func ExpireMails() {
+
+	var mails []*Mail
+	query := `select * from hops.mails where expires_at < $1`
+
+	if _, err := db.Select(&mails, query, time.Now()); err != nil && err != sql.ErrNoRows {
+		log.Println(fmt.Errorf("ExpireMails: %s", err.Error()))
+	}
+
+	for _, m := range mails {
+		if m.returnable() {
+			m.returnToSender()
+			continue
+		}
+
+		if m.HasItem && !m.ClaimedAt.Valid { // the attached item goes with the mail, it leaves the item cache too
+			if item, err := FindMailItem(m.ID); err != nil {
+				log.Println(err)
+			} else if item != nil {
+				item.Delete()
+			}
+		}
+		if err := m.Delete(); err != nil {
+			log.Println(err)
+		}
+	}
+
+	time.AfterFunc(time.Minute, func() {
+		ExpireMails()
+	})
+}
+
+// returnable reports whether the expired mail goes back to its sender instead of being deleted, a mail is returned
+// only once.
+func (m *Mail) returnable() bool {
+	return m.hasAttachments() && m.SenderID.Valid && !m.IsReturned
+}
+
+// returnTo addresses the mail back to its sender, the name of the receiver is kept in the text.
+func (m *Mail) returnTo(receiverName string, now time.Time) {
+	if receiverName != "" {
+		m.Text = fmt.Sprintf("Returned by %s: %s", receiverName, m.Text)
+	}
+
+	m.ReceiverID = int(m.SenderID.Int64)
+	m.IsRead = false
+	m.IsReturned = true
+	m.SentAt = null.TimeFrom(now)
+	m.ExpiresAt = null.TimeFrom(now.Add(MAIL_EXPIRY))
+}
+
+func (m *Mail) returnToSender() {
+
+	receiver, err := FindCharacterByID(m.ReceiverID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	name := ""
+	if receiver != nil {
+		name = receiver.Name
+	}
+
+	m.returnTo(name, time.Now())
+	if err := m.Update(); err != nil {
+		log.Println(err)
+		return
+	}
+
+	characterMutex.RLock()
+	sender, ok := characters[m.ReceiverID]
+	characterMutex.RUnlock()
+
+	if ok && sender.IsOnline && sender.Socket != nil {
+		sender.Socket.Write(messaging.InfoMessage("One of your letters came back unclaimed."))
+	}
+}
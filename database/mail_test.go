@@ -0,0 +1,92 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestMailIsClaimedOnce(t *testing.T) {
+	tests := []struct {
+		name    string
+		mail    Mail
+		attach  bool
+		returns bool
+	}{
+		{"gold", Mail{SenderID: null.IntFrom(1), Gold: 100}, true, true},
+		{"item", Mail{SenderID: null.IntFrom(1), HasItem: true}, true, true},
+		{"claimed", Mail{SenderID: null.IntFrom(1), Gold: 100, HasItem: true, ClaimedAt: null.TimeFrom(time.Now())}, false, false},
+		{"letter only", Mail{SenderID: null.IntFrom(1)}, false, false},
+		{"system mail", Mail{Gold: 100}, true, false},
+		{"returned already", Mail{SenderID: null.IntFrom(1), Gold: 100, IsReturned: true}, true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.mail.hasAttachments(); got != test.attach {
+				t.Errorf("hasAttachments() = %v, want %v", got, test.attach)
+			}
+			if got := test.mail.returnable(); got != test.returns {
+				t.Errorf("returnable() = %v, want %v", got, test.returns)
+			}
+		})
+	}
+}
+
+func TestMailReturnsToSender(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	m := &Mail{SenderID: null.IntFrom(7), SenderName: "Sender", ReceiverID: 9, Text: "hello", Gold: 100, IsRead: true}
+
+	m.returnTo("Receiver", now)
+
+	if m.ReceiverID != 7 {
+		t.Fatalf("mail went to %d, want the sender 7", m.ReceiverID)
+	}
+	if m.IsRead || !m.IsReturned {
+		t.Fatalf("returned mail should be unread and marked returned: read %v, returned %v", m.IsRead, m.IsReturned)
+	}
+	if m.Text != "Returned by Receiver: hello" {
+		t.Fatalf("unexpected text %q", m.Text)
+	}
+	if !m.ExpiresAt.Time.Equal(now.Add(MAIL_EXPIRY)) {
+		t.Fatalf("returned mail expires at %v, want %v", m.ExpiresAt.Time, now.Add(MAIL_EXPIRY))
+	}
+	if !m.hasAttachments() || m.returnable() { // the sender can claim it, but it is deleted when it expires again
+		t.Fatalf("returned mail should keep its attachments and not be returned twice")
+	}
+}
+
+func TestFirstFreeSlotOfFullInventory(t *testing.T) {
+	slots := func(free ...int16) []*InventorySlot {
+		arr := make([]*InventorySlot, 400)
+		for i := range arr {
+			arr[i] = &InventorySlot{ItemID: 1}
+		}
+		for _, slotID := range free {
+			arr[slotID].ItemID = 0
+		}
+		return arr
+	}
+
+	tests := []struct {
+		name     string
+		slots    []*InventorySlot
+		from, to int16
+		want     int16
+	}{
+		{"full", slots(), 11, 66, -1},
+		{"first free", slots(20, 30), 11, 66, 20},
+		{"free outside the range", slots(5, 70), 11, 66, -1},
+		{"expansion", slots(341), 341, 396, 341},
+		{"short inventory", slots()[:50], 11, 66, -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := firstFreeSlot(test.slots, test.from, test.to); got != test.want {
+				t.Errorf("firstFreeSlot() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package database
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"hero-emulator/utils"
+)
+
+const (
+	NavGridDir = "data/maps"
+
+	PATH_MAX_NODES   = 20000 // nodes expanded before a path search gives up
+	NEAREST_WALKABLE = 8     // cells searched around a blocked target
+)
+
+var (
+	NavGrids  = make(map[int16]*NavGrid)
+	navMutex  sync.RWMutex
+	neighbors = [8][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+)
+
+// NavGrid is the walkability grid of a map. Cell (x, y) covers the coordinates
+// [x*CellSize, (x+1)*CellSize) and [y*CellSize, (y+1)*CellSize).
+type NavGrid struct {
+	MapID    int16
+	Width    int
+	Height   int
+	CellSize float64
+	blocked  []bool
+}
+
+// LoadNavGrids reads the grids of data/maps/<map id>.grid. A grid file starts with a
+// "<width> <height> <cell size>" line followed by one row per y, '.' is walkable and
+// any other character is blocked. Maps without a grid are walkable everywhere.
+func LoadNavGrids() (int, error) {
+
+	files, err := filepath.Glob(filepath.Join(NavGridDir, "*.grid"))
+	if err != nil {
+		return 0, fmt.Errorf("LoadNavGrids: %s", err.Error())
+	}
+
+	grids := make(map[int16]*NavGrid)
+	for _, file := range files {
+		grid, err := readNavGrid(file)
+		if err != nil {
+			return 0, fmt.Errorf("LoadNavGrids %s: %s", file, err.Error())
+		}
+		grids[grid.MapID] = grid
+	}
+
+	navMutex.Lock()
+	NavGrids = grids
+	navMutex.Unlock()
+	return len(grids), nil
+}
+
+func readNavGrid(file string) (*NavGrid, error) {
+
+	mapID, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(file), ".grid"), 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid map id")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	grid := &NavGrid{MapID: int16(mapID)}
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("missing header")
+	}
+	if _, err := fmt.Sscan(scanner.Text(), &grid.Width, &grid.Height, &grid.CellSize); err != nil {
+		return nil, fmt.Errorf("invalid header: %s", err.Error())
+	}
+	if grid.Width <= 0 || grid.Height <= 0 || grid.CellSize <= 0 {
+		return nil, fmt.Errorf("invalid header")
+	}
+
+	grid.blocked = make([]bool, grid.Width*grid.Height)
+	y := 0
+	for scanner.Scan() {
+		row := strings.TrimRight(scanner.Text(), "\r")
+		if row == "" {
+			continue
+		}
+		if y >= grid.Height {
+			return nil, fmt.Errorf("more than %d rows", grid.Height)
+		}
+		if len(row) != grid.Width {
+			return nil, fmt.Errorf("row %d has %d cells, expected %d", y, len(row), grid.Width)
+		}
+		for x := 0; x < grid.Width; x++ {
+			grid.blocked[y*grid.Width+x] = row[x] != '.'
+		}
+		y++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if y != grid.Height {
+		return nil, fmt.Errorf("%d rows, expected %d", y, grid.Height)
+	}
+
+	return grid, nil
+}
+
+func FindNavGrid(mapID int16) *NavGrid {
+	navMutex.RLock()
+	defer navMutex.RUnlock()
+	return NavGrids[mapID]
+}
+
+func (g *NavGrid) cell(location *utils.Location) (int, int) {
+	return int(math.Floor(location.X / g.CellSize)), int(math.Floor(location.Y / g.CellSize))
+}
+
+func (g *NavGrid) center(x, y int) utils.Location {
+	return utils.Location{X: (float64(x) + 0.5) * g.CellSize, Y: (float64(y) + 0.5) * g.CellSize}
+}
+
+func (g *NavGrid) walkable(x, y int) bool {
+	return x >= 0 && y >= 0 && x < g.Width && y < g.Height && !g.blocked[y*g.Width+x]
+}
+
+func (g *NavGrid) Walkable(location *utils.Location) bool {
+	x, y := g.cell(location)
+	return g.walkable(x, y)
+}
+
+// lineOfSight reports whether the straight line between a and b only crosses walkable cells.
+func (g *NavGrid) lineOfSight(a, b *utils.Location) bool {
+	d := utils.CalculateDistance(a, b)
+	steps := int(math.Ceil(d/(g.CellSize/2))) + 1
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		if !g.Walkable(&utils.Location{X: a.X + (b.X-a.X)*t, Y: a.Y + (b.Y-a.Y)*t}) {
+			return false
+		}
+	}
+	return true
+}
+
+// nearestWalkable returns the closest walkable cell around (x, y).
+func (g *NavGrid) nearestWalkable(x, y int) (int, int, bool) {
+	if g.walkable(x, y) {
+		return x, y, true
+	}
+
+	for r := 1; r <= NEAREST_WALKABLE; r++ {
+		best, bx, by := math.MaxInt32, 0, 0
+		for dy := -r; dy <= r; dy++ {
+			for dx := -r; dx <= r; dx++ {
+				if dx != -r && dx != r && dy != -r && dy != r {
+					continue
+				}
+				if d := dx*dx + dy*dy; g.walkable(x+dx, y+dy) && d < best {
+					best, bx, by = d, x+dx, y+dy
+				}
+			}
+		}
+		if best != math.MaxInt32 {
+			return bx, by, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+type pathNode struct {
+	index int
+	f     float64
+	pos   int
+}
+
+type pathQueue []*pathNode
+
+func (q pathQueue) Len() int            { return len(q) }
+func (q pathQueue) Less(i, j int) bool  { return q[i].f < q[j].f }
+func (q pathQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i]; q[i].pos = i; q[j].pos = j }
+func (q *pathQueue) Push(x interface{}) { n := x.(*pathNode); n.pos = len(*q); *q = append(*q, n) }
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
+
+func octile(ax, ay, bx, by int) float64 {
+	dx, dy := math.Abs(float64(ax-bx)), math.Abs(float64(ay-by))
+	return math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy)
+}
+
+// FindPath returns the waypoints from start to end with A*, the last waypoint is end or the
+// closest walkable point to it. Diagonal moves do not cut blocked corners and waypoints in
+// line of sight of each other are merged. It returns nil if end cannot be reached.
+func (g *NavGrid) FindPath(start, end *utils.Location) []utils.Location {
+
+	sx, sy := g.cell(start)
+	ex, ey, ok := g.nearestWalkable(g.cell(end))
+	if !ok {
+		return nil
+	}
+
+	goal := *end
+	if !g.Walkable(end) {
+		goal = g.center(ex, ey)
+	}
+	if g.lineOfSight(start, &goal) {
+		return []utils.Location{goal}
+	}
+	if sx < 0 || sy < 0 || sx >= g.Width || sy >= g.Height {
+		return nil
+	}
+
+	startIndex, endIndex := sy*g.Width+sx, ey*g.Width+ex
+	cost := map[int]float64{startIndex: 0}
+	parent := map[int]int{}
+	closed := map[int]bool{}
+
+	open := &pathQueue{}
+	heap.Push(open, &pathNode{index: startIndex, f: octile(sx, sy, ex, ey)})
+
+	found := false
+	for open.Len() > 0 && len(closed) < PATH_MAX_NODES {
+		node := heap.Pop(open).(*pathNode)
+		if closed[node.index] {
+			continue
+		}
+		if node.index == endIndex {
+			found = true
+			break
+		}
+		closed[node.index] = true
+
+		x, y := node.index%g.Width, node.index/g.Width
+		for _, n := range neighbors {
+			nx, ny := x+n[0], y+n[1]
+			if !g.walkable(nx, ny) {
+				continue
+			}
+			if n[0] != 0 && n[1] != 0 && (!g.walkable(x+n[0], y) || !g.walkable(x, y+n[1])) {
+				continue
+			}
+
+			index := ny*g.Width + nx
+			if closed[index] {
+				continue
+			}
+
+			step := 1.0
+			if n[0] != 0 && n[1] != 0 {
+				step = math.Sqrt2
+			}
+			c := cost[node.index] + step
+			if old, ok := cost[index]; ok && old <= c {
+				continue
+			}
+
+			cost[index] = c
+			parent[index] = node.index
+			heap.Push(open, &pathNode{index: index, f: c + octile(nx, ny, ex, ey)})
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	cells := []int{}
+	for index := endIndex; index != startIndex; index = parent[index] {
+		cells = append(cells, index)
+	}
+
+	path := make([]utils.Location, 0, len(cells))
+	for i := len(cells) - 1; i > 0; i-- {
+		path = append(path, g.center(cells[i]%g.Width, cells[i]/g.Width))
+	}
+	path = append(path, goal)
+
+	return g.smooth(start, path)
+}
+
+// smooth drops the waypoints that can be skipped by walking straight to a later one.
+func (g *NavGrid) smooth(start *utils.Location, path []utils.Location) []utils.Location {
+	result := []utils.Location{}
+	from := *start
+	for i := 0; i < len(path); {
+		next := i
+		for j := len(path) - 1; j > i; j-- {
+			if g.lineOfSight(&from, &path[j]) {
+				next = j
+				break
+			}
+		}
+		result = append(result, path[next])
+		from = path[next]
+		i = next + 1
+	}
+	return result
+}
+
+// IsWalkable reports whether the location on the map can be walked on.
+func IsWalkable(mapID int16, location *utils.Location) bool {
+	grid := FindNavGrid(mapID)
+	return grid == nil || grid.Walkable(location)
+}
+
+// FindPath returns the waypoints to walk from start to end on the map, see NavGrid.FindPath.
+func FindPath(mapID int16, start, end *utils.Location) []utils.Location {
+	grid := FindNavGrid(mapID)
+	if grid == nil {
+		return []utils.Location{*end}
+	}
+	return grid.FindPath(start, end)
+}
+
+// RandomWalkablePoint returns a random walkable point inside the rectangle of min and max.
+func RandomWalkablePoint(mapID int16, min, max *utils.Location) utils.Location {
+	grid := FindNavGrid(mapID)
+	point := utils.Location{X: utils.RandFloat(min.X, max.X), Y: utils.RandFloat(min.Y, max.Y)}
+	if grid == nil {
+		return point
+	}
+
+	for i := 0; i < 20 && !grid.Walkable(&point); i++ {
+		point = utils.Location{X: utils.RandFloat(min.X, max.X), Y: utils.RandFloat(min.Y, max.Y)}
+	}
+	if !grid.Walkable(&point) {
+		if x, y, ok := grid.nearestWalkable(grid.cell(&point)); ok {
+			point = grid.center(x, y)
+		}
+	}
+	return point
+}
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"hero-emulator/utils"
+)
+
+// testGrid builds a grid with cells of size 1 from rows where '.' is walkable.
+func testGrid(rows ...string) *NavGrid {
+	grid := &NavGrid{Width: len(rows[0]), Height: len(rows), CellSize: 1}
+	grid.blocked = make([]bool, grid.Width*grid.Height)
+	for y, row := range rows {
+		for x := 0; x < grid.Width; x++ {
+			grid.blocked[y*grid.Width+x] = row[x] != '.'
+		}
+	}
+	return grid
+}
+
+func TestNavGridWalkable(t *testing.T) {
+	grid := testGrid(
+		"..#",
+		"...",
+	)
+
+	tests := []struct {
+		name     string
+		location utils.Location
+		want     bool
+	}{
+		{"open cell", utils.Location{X: 0.5, Y: 0.5}, true},
+		{"blocked cell", utils.Location{X: 2.5, Y: 0.5}, false},
+		{"cell edge belongs to the next cell", utils.Location{X: 2, Y: 0.2}, false},
+		{"left of the grid", utils.Location{X: -0.5, Y: 0.5}, false},
+		{"below the grid", utils.Location{X: 0.5, Y: 2.5}, false},
+	}
+
+	for _, tt := range tests {
+		if got := grid.Walkable(&tt.location); got != tt.want {
+			t.Errorf("%s: Walkable(%v) = %v, want %v", tt.name, tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestNavGridFindPath(t *testing.T) {
+	wall := []string{
+		"..........",
+		".....#....",
+		".....#....",
+		".....#....",
+		".....#....",
+		".....#....",
+		".....#....",
+		".....#....",
+		".....#....",
+		"..........",
+	}
+
+	sealed := []string{"." + strings.Repeat("#", 19)} // only the corner of the start is open
+	for i := 1; i < 20; i++ {
+		sealed = append(sealed, strings.Repeat("#", 20))
+	}
+
+	tests := []struct {
+		name   string
+		rows   []string
+		start  utils.Location
+		end    utils.Location
+		goal   *utils.Location // the last waypoint, nil when no path is expected
+		around bool            // the straight line is blocked, the path needs more than one waypoint
+	}{
+		{
+			name:  "straight line",
+			rows:  wall,
+			start: utils.Location{X: 1.5, Y: 4.5},
+			end:   utils.Location{X: 3.5, Y: 6.5},
+			goal:  &utils.Location{X: 3.5, Y: 6.5},
+		},
+		{
+			name:   "around a wall",
+			rows:   wall,
+			start:  utils.Location{X: 2.5, Y: 4.5},
+			end:    utils.Location{X: 8.5, Y: 4.5},
+			goal:   &utils.Location{X: 8.5, Y: 4.5},
+			around: true,
+		},
+		{
+			name: "closed room",
+			rows: []string{
+				"..........",
+				"....###...",
+				"....#.#...",
+				"....###...",
+				"..........",
+			},
+			start: utils.Location{X: 0.5, Y: 0.5},
+			end:   utils.Location{X: 5.5, Y: 2.5},
+		},
+		{
+			name: "split map",
+			rows: []string{
+				"....#.....",
+				"....#.....",
+				"....#.....",
+			},
+			start: utils.Location{X: 0.5, Y: 1.5},
+			end:   utils.Location{X: 8.5, Y: 1.5},
+		},
+		{
+			name: "blocked target moves to the nearest walkable cell",
+			rows: []string{
+				"......",
+				"...###",
+				"...###",
+			},
+			start: utils.Location{X: 0.5, Y: 0.5},
+			end:   utils.Location{X: 4.5, Y: 2.5},
+			goal:  &utils.Location{X: 4.5, Y: 0.5},
+		},
+		{
+			name:  "blocked target without walkable cells around",
+			rows:  sealed,
+			start: utils.Location{X: 0.5, Y: 0.5},
+			end:   utils.Location{X: 15.5, Y: 15.5},
+		},
+	}
+
+	for _, tt := range tests {
+		grid := testGrid(tt.rows...)
+		path := grid.FindPath(&tt.start, &tt.end)
+
+		if tt.goal == nil {
+			if path != nil {
+				t.Errorf("%s: expected no path, got %v", tt.name, path)
+			}
+			continue
+		}
+		if len(path) == 0 {
+			t.Errorf("%s: expected a path to %v, got none", tt.name, *tt.goal)
+			continue
+		}
+
+		if last := path[len(path)-1]; last != *tt.goal {
+			t.Errorf("%s: path ends at %v, want %v", tt.name, last, *tt.goal)
+		}
+		if tt.around && len(path) < 2 {
+			t.Errorf("%s: path %v walks through the wall", tt.name, path)
+		}
+
+		from := tt.start
+		for _, p := range path {
+			if !grid.lineOfSight(&from, &p) {
+				t.Errorf("%s: segment %v -> %v crosses a blocked cell", tt.name, from, p)
+			}
+			from = p
+		}
+	}
+}
+
+func TestIsWalkableRejectsBlockedTargets(t *testing.T) {
+	navMutex.Lock()
+	old := NavGrids
+	NavGrids = map[int16]*NavGrid{1: testGrid("..", ".#")}
+	navMutex.Unlock()
+	defer func() {
+		navMutex.Lock()
+		NavGrids = old
+		navMutex.Unlock()
+	}()
+
+	tests := []struct {
+		name     string
+		mapID    int16
+		location utils.Location
+		want     bool
+	}{
+		{"open cell", 1, utils.Location{X: 0.5, Y: 1.5}, true},
+		{"wall", 1, utils.Location{X: 1.5, Y: 1.5}, false},
+		{"off the terrain", 1, utils.Location{X: 4, Y: 4}, false},
+		{"map without a grid", 2, utils.Location{X: 1.5, Y: 1.5}, true},
+	}
+
+	for _, tt := range tests {
+		if got := IsWalkable(tt.mapID, &tt.location); got != tt.want {
+			t.Errorf("%s: IsWalkable(%d, %v) = %v, want %v", tt.name, tt.mapID, tt.location, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+	gorp "gopkg.in/gorp.v1"
+)
+
+const (
+	NPC_ACTION_TELEPORT     = "teleport"
+	NPC_ACTION_OPEN_SHOP    = "open-shop"
+	NPC_ACTION_OPEN_MENU    = "open-menu"
+	NPC_ACTION_CHANGE_CLASS = "change-class"
+	NPC_ACTION_CHARGE_GOLD  = "charge-gold"
+	NPC_ACTION_GRANT_BUFF   = "grant-buff"
+	NPC_ACTION_DUNGEON      = "enter-dungeon"
+	NPC_ACTION_BATTLEGROUND = "join-battleground"
+)
+
+// NPCActionKey identifies an action button, NPCID 0 applies to every npc offering the action.
+type NPCActionKey struct {
+	ActionID int
+	NPCID    int
+}
+
+type NPCAction struct {
+	ID            int             `db:"id"`
+	NPCID         int             `db:"npc_id"`
+	Type          string          `db:"type"`
+	Params        json.RawMessage `db:"params"`
+	MinLevel      int             `db:"min_level"`
+	MaxLevel      int             `db:"max_level"`
+	Faction       int             `db:"faction"`
+	RequiredItems pq.Int64Array   `db:"required_items"`
+	ItemActivated bool            `db:"item_activated"`
+	ConsumeItem   bool            `db:"consume_item"`
+	GoldCost      uint64          `db:"gold_cost"`
+	FailText      int             `db:"fail_text"`
+}
+
+var (
+	NPCActions = make(map[NPCActionKey]*NPCAction)
+)
+
+func (e *NPCAction) Create() error {
+	return db.Insert(e)
+}
+
+func (e *NPCAction) CreateWithTransaction(tr *gorp.Transaction) error {
+	return tr.Insert(e)
+}
+
+func (e *NPCAction) Update() error {
+	_, err := db.Update(e)
+	return err
+}
+
+func (e *NPCAction) Delete() error {
+	_, err := db.Delete(e)
+	return err
+}
+
+// GetParams decodes the type specific parameters of the action into v.
+func (e *NPCAction) GetParams(v interface{}) error {
+	if len(e.Params) == 0 {
+		return nil
+	}
+	return json.Unmarshal(e.Params, v)
+}
+
+func (e *NPCAction) GetRequiredItems() []int64 {
+	return []int64(e.RequiredItems)
+}
+
+// FindNPCAction returns the npc specific definition of the action, or the generic one.
+func FindNPCAction(actionID, npcID int) *NPCAction {
+	if action, ok := NPCActions[NPCActionKey{ActionID: actionID, NPCID: npcID}]; ok {
+		return action
+	}
+	return NPCActions[NPCActionKey{ActionID: actionID}]
+}
+
+func getNPCActions() error {
+	var actions []*NPCAction
+	query := `select * from data.npc_actions`
+
+	if _, err := db.Select(&actions, query); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("getNPCActions: %s", err.Error())
+	}
+
+	for _, a := range actions {
+		NPCActions[NPCActionKey{ActionID: a.ID, NPCID: a.NPCID}] = a
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	gorp "gopkg.in/gorp.v1"
+)
+
+const (
+	MOB_MELEE  = "melee"
+	MOB_RANGED = "ranged"
+	MOB_CASTER = "caster"
+)
+
+// NPCBehavior configures the brain of a mob, zero values are taken from the archetype.
+type NPCBehavior struct {
+	NPCID        int     `db:"npc_id"`
+	Archetype    string  `db:"archetype"`
+	Aggressive   bool    `db:"aggressive"`
+	AggroRange   float64 `db:"aggro_range"`
+	AttackRange  float64 `db:"attack_range"`
+	KeepDistance float64 `db:"keep_distance"` // casters step back from targets closer than this
+	ChaseRange   float64 `db:"chase_range"`
+	LeashRange   float64 `db:"leash_range"` // distance the mob may leave its spawn area
+	AssistRange  float64 `db:"assist_range"`
+	FleeHP       int     `db:"flee_hp"`       // hp percent to flee at, 0 never flees
+	RegenPercent int     `db:"regen_percent"` // hp percent regenerated per tick while resetting
+	SkillChance  int     `db:"skill_chance"`  // per mille
+}
+
+var (
+	NPCBehaviors = make(map[int]*NPCBehavior)
+
+	// MobArchetypes holds the default behavior of each archetype.
+	MobArchetypes = map[string]*NPCBehavior{
+		MOB_MELEE:  {Archetype: MOB_MELEE, Aggressive: true, AggroRange: 15, AttackRange: 5, ChaseRange: 50, RegenPercent: 10, SkillChance: 400},
+		MOB_RANGED: {Archetype: MOB_RANGED, Aggressive: true, AggroRange: 20, AttackRange: 15, ChaseRange: 50, RegenPercent: 10, SkillChance: 200},
+		MOB_CASTER: {Archetype: MOB_CASTER, Aggressive: true, AggroRange: 20, AttackRange: 15, KeepDistance: 6, ChaseRange: 50,
+			RegenPercent: 10, SkillChance: 800},
+	}
+)
+
+func (e *NPCBehavior) Create() error {
+	return db.Insert(e)
+}
+
+func (e *NPCBehavior) CreateWithTransaction(tr *gorp.Transaction) error {
+	return tr.Insert(e)
+}
+
+func (e *NPCBehavior) Update() error {
+	_, err := db.Update(e)
+	return err
+}
+
+func (e *NPCBehavior) Delete() error {
+	_, err := db.Delete(e)
+	return err
+}
+
+// RegisterMobArchetype adds or replaces the defaults of an archetype.
+func RegisterMobArchetype(name string, defaults *NPCBehavior) {
+	defaults.Archetype = name
+	MobArchetypes[name] = defaults
+}
+
+// GetNPCBehavior returns the behavior of the npc filled up with the defaults of its archetype.
+func GetNPCBehavior(npcID int) *NPCBehavior {
+
+	row, ok := NPCBehaviors[npcID]
+	if !ok {
+		b := *MobArchetypes[MOB_MELEE]
+		b.NPCID = npcID
+		return &b
+	}
+
+	archetype, ok := MobArchetypes[row.Archetype]
+	if !ok {
+		archetype = MobArchetypes[MOB_MELEE]
+	}
+
+	b := *row
+	b.Archetype = archetype.Archetype
+	if b.AggroRange == 0 {
+		b.AggroRange = archetype.AggroRange
+	}
+	if b.AttackRange == 0 {
+		b.AttackRange = archetype.AttackRange
+	}
+	if b.KeepDistance == 0 {
+		b.KeepDistance = archetype.KeepDistance
+	}
+	if b.ChaseRange == 0 {
+		b.ChaseRange = archetype.ChaseRange
+	}
+	if b.LeashRange == 0 {
+		b.LeashRange = archetype.LeashRange
+	}
+	if b.AssistRange == 0 {
+		b.AssistRange = archetype.AssistRange
+	}
+	if b.FleeHP == 0 {
+		b.FleeHP = archetype.FleeHP
+	}
+	if b.RegenPercent == 0 {
+		b.RegenPercent = archetype.RegenPercent
+	}
+	if b.SkillChance == 0 {
+		b.SkillChance = archetype.SkillChance
+	}
+
+	return &b
+}
+
+func getNPCBehaviors() error {
+	var behaviors []*NPCBehavior
+	query := `select * from data.npc_behaviors`
+
+	if _, err := db.Select(&behaviors, query); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("getNPCBehaviors: %s", err.Error())
+	}
+
+	for _, b := range behaviors {
+		NPCBehaviors[b.NPCID] = b
+	}
+
+	return nil
+}
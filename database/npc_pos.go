@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"sync"
 
 	"hero-emulator/utils"
 
@@ -10,7 +11,12 @@ import (
 )
 
 var (
-	NPCPos []*NpcPosition
+	NPCPos      []*NpcPosition
+	npcPosMutex sync.Mutex
+
+	// spawnedPos holds the positions of the mobs spawned at runtime, they are not stored in the database
+	// and are false once released for reuse.
+	spawnedPos = make(map[int]bool)
 )
 
 type NpcPosition struct {
@@ -52,6 +58,80 @@ func (e *NpcPosition) Delete() error {
 	return err
 }
 
+// AddNPCPos stores the position of a mob spawned at runtime, it reuses a released position when there is one.
+func AddNPCPos(pos *NpcPosition) {
+	npcPosMutex.Lock()
+	defer npcPosMutex.Unlock()
+
+	for id, used := range spawnedPos {
+		if !used {
+			pos.ID = id
+			NPCPos[id] = pos
+			spawnedPos[id] = true
+			return
+		}
+	}
+
+	pos.ID = len(NPCPos)
+	NPCPos = append(NPCPos, pos)
+	spawnedPos[pos.ID] = true
+}
+
+// ReleaseNPCPos frees the position of a removed mob which was spawned at runtime.
+func ReleaseNPCPos(id int) {
+	npcPosMutex.Lock()
+	defer npcPosMutex.Unlock()
+
+	if spawnedPos[id] {
+		spawnedPos[id] = false
+	}
+}
+
+func isSpawnedPos(id int) bool {
+	npcPosMutex.Lock()
+	defer npcPosMutex.Unlock()
+	_, ok := spawnedPos[id]
+	return ok
+}
+
+// mergeNPCPos puts the loaded rows in place of the current positions. The rows must not take the place of a
+// position a spawned mob uses, and a removed row must not be used by a stored mob. The positions of the spawned
+// mobs beyond the rows keep their index, the gaps below them are filled with released positions.
+func mergeNPCPos(loaded []*NpcPosition) ([]*NpcPosition, error) {
+
+	last := len(loaded) - 1
+	for id, used := range spawnedPos {
+		if id < len(loaded) && used {
+			return nil, fmt.Errorf("npc_pos %d is used by a spawned mob, reload it once the mob is gone", id)
+		} else if used && id > last {
+			last = id
+		}
+	}
+
+	for _, ai := range AllAIs() {
+		if _, spawned := spawnedPos[ai.PosID]; !spawned && ai.PosID >= len(loaded) && ai.PosID < len(NPCPos) {
+			return nil, fmt.Errorf("npc_pos %d was removed but mob %d still uses it", ai.PosID, ai.ID)
+		}
+	}
+
+	merged := append([]*NpcPosition{}, loaded...)
+	for i := len(loaded); i <= last; i++ {
+		if _, spawned := spawnedPos[i]; spawned {
+			merged = append(merged, NPCPos[i])
+		} else { // a removed row, reused by the next spawned mob
+			merged = append(merged, &NpcPosition{ID: i})
+			spawnedPos[i] = false
+		}
+	}
+
+	for id := range spawnedPos {
+		if id < len(loaded) || id > last {
+			delete(spawnedPos, id)
+		}
+	}
+	return merged, nil
+}
+
 func GetAllNPCPos() ([]*NpcPosition, error) {
 
 	var arr []*NpcPosition
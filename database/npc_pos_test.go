@@ -0,0 +1,70 @@
+package database
+
+import "testing"
+
+func positions(n int) []*NpcPosition {
+	arr := make([]*NpcPosition, n)
+	for i := range arr {
+		arr[i] = &NpcPosition{ID: i, NPCID: 1}
+	}
+	return arr
+}
+
+func TestMergeNPCPos(t *testing.T) {
+	tests := []struct {
+		name    string
+		current int
+		spawned map[int]bool
+		mobs    []int // the positions of the registered mobs
+		loaded  int
+		want    int
+		fails   bool
+	}{
+		{"grows", 3, nil, nil, 5, 5, false},
+		{"shrinks", 5, nil, []int{0, 1}, 3, 3, false},
+		{"removed row in use", 5, nil, []int{4}, 3, 0, true},
+		{"spawned position keeps its index", 6, map[int]bool{5: true}, []int{5}, 3, 6, false},
+		{"released positions are dropped", 6, map[int]bool{5: false}, nil, 3, 3, false},
+		{"row over a spawned position", 4, map[int]bool{3: true}, []int{3}, 5, 0, true},
+	}
+
+	defer func(pos []*NpcPosition, spawned map[int]bool, ais map[int]*AI) {
+		NPCPos, spawnedPos, AIs = pos, spawned, ais
+	}(NPCPos, spawnedPos, AIs)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			NPCPos = positions(test.current)
+			spawnedPos = make(map[int]bool)
+			for id, used := range test.spawned {
+				spawnedPos[id] = used
+			}
+			AIs = make(map[int]*AI)
+			for i, posID := range test.mobs {
+				AIs[i+1] = &AI{ID: i + 1, PosID: posID}
+			}
+
+			merged, err := mergeNPCPos(positions(test.loaded))
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected the merge to be rejected")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+
+			if len(merged) != test.want {
+				t.Fatalf("merged %d positions, want %d", len(merged), test.want)
+			}
+			for i, pos := range merged {
+				if pos.ID != i {
+					t.Fatalf("position %d stored at index %d", pos.ID, i)
+				}
+				if _, spawned := spawnedPos[i]; !spawned && i >= test.loaded {
+					t.Fatalf("position %d beyond the rows is not a spawned one", i)
+				}
+			}
+		})
+	}
+}
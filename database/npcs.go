@@ -32,6 +32,16 @@ type NPC struct {
 	SkillID     int    `db:"skill_id"`
 }
 
+// ExpFor returns the exp given to a character of the level.
+func (e *NPC) ExpFor(level int) int64 {
+	if level <= 100 {
+		return e.Exp
+	} else if level <= 200 {
+		return e.DivineExp
+	}
+	return e.DarknessExp
+}
+
 func (e *NPC) Create() error {
 	return db.Insert(e)
 }
@@ -14,9 +14,13 @@ var (
 )
 
 type Party struct {
-	Leader  *Character
-	Members map[int]*PartyMember
-	mutex   sync.RWMutex
+	Leader   *Character
+	Members  map[int]*PartyMember
+	LootMode LootMode
+	lootTurn int
+	rolls    map[int]*LootRoll
+	rollSeq  int
+	mutex    sync.RWMutex
 }
 
 type PartyMember struct {
@@ -0,0 +1,301 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"hero-emulator/config"
+	"hero-emulator/messaging"
+	"hero-emulator/utils"
+)
+
+type LootMode byte
+
+const (
+	LOOT_FREE_FOR_ALL LootMode = iota
+	LOOT_ROUND_ROBIN
+	LOOT_LEADER
+	LOOT_NEED_GREED
+)
+
+const (
+	ROLL_PASS byte = iota
+	ROLL_NEED
+	ROLL_GREED
+)
+
+var (
+	LOOT_MODE_CHANGED = utils.Packet{0xAA, 0x55, 0x03, 0x00, 0x52, 0x08, 0x00, 0x55, 0xAA}
+	LOOT_ROLL         = utils.Packet{0xAA, 0x55, 0x0A, 0x00, 0x52, 0x09, 0x55, 0xAA}
+)
+
+// LootRoll is a need/greed roll of a party for a single item.
+type LootRoll struct {
+	ID      int
+	Item    *InventorySlot
+	Members []*Character
+	Choices map[int]byte
+	done    bool
+}
+
+func (m LootMode) String() string {
+	switch m {
+	case LOOT_ROUND_ROBIN:
+		return "round-robin"
+	case LOOT_LEADER:
+		return "leader"
+	case LOOT_NEED_GREED:
+		return "need/greed"
+	}
+	return "free-for-all"
+}
+
+// partyExpShares returns the part of the group exp each member gets, level weighting and
+// the party size bonus are configured in config.Default.Party.
+func partyExpShares(members []*Contributor) []float64 {
+
+	rules := config.Default.Party
+	n := len(members)
+	shares := make([]float64, n)
+
+	avgLevel := 0.0
+	for _, m := range members {
+		avgLevel += float64(m.Level)
+	}
+	avgLevel /= float64(n)
+
+	bonus := 0.0
+	if n < len(rules.ExpSizeBonus) {
+		bonus = rules.ExpSizeBonus[n]
+	} else if len(rules.ExpSizeBonus) > 0 {
+		bonus = rules.ExpSizeBonus[len(rules.ExpSizeBonus)-1]
+	}
+
+	for i, m := range members {
+		weight := 1.0
+		if avgLevel > 0 {
+			weight = (1 - rules.ExpLevelWeight) + rules.ExpLevelWeight*float64(m.Level)/avgLevel
+		}
+		shares[i] = weight / float64(n) * (1 + bonus)
+	}
+
+	return shares
+}
+
+func (p *Party) SetLootMode(mode LootMode) {
+	p.mutex.Lock()
+	p.LootMode = mode
+	p.mutex.Unlock()
+
+	resp := LOOT_MODE_CHANGED
+	resp[6] = byte(mode)
+	resp.Concat(messaging.InfoMessage(fmt.Sprintf("Loot mode is set to %s.", mode)))
+	p.Broadcast(resp)
+}
+
+// Broadcast sends the data to the leader and the accepted members.
+func (p *Party) Broadcast(data []byte) {
+	for _, c := range p.presentMembers(nil) {
+		c.Socket.Write(data)
+	}
+}
+
+// presentMembers returns the leader and accepted members, filtered by near if it is given.
+func (p *Party) presentMembers(near func(*Character) bool) []*Character {
+	members := []*Character{p.Leader}
+	for _, m := range p.GetMembers() {
+		if m.Accepted {
+			members = append(members, m.Character)
+		}
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].ID < members[j].ID
+	})
+
+	result := []*Character{}
+	for _, c := range members {
+		if c != nil && c.Socket != nil && (near == nil || near(c)) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// DistributeDrop applies the loot mode of the party to a drop of a mob killed by the party.
+// It returns false if the drop should not be put on the ground.
+func (p *Party) DistributeDrop(ai *AI, dr *Drop) bool {
+
+	near := func(c *Character) bool {
+		return c.IsOnline && ai.isPresent(c, ai.Server)
+	}
+
+	p.mutex.Lock()
+	mode, leader := p.LootMode, p.Leader
+	p.mutex.Unlock()
+
+	switch mode {
+	case LOOT_ROUND_ROBIN:
+		dr.Claimer = p.nextLooter(near)
+
+	case LOOT_LEADER:
+		if leader != nil && leader.Socket != nil && near(leader) {
+			dr.Claimer = leader
+		} else { // the leader is away, the drop goes round among the members who are there
+			dr.Claimer = p.nextLooter(near)
+		}
+
+	case LOOT_NEED_GREED:
+		dr.Claimer = nil
+		if dr.Item.Plus >= config.Default.Party.NeedGreedMinPlus {
+			members := p.presentMembers(near)
+			if len(members) > 0 {
+				p.startRoll(dr.Item, members)
+				return false
+			}
+		}
+
+	default:
+		dr.Claimer = nil
+	}
+
+	return true
+}
+
+// nextLooter returns the member whose turn it is among the present ones, nil when nobody is there.
+func (p *Party) nextLooter(near func(*Character) bool) *Character {
+
+	members := p.presentMembers(near)
+	if len(members) == 0 {
+		return nil
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	c := members[p.lootTurn%len(members)]
+	p.lootTurn++
+	return c
+}
+
+func (p *Party) startRoll(item *InventorySlot, members []*Character) {
+
+	p.mutex.Lock()
+	if p.rolls == nil {
+		p.rolls = make(map[int]*LootRoll)
+	}
+	p.rollSeq++
+	roll := &LootRoll{ID: p.rollSeq, Item: item, Members: members, Choices: make(map[int]byte)}
+	p.rolls[roll.ID] = roll
+	p.mutex.Unlock()
+
+	resp := LOOT_ROLL
+	resp.Insert(utils.IntToBytes(uint64(roll.ID), 2, true), 6)       // roll id
+	resp.Insert(utils.IntToBytes(uint64(item.ItemID), 4, true), 8)   // item id
+	resp.Insert(utils.IntToBytes(uint64(item.Plus), 1, true), 12)    // item plus
+	resp.Insert(utils.IntToBytes(uint64(len(members)), 1, true), 13) // roll count
+	for _, c := range members {
+		c.Socket.Write(resp)
+	}
+
+	time.AfterFunc(time.Duration(config.Default.Party.LootRollSeconds)*time.Second, func() {
+		p.resolveRoll(roll.ID)
+	})
+}
+
+// Roll records the need/greed choice of a member, the roll ends when every member has chosen.
+func (p *Party) Roll(c *Character, rollID int, choice byte) {
+
+	p.mutex.Lock()
+	roll, ok := p.rolls[rollID]
+	if !ok || roll.done {
+		p.mutex.Unlock()
+		return
+	}
+
+	allowed := false
+	for _, m := range roll.Members {
+		allowed = allowed || m.ID == c.ID
+	}
+	if allowed {
+		roll.Choices[c.ID] = choice
+	}
+	finished := len(roll.Choices) == len(roll.Members)
+	p.mutex.Unlock()
+
+	if finished {
+		p.resolveRoll(rollID)
+	}
+}
+
+func (p *Party) resolveRoll(rollID int) {
+
+	p.mutex.Lock()
+	roll, ok := p.rolls[rollID]
+	if !ok || roll.done {
+		p.mutex.Unlock()
+		return
+	}
+	roll.done = true
+	delete(p.rolls, rollID)
+	p.mutex.Unlock()
+
+	winner, best := rollWinner(roll.Members, roll.Choices, func() int64 {
+		return utils.RandInt(1, 101)
+	})
+
+	name := fmt.Sprint(roll.Item.ItemID)
+	if info, ok := Items[roll.Item.ItemID]; ok {
+		name = info.Name
+	}
+
+	if winner == nil {
+		p.Broadcast(messaging.InfoMessage(fmt.Sprintf("Everyone passed on %s.", name)))
+		return
+	}
+
+	mail := func(text string) {
+		if err := SendSystemMail(winner.ID, "Party Loot", text, 0, roll.Item); err != nil {
+			log.Println(err)
+		}
+		p.Broadcast(messaging.InfoMessage(fmt.Sprintf("%s won %s with %d, it was sent by mail.", winner.Name, name, best)))
+	}
+
+	if !winner.IsOnline || winner.Socket == nil { // left the game during the roll
+		mail(fmt.Sprintf("You won %s while you were away.", name))
+		return
+	}
+
+	data, _, err := winner.AddItem(roll.Item, -1, true)
+	if err != nil || data == nil {
+		mail(fmt.Sprintf("You won %s but had no room for it.", name))
+		return
+	}
+
+	winner.Socket.Write(*data)
+	p.Broadcast(messaging.InfoMessage(fmt.Sprintf("%s won %s with %d.", winner.Name, name, best)))
+}
+
+// rollWinner returns the member who won the roll and its value, nil when everyone passed. A need beats a greed,
+// the higher value wins between the same choices.
+func rollWinner(members []*Character, choices map[int]byte, roll func() int64) (*Character, int64) {
+
+	rank := map[byte]int{ROLL_NEED: 2, ROLL_GREED: 1}
+
+	var winner *Character
+	best, bestRank := int64(-1), 0
+	for _, m := range members {
+		r := rank[choices[m.ID]]
+		if r == 0 { // passed
+			continue
+		}
+
+		value := roll()
+		if r > bestRank || (r == bestRank && value > best) {
+			winner, best, bestRank = m, value, r
+		}
+	}
+
+	return winner, best
+}
@@ -0,0 +1,561 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"hero-emulator/messaging"
+	"hero-emulator/utils"
+
+	gorp "gopkg.in/gorp.v1"
+	null "gopkg.in/guregu/null.v3"
+)
+
+const (
+	QUEST_OBJECTIVE_KILL    = "kill"
+	QUEST_OBJECTIVE_COLLECT = "collect"
+	QUEST_OBJECTIVE_TALK    = "talk"
+)
+
+var (
+	Quests = make(map[int]*QuestInfo)
+
+	characterQuests = make(map[int][]*CharacterQuest)
+	cqMutex         sync.RWMutex
+
+	QUEST_LIST      = utils.Packet{0xAA, 0x55, 0x00, 0x00, 0x5A, 0x01, 0x55, 0xAA}
+	QUEST_ACCEPTED  = utils.Packet{0xAA, 0x55, 0x00, 0x00, 0x5A, 0x02, 0x55, 0xAA}
+	QUEST_PROGRESS  = utils.Packet{0xAA, 0x55, 0x00, 0x00, 0x5A, 0x03, 0x55, 0xAA}
+	QUEST_COMPLETED = utils.Packet{0xAA, 0x55, 0x00, 0x00, 0x5A, 0x04, 0x55, 0xAA}
+	QUEST_ABANDONED = utils.Packet{0xAA, 0x55, 0x00, 0x00, 0x5A, 0x05, 0x55, 0xAA}
+)
+
+type QuestObjective struct {
+	Type   string `json:"type"`
+	Target int    `json:"target"`
+	Count  int    `json:"count"`
+}
+
+type QuestReward struct {
+	ItemID   int64 `json:"item_id"`
+	Quantity uint  `json:"quantity"`
+}
+
+type QuestInfo struct {
+	ID           int             `db:"id"`
+	Name         string          `db:"name"`
+	NPCID        int             `db:"npc_id"`
+	MinLevel     int             `db:"min_level"`
+	MaxLevel     int             `db:"max_level"`
+	Prerequisite int             `db:"prerequisite"`
+	Repeatable   bool            `db:"repeatable"`
+	Objectives   json.RawMessage `db:"objectives"`
+	RewardExp    int64           `db:"reward_exp"`
+	RewardGold   uint64          `db:"reward_gold"`
+	RewardItems  json.RawMessage `db:"reward_items"`
+}
+
+type CharacterQuest struct {
+	ID          int             `db:"id"`
+	CharacterID int             `db:"character_id"`
+	QuestID     int             `db:"quest_id"`
+	Progress    json.RawMessage `db:"progress"`
+	IsCompleted bool            `db:"is_completed"`
+	AcceptedAt  null.Time       `db:"accepted_at"`
+	CompletedAt null.Time       `db:"completed_at"`
+}
+
+func (e *QuestInfo) Create() error {
+	return db.Insert(e)
+}
+
+func (e *QuestInfo) CreateWithTransaction(tr *gorp.Transaction) error {
+	return tr.Insert(e)
+}
+
+func (e *QuestInfo) Update() error {
+	_, err := db.Update(e)
+	return err
+}
+
+func (e *QuestInfo) Delete() error {
+	_, err := db.Delete(e)
+	return err
+}
+
+func (e *QuestInfo) GetObjectives() ([]*QuestObjective, error) {
+	objectives := []*QuestObjective{}
+	if e.Objectives != nil {
+		if err := json.Unmarshal(e.Objectives, &objectives); err != nil {
+			return nil, err
+		}
+	}
+
+	return objectives, nil
+}
+
+func (e *QuestInfo) GetRewards() ([]*QuestReward, error) {
+	rewards := []*QuestReward{}
+	if e.RewardItems != nil {
+		if err := json.Unmarshal(e.RewardItems, &rewards); err != nil {
+			return nil, err
+		}
+	}
+
+	return rewards, nil
+}
+
+func getQuests() error {
+	var quests []*QuestInfo
+	query := `select * from data.quests`
+
+	if _, err := db.Select(&quests, query); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("getQuests: %s", err.Error())
+	}
+
+	for _, q := range quests {
+		Quests[q.ID] = q
+	}
+
+	return nil
+}
+
+func (q *CharacterQuest) Create() error {
+	return db.Insert(q)
+}
+
+func (q *CharacterQuest) Update() error {
+	_, err := db.Update(q)
+	return err
+}
+
+func (q *CharacterQuest) Delete() error {
+	_, err := db.Delete(q)
+	return err
+}
+
+func (q *CharacterQuest) GetProgress() []int {
+	progress := []int{}
+	if q.Progress != nil {
+		json.Unmarshal(q.Progress, &progress)
+	}
+	return progress
+}
+
+func (q *CharacterQuest) SetProgress(progress []int) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+
+	q.Progress = data
+	return nil
+}
+
+func FindQuestsByCharacterID(characterID int) ([]*CharacterQuest, error) {
+
+	cqMutex.RLock()
+	quests, ok := characterQuests[characterID]
+	cqMutex.RUnlock()
+	if ok {
+		return quests, nil
+	}
+
+	query := `select * from hops.characters_quests where character_id = $1 order by id`
+	if _, err := db.Select(&quests, query, characterID); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("FindQuestsByCharacterID: %s", err.Error())
+	}
+
+	cqMutex.Lock()
+	defer cqMutex.Unlock()
+	characterQuests[characterID] = quests
+	return quests, nil
+}
+
+func DeleteQuestsFromCache(characterID int) {
+	cqMutex.Lock()
+	defer cqMutex.Unlock()
+	delete(characterQuests, characterID)
+}
+
+func (c *Character) FindQuest(questID int) *CharacterQuest {
+	quests, err := FindQuestsByCharacterID(c.ID)
+	if err != nil {
+		return nil
+	}
+
+	for _, q := range quests {
+		if q.QuestID == questID {
+			return q
+		}
+	}
+
+	return nil
+}
+
+// CanAcceptQuest checks level range, prerequisite and whether the quest is already taken.
+func (c *Character) CanAcceptQuest(info *QuestInfo) bool {
+	if c.Level < info.MinLevel || (info.MaxLevel > 0 && c.Level > info.MaxLevel) {
+		return false
+	}
+
+	if info.Prerequisite > 0 {
+		pre := c.FindQuest(info.Prerequisite)
+		if pre == nil || !pre.IsCompleted {
+			return false
+		}
+	}
+
+	q := c.FindQuest(info.ID)
+	return q == nil || (q.IsCompleted && info.Repeatable)
+}
+
+func (c *Character) AcceptQuest(questID int) ([]byte, error) {
+
+	info, ok := Quests[questID]
+	if !ok || !c.CanAcceptQuest(info) {
+		return messaging.InfoMessage("You cannot accept this quest."), nil
+	}
+
+	objectives, err := info.GetObjectives()
+	if err != nil {
+		return nil, err
+	}
+
+	q := c.FindQuest(questID)
+	isNew := q == nil
+	if isNew {
+		q = &CharacterQuest{CharacterID: c.ID, QuestID: questID}
+	}
+
+	q.IsCompleted = false
+	q.AcceptedAt = null.TimeFrom(time.Now())
+	q.CompletedAt = null.Time{}
+	if err := q.SetProgress(make([]int, len(objectives))); err != nil {
+		return nil, err
+	}
+
+	if isNew {
+		if err := q.Create(); err != nil {
+			return nil, err
+		}
+
+		cqMutex.Lock()
+		characterQuests[c.ID] = append(characterQuests[c.ID], q)
+		cqMutex.Unlock()
+	} else if err := q.Update(); err != nil {
+		return nil, err
+	}
+
+	resp := QUEST_ACCEPTED
+	resp.Insert(utils.IntToBytes(uint64(questID), 4, true), 6) // quest id
+	resp.SetLength(6)
+
+	// items collected before accepting the quest count as well
+	for _, o := range objectives {
+		if o.Type == QUEST_OBJECTIVE_COLLECT {
+			resp.Concat(c.QuestProgress(QUEST_OBJECTIVE_COLLECT, o.Target, 0))
+		}
+	}
+
+	return resp, nil
+}
+
+func (c *Character) AbandonQuest(questID int) ([]byte, error) {
+
+	q := c.FindQuest(questID)
+	if q == nil || q.IsCompleted {
+		return nil, nil
+	}
+
+	if err := q.Delete(); err != nil {
+		return nil, err
+	}
+
+	cqMutex.Lock()
+	quests := characterQuests[c.ID]
+	for i, cq := range quests {
+		if cq == q {
+			characterQuests[c.ID] = append(quests[:i], quests[i+1:]...)
+			break
+		}
+	}
+	cqMutex.Unlock()
+
+	resp := QUEST_ABANDONED
+	resp.Insert(utils.IntToBytes(uint64(questID), 4, true), 6) // quest id
+	resp.SetLength(6)
+	return resp, nil
+}
+
+// countItem returns the total quantity of an item inside the inventory.
+func (c *Character) countItem(itemID int64) int {
+	slots, err := c.InventorySlots()
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	for index, s := range slots {
+		if s.ItemID != itemID || (index >= 0x43 && index <= 0x132) {
+			continue
+		}
+		count += int(s.Quantity)
+	}
+	return count
+}
+
+// QuestProgress advances every active quest objective with matching type and target,
+// collect objectives are measured by the items inside the inventory instead of amount.
+func (c *Character) QuestProgress(objType string, target int, amount int) []byte {
+
+	quests, err := FindQuestsByCharacterID(c.ID)
+	if err != nil {
+		return nil
+	}
+
+	c.QuestMutex.Lock() // kills of the party and looted items advance the same quests at once
+	defer c.QuestMutex.Unlock()
+
+	resp := utils.Packet{}
+	for _, q := range quests {
+		info, ok := Quests[q.QuestID]
+		if q.IsCompleted || !ok {
+			continue
+		}
+
+		objectives, err := info.GetObjectives()
+		if err != nil {
+			continue
+		}
+
+		progress := q.GetProgress()
+		changed := false
+		for i, o := range objectives {
+			if o.Type != objType || o.Target != target || i >= len(progress) {
+				continue
+			}
+
+			value := progress[i] + amount
+			if objType == QUEST_OBJECTIVE_COLLECT {
+				value = c.countItem(int64(target))
+			}
+			if value > o.Count {
+				value = o.Count
+			}
+
+			if value != progress[i] {
+				progress[i] = value
+				changed = true
+				resp.Concat(questProgressPacket(q.QuestID, i, value, o.Count))
+			}
+		}
+
+		if changed {
+			q.SetProgress(progress)
+			saved := *q // the copy is written, the quest may change again meanwhile
+			go func() {
+				if err := saved.Update(); err != nil {
+					log.Println(fmt.Errorf("QuestProgress: %s", err.Error()))
+				}
+			}()
+		}
+	}
+
+	return resp
+}
+
+func questProgressPacket(questID, objective, value, count int) []byte {
+	resp := QUEST_PROGRESS
+	resp.Insert(utils.IntToBytes(uint64(questID), 4, true), 6)    // quest id
+	resp.Insert(utils.IntToBytes(uint64(objective), 1, true), 10) // objective index
+	resp.Insert(utils.IntToBytes(uint64(value), 2, true), 11)     // current value
+	resp.Insert(utils.IntToBytes(uint64(count), 2, true), 13)     // required value
+	resp.SetLength(11)
+	return resp
+}
+
+func (c *Character) IsQuestFinished(q *CharacterQuest) bool {
+	info, ok := Quests[q.QuestID]
+	if !ok {
+		return false
+	}
+
+	objectives, err := info.GetObjectives()
+	if err != nil {
+		return false
+	}
+
+	c.QuestMutex.Lock()
+	progress := q.GetProgress()
+	c.QuestMutex.Unlock()
+
+	for i, o := range objectives {
+		if o.Type == QUEST_OBJECTIVE_COLLECT {
+			if c.countItem(int64(o.Target)) < o.Count {
+				return false
+			}
+		} else if i >= len(progress) || progress[i] < o.Count {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CompleteQuest takes the collected items and hands out the quest rewards.
+func (c *Character) CompleteQuest(questID int) ([]byte, error) {
+
+	info, ok := Quests[questID]
+	q := c.FindQuest(questID)
+	if !ok || q == nil || q.IsCompleted || !c.IsQuestFinished(q) {
+		return messaging.InfoMessage("Quest objectives are not completed yet."), nil
+	}
+
+	objectives, err := info.GetObjectives()
+	if err != nil {
+		return nil, err
+	}
+
+	rewards, err := info.GetRewards()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rewards) > 0 {
+		if _, err := c.FindFreeSlots(len(rewards)); err != nil {
+			return messaging.InfoMessage("Not enough inventory space."), nil
+		}
+	}
+
+	resp := utils.Packet{}
+	for _, o := range objectives {
+		if o.Type != QUEST_OBJECTIVE_COLLECT {
+			continue
+		}
+
+		for remaining := uint(o.Count); remaining > 0; {
+			slotID, slot, err := c.FindItemInInventory(nil, int64(o.Target))
+			if err != nil || slotID == -1 {
+				break
+			}
+
+			amount := remaining
+			if slot.Quantity < amount {
+				amount = slot.Quantity
+			}
+
+			resp.Concat(*c.DecrementItem(slotID, amount))
+			remaining -= amount
+		}
+	}
+
+	q.IsCompleted = true
+	q.CompletedAt = null.TimeFrom(time.Now())
+	if err := q.Update(); err != nil {
+		return nil, err
+	}
+
+	if info.RewardExp > 0 {
+		data, levelUp := c.AddExp(info.RewardExp)
+		if levelUp {
+			if statData, err := c.GetStats(); err == nil {
+				resp.Concat(statData)
+			}
+		}
+		resp.Concat(data)
+	}
+
+	if info.RewardGold > 0 {
+		resp.Concat(c.LootGold(info.RewardGold))
+	}
+
+	for _, r := range rewards {
+		quantity := r.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+
+		reward := NewSlot()
+		reward.ItemID = r.ItemID
+		reward.Quantity = quantity
+
+		data, _, err := c.AddItem(reward, -1, false)
+		if err != nil {
+			return nil, err
+		} else if data != nil {
+			resp.Concat(*data)
+		}
+	}
+
+	r := QUEST_COMPLETED
+	r.Insert(utils.IntToBytes(uint64(questID), 4, true), 6) // quest id
+	r.SetLength(6)
+	resp.Concat(r)
+	return resp, nil
+}
+
+// GetQuestList lists the active quests followed by the quests available at an npc, npcID 0 lists active quests only.
+func (c *Character) GetQuestList(npcID int) ([]byte, error) {
+
+	quests, err := FindQuestsByCharacterID(c.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	active := []*CharacterQuest{}
+	for _, q := range quests {
+		if !q.IsCompleted {
+			active = append(active, q)
+		}
+	}
+
+	available := []*QuestInfo{}
+	if npcID > 0 {
+		for _, info := range Quests {
+			if info.NPCID == npcID && c.CanAcceptQuest(info) {
+				available = append(available, info)
+			}
+		}
+	}
+
+	resp := QUEST_LIST
+	index, length := 6, int16(4)
+
+	resp.Insert(utils.IntToBytes(uint64(len(active)), 1, true), index) // active quest count
+	index++
+	length++
+
+	for _, q := range active {
+		finished := byte(0)
+		if c.IsQuestFinished(q) {
+			finished = 1
+		}
+
+		resp.Insert(utils.IntToBytes(uint64(q.QuestID), 4, true), index) // quest id
+		index += 4
+		resp.Insert([]byte{finished}, index) // can be completed
+		index++
+		length += 5
+	}
+
+	resp.Insert(utils.IntToBytes(uint64(len(available)), 1, true), index) // available quest count
+	index++
+	length++
+
+	for _, info := range available {
+		resp.Insert(utils.IntToBytes(uint64(info.ID), 4, true), index) // quest id
+		index += 4
+		length += 4
+	}
+
+	resp.SetLength(length)
+	return resp, nil
+}
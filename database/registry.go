@@ -0,0 +1,596 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Data tables which can be reloaded while the server is running.
+const (
+	TABLE_ITEMS           = "items"
+	TABLE_DROPS           = "drops"
+	TABLE_SCRIPTS         = "scripts"
+	TABLE_NPCS            = "npcs"
+	TABLE_NPC_POS         = "npcpos"
+	TABLE_SHOPS           = "shops"
+	TABLE_SHOP_ITEMS      = "shopitems"
+	TABLE_HT_ITEMS        = "htshop"
+	TABLE_BUFF_INFECTIONS = "buffinf"
+	TABLE_FUSIONS         = "advancedfusions"
+	TABLE_GAMBLINGS       = "gamblings"
+	TABLE_CRAFT_ITEMS     = "craftitems"
+	TABLE_PRODUCTIONS     = "productions"
+	TABLE_EXPS            = "exp"
+	TABLE_QUESTS          = "quests"
+	TABLE_NPC_ACTIONS     = "npcactions"
+	TABLE_NPC_BEHAVIORS   = "npcbehaviors"
+)
+
+var (
+	DataTables = []string{TABLE_ITEMS, TABLE_DROPS, TABLE_SCRIPTS, TABLE_NPCS, TABLE_NPC_POS, TABLE_SHOPS, TABLE_SHOP_ITEMS, TABLE_HT_ITEMS,
+		TABLE_BUFF_INFECTIONS, TABLE_FUSIONS, TABLE_GAMBLINGS, TABLE_CRAFT_ITEMS, TABLE_PRODUCTIONS, TABLE_EXPS, TABLE_QUESTS,
+		TABLE_NPC_ACTIONS, TABLE_NPC_BEHAVIORS}
+
+	// RegisterNPC replaces a reloaded npc position inside the map register, old is nil for new positions.
+	RegisterNPC func(old, pos *NpcPosition)
+
+	registryMutex sync.Mutex
+	dataMutex     sync.RWMutex // the world ticks read the tables under it, swap replaces them under it
+	maxDiffErrors = 10
+)
+
+// DataSnapshot is a complete copy of the game data tables.
+type DataSnapshot struct {
+	Items          map[int64]*Item
+	Drops          map[int]*DropInfo
+	NPCScripts     map[int]*NPCScript
+	NPCs           map[int]*NPC
+	NPCPos         []*NpcPosition
+	Shops          map[int]*Shop
+	ShopItems      map[int]*ShopItem
+	HTItems        map[int]*HtItem
+	BuffInfections map[int]*BuffInfection
+	Fusions        map[int64]*Fusion
+	GamblingItems  map[int]*Gambling
+	CraftItems     map[int]*CraftItem
+	Productions    map[int]*Production
+	EXPs           map[int16]*ExpInfo
+	Quests         map[int]*QuestInfo
+	NPCActions     map[NPCActionKey]*NPCAction
+	NPCBehaviors   map[int]*NPCBehavior
+
+	npcPosLoaded bool
+}
+
+type TableDiff struct {
+	Table   string
+	Added   int
+	Removed int
+	Changed int
+}
+
+type DataDiff struct {
+	Tables []*TableDiff
+	Errors []string
+}
+
+func (d *TableDiff) String() string {
+	return fmt.Sprintf("%s: +%d -%d ~%d", d.Table, d.Added, d.Removed, d.Changed)
+}
+
+func (d *DataDiff) Lines() []string {
+	lines := []string{}
+	for _, t := range d.Tables {
+		if t.Added > 0 || t.Removed > 0 || t.Changed > 0 {
+			lines = append(lines, t.String())
+		}
+	}
+
+	if len(lines) == 0 && len(d.Errors) == 0 {
+		lines = append(lines, "No changes.")
+	}
+
+	for i, e := range d.Errors {
+		if i == maxDiffErrors {
+			lines = append(lines, fmt.Sprintf("... and %d more errors", len(d.Errors)-i))
+			break
+		}
+		lines = append(lines, e)
+	}
+
+	return lines
+}
+
+// currentSnapshot captures the tables which are currently in use.
+func currentSnapshot() *DataSnapshot {
+	return &DataSnapshot{
+		Items:          Items,
+		Drops:          Drops,
+		NPCScripts:     NPCScripts,
+		NPCs:           NPCs,
+		NPCPos:         NPCPos,
+		Shops:          Shops,
+		ShopItems:      ShopItems,
+		HTItems:        HTItems,
+		BuffInfections: BuffInfections,
+		Fusions:        Fusions,
+		GamblingItems:  GamblingItems,
+		CraftItems:     CraftItems,
+		Productions:    Productions,
+		EXPs:           EXPs,
+		Quests:         Quests,
+		NPCActions:     NPCActions,
+		NPCBehaviors:   NPCBehaviors,
+	}
+}
+
+func selectTable(arr interface{}, table string) error {
+	query := fmt.Sprintf(`select * from data.%s`, table)
+	if _, err := db.Select(arr, query); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("selectTable %s: %s", table, err.Error())
+	}
+
+	return nil
+}
+
+func (s *DataSnapshot) load(table string) error {
+
+	switch table {
+	case TABLE_ITEMS:
+		var arr []*Item
+		if err := selectTable(&arr, "items"); err != nil {
+			return err
+		}
+		s.Items = make(map[int64]*Item)
+		for _, e := range arr {
+			s.Items[e.ID] = e
+		}
+
+	case TABLE_DROPS:
+		drops, err := selectDrops()
+		if err != nil {
+			return fmt.Errorf("%s: %s", table, err.Error())
+		}
+		s.Drops = drops
+
+	case TABLE_SCRIPTS:
+		var arr []*NPCScript
+		if err := selectTable(&arr, "npc_scripts"); err != nil {
+			return err
+		}
+		s.NPCScripts = make(map[int]*NPCScript)
+		for _, e := range arr {
+			s.NPCScripts[e.ID] = e
+		}
+
+	case TABLE_NPCS:
+		npcs, err := GetAllNPCs()
+		if err != nil {
+			return err
+		}
+		if npcs == nil {
+			npcs = make(map[int]*NPC)
+		}
+		s.NPCs = npcs
+
+	case TABLE_NPC_POS:
+		arr, err := GetAllNPCPos()
+		if err != nil {
+			return err
+		}
+		s.NPCPos = arr
+		s.npcPosLoaded = true
+
+	case TABLE_SHOPS:
+		var arr []*Shop
+		if err := selectTable(&arr, "shop_table"); err != nil {
+			return err
+		}
+		s.Shops = make(map[int]*Shop)
+		for _, e := range arr {
+			s.Shops[e.ID] = e
+		}
+
+	case TABLE_SHOP_ITEMS:
+		var arr []*ShopItem
+		if err := selectTable(&arr, "shop_items"); err != nil {
+			return err
+		}
+		s.ShopItems = make(map[int]*ShopItem)
+		for _, e := range arr {
+			s.ShopItems[e.Type] = e
+		}
+
+	case TABLE_HT_ITEMS:
+		var arr []*HtItem
+		if err := selectTable(&arr, "ht_shop"); err != nil {
+			return err
+		}
+		s.HTItems = make(map[int]*HtItem)
+		for _, e := range arr {
+			s.HTItems[e.ID] = e
+		}
+
+	case TABLE_BUFF_INFECTIONS:
+		var arr []*BuffInfection
+		if err := selectTable(&arr, "buff_infections"); err != nil {
+			return err
+		}
+		s.BuffInfections = make(map[int]*BuffInfection)
+		for _, e := range arr {
+			s.BuffInfections[e.ID] = e
+		}
+
+	case TABLE_FUSIONS:
+		var arr []*Fusion
+		if err := selectTable(&arr, "advanced_fusion"); err != nil {
+			return err
+		}
+		s.Fusions = make(map[int64]*Fusion)
+		for _, e := range arr {
+			s.Fusions[e.Item1] = e
+		}
+
+	case TABLE_GAMBLINGS:
+		var arr []*Gambling
+		if err := selectTable(&arr, "gambling"); err != nil {
+			return err
+		}
+		s.GamblingItems = make(map[int]*Gambling)
+		for _, e := range arr {
+			s.GamblingItems[e.ID] = e
+		}
+
+	case TABLE_CRAFT_ITEMS:
+		var arr []*CraftItem
+		if err := selectTable(&arr, "craft_items"); err != nil {
+			return err
+		}
+		s.CraftItems = make(map[int]*CraftItem)
+		for _, e := range arr {
+			s.CraftItems[e.ID] = e
+		}
+
+	case TABLE_PRODUCTIONS:
+		var arr []*Production
+		if err := selectTable(&arr, "productions"); err != nil {
+			return err
+		}
+		s.Productions = make(map[int]*Production)
+		for _, e := range arr {
+			s.Productions[e.ID] = e
+		}
+
+	case TABLE_EXPS:
+		var arr []*ExpInfo
+		if err := selectTable(&arr, "exp_table"); err != nil {
+			return err
+		}
+		s.EXPs = make(map[int16]*ExpInfo)
+		for _, e := range arr {
+			s.EXPs[e.Level] = e
+		}
+
+	case TABLE_QUESTS:
+		var arr []*QuestInfo
+		if err := selectTable(&arr, "quests"); err != nil {
+			return err
+		}
+		s.Quests = make(map[int]*QuestInfo)
+		for _, e := range arr {
+			s.Quests[e.ID] = e
+		}
+
+	case TABLE_NPC_ACTIONS:
+		var arr []*NPCAction
+		if err := selectTable(&arr, "npc_actions"); err != nil {
+			return err
+		}
+		s.NPCActions = make(map[NPCActionKey]*NPCAction)
+		for _, e := range arr {
+			s.NPCActions[NPCActionKey{ActionID: e.ID, NPCID: e.NPCID}] = e
+		}
+
+	case TABLE_NPC_BEHAVIORS:
+		var arr []*NPCBehavior
+		if err := selectTable(&arr, "npc_behaviors"); err != nil {
+			return err
+		}
+		s.NPCBehaviors = make(map[int]*NPCBehavior)
+		for _, e := range arr {
+			s.NPCBehaviors[e.NPCID] = e
+		}
+
+	default:
+		return fmt.Errorf("unknown data table: %s", table)
+	}
+
+	return nil
+}
+
+// Validate checks the cross references between the tables of the snapshot.
+func (s *DataSnapshot) Validate() []string {
+
+	errs := []string{}
+
+	for _, drop := range s.Drops {
+		weight := 0
+		for _, entry := range drop.rows {
+			if entry.Weight < weight {
+				errs = append(errs, fmt.Sprintf("drop %d: weight %d of entry %d is below %d", drop.ID, entry.Weight, entry.ID, weight))
+			}
+			weight = entry.Weight
+
+			if entry.NestedDrop > 0 {
+				if _, ok := s.Drops[entry.NestedDrop]; !ok {
+					errs = append(errs, fmt.Sprintf("drop %d: unknown nested drop %d", drop.ID, entry.NestedDrop))
+				}
+			} else if _, ok := s.Items[int64(entry.ItemID)]; !ok && entry.ItemID > 0 {
+				errs = append(errs, fmt.Sprintf("drop %d: unknown item %d", drop.ID, entry.ItemID))
+			}
+
+			if entry.MaxQuantity > 0 && entry.MaxQuantity < entry.MinQuantity {
+				errs = append(errs, fmt.Sprintf("drop %d: entry %d has max quantity below min quantity", drop.ID, entry.ID))
+			}
+		}
+		if weight > 1000 {
+			errs = append(errs, fmt.Sprintf("drop %d: total weight %d exceeds 1000", drop.ID, weight))
+		}
+	}
+
+	for _, npc := range s.NPCs {
+		if npc.DropID == 0 {
+			continue
+		}
+		if _, ok := s.Drops[npc.DropID]; !ok {
+			errs = append(errs, fmt.Sprintf("npc %d: unknown drop %d", npc.ID, npc.DropID))
+		}
+	}
+
+	for i, pos := range s.NPCPos {
+		if pos.ID != i { // mobs access positions by index
+			errs = append(errs, fmt.Sprintf("npc_pos %d: stored at index %d", pos.ID, i))
+		}
+		if isSpawnedPos(pos.ID) {
+			continue
+		}
+		if _, ok := s.NPCs[pos.NPCID]; !ok {
+			errs = append(errs, fmt.Sprintf("npc_pos %d: unknown npc %d", pos.ID, pos.NPCID))
+		}
+	}
+
+	for _, shopItem := range s.ShopItems {
+		for _, id := range shopItem.GetItems() {
+			if _, ok := s.Items[int64(id)]; !ok && id > 0 {
+				errs = append(errs, fmt.Sprintf("shop_items %d: unknown item %d", shopItem.Type, id))
+			}
+		}
+	}
+
+	for _, quest := range s.Quests {
+		if _, ok := s.NPCs[quest.NPCID]; !ok && quest.NPCID > 0 {
+			errs = append(errs, fmt.Sprintf("quest %d: unknown npc %d", quest.ID, quest.NPCID))
+		}
+		if _, ok := s.Quests[quest.Prerequisite]; !ok && quest.Prerequisite > 0 {
+			errs = append(errs, fmt.Sprintf("quest %d: unknown prerequisite %d", quest.ID, quest.Prerequisite))
+		}
+		if _, err := quest.GetObjectives(); err != nil {
+			errs = append(errs, fmt.Sprintf("quest %d: invalid objectives: %s", quest.ID, err.Error()))
+		}
+		rewards, err := quest.GetRewards()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("quest %d: invalid rewards: %s", quest.ID, err.Error()))
+		}
+		for _, r := range rewards {
+			if _, ok := s.Items[r.ItemID]; !ok {
+				errs = append(errs, fmt.Sprintf("quest %d: unknown reward item %d", quest.ID, r.ItemID))
+			}
+		}
+	}
+
+	for key, action := range s.NPCActions {
+		if _, ok := s.NPCs[key.NPCID]; !ok && key.NPCID > 0 {
+			errs = append(errs, fmt.Sprintf("npc_actions %d: unknown npc %d", key.ActionID, key.NPCID))
+		}
+		for _, id := range action.GetRequiredItems() {
+			if _, ok := s.Items[id]; !ok {
+				errs = append(errs, fmt.Sprintf("npc_actions %d: unknown required item %d", key.ActionID, id))
+			}
+		}
+		if !json.Valid(action.Params) && len(action.Params) > 0 {
+			errs = append(errs, fmt.Sprintf("npc_actions %d: invalid params", key.ActionID))
+		}
+	}
+
+	for id, behavior := range s.NPCBehaviors {
+		if _, ok := s.NPCs[id]; !ok {
+			errs = append(errs, fmt.Sprintf("npc_behaviors %d: unknown npc", id))
+		}
+		if _, ok := MobArchetypes[behavior.Archetype]; !ok && behavior.Archetype != "" {
+			errs = append(errs, fmt.Sprintf("npc_behaviors %d: unknown archetype %s", id, behavior.Archetype))
+		}
+	}
+
+	sort.Strings(errs)
+	return errs
+}
+
+// diffTable compares two maps with pointer values by key.
+func diffTable(name string, old, new interface{}) *TableDiff {
+
+	diff := &TableDiff{Table: name}
+	oldMap, newMap := reflect.ValueOf(old), reflect.ValueOf(new)
+
+	for _, key := range newMap.MapKeys() {
+		o := oldMap.MapIndex(key)
+		if !o.IsValid() {
+			diff.Added++
+		} else if !reflect.DeepEqual(o.Elem().Interface(), newMap.MapIndex(key).Elem().Interface()) {
+			diff.Changed++
+		}
+	}
+
+	for _, key := range oldMap.MapKeys() {
+		if !newMap.MapIndex(key).IsValid() {
+			diff.Removed++
+		}
+	}
+
+	return diff
+}
+
+// npcPosByID returns the stored positions, the positions of the spawned mobs are not part of the table.
+func npcPosByID(arr []*NpcPosition) map[int]NpcPosition {
+	m := make(map[int]NpcPosition)
+	for _, pos := range arr {
+		if isSpawnedPos(pos.ID) {
+			continue
+		}
+
+		p := *pos
+		p.PseudoID = 0
+		m[p.ID] = p
+	}
+	return m
+}
+
+func diffSnapshots(old, new *DataSnapshot) []*TableDiff {
+
+	oldPos, newPos := npcPosByID(old.NPCPos), npcPosByID(new.NPCPos)
+	posDiff := &TableDiff{Table: TABLE_NPC_POS}
+	for id, p := range newPos {
+		if o, ok := oldPos[id]; !ok {
+			posDiff.Added++
+		} else if o != p {
+			posDiff.Changed++
+		}
+	}
+	for id := range oldPos {
+		if _, ok := newPos[id]; !ok {
+			posDiff.Removed++
+		}
+	}
+
+	return []*TableDiff{
+		diffTable(TABLE_ITEMS, old.Items, new.Items),
+		diffTable(TABLE_DROPS, old.Drops, new.Drops),
+		diffTable(TABLE_SCRIPTS, old.NPCScripts, new.NPCScripts),
+		diffTable(TABLE_NPCS, old.NPCs, new.NPCs),
+		posDiff,
+		diffTable(TABLE_SHOPS, old.Shops, new.Shops),
+		diffTable(TABLE_SHOP_ITEMS, old.ShopItems, new.ShopItems),
+		diffTable(TABLE_HT_ITEMS, old.HTItems, new.HTItems),
+		diffTable(TABLE_BUFF_INFECTIONS, old.BuffInfections, new.BuffInfections),
+		diffTable(TABLE_FUSIONS, old.Fusions, new.Fusions),
+		diffTable(TABLE_GAMBLINGS, old.GamblingItems, new.GamblingItems),
+		diffTable(TABLE_CRAFT_ITEMS, old.CraftItems, new.CraftItems),
+		diffTable(TABLE_PRODUCTIONS, old.Productions, new.Productions),
+		diffTable(TABLE_EXPS, old.EXPs, new.EXPs),
+		diffTable(TABLE_QUESTS, old.Quests, new.Quests),
+		diffTable(TABLE_NPC_ACTIONS, old.NPCActions, new.NPCActions),
+		diffTable(TABLE_NPC_BEHAVIORS, old.NPCBehaviors, new.NPCBehaviors),
+	}
+}
+
+// swap publishes every table of the snapshot. The world ticks and the packet handlers read the tables under
+// dataMutex, so they see either the old or the new snapshot as a whole. The positions of the spawned mobs are
+// carried over into the snapshot.
+func (s *DataSnapshot) swap(old *DataSnapshot) error {
+
+	dataMutex.Lock()
+	defer dataMutex.Unlock()
+	npcPosMutex.Lock()
+	defer npcPosMutex.Unlock()
+
+	if !s.npcPosLoaded {
+		s.NPCPos = NPCPos // positions may have been spawned since the snapshot was taken
+	} else {
+		merged, err := mergeNPCPos(s.NPCPos)
+		if err != nil {
+			return err
+		}
+		s.NPCPos = merged
+	}
+
+	oldPos := make(map[int]*NpcPosition)
+	for _, pos := range old.NPCPos {
+		oldPos[pos.ID] = pos
+	}
+
+	for _, pos := range s.NPCPos {
+		if !pos.IsNPC || pos.Attackable {
+			continue
+		}
+
+		o := oldPos[pos.ID]
+		if o != nil && o.PseudoID > 0 && o.MapID == pos.MapID {
+			pos.PseudoID = o.PseudoID
+		}
+
+		if RegisterNPC != nil {
+			RegisterNPC(o, pos)
+		}
+	}
+
+	Items = s.Items
+	Drops = s.Drops
+	NPCScripts = s.NPCScripts
+	NPCs = s.NPCs
+	NPCPos = s.NPCPos
+	Shops = s.Shops
+	ShopItems = s.ShopItems
+	HTItems = s.HTItems
+	BuffInfections = s.BuffInfections
+	Fusions = s.Fusions
+	GamblingItems = s.GamblingItems
+	CraftItems = s.CraftItems
+	Productions = s.Productions
+	EXPs = s.EXPs
+	Quests = s.Quests
+	NPCActions = s.NPCActions
+	NPCBehaviors = s.NPCBehaviors
+	return nil
+}
+
+// ReloadData loads the given tables into a new snapshot, validates it and swaps it in.
+// The snapshot is rejected if validation fails unless force is set.
+func ReloadData(force bool, tables ...string) (*DataDiff, error) {
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	old := currentSnapshot()
+	snapshot := *old
+
+	for _, table := range tables {
+		if err := snapshot.load(strings.ToLower(table)); err != nil {
+			return nil, err
+		}
+	}
+
+	known := make(map[string]struct{})
+	for _, e := range old.Validate() {
+		known[e] = struct{}{}
+	}
+
+	diff := &DataDiff{Tables: diffSnapshots(old, &snapshot)}
+	for _, e := range snapshot.Validate() {
+		if _, ok := known[e]; !ok { // only new problems block the reload
+			diff.Errors = append(diff.Errors, e)
+		}
+	}
+
+	if len(diff.Errors) > 0 && !force {
+		return diff, fmt.Errorf("reload rejected: %d validation errors", len(diff.Errors))
+	}
+
+	if err := snapshot.swap(old); err != nil {
+		return diff, fmt.Errorf("reload rejected: %s", err.Error())
+	}
+	return diff, nil
+}
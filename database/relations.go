@@ -0,0 +1,318 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"hero-emulator/messaging"
+	"hero-emulator/utils"
+
+	null "gopkg.in/guregu/null.v3"
+)
+
+const (
+	RELATION_FRIEND = "friend"
+	RELATION_BLOCK  = "block"
+
+	MAX_FRIENDS = 50
+	MAX_BLOCKS  = 100
+)
+
+var (
+	characterRelations = make(map[int]map[int]*CharacterRelation)
+	relationMutex      sync.RWMutex
+)
+
+// CharacterRelation is an entry of the friend or the block list of a character.
+type CharacterRelation struct {
+	CharacterID int       `db:"character_id"`
+	TargetID    int       `db:"target_id"`
+	Type        string    `db:"type"`
+	CreatedAt   null.Time `db:"created_at"`
+}
+
+func (r *CharacterRelation) Create() error {
+	return db.Insert(r)
+}
+
+func (r *CharacterRelation) Update() error {
+	_, err := db.Update(r)
+	return err
+}
+
+func (r *CharacterRelation) Delete() error {
+	_, err := db.Delete(r)
+	return err
+}
+
+// FindRelationsByCharacterID returns the friends and the blocked characters of the character by their ids.
+func FindRelationsByCharacterID(characterID int) (map[int]*CharacterRelation, error) {
+
+	relationMutex.RLock()
+	relations, ok := characterRelations[characterID]
+	relationMutex.RUnlock()
+	if ok {
+		return relations, nil
+	}
+
+	var arr []*CharacterRelation
+	query := `select * from hops.character_relations where character_id = $1`
+
+	if _, err := db.Select(&arr, query, characterID); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("FindRelationsByCharacterID: %s", err.Error())
+	}
+
+	relations = make(map[int]*CharacterRelation)
+	for _, r := range arr {
+		relations[r.TargetID] = r
+	}
+
+	relationMutex.Lock()
+	defer relationMutex.Unlock()
+	characterRelations[characterID] = relations
+	return relations, nil
+}
+
+func DeleteRelationsFromCache(characterID int) {
+	relationMutex.Lock()
+	defer relationMutex.Unlock()
+	delete(characterRelations, characterID)
+}
+
+func (c *Character) findRelation(targetID int) *CharacterRelation {
+	relations, err := FindRelationsByCharacterID(c.ID)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	relationMutex.RLock()
+	defer relationMutex.RUnlock()
+	return relations[targetID]
+}
+
+// IsBlocking reports whether the character has the other one in its block list.
+func (c *Character) IsBlocking(other *Character) bool {
+	if c == nil || other == nil {
+		return false
+	}
+
+	r := c.findRelation(other.ID)
+	return r != nil && r.Type == RELATION_BLOCK
+}
+
+// AddRelation puts the named character into the friend or the block list, a friend which is blocked leaves the
+// friend list and the other way around.
+func (c *Character) AddRelation(name, relType string) ([]byte, error) {
+
+	target, err := FindCharacterByName(name)
+	if err != nil {
+		return nil, err
+	} else if target == nil {
+		return messaging.InfoMessage(fmt.Sprintf("%s does not exist.", name)), nil
+	} else if target.ID == c.ID {
+		return messaging.InfoMessage("You cannot add yourself."), nil
+	}
+
+	relations, err := FindRelationsByCharacterID(c.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, list := MAX_FRIENDS, "friend list"
+	if relType == RELATION_BLOCK {
+		limit, list = MAX_BLOCKS, "block list"
+	}
+
+	relationMutex.Lock()
+	r, ok := relations[target.ID]
+	if ok && r.Type == relType {
+		relationMutex.Unlock()
+		return messaging.InfoMessage(fmt.Sprintf("%s is already in your %s.", target.Name, list)), nil
+	}
+
+	count := 0
+	for _, rel := range relations {
+		if rel.Type == relType {
+			count++
+		}
+	}
+	if count >= limit {
+		relationMutex.Unlock()
+		return messaging.InfoMessage(fmt.Sprintf("Your %s is full.", list)), nil
+	}
+
+	if ok {
+		r.Type = relType
+		err = r.Update()
+	} else {
+		r = &CharacterRelation{CharacterID: c.ID, TargetID: target.ID, Type: relType, CreatedAt: null.TimeFrom(time.Now())}
+		if err = r.Create(); err == nil {
+			relations[target.ID] = r
+		}
+	}
+	relationMutex.Unlock()
+
+	if err != nil {
+		return nil, fmt.Errorf("AddRelation: %s", err.Error())
+	}
+
+	if relType == RELATION_BLOCK {
+		return messaging.InfoMessage(fmt.Sprintf("You blocked %s.", target.Name)), nil
+	}
+
+	resp := utils.Packet{}
+	resp.Concat(messaging.InfoMessage(fmt.Sprintf("%s was added to your friend list.", target.Name)))
+	if target.IsOnline {
+		resp.Concat(messaging.InfoMessage(fmt.Sprintf("%s is online.", target.Name)))
+	}
+	return resp, nil
+}
+
+// RemoveRelation takes the named character out of the friend or the block list.
+func (c *Character) RemoveRelation(name, relType string) ([]byte, error) {
+
+	list := "friend list"
+	if relType == RELATION_BLOCK {
+		list = "block list"
+	}
+
+	target, err := FindCharacterByName(name)
+	if err != nil {
+		return nil, err
+	} else if target == nil {
+		return messaging.InfoMessage(fmt.Sprintf("%s is not in your %s.", name, list)), nil
+	}
+
+	relations, err := FindRelationsByCharacterID(c.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	relationMutex.Lock()
+	r, ok := relations[target.ID]
+	if !ok || r.Type != relType {
+		relationMutex.Unlock()
+		return messaging.InfoMessage(fmt.Sprintf("%s is not in your %s.", target.Name, list)), nil
+	}
+	delete(relations, target.ID)
+	relationMutex.Unlock()
+
+	if err := r.Delete(); err != nil {
+		return nil, fmt.Errorf("RemoveRelation: %s", err.Error())
+	}
+	return messaging.InfoMessage(fmt.Sprintf("%s was removed from your %s.", target.Name, list)), nil
+}
+
+// RelationLines lists the friends with their status or the blocked characters.
+func (c *Character) RelationLines(relType string) []string {
+
+	relations, err := FindRelationsByCharacterID(c.ID)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	relationMutex.RLock()
+	ids := []int{}
+	for id, r := range relations {
+		if r.Type == relType {
+			ids = append(ids, id)
+		}
+	}
+	relationMutex.RUnlock()
+
+	lines := []string{}
+	for _, id := range ids {
+		target, err := FindCharacterByID(id)
+		if err != nil || target == nil {
+			continue
+		}
+
+		if relType == RELATION_BLOCK {
+			lines = append(lines, target.Name)
+		} else if target.IsOnline {
+			lines = append(lines, fmt.Sprintf("%s (online)", target.Name))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s (offline)", target.Name))
+		}
+	}
+	sort.Strings(lines)
+
+	if len(lines) == 0 && relType == RELATION_BLOCK {
+		lines = append(lines, "Your block list is empty.")
+	} else if len(lines) == 0 {
+		lines = append(lines, "Your friend list is empty.")
+	}
+	return lines
+}
+
+// NotifyFriends tells the online characters which have the character in their friend list that it logged in or out,
+// characters blocked by it are not told.
+func NotifyFriends(c *Character, online bool) {
+
+	var ids []int
+	query := `select character_id from hops.character_relations where target_id = $1 and type = $2`
+
+	if _, err := db.Select(&ids, query, c.ID, RELATION_FRIEND); err != nil {
+		if err != sql.ErrNoRows {
+			log.Println(fmt.Errorf("NotifyFriends: %s", err.Error()))
+		}
+		return
+	}
+
+	status := "offline"
+	if online {
+		status = "online"
+	}
+	msg := messaging.InfoMessage(fmt.Sprintf("%s is %s.", c.Name, status))
+
+	for _, id := range ids {
+		characterMutex.RLock()
+		friend, ok := characters[id]
+		characterMutex.RUnlock()
+
+		if !ok || !friend.IsOnline || friend.Socket == nil || c.IsBlocking(friend) {
+			continue
+		}
+		friend.Socket.Write(msg)
+	}
+}
+
+// OnlineFriendsMessage tells the character how many of its friends are online, nil when none is.
+func (c *Character) OnlineFriendsMessage() []byte {
+
+	relations, err := FindRelationsByCharacterID(c.ID)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	relationMutex.RLock()
+	ids := []int{}
+	for id, r := range relations {
+		if r.Type == RELATION_FRIEND {
+			ids = append(ids, id)
+		}
+	}
+	relationMutex.RUnlock()
+
+	count := 0
+	for _, id := range ids {
+		characterMutex.RLock()
+		friend, ok := characters[id]
+		characterMutex.RUnlock()
+		if ok && friend.IsOnline {
+			count++
+		}
+	}
+
+	if count == 0 {
+		return nil
+	}
+	return messaging.InfoMessage(fmt.Sprintf("%d of your friends are online.", count))
+}
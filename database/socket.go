@@ -16,6 +16,10 @@ import (
 	"github.com/nats-io/nats.go"
 )
 
+const (
+	SOCKET_WRITE_TIMEOUT = 500 * time.Millisecond // a client which does not read for this long is dropped
+)
+
 var (
 	Handler     func(*Socket, []byte, uint16) ([]byte, error)
 	Sockets     = make(map[string]*Socket)
@@ -144,6 +148,9 @@ func (s *Socket) OnClose() {
 }
 
 func (s *Socket) recognizePacket(data []byte) ([]byte, error) {
+	dataMutex.RLock() // the handlers see the data tables of one snapshot, a reload waits for them
+	defer dataMutex.RUnlock()
+
 	packets := bytes.SplitAfter(data, []byte{0x55, 0xAA})
 
 	resp := utils.Packet{}
@@ -196,9 +203,18 @@ func (s *Socket) recognizePacket(data []byte) ([]byte, error) {
 	return resp, nil
 }
 
+// Write sends the data to the client within SOCKET_WRITE_TIMEOUT, the connection of a client which stopped reading
+// is closed so the world and the chat do not wait for it.
 func (s *Socket) Write(data []byte) error {
 	if s != nil && s.Conn != nil {
+		s.Conn.SetWriteDeadline(time.Now().Add(SOCKET_WRITE_TIMEOUT))
 		_, err := s.Conn.Write(data)
+		s.Conn.SetWriteDeadline(time.Time{})
+
+		if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
+			log.Printf("Write %s: %s, closing the connection", s.ClientAddr, err.Error())
+			s.Conn.Close()
+		}
 		return err
 	}
 
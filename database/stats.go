@@ -150,6 +150,7 @@ func (t *Stat) Calculate() error {
 	c.AdditionalDropMultiplier = 0
 	c.AdditionalExpMultiplier = 0
 	c.AdditionalRunningSpeed = 0
+	c.GuildEffects()
 	c.ItemEffects(&temp, 0, 9)         // NORMAL ITEMS
 	c.ItemEffects(&temp, 307, 315)     // HT ITEMS
 	c.ItemEffects(&temp, 0x0B, 0x43)   // INV BUFFS1
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"sort"
+	"sync"
+)
+
+// ThreatTable keeps the threat of each character against a mob.
+type ThreatTable struct {
+	values map[int]int
+	mutex  sync.RWMutex
+}
+
+func (t *ThreatTable) Add(characterID, amount int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.values == nil {
+		t.values = make(map[int]int)
+	}
+	t.values[characterID] += amount
+}
+
+func (t *ThreatTable) Get(characterID int) int {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.values[characterID]
+}
+
+func (t *ThreatTable) Has(characterID int) bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	_, ok := t.values[characterID]
+	return ok
+}
+
+func (t *ThreatTable) Remove(characterID int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.values, characterID)
+}
+
+func (t *ThreatTable) Clear() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.values = nil
+}
+
+func (t *ThreatTable) Len() int {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return len(t.values)
+}
+
+// Sorted returns the character ids by descending threat.
+func (t *ThreatTable) Sorted() []int {
+	t.mutex.RLock()
+	ids := make([]int, 0, len(t.values))
+	for id := range t.values {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		if t.values[ids[i]] == t.values[ids[j]] {
+			return ids[i] < ids[j]
+		}
+		return t.values[ids[i]] > t.values[ids[j]]
+	})
+	t.mutex.RUnlock()
+
+	return ids
+}
+
+// AddHealThreat gives the healer half of the healed amount as threat on every mob fighting the target.
+func AddHealThreat(healer, target *Character, amount int) {
+	if amount <= 0 || healer.Socket == nil || healer.Socket.User == nil {
+		return
+	}
+
+	server := healer.Socket.User.ConnectedServer
+	for _, ai := range MapAIs(server, healer.Map) {
+		if ai.State != AI_STATE_RESET && ai.HP > 0 && ai.Threat.Has(target.ID) {
+			ai.Threat.Add(healer.ID, amount/2+1)
+		}
+	}
+}
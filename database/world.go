@@ -0,0 +1,347 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"hero-emulator/config"
+)
+
+const (
+	PHASE_TIMERS = iota
+	PHASE_MOBS
+	PHASE_CHARACTERS
+	PHASE_PETS
+	PHASE_BUFFS
+	phaseCount
+)
+
+var (
+	Worlds      = make(map[int]*World)
+	worldsMutex sync.RWMutex
+
+	phaseNames = [phaseCount]string{"timers", "mobs", "characters", "pets", "buffs"}
+)
+
+// World runs the game loop of a server. Every tick runs the due timers, then the mobs, characters,
+// pets and buffs, each ordered by map and id.
+type World struct {
+	Server int
+	Stats  TickStats
+
+	tick       uint64
+	timerSeq   uint64
+	timers     []*worldTimer
+	buffChecks map[int]bool // characters whose buffs are being checked off the tick
+	mutex      sync.Mutex
+	stop       chan struct{}
+}
+
+// TickStats measures the ticks of a world, Drift is how late a tick started.
+type TickStats struct {
+	Ticks     uint64
+	Overruns  uint64
+	Last      time.Duration
+	Max       time.Duration
+	Average   time.Duration
+	Drift     time.Duration
+	MaxDrift  time.Duration
+	Phases    [phaseCount]time.Duration
+	Mobs      int
+	Players   int
+	lastLogAt time.Time
+}
+
+type worldTimer struct {
+	due uint64
+	seq uint64
+	fn  func()
+}
+
+func tickInterval() time.Duration {
+	return time.Duration(config.Default.World.TickMillis) * time.Millisecond
+}
+
+// StartWorlds starts the game loop of every server.
+func StartWorlds() {
+	worldsMutex.Lock()
+	defer worldsMutex.Unlock()
+
+	for s := 1; s <= SERVER_COUNT; s++ {
+		if _, ok := Worlds[s]; ok {
+			continue
+		}
+
+		w := &World{Server: s, buffChecks: make(map[int]bool), stop: make(chan struct{})}
+		Worlds[s] = w
+		go w.Run()
+	}
+}
+
+func FindWorld(server int) *World {
+	worldsMutex.RLock()
+	defer worldsMutex.RUnlock()
+	return Worlds[server]
+}
+
+// AfterTicks runs fn on the world of the server after d, or with time.AfterFunc if the world is not running.
+func AfterTicks(server int, d time.Duration, fn func()) {
+	if w := FindWorld(server); w != nil {
+		w.After(d, fn)
+		return
+	}
+	time.AfterFunc(d, fn)
+}
+
+// After runs fn in the timer phase of the first tick after d.
+func (w *World) After(d time.Duration, fn func()) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	ticks := uint64((d + tickInterval() - 1) / tickInterval())
+	if ticks == 0 {
+		ticks = 1
+	}
+
+	w.timerSeq++
+	w.timers = append(w.timers, &worldTimer{due: w.tick + ticks, seq: w.timerSeq, fn: fn})
+}
+
+func (w *World) Stop() {
+	close(w.stop)
+}
+
+func (w *World) Run() {
+	ticker := time.NewTicker(tickInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case scheduled := <-ticker.C:
+			w.guard("tick", 0, func() {
+				w.Tick(scheduled)
+			})
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// Tick runs a single tick of the world, scheduled is the time the tick was due.
+func (w *World) Tick(scheduled time.Time) {
+
+	dataMutex.RLock() // a reload waits for the tick
+	defer dataMutex.RUnlock()
+
+	start := time.Now()
+	phases := [phaseCount]time.Duration{}
+	measure := func(phase int, fn func()) {
+		t := time.Now()
+		fn()
+		phases[phase] = time.Since(t)
+	}
+
+	w.mutex.Lock()
+	w.tick++
+	w.mutex.Unlock()
+
+	measure(PHASE_TIMERS, w.runTimers)
+
+	mobs := w.mobs()
+	measure(PHASE_MOBS, func() {
+		for _, ai := range mobs {
+			w.guard("mob", ai.ID, ai.AIHandler)
+		}
+	})
+
+	players := charactersOfServer(w.Server)
+	measure(PHASE_CHARACTERS, func() {
+		for _, c := range players {
+			if handler := c.HandlerCB; handler != nil {
+				w.guard("character", c.ID, handler)
+			}
+			if explore := c.ExploreWorld; explore != nil {
+				w.guard("character", c.ID, explore)
+			}
+		}
+	})
+
+	measure(PHASE_PETS, func() {
+		for _, c := range players {
+			if handler := c.PetHandlerCB; handler != nil {
+				w.guard("pet of character", c.ID, handler)
+			}
+		}
+	})
+
+	measure(PHASE_BUFFS, func() {
+		for _, c := range players {
+			if c.HandlerCB != nil {
+				w.handleBuffs(c)
+			}
+		}
+	})
+
+	w.record(start, scheduled, phases, len(mobs), len(players))
+}
+
+func (w *World) runTimers() {
+	w.mutex.Lock()
+	due, rest := []*worldTimer{}, w.timers[:0]
+	for _, t := range w.timers {
+		if t.due <= w.tick {
+			due = append(due, t)
+		} else {
+			rest = append(rest, t)
+		}
+	}
+	w.timers = rest
+	w.mutex.Unlock()
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].seq < due[j].seq
+	})
+
+	for _, t := range due {
+		w.guard("timer", int(t.seq), t.fn)
+	}
+}
+
+// guard runs fn and recovers its panic, a failing mob, character or timer must not stop the world.
+func (w *World) guard(kind string, id int, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("world %d: %s %d panicked: %v\n%s", w.Server, kind, id, r, debug.Stack())
+		}
+	}()
+	fn()
+}
+
+// handleBuffs checks the buffs of the character off the tick as they are read from the database, a character is
+// checked again only after its previous check finished.
+func (w *World) handleBuffs(c *Character) {
+	w.mutex.Lock()
+	if w.buffChecks[c.ID] {
+		w.mutex.Unlock()
+		return
+	}
+	w.buffChecks[c.ID] = true
+	w.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			w.mutex.Lock()
+			delete(w.buffChecks, c.ID)
+			w.mutex.Unlock()
+		}()
+		w.guard("buffs of character", c.ID, c.HandleBuffs)
+	}()
+}
+
+// mobs returns the running mobs of the world ordered by map and id, followed by the mobs of the instances.
+func (w *World) mobs() []*AI {
+	if w.Server >= len(AIsByMap) {
+		return nil
+	}
+
+	aiMutex.RLock()
+	maps := []int{}
+	for m := range AIsByMap[w.Server] {
+		maps = append(maps, int(m))
+	}
+	sort.Ints(maps)
+
+	mobs := []*AI{}
+	for _, m := range maps {
+		for _, ai := range AIsByMap[w.Server][int16(m)] {
+			if ai.Handler != nil && ai.WalkingSpeed > 0 {
+				mobs = append(mobs, ai)
+			}
+		}
+	}
+	aiMutex.RUnlock()
+
+	sort.SliceStable(mobs, func(i, j int) bool {
+		if mobs[i].Map == mobs[j].Map {
+			return mobs[i].ID < mobs[j].ID
+		}
+		return mobs[i].Map < mobs[j].Map
+	})
+
+	return append(mobs, instanceMobs(w.Server)...)
+}
+
+func charactersOfServer(server int) []*Character {
+	characterMutex.RLock()
+	result := []*Character{}
+	for _, c := range characters {
+		if c.IsOnline && c.Socket != nil && c.Socket.User != nil && c.Socket.User.ConnectedServer == server {
+			result = append(result, c)
+		}
+	}
+	characterMutex.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result
+}
+
+func (w *World) record(start, scheduled time.Time, phases [phaseCount]time.Duration, mobs, players int) {
+
+	took := time.Since(start)
+	drift := start.Sub(scheduled)
+	budget := time.Duration(config.Default.World.BudgetMillis) * time.Millisecond
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	st := &w.Stats
+	st.Ticks++
+	st.Last, st.Drift, st.Phases, st.Mobs, st.Players = took, drift, phases, mobs, players
+	if took > st.Max {
+		st.Max = took
+	}
+	if drift > st.MaxDrift {
+		st.MaxDrift = drift
+	}
+	st.Average += (took - st.Average) / 16 // moving average of the last ticks
+
+	if budget > 0 && took > budget {
+		st.Overruns++
+		if time.Since(st.lastLogAt) >= 10*time.Second {
+			st.lastLogAt = time.Now()
+			log.Printf("world %d tick %d took %s (budget %s): %s", w.Server, w.tick, took, budget, st.phaseLine())
+		}
+	}
+}
+
+func (st *TickStats) phaseLine() string {
+	line := ""
+	for i, d := range st.Phases {
+		if i > 0 {
+			line += ", "
+		}
+		line += fmt.Sprintf("%s %s", phaseNames[i], d)
+	}
+	return line
+}
+
+// Lines describes the tick stats of the world.
+func (w *World) Lines() []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	st := w.Stats
+	return []string{
+		fmt.Sprintf("Server %d: %d ticks, %d mobs, %d players", w.Server, st.Ticks, st.Mobs, st.Players),
+		fmt.Sprintf("Tick: last %s, avg %s, max %s, %d overruns", st.Last, st.Average, st.Max, st.Overruns),
+		fmt.Sprintf("Drift: last %s, max %s", st.Drift, st.MaxDrift),
+		st.phaseLine(),
+	}
+}
@@ -0,0 +1,127 @@
+package dropsim
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"hero-emulator/database"
+)
+
+// Options describes a simulation of Kills kills of a single npc.
+type Options struct {
+	NPCID          int
+	Kills          int
+	Map            int16
+	DropRate       float64 // server drop rate, 0 keeps database.DROP_RATE
+	DropMultiplier float64 // claimer drop multiplier including buffs
+	Pickaxe        bool
+	Attackable     bool
+	KillsPerHour   float64
+	Seed           int64
+}
+
+type ItemRate struct {
+	ItemID  int
+	Name    string
+	Count   int
+	PerKill float64
+}
+
+type Report struct {
+	NPC         *database.NPC
+	Kills       int
+	Items       []*ItemRate
+	TotalItems  int // dropped stacks, Count of the items includes quantities
+	Gold        uint64
+	GoldPerKill float64
+	GoldPerHour float64
+	MaxDepth    int
+	Depths      map[int]int // kills by deepest nested drop table
+}
+
+// Simulate rolls the drops of the npc through database.RollDrops, the same code path the server uses on kills.
+// The data tables have to be loaded before, e.g. with database.InitDataDB.
+func Simulate(opts *Options) (*Report, error) {
+
+	npc, ok := database.NPCs[opts.NPCID]
+	if !ok {
+		return nil, fmt.Errorf("unknown npc %d", opts.NPCID)
+	} else if opts.Kills <= 0 {
+		return nil, fmt.Errorf("kill count must be positive")
+	}
+
+	if opts.DropRate > 0 {
+		rate := database.DROP_RATE
+		database.DROP_RATE = opts.DropRate
+		defer func() { database.DROP_RATE = rate }()
+	}
+
+	if opts.Seed != 0 {
+		rand.Seed(opts.Seed)
+	}
+
+	mod := &database.DropModifiers{Map: opts.Map, Attackable: opts.Attackable, DropMultiplier: opts.DropMultiplier, Pickaxe: opts.Pickaxe}
+	report := &Report{NPC: npc, Kills: opts.Kills, Depths: make(map[int]int)}
+	counts := make(map[int]int)
+
+	for i := 0; i < opts.Kills; i++ {
+		roll := database.RollDrops(npc, mod)
+		for _, entry := range roll.Items {
+			counts[entry.ItemID] += int(entry.RollQuantity())
+		}
+
+		report.TotalItems += len(roll.Items)
+		report.Depths[roll.Depth]++
+		if roll.Depth > report.MaxDepth {
+			report.MaxDepth = roll.Depth
+		}
+
+		report.Gold += database.RollGold(npc)
+	}
+
+	for id, count := range counts {
+		rate := &ItemRate{ItemID: id, Count: count, PerKill: float64(count) / float64(opts.Kills)}
+		if item, ok := database.Items[int64(id)]; ok {
+			rate.Name = item.Name
+		}
+		report.Items = append(report.Items, rate)
+	}
+
+	sort.Slice(report.Items, func(i, j int) bool {
+		if report.Items[i].Count == report.Items[j].Count {
+			return report.Items[i].ItemID < report.Items[j].ItemID
+		}
+		return report.Items[i].Count > report.Items[j].Count
+	})
+
+	report.GoldPerKill = float64(report.Gold) / float64(opts.Kills)
+	report.GoldPerHour = report.GoldPerKill * opts.KillsPerHour
+	return report, nil
+}
+
+func (r *Report) Lines() []string {
+
+	lines := []string{
+		fmt.Sprintf("%s (%d), %d kills", r.NPC.Name, r.NPC.ID, r.Kills),
+		fmt.Sprintf("Items: %d (%.3f per kill)", r.TotalItems, float64(r.TotalItems)/float64(r.Kills)),
+		fmt.Sprintf("Gold: %.1f per kill, %.0f per hour", r.GoldPerKill, r.GoldPerHour),
+	}
+
+	depths := []int{}
+	for depth := range r.Depths {
+		depths = append(depths, depth)
+	}
+	sort.Ints(depths)
+
+	lines = append(lines, fmt.Sprintf("Max recursion depth: %d", r.MaxDepth))
+	for _, depth := range depths {
+		lines = append(lines, fmt.Sprintf("  depth %d: %d kills", depth, r.Depths[depth]))
+	}
+
+	for _, item := range r.Items {
+		lines = append(lines, fmt.Sprintf("%10d %-32s %8d %8.4f%%", item.ItemID, item.Name, item.Count, item.PerKill*100))
+	}
+
+	return lines
+}
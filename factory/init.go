@@ -60,6 +60,8 @@ var (
 		20994: &player.RespondPartyRequestHandler{},
 		20995: &player.LeavePartyHandler{},
 		20998: &player.ExpelFromPartyHandler{},
+		21000: &player.ChangeLootModeHandler{},
+		21001: &player.LootRollHandler{},
 		21249: &player.SendTradeRequestHandler{},
 		21250: &player.RespondTradeRequestHandler{},
 		21251: &player.CancelTradeHandler{},
@@ -23,6 +23,7 @@ import (
 	"hero-emulator/nats"
 	"hero-emulator/redis"
 
+	NATS "github.com/nats-io/nats.go"
 	"github.com/robfig/cron"
 	"github.com/thoas/go-funk"
 )
@@ -108,15 +109,30 @@ func main() {
 	cronHandler()
 	ai.Init()
 	go database.UnbanUsers()
-	s := nats.RunServer(nil)
-	defer s.Shutdown()
-	c, err := nats.ConnectSelf(nil)
+	go database.ExpireMails()
+	go database.RunEventScheduler()
+	go database.RunChatLogger()
+	var (
+		c   *NATS.Conn
+		err error
+	)
+	if url := config.Default.Nats.URL; url != "" {
+		c, err = nats.Connect(url)
+	} else { // single process
+		s := nats.RunServer(nil)
+		defer s.Shutdown()
+		c, err = nats.ConnectSelf(nil)
+	}
 	defer c.Close()
 
 	if err != nil {
 		log.Fatalln(err)
 	}
 
+	if err := database.SubscribeChat(); err != nil {
+		log.Fatalln(err)
+	}
+
 	go api.InitGRPC()
 	startServer()
 }
@@ -178,7 +194,7 @@ func StartLogging() {
 }
 
 func createServerMobs(server int) {
-	aiSet := funk.Filter(funk.Values(database.AIs), func(ai *database.AI) bool {
+	aiSet := funk.Filter(database.AllAIs(), func(ai *database.AI) bool {
 		return ai.Server == 1
 	}).([]*database.AI)
 
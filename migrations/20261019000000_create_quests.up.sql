@@ -0,0 +1,28 @@
+CREATE TABLE data.quests (
+	id int4 NOT NULL,
+	"name" text NOT NULL,
+	npc_id int4 NOT NULL DEFAULT 0,
+	min_level int4 NOT NULL DEFAULT 0,
+	max_level int4 NOT NULL DEFAULT 0,
+	prerequisite int4 NOT NULL DEFAULT 0,
+	repeatable bool NOT NULL DEFAULT false,
+	objectives jsonb NOT NULL DEFAULT '[]'::jsonb,
+	reward_exp int8 NOT NULL DEFAULT 0,
+	reward_gold int8 NOT NULL DEFAULT 0,
+	reward_items jsonb NOT NULL DEFAULT '[]'::jsonb,
+	CONSTRAINT quests_pkey PRIMARY KEY (id)
+);
+
+CREATE TABLE hops.characters_quests (
+	id serial NOT NULL,
+	character_id int4 NOT NULL,
+	quest_id int4 NOT NULL,
+	progress jsonb NOT NULL DEFAULT '[]'::jsonb,
+	is_completed bool NOT NULL DEFAULT false,
+	accepted_at timestamptz NULL,
+	completed_at timestamptz NULL,
+	CONSTRAINT characters_quests_pkey PRIMARY KEY (id),
+	CONSTRAINT characters_quests_character_id_quest_id_key UNIQUE (character_id, quest_id)
+);
+ALTER TABLE hops.characters_quests ADD CONSTRAINT characters_quests_character_id_fkey FOREIGN KEY (character_id) REFERENCES hops."characters"(id) ON DELETE CASCADE;
+ALTER TABLE hops.characters_quests ADD CONSTRAINT characters_quests_quest_id_fkey FOREIGN KEY (quest_id) REFERENCES data.quests(id);
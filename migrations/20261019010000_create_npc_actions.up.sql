@@ -0,0 +1,93 @@
+CREATE TABLE data.npc_actions (
+	id int4 NOT NULL,
+	npc_id int4 NOT NULL DEFAULT 0,
+	"type" text NOT NULL,
+	params jsonb NOT NULL DEFAULT '{}'::jsonb,
+	min_level int4 NOT NULL DEFAULT 0,
+	max_level int4 NOT NULL DEFAULT 0,
+	faction int4 NOT NULL DEFAULT 0,
+	required_items _int8 NOT NULL DEFAULT '{}'::bigint[],
+	item_activated bool NOT NULL DEFAULT false,
+	consume_item bool NOT NULL DEFAULT false,
+	gold_cost int8 NOT NULL DEFAULT 0,
+	fail_text int4 NOT NULL DEFAULT 0,
+	CONSTRAINT npc_actions_pkey PRIMARY KEY (id, npc_id)
+);
+
+INSERT INTO data.npc_actions (id, npc_id, "type", params) VALUES
+	(1, 0, 'open-shop', '{}'),
+	(2, 0, 'open-menu', '{"menu": "composition"}'),
+	(4, 0, 'open-menu', '{"menu": "strengthen"}'),
+	(6, 0, 'open-menu', '{"menu": "bank"}'),
+	(64, 0, 'open-menu', '{"menu": "guild"}'),
+	(194, 0, 'open-menu', '{"menu": "dismantle"}'),
+	(195, 0, 'open-menu', '{"menu": "extraction"}'),
+	(559, 0, 'open-menu', '{"menu": "adv-fusion"}'),
+	(631, 0, 'open-menu', '{"menu": "tactical-space"}'),
+	(737, 0, 'open-menu', '{"menu": "create-socket"}'),
+	(738, 0, 'open-menu', '{"menu": "upgrade-socket"}'),
+	(739, 0, 'open-menu', '{"menu": "co-production"}'),
+	(906, 0, 'open-menu', '{"menu": "appearance"}'),
+	(970, 0, 'open-menu', '{"menu": "consignment"}'),
+	(3230, 0, 'open-menu', '{"menu": "high-synthetic"}'),
+	(3231, 0, 'open-menu', '{"menu": "synthesis"}'),
+	(3318, 0, 'open-menu', '{"menu": "co-production"}'),
+	(3426, 0, 'open-menu', '{"menu": "disc-item"}'),
+	(3930, 0, 'open-menu', '{"menu": "marbles"}'),
+	(13, 20415, 'teleport', '{"map": 254}'),
+	(78, 0, 'teleport', '{"map": 1}'),
+	(86, 0, 'teleport', '{"map": 5}'),
+	(103, 0, 'teleport', '{"map": 2}'),
+	(104, 0, 'teleport', '{"map": 3}'),
+	(106, 0, 'teleport', '{"map": 11}'),
+	(706, 0, 'teleport', '{"map": 46}'),
+	(3106, 0, 'teleport', '{"map": 40}'),
+	(3107, 0, 'teleport', '{"map": 44}'),
+	(3110, 0, 'teleport', '{"map": 41}'),
+	(3307, 0, 'teleport', '{"map": 231}'),
+	(3322, 0, 'teleport', '{"map": 37, "x": 567, "y": 747, "factions": [1, 2]}'),
+	(4171, 0, 'teleport', '{"map": 71}'),
+	(4190, 0, 'teleport', '{"map": 70}'),
+	(5214, 0, 'teleport', '{"dk_map": 0}'),
+	(197101, 0, 'teleport', '{"map": 254}');
+
+INSERT INTO data.npc_actions (id, npc_id, "type", params, min_level, max_level) VALUES
+	(742, 0, 'teleport', '{"map": 93}', 0, 100),
+	(743, 0, 'teleport', '{"map": 94}', 0, 100),
+	(744, 0, 'teleport', '{"map": 95}', 0, 100),
+	(745, 0, 'teleport', '{"map": 96}', 101, 0),
+	(746, 0, 'teleport', '{"map": 97}', 101, 0),
+	(747, 0, 'teleport', '{"map": 98}', 101, 0),
+	(748, 0, 'teleport', '{"map": 99}', 101, 0),
+	(3233, 0, 'teleport', '{"map": 100}', 0, 100),
+	(3235, 0, 'teleport', '{"map": 101}', 101, 0);
+
+INSERT INTO data.npc_actions (id, npc_id, "type", params, required_items, item_activated, consume_item, fail_text) VALUES
+	(5215, 0, 'teleport', '{"dk_map": 1}', '{15700040,15710087}', true, false, 999993),
+	(7312, 0, 'teleport', '{"dk_map": 2}', '{15710087}', true, false, 999993),
+	(3315, 0, 'teleport', '{"map": 244}', '{99002341}', false, true, 23740),
+	(3316, 0, 'teleport', '{"map": 151}', '{990029421}', false, true, 23740),
+	(77, 0, 'teleport', '{"map": 152}', '{990029422}', false, true, 23740);
+
+INSERT INTO data.npc_actions (id, npc_id, "type", params, min_level) VALUES
+	(13, 20006, 'change-class', '{"class": 13, "from": [0], "items": [16210003]}', 10),
+	(13, 20020, 'change-class', '{"class": 11, "from": [0], "items": [16210001]}', 10),
+	(13, 20021, 'change-class', '{"class": 12, "from": [0], "items": [16210002]}', 10),
+	(13, 20022, 'change-class', '{"class": 14, "from": [0], "items": [16210004]}', 10),
+	(148, 0, 'change-class', '{"class": 21, "from": [11], "items": [16100039, 16100200]}', 50),
+	(149, 0, 'change-class', '{"class": 22, "from": [11], "items": [16100040, 16100200]}', 50),
+	(151, 0, 'change-class', '{"class": 23, "from": [12], "items": [16100041, 16100200]}', 50),
+	(152, 0, 'change-class', '{"class": 24, "from": [12], "items": [16100042, 16100200]}', 50),
+	(154, 0, 'change-class', '{"class": 27, "from": [14], "items": [16100043, 16100200]}', 50),
+	(155, 0, 'change-class', '{"class": 28, "from": [14], "items": [16100044, 16100200]}', 50),
+	(157, 0, 'change-class', '{"class": 25, "from": [13], "items": [16100045, 16100200]}', 50),
+	(158, 0, 'change-class', '{"class": 26, "from": [13], "items": [16100046, 16100200]}', 50),
+	(3309, 0, 'change-class', '{"class": 41, "from_min": 30, "from_max": 39, "items": [100030020, 100030021, 100032001], "name": "God of War"}', 0),
+	(3310, 0, 'change-class', '{"class": 42, "from_min": 30, "from_max": 39, "items": [100030022, 100030023, 100032002], "name": "God of Death"}', 0),
+	(3311, 0, 'change-class', '{"class": 43, "from_min": 30, "from_max": 39, "items": [100030024, 100030025, 100032003], "name": "God of Blade"}', 0);
+
+INSERT INTO data.npc_actions (id, npc_id, "type", params, gold_cost) VALUES
+	(3306, 0, 'charge-gold', '{"items": [{"item_id": 13000170, "quantity": 120}], "unique": true}', 20000000),
+	(3319, 0, 'charge-gold', '{"items": [{"item_id": 13000074, "quantity": 240}], "unique": true}', 40000000),
+	(3320, 0, 'charge-gold', '{"items": [{"item_id": 13000173, "quantity": 480}], "unique": true}', 180000000),
+	(3321, 0, 'charge-gold', '{"items": [{"item_id": 23000141, "quantity": 960}], "unique": true}', 11195000000);
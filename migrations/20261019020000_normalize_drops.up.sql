@@ -0,0 +1,26 @@
+CREATE TABLE data.drop_items (
+	id serial NOT NULL,
+	drop_id int4 NOT NULL,
+	item_id int8 NOT NULL DEFAULT 0,
+	weight int4 NOT NULL,
+	nested_drop int4 NOT NULL DEFAULT 0,
+	min_quantity int4 NOT NULL DEFAULT 1,
+	max_quantity int4 NOT NULL DEFAULT 1,
+	CONSTRAINT drop_items_pkey PRIMARY KEY (id)
+);
+ALTER TABLE data.drop_items ADD CONSTRAINT drop_items_drop_id_fkey FOREIGN KEY (drop_id) REFERENCES data.drops(id) ON DELETE CASCADE;
+CREATE INDEX drop_items_drop_id_idx ON data.drop_items USING btree (drop_id);
+
+INSERT INTO data.drop_items (drop_id, item_id, weight, nested_drop)
+SELECT d.id,
+	CASE WHEN n.id IS NULL THEN e.item ELSE 0 END,
+	e.prob,
+	COALESCE(n.id, 0)
+FROM data.drops d
+CROSS JOIN LATERAL unnest(d.items, d.probabilities) WITH ORDINALITY AS e(item, prob, idx)
+LEFT JOIN data.drops n ON n.id = e.item
+WHERE e.item IS NOT NULL AND e.prob IS NOT NULL
+ORDER BY d.id, e.idx;
+
+ALTER TABLE data.drops DROP COLUMN items;
+ALTER TABLE data.drops DROP COLUMN probabilities;
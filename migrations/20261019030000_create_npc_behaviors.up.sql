@@ -0,0 +1,17 @@
+CREATE TABLE data.npc_behaviors (
+	npc_id int4 NOT NULL,
+	archetype text NOT NULL DEFAULT 'melee',
+	aggressive bool NOT NULL DEFAULT true,
+	aggro_range float8 NOT NULL DEFAULT 0,
+	attack_range float8 NOT NULL DEFAULT 0,
+	keep_distance float8 NOT NULL DEFAULT 0,
+	chase_range float8 NOT NULL DEFAULT 0,
+	leash_range float8 NOT NULL DEFAULT 0,
+	assist_range float8 NOT NULL DEFAULT 0,
+	flee_hp int4 NOT NULL DEFAULT 0,
+	regen_percent int4 NOT NULL DEFAULT 0,
+	skill_chance int4 NOT NULL DEFAULT 0,
+	CONSTRAINT npc_behaviors_pkey PRIMARY KEY (npc_id)
+);
+
+ALTER TABLE data.npc_behaviors ADD CONSTRAINT npc_behaviors_npc_id_fkey FOREIGN KEY (npc_id) REFERENCES data.npc_table(id) ON DELETE CASCADE;
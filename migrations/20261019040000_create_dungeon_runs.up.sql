@@ -0,0 +1,26 @@
+CREATE TABLE hops.dungeon_runs (
+	id serial NOT NULL,
+	dungeon_id int4 NOT NULL,
+	server int4 NOT NULL DEFAULT 0,
+	"result" text NOT NULL,
+	started_at timestamptz NOT NULL,
+	ended_at timestamptz NOT NULL,
+	duration int4 NOT NULL DEFAULT 0,
+	kills int4 NOT NULL DEFAULT 0,
+	deaths int4 NOT NULL DEFAULT 0,
+	damage int8 NOT NULL DEFAULT 0,
+	CONSTRAINT dungeon_runs_pkey PRIMARY KEY (id)
+);
+CREATE INDEX dungeon_runs_dungeon_id_result_duration_idx ON hops.dungeon_runs USING btree (dungeon_id, "result", duration);
+
+CREATE TABLE hops.dungeon_run_members (
+	run_id int4 NOT NULL,
+	character_id int4 NOT NULL,
+	"name" text NOT NULL,
+	kills int4 NOT NULL DEFAULT 0,
+	deaths int4 NOT NULL DEFAULT 0,
+	damage int8 NOT NULL DEFAULT 0,
+	CONSTRAINT dungeon_run_members_pkey PRIMARY KEY (run_id, character_id)
+);
+ALTER TABLE hops.dungeon_run_members ADD CONSTRAINT dungeon_run_members_run_id_fkey FOREIGN KEY (run_id) REFERENCES hops.dungeon_runs(id) ON DELETE CASCADE;
+ALTER TABLE hops.dungeon_run_members ADD CONSTRAINT dungeon_run_members_character_id_fkey FOREIGN KEY (character_id) REFERENCES hops."characters"(id) ON DELETE CASCADE;
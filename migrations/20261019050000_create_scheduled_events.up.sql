@@ -0,0 +1,12 @@
+CREATE TABLE hops.scheduled_events (
+	id serial NOT NULL,
+	"type" text NOT NULL,
+	"name" text NOT NULL,
+	schedule text NOT NULL,
+	duration int4 NOT NULL DEFAULT 0,
+	announce int4 NOT NULL DEFAULT 0,
+	params jsonb NOT NULL DEFAULT '{}'::jsonb,
+	paused bool NOT NULL DEFAULT false,
+	last_run timestamptz NULL,
+	CONSTRAINT scheduled_events_pkey PRIMARY KEY (id)
+);
@@ -0,0 +1,38 @@
+CREATE TABLE hops.guild_members (
+	character_id int4 NOT NULL,
+	guild_id int4 NOT NULL,
+	"role" int2 NOT NULL DEFAULT 1,
+	joined_at timestamptz NOT NULL DEFAULT now(),
+	contribution int8 NOT NULL DEFAULT 0,
+	last_online timestamptz NULL,
+	CONSTRAINT guild_members_pkey PRIMARY KEY (character_id)
+);
+ALTER TABLE hops.guild_members ADD CONSTRAINT guild_members_guild_id_fkey FOREIGN KEY (guild_id) REFERENCES hops.guilds(id) ON DELETE CASCADE;
+ALTER TABLE hops.guild_members ADD CONSTRAINT guild_members_character_id_fkey FOREIGN KEY (character_id) REFERENCES hops."characters"(id) ON DELETE CASCADE;
+CREATE INDEX guild_members_guild_id_idx ON hops.guild_members USING btree (guild_id);
+
+CREATE TABLE hops.guild_logs (
+	id serial NOT NULL,
+	guild_id int4 NOT NULL,
+	character_id int4 NOT NULL,
+	"name" text NOT NULL DEFAULT ''::text,
+	actor_id int4 NOT NULL DEFAULT 0,
+	actor_name text NOT NULL DEFAULT ''::text,
+	"action" text NOT NULL,
+	"role" int2 NOT NULL DEFAULT 0,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT guild_logs_pkey PRIMARY KEY (id)
+);
+ALTER TABLE hops.guild_logs ADD CONSTRAINT guild_logs_guild_id_fkey FOREIGN KEY (guild_id) REFERENCES hops.guilds(id) ON DELETE CASCADE;
+CREATE INDEX guild_logs_guild_id_created_at_idx ON hops.guild_logs USING btree (guild_id, created_at);
+
+INSERT INTO hops.guild_members (character_id, guild_id, "role")
+SELECT DISTINCT ON ((m->>'id')::int4) (m->>'id')::int4, g.id, COALESCE((m->>'role')::int2, 1)
+FROM hops.guilds g
+CROSS JOIN LATERAL jsonb_array_elements(CASE WHEN jsonb_typeof(g.members) = 'array' THEN g.members ELSE '[]'::jsonb END) AS m
+JOIN hops."characters" c ON c.id = (m->>'id')::int4 AND c.guild_id = g.id
+ORDER BY (m->>'id')::int4, g.id;
+
+UPDATE hops.guilds g SET member_count = (SELECT count(*) FROM hops.guild_members m WHERE m.guild_id = g.id);
+
+ALTER TABLE hops.guilds DROP COLUMN members;
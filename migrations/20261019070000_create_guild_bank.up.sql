@@ -0,0 +1,20 @@
+ALTER TABLE hops.guilds ADD COLUMN bank_gold int8 NOT NULL DEFAULT 0;
+
+ALTER TABLE hops.items_characters ADD COLUMN guild_id int4 NULL;
+ALTER TABLE hops.items_characters ADD CONSTRAINT items_characters_guild_id_fkey FOREIGN KEY (guild_id) REFERENCES hops.guilds(id) ON DELETE CASCADE;
+CREATE INDEX items_characters_guild_id_idx ON hops.items_characters USING btree (guild_id);
+
+CREATE TABLE hops.guild_bank_logs (
+	id serial NOT NULL,
+	guild_id int4 NOT NULL,
+	character_id int4 NOT NULL,
+	"name" text NOT NULL DEFAULT ''::text,
+	"action" text NOT NULL,
+	item_id int8 NOT NULL DEFAULT 0,
+	quantity int4 NOT NULL DEFAULT 0,
+	gold int8 NOT NULL DEFAULT 0,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT guild_bank_logs_pkey PRIMARY KEY (id)
+);
+ALTER TABLE hops.guild_bank_logs ADD CONSTRAINT guild_bank_logs_guild_id_fkey FOREIGN KEY (guild_id) REFERENCES hops.guilds(id) ON DELETE CASCADE;
+CREATE INDEX guild_bank_logs_guild_id_created_at_idx ON hops.guild_bank_logs USING btree (guild_id, created_at);
@@ -0,0 +1,29 @@
+CREATE TABLE hops.guild_wars (
+	id serial NOT NULL,
+	challenger_id int4 NOT NULL,
+	enemy_id int4 NOT NULL,
+	state text NOT NULL,
+	duration int4 NOT NULL,
+	stakes int8 NOT NULL DEFAULT 0,
+	challenger_score int4 NOT NULL DEFAULT 0,
+	enemy_score int4 NOT NULL DEFAULT 0,
+	winner_id int4 NULL,
+	declared_at timestamptz NOT NULL DEFAULT now(),
+	started_at timestamptz NULL,
+	ends_at timestamptz NULL,
+	finished_at timestamptz NULL,
+	CONSTRAINT guild_wars_pkey PRIMARY KEY (id)
+);
+ALTER TABLE hops.guild_wars ADD CONSTRAINT guild_wars_challenger_id_fkey FOREIGN KEY (challenger_id) REFERENCES hops.guilds(id) ON DELETE CASCADE;
+ALTER TABLE hops.guild_wars ADD CONSTRAINT guild_wars_enemy_id_fkey FOREIGN KEY (enemy_id) REFERENCES hops.guilds(id) ON DELETE CASCADE;
+CREATE INDEX guild_wars_challenger_id_idx ON hops.guild_wars USING btree (challenger_id);
+CREATE INDEX guild_wars_enemy_id_idx ON hops.guild_wars USING btree (enemy_id);
+
+CREATE TABLE hops.guild_alliances (
+	guild_id int4 NOT NULL,
+	ally_id int4 NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT guild_alliances_pkey PRIMARY KEY (guild_id, ally_id)
+);
+ALTER TABLE hops.guild_alliances ADD CONSTRAINT guild_alliances_guild_id_fkey FOREIGN KEY (guild_id) REFERENCES hops.guilds(id) ON DELETE CASCADE;
+ALTER TABLE hops.guild_alliances ADD CONSTRAINT guild_alliances_ally_id_fkey FOREIGN KEY (ally_id) REFERENCES hops.guilds(id) ON DELETE CASCADE;
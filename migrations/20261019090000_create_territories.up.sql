@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS "data".fiveclan_war (
+	id int4 NOT NULL,
+	clanid int4 NOT NULL DEFAULT 0,
+	expires_at timestamptz NULL,
+	CONSTRAINT fiveclan_war_pkey PRIMARY KEY (id)
+);
+ALTER TABLE "data".fiveclan_war ADD COLUMN IF NOT EXISTS captured_at timestamptz NULL;
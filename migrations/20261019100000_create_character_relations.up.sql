@@ -0,0 +1,10 @@
+CREATE TABLE hops.character_relations (
+	character_id int4 NOT NULL,
+	target_id int4 NOT NULL,
+	type text NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT character_relations_pkey PRIMARY KEY (character_id, target_id)
+);
+ALTER TABLE hops.character_relations ADD CONSTRAINT character_relations_character_id_fkey FOREIGN KEY (character_id) REFERENCES hops.characters(id) ON DELETE CASCADE;
+ALTER TABLE hops.character_relations ADD CONSTRAINT character_relations_target_id_fkey FOREIGN KEY (target_id) REFERENCES hops.characters(id) ON DELETE CASCADE;
+CREATE INDEX character_relations_target_id_idx ON hops.character_relations USING btree (target_id);
@@ -0,0 +1,23 @@
+CREATE TABLE hops.mails (
+	id serial NOT NULL,
+	sender_id int4 NULL,
+	sender_name text NOT NULL DEFAULT ''::text,
+	receiver_id int4 NOT NULL,
+	"text" text NOT NULL DEFAULT ''::text,
+	gold int8 NOT NULL DEFAULT 0,
+	has_item bool NOT NULL DEFAULT false,
+	is_read bool NOT NULL DEFAULT false,
+	is_returned bool NOT NULL DEFAULT false,
+	claimed_at timestamptz NULL,
+	sent_at timestamptz NOT NULL DEFAULT now(),
+	expires_at timestamptz NOT NULL,
+	CONSTRAINT mails_pkey PRIMARY KEY (id)
+);
+ALTER TABLE hops.mails ADD CONSTRAINT mails_sender_id_fkey FOREIGN KEY (sender_id) REFERENCES hops.characters(id) ON DELETE SET NULL;
+ALTER TABLE hops.mails ADD CONSTRAINT mails_receiver_id_fkey FOREIGN KEY (receiver_id) REFERENCES hops.characters(id) ON DELETE CASCADE;
+CREATE INDEX mails_receiver_id_idx ON hops.mails USING btree (receiver_id);
+CREATE INDEX mails_expires_at_idx ON hops.mails USING btree (expires_at);
+
+ALTER TABLE hops.items_characters ADD COLUMN mail_id int4 NULL;
+ALTER TABLE hops.items_characters ADD CONSTRAINT items_characters_mail_id_fkey FOREIGN KEY (mail_id) REFERENCES hops.mails(id) ON DELETE CASCADE;
+CREATE INDEX items_characters_mail_id_idx ON hops.items_characters USING btree (mail_id);
@@ -0,0 +1,14 @@
+CREATE TABLE hops.chat_mutes (
+	id serial NOT NULL,
+	user_id text NOT NULL,
+	character_name text NOT NULL DEFAULT ''::text,
+	reason text NOT NULL DEFAULT ''::text,
+	issued_by text NOT NULL,
+	automatic bool NOT NULL DEFAULT false,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	expires_at timestamptz NULL,
+	lifted_at timestamptz NULL,
+	lifted_by text NULL,
+	CONSTRAINT chat_mutes_pkey PRIMARY KEY (id)
+);
+CREATE INDEX chat_mutes_user_id_created_at_idx ON hops.chat_mutes USING btree (user_id, created_at);
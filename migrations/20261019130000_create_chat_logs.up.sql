@@ -0,0 +1,33 @@
+CREATE TABLE hops.chat_logs (
+	id bigserial NOT NULL,
+	channel text NOT NULL,
+	"server" int4 NOT NULL DEFAULT 0,
+	"map" int2 NOT NULL DEFAULT 0,
+	sender_id int4 NOT NULL,
+	sender_name text NOT NULL,
+	receivers int4[] NOT NULL DEFAULT '{}'::integer[],
+	message text NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT chat_logs_pkey PRIMARY KEY (id)
+);
+CREATE INDEX chat_logs_sender_id_created_at_idx ON hops.chat_logs USING btree (sender_id, created_at);
+CREATE INDEX chat_logs_created_at_idx ON hops.chat_logs USING btree (created_at);
+
+CREATE TABLE hops.chat_reports (
+	id serial NOT NULL,
+	reporter_id int4 NOT NULL,
+	reporter_name text NOT NULL,
+	target_id int4 NOT NULL,
+	target_name text NOT NULL,
+	reason text NOT NULL,
+	context jsonb NOT NULL DEFAULT '[]'::jsonb,
+	status text NOT NULL DEFAULT 'open'::text,
+	handled_by text NULL,
+	"action" text NULL,
+	note text NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	handled_at timestamptz NULL,
+	CONSTRAINT chat_reports_pkey PRIMARY KEY (id)
+);
+CREATE INDEX chat_reports_status_created_at_idx ON hops.chat_reports USING btree (status, created_at);
+CREATE INDEX chat_reports_reporter_id_created_at_idx ON hops.chat_reports USING btree (reporter_id, created_at);
@@ -0,0 +1,4 @@
+INSERT INTO "data".buff_infections (id, "name", poison_def, paralysis_def, confusion_def, base_def, additional_def, arts_def,
+	additional_arts_def, max_hp, hp_recovery_rate, str, dex, "int", base_hp, additional_hp)
+VALUES (99001, 'House Blessing', 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+ON CONFLICT (id) DO NOTHING;
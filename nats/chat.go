@@ -0,0 +1,97 @@
+package nats
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	CHAT_GUILDS   = "chat.guild.*"
+	CHAT_FACTIONS = "chat.faction.*.*"
+	CHAT_SERVERS  = "chat.server.*"
+	CHAT_GLOBAL   = "chat.global"
+)
+
+// ChatMessage is a chat packet routed to the characters of every game process, the fields tell the receivers
+// of the subject it was sent to.
+type ChatMessage struct {
+	SenderID   int    `json:"sender_id"`
+	SenderName string `json:"sender_name"`
+	ExcludeID  int    `json:"exclude_id"` // the sender when it gets the message as the reply
+	Receiver   string `json:"receiver"`
+	GuildID    int    `json:"guild_id"`
+	Server     int    `json:"server"`
+	Faction    int    `json:"faction"`
+	Data       []byte `json:"data"`
+}
+
+// ChatAck is the reply of the process of a whispered character.
+type ChatAck struct {
+	CharacterID int  `json:"character_id"`
+	Delivered   bool `json:"delivered"`
+}
+
+func CharacterSubject(name string) string {
+	return "chat.character." + strings.ToLower(name)
+}
+
+func GuildSubject(guildID int) string {
+	return fmt.Sprintf("chat.guild.%d", guildID)
+}
+
+func FactionSubject(server, faction int) string {
+	return fmt.Sprintf("chat.faction.%d.%d", server, faction)
+}
+
+func ServerSubject(server int) string {
+	return fmt.Sprintf("chat.server.%d", server)
+}
+
+// Publish sends the message to the subject without waiting for the receivers.
+func (m *ChatMessage) Publish(subject string) error {
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return Connection().Publish(subject, data)
+}
+
+// Request sends the message to the subject and waits for the acknowledgement of the receiver, a nil ack means
+// nobody received it within the timeout.
+func (m *ChatMessage) Request(subject string, timeout time.Duration) (*ChatAck, error) {
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := Connection().Request(subject, data, timeout)
+	if err == nats.ErrTimeout {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	ack := &ChatAck{}
+	if err := json.Unmarshal(msg.Data, ack); err != nil {
+		return nil, err
+	}
+	return ack, nil
+}
+
+// Ack replies to the request of the message.
+func (a *ChatAck) Ack(msg *nats.Msg) error {
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	return msg.Respond(data)
+}
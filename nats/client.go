@@ -33,12 +33,17 @@ type CastPacket struct {
 }
 
 func ConnectSelf(opts *server.Options) (*nats.Conn, error) {
-	var err error
 	if opts == nil {
 		opts = &DefaultOptions
 	}
 
 	url := fmt.Sprintf("nats://%s:%d", opts.Host, opts.Port)
+	return Connect(url)
+}
+
+// Connect connects to the server shared by the game processes.
+func Connect(url string) (*nats.Conn, error) {
+	var err error
 	conn, err = nats.Connect(url, nats.Timeout(5*time.Second))
 	if err != nil {
 		return nil, err
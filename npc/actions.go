@@ -0,0 +1,364 @@
+package npc
+
+import (
+	"fmt"
+	"log"
+
+	"hero-emulator/database"
+	"hero-emulator/messaging"
+	"hero-emulator/utils"
+
+	"github.com/thoas/go-funk"
+)
+
+// ActionHandler executes a data defined npc action, costs of the action are only paid when ok is true.
+type ActionHandler func(s *database.Socket, npcID int, action *database.NPCAction) (resp []byte, ok bool, err error)
+
+type teleportParams struct {
+	Map      int16   `json:"map"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	DKMap    *int    `json:"dk_map"`
+	Factions []int   `json:"factions"` // the factions which can use the teleport, empty lets every faction use it
+}
+
+type shopParams struct {
+	Shop int `json:"shop"`
+}
+
+type menuParams struct {
+	Menu string `json:"menu"`
+}
+
+type classParams struct {
+	Class   int     `json:"class"`
+	From    []int   `json:"from"`
+	FromMin int     `json:"from_min"` // classes from FromMin to FromMax can change as well as the ones of From
+	FromMax int     `json:"from_max"`
+	Items   []int64 `json:"items"`
+	Name    string  `json:"name"`
+}
+
+type goldParams struct {
+	Items  []*database.QuestReward `json:"items"`
+	Unique bool                    `json:"unique"`
+}
+
+type buffParams struct {
+	Buff     int   `json:"buff"`
+	Duration int64 `json:"duration"`
+}
+
+type dungeonParams struct {
+	Dungeon int `json:"dungeon"`
+}
+
+type battlegroundParams struct {
+	Battleground int `json:"battleground"`
+}
+
+var (
+	actionHandlers = map[string]ActionHandler{
+		database.NPC_ACTION_TELEPORT:     teleportAction,
+		database.NPC_ACTION_OPEN_SHOP:    openShopAction,
+		database.NPC_ACTION_OPEN_MENU:    openMenuAction,
+		database.NPC_ACTION_CHANGE_CLASS: changeClassAction,
+		database.NPC_ACTION_CHARGE_GOLD:  chargeGoldAction,
+		database.NPC_ACTION_GRANT_BUFF:   grantBuffAction,
+		database.NPC_ACTION_DUNGEON:      enterDungeonAction,
+		database.NPC_ACTION_BATTLEGROUND: joinBattlegroundAction,
+	}
+
+	menus = map[string]utils.Packet{
+		"composition":    COMPOSITION_MENU,
+		"strengthen":     STRENGTHEN_MENU,
+		"dismantle":      DISMANTLE_MENU,
+		"extraction":     EXTRACTION_MENU,
+		"adv-fusion":     ADV_FUSION_MENU,
+		"tactical-space": TACTICAL_SPACE,
+		"create-socket":  CREATE_SOCKET_MENU,
+		"upgrade-socket": UPGRADE_SOCKET_MENU,
+		"consignment":    CONSIGNMENT_MENU,
+		"co-production":  CO_PRODUCTION_MENU,
+		"synthesis":      SYNTHESIS_MENU,
+		"high-synthetic": HIGH_SYNTHETIC_MENU,
+		"appearance":     APPEARANCE_MENU,
+		"disc-item":      DISC_ITEM_MENU,
+		"marbles":        HIGH_SYNTHETIC_MENU,
+		"guild":          GUILD_MENU,
+	}
+)
+
+func runAction(s *database.Socket, npcID int, action *database.NPCAction) ([]byte, error) {
+
+	handler, ok := actionHandlers[action.Type]
+	if !ok {
+		log.Printf("npc action %d: unknown type %s", action.ID, action.Type)
+		return nil, nil
+	}
+
+	c := s.Character
+	resp, slotID, ok := checkRequirements(c, npcID, action)
+	if !ok {
+		return resp, nil
+	}
+
+	data, ok, err := handler(s, npcID, action)
+	if err != nil || !ok {
+		return data, err
+	}
+
+	resp = utils.Packet{}
+	if action.ConsumeItem && slotID >= 0 {
+		resp.Concat(*c.DecrementItem(slotID, 1))
+	}
+
+	if action.GoldCost > 0 {
+		c.LootGold(-action.GoldCost)
+		resp.Concat(c.GetGold())
+	}
+
+	resp.Concat(data)
+	return resp, nil
+}
+
+// checkRequirements returns the failure response when the character cannot use the action,
+// slotID is the slot of the required item if there is any.
+func checkRequirements(c *database.Character, npcID int, action *database.NPCAction) (utils.Packet, int16, bool) {
+
+	fail := func(resp utils.Packet) (utils.Packet, int16, bool) {
+		if action.FailText > 0 {
+			return GetNPCMenu(npcID, action.FailText, 0, nil), -1, false
+		}
+		return resp, -1, false
+	}
+
+	if c.Level < action.MinLevel {
+		resp := NOT_ENOUGH_LEVEL
+		resp.Insert(utils.IntToBytes(uint64(npcID), 4, true), 6) // npc id
+		return fail(resp)
+	} else if action.MaxLevel > 0 && c.Level > action.MaxLevel {
+		return fail(messaging.InfoMessage("Your level is too high."))
+	} else if action.Faction > 0 && c.Faction != action.Faction {
+		return fail(messaging.InfoMessage("Your faction cannot use this."))
+	} else if c.Gold < action.GoldCost {
+		return fail(messaging.SystemMessage(messaging.INSUFFICIENT_GOLD))
+	}
+
+	slotID := int16(-1)
+	if items := action.GetRequiredItems(); len(items) > 0 {
+		var f func(*database.InventorySlot) bool
+		if action.ItemActivated {
+			f = func(item *database.InventorySlot) bool {
+				return item.Activated
+			}
+		}
+
+		var (
+			item *database.InventorySlot
+			err  error
+		)
+		slotID, item, err = c.FindItemInInventory(f, items...)
+		if err != nil || item == nil { // You don't have ticket
+			return fail(messaging.InfoMessage("You don't have the required item."))
+		}
+	}
+
+	return nil, slotID, true
+}
+
+func teleportAction(s *database.Socket, npcID int, action *database.NPCAction) ([]byte, bool, error) {
+
+	params := &teleportParams{}
+	if err := action.GetParams(params); err != nil {
+		return nil, false, err
+	}
+
+	c := s.Character
+	if len(params.Factions) > 0 && !funk.ContainsInt(params.Factions, c.Faction) {
+		return nil, false, nil
+	}
+
+	mapID := params.Map
+	if params.DKMap != nil {
+		maps, ok := database.DKMaps[c.Map]
+		if !ok || *params.DKMap >= len(maps) {
+			return nil, false, nil
+		}
+		mapID = maps[*params.DKMap]
+	}
+
+	var coordinate *utils.Location
+	if params.X > 0 || params.Y > 0 {
+		coordinate = &utils.Location{X: params.X, Y: params.Y}
+	}
+
+	resp, err := c.ChangeMap(mapID, coordinate)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return resp, true, nil
+}
+
+func openShopAction(s *database.Socket, npcID int, action *database.NPCAction) ([]byte, bool, error) {
+
+	params := &shopParams{}
+	if err := action.GetParams(params); err != nil {
+		return nil, false, err
+	}
+
+	shopNo := params.Shop
+	if shopNo == 0 {
+		shopNo = shops[npcID]
+	}
+
+	resp := OPEN_SHOP
+	resp.Insert(utils.IntToBytes(uint64(shopNo), 4, true), 7) // shop id
+	return resp, true, nil
+}
+
+func openMenuAction(s *database.Socket, npcID int, action *database.NPCAction) ([]byte, bool, error) {
+
+	params := &menuParams{}
+	if err := action.GetParams(params); err != nil {
+		return nil, false, err
+	}
+
+	c := s.Character
+	switch params.Menu {
+	case "bank":
+		return c.BankItems(), true, nil
+	case "guild":
+		if c.GuildID != -1 {
+			return nil, false, nil
+		}
+	}
+
+	menu, ok := menus[params.Menu]
+	if !ok {
+		return nil, false, fmt.Errorf("npc action %d: unknown menu %s", action.ID, params.Menu)
+	}
+
+	resp := menu
+	return resp, true, nil
+}
+
+func changeClassAction(s *database.Socket, npcID int, action *database.NPCAction) ([]byte, bool, error) {
+
+	params := &classParams{}
+	if err := action.GetParams(params); err != nil {
+		return nil, false, err
+	}
+
+	c := s.Character
+	inRange := params.FromMax > 0 && c.Class >= params.FromMin && c.Class <= params.FromMax
+	if !funk.ContainsInt(params.From, c.Class) && !inRange {
+		resp := INVALID_CLASS
+		resp.Insert(utils.IntToBytes(uint64(npcID), 4, true), 6) // npc id
+		return resp, false, nil
+	}
+
+	if len(params.Items) > 0 {
+		if _, err := c.FindFreeSlots(len(params.Items)); err != nil {
+			return messaging.InfoMessage("Not enough inventory space."), false, nil
+		}
+	}
+
+	c.Class = params.Class
+	resp := JOB_PROMOTED
+	resp[6] = byte(c.Class)
+
+	for _, id := range params.Items {
+		book := database.NewSlot()
+		book.ItemID = id
+		book.Quantity = 1
+
+		r, _, err := c.AddItem(book, -1, false)
+		if err != nil {
+			return nil, false, err
+		} else if r != nil {
+			resp.Concat(*r)
+		}
+	}
+
+	if params.Name != "" {
+		resp.Concat(messaging.InfoMessage(fmt.Sprintf("Promoted as a %s.", params.Name))) //NOTICE TO PROMOTE
+	}
+
+	go c.Update()
+	return resp, true, nil
+}
+
+func chargeGoldAction(s *database.Socket, npcID int, action *database.NPCAction) ([]byte, bool, error) {
+
+	params := &goldParams{}
+	if err := action.GetParams(params); err != nil {
+		return nil, false, err
+	}
+
+	c := s.Character
+	for _, r := range params.Items {
+		if !params.Unique {
+			break
+		}
+		_, item, err := c.FindItemInInventory(nil, r.ItemID)
+		if item != nil || err != nil {
+			return nil, false, err
+		}
+	}
+
+	resp := utils.Packet{}
+	for _, r := range params.Items {
+		reward := database.NewSlot()
+		reward.ItemID = r.ItemID
+		reward.Quantity = r.Quantity
+
+		itemData, _, err := c.AddItem(reward, -1, true)
+		if err != nil {
+			return nil, false, err
+		} else if itemData == nil {
+			return resp, false, nil
+		}
+
+		resp.Concat(*itemData)
+	}
+
+	return resp, true, nil
+}
+
+func grantBuffAction(s *database.Socket, npcID int, action *database.NPCAction) ([]byte, bool, error) {
+
+	params := &buffParams{}
+	if err := action.GetParams(params); err != nil {
+		return nil, false, err
+	}
+
+	resp, err := s.Character.GrantBuff(params.Buff, params.Duration)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return resp, true, nil
+}
+
+func enterDungeonAction(s *database.Socket, npcID int, action *database.NPCAction) ([]byte, bool, error) {
+
+	params := &dungeonParams{}
+	if err := action.GetParams(params); err != nil {
+		return nil, false, err
+	}
+
+	return database.EnterDungeon(s.Character, params.Dungeon)
+}
+
+func joinBattlegroundAction(s *database.Socket, npcID int, action *database.NPCAction) ([]byte, bool, error) {
+
+	params := &battlegroundParams{}
+	if err := action.GetParams(params); err != nil {
+		return nil, false, err
+	}
+
+	resp, err := database.JoinBattleground(s.Character, params.Battleground)
+	return resp, err == nil && s.Character.BattlegroundID != 0, err
+}
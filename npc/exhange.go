@@ -95,6 +95,7 @@ func (h *BuyItemHandler) Handle(s *database.Socket, data []byte) ([]byte, error)
 	cost := uint64(info.BuyPrice) * uint64(quantity)
 	if slots[slotID].ItemID == 0 && cost <= c.Gold && quantity > 0 && info.SpecialItem == 0 { // slot is empty, player can afford and quantity is positive
 		c.LootGold(-cost)
+		database.CollectTerritoryTax(c, cost)
 		if info.Timer != 0 {
 			quantity = int64(info.Timer)
 		}
@@ -48,6 +48,7 @@ var (
 	SYNTHESIS_MENU      = utils.Packet{0xAA, 0x55, 0x03, 0x00, 0x57, 0x45, 0x01, 0x55, 0xAA}
 	HIGH_SYNTHETIC_MENU = utils.Packet{0xAA, 0x55, 0x03, 0x00, 0x57, 0x46, 0x01, 0x55, 0xAA}
 	APPEARANCE_MENU     = utils.Packet{0xAA, 0x55, 0x03, 0x00, 0x57, 0x41, 0x01, 0x55, 0xAA}
+	DISC_ITEM_MENU      = utils.Packet{0xAA, 0x55, 0x03, 0x00, 0x57, 0x49, 0x01, 0x55, 0xAA}
 
 	FACTION_WAR = utils.Packet{0xAA, 0x55, 0x23, 0x00, 0x65, 0x00, 0x0A, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x55, 0xAA}
@@ -222,6 +223,7 @@ func (h *PressButtonHandler) Handle(s *database.Socket, data []byte) ([]byte, er
 
 	npcID := int(utils.BytesToInt(data[6:8], true))
 	index := int(utils.BytesToInt(data[8:10], true))
+	s.Write(c.QuestProgress(database.QUEST_OBJECTIVE_TALK, npcID, 1))
 	indexes := []int{index & 7, (index & 56) / 8, (index & 448) / 64, (index & 3584) / 512, (index & 28672) / 4096}
 	indexes = funk.FilterInt(indexes, func(i int) bool {
 		return i > 0
@@ -256,383 +258,31 @@ func (h *PressButtonHandler) Handle(s *database.Socket, data []byte) ([]byte, er
 		actions := gjson.Get(script, "actions").Array()
 		actIndex := indexes[len(indexes)-1] - 1
 		actID := actions[actIndex].Int()
+		if action := database.FindNPCAction(int(actID), npcID); action != nil {
+			return runAction(s, npcID, action)
+		}
 		log.Println(actID)
 
 		resp := utils.Packet{}
-		var err error
-		book1, book2, job := 0, 0, 0
 		switch actID {
-		case 1: // Exchange
-			shopNo := shops[npcID]
-			resp = OPEN_SHOP
-			resp.Insert(utils.IntToBytes(uint64(shopNo), 4, true), 7) // shop id
-
-		case 2: // Compositon
-			resp = COMPOSITION_MENU
-
-		case 4: // Strengthen
-			resp = STRENGTHEN_MENU
-
-		case 6: // Deposit
-			resp = c.BankItems()
-
 		case 13: // Accept
 			switch npcID {
-			case 20006: // Hunter trainer
-				book1, job = 16210003, 13
-				resp, err = firstJobPromotion(c, book1, job, npcID)
-				if err != nil {
-					return nil, err
-				}
-			case 20020: // Warrior trainer
-				book1, job = 16210001, 11
-				resp, err = firstJobPromotion(c, book1, job, npcID)
-				if err != nil {
-					return nil, err
-				}
-			case 20021: // Physician trainer
-				book1, job = 16210002, 12
-				resp, err = firstJobPromotion(c, book1, job, npcID)
-				if err != nil {
-					return nil, err
-				}
-			case 20022: // Assassin trainer
-				book1, job = 16210004, 14
-				resp, err = firstJobPromotion(c, book1, job, npcID)
-				if err != nil {
-					return nil, err
-				}
 			case 20057: //HERO BATTLE MANAGER
 				switch index {
 				case 11: //THE GREAT WAR
-					if database.CanJoinWar {
-						if c.Faction == 1 {
-							x := 75.0
-							y := 45.0
-							c.IsinWar = true
-							database.OrderCharacters[c.ID] = c
-							data, _ := c.ChangeMap(230, database.ConvertPointToLocation(fmt.Sprintf("%.1f,%.1f", x, y)))
-							c.Socket.Write(data)
-						} else {
-							x := 81.0
-							y := 475.0
-							c.IsinWar = true
-							database.ShaoCharacters[c.ID] = c
-							data, _ := c.ChangeMap(230, database.ConvertPointToLocation(fmt.Sprintf("%.1f,%.1f", x, y)))
-							c.Socket.Write(data)
-						}
-					}
+					resp, _ = database.JoinBattleground(c, database.BATTLEGROUND_GREAT_WAR)
 				case 10: //FACTION WAR
 
 				case 9: //FLAG KINGDOM
 				}
-			case 20415: // RDL tavern
-				resp, _ = c.ChangeMap(254, nil)
-			}
-		case 64: // Create Guild
-			if c.GuildID == -1 {
-				resp = GUILD_MENU
-			}
-		case 3110: //New zone
-			resp, _ = c.ChangeMap(41, nil)
-		case 3106: //New zone
-			resp, _ = c.ChangeMap(40, nil)
-		case 4171: //Legendary fox Port
-			resp, _ = c.ChangeMap(71, nil)
-
-		case 3107: //New zone
-			resp, _ = c.ChangeMap(44, nil)
-		case 78: // Move to Dragon Castle
-			resp, _ = c.ChangeMap(1, nil)
-		case 706: // Move to Gm Map
-			resp, _ = c.ChangeMap(46, nil)
-
-		case 86: // Move to Spirit Spire
-			resp, _ = c.ChangeMap(5, nil)
-		case 103: // Move to Highlands
-			resp, _ = c.ChangeMap(2, nil)
-
-		case 104: // Move to Venom Swamp
-			resp, _ = c.ChangeMap(3, nil)
-		case 4190: //Move To Starter Zone
-			resp, _ = c.ChangeMap(70, nil)
-		case 106: // Move to Silent Valley
-			resp, _ = c.ChangeMap(11, nil)
-		case 148: // Become a Champion
-			book1, book2, job = 16100039, 16100200, 21
-			resp, err = secondJobPromotion(c, book1, book2, 11, job, npcID)
-			if err != nil {
-				return nil, err
-			}
-		case 149: // Become a Musa
-			book1, book2, job = 16100040, 16100200, 22
-			resp, err = secondJobPromotion(c, book1, book2, 11, job, npcID)
-			if err != nil {
-				return nil, err
-			}
-		case 151: // Become a Surgeon
-			book1, book2, job = 16100041, 16100200, 23
-			resp, err = secondJobPromotion(c, book1, book2, 12, job, npcID)
-			if err != nil {
-				return nil, err
-			}
-		case 152: // Become a Combat Medic
-			book1, book2, job = 16100042, 16100200, 24
-			resp, err = secondJobPromotion(c, book1, book2, 12, job, npcID)
-			if err != nil {
-				return nil, err
-			}
-		case 154: // Become a Slayer
-			book1, book2, job = 16100043, 16100200, 27
-			resp, err = secondJobPromotion(c, book1, book2, 14, job, npcID)
-			if err != nil {
-				return nil, err
-			}
-		case 155: // Become a Shinobi
-			book1, book2, job = 16100044, 16100200, 28
-			resp, err = secondJobPromotion(c, book1, book2, 14, job, npcID)
-			if err != nil {
-				return nil, err
 			}
-		case 157: // Become a Tracker
-			book1, book2, job = 16100045, 16100200, 25
-			resp, err = secondJobPromotion(c, book1, book2, 13, job, npcID)
-			if err != nil {
-				return nil, err
-			}
-		case 158: // Become a Ranger
-			book1, book2, job = 16100046, 16100200, 26
-			resp, err = secondJobPromotion(c, book1, book2, 13, job, npcID)
-			if err != nil {
-				return nil, err
-			}
-
-		case 194: // Dismantle
-			resp = DISMANTLE_MENU
-
-		case 195: // Extraction
-			resp = EXTRACTION_MENU
-
 		case 116:
-			database.AddMemberToFactionWar(c)
-			resp, _ = c.ChangeMap(255, nil)
-
-		case 5214: // Exit Paid Zone
-			if maps, ok := database.DKMaps[c.Map]; ok {
-				resp, err = c.ChangeMap(maps[0], nil)
-				if err != nil {
-					return nil, err
-				}
-			}
+			resp, _ = database.JoinBattleground(c, database.BATTLEGROUND_FACTION_WAR)
 
-		case 5215: // Enter Paid Zone
-			f := func(item *database.InventorySlot) bool {
-				return item.Activated
-			}
-			_, item, err := c.FindItemInInventory(f, 15700040, 15710087)
-			if err != nil {
-				return nil, err
-			} else if item == nil { // You don't have ticket
-				resp := GetNPCMenu(npcID, 999993, 0, nil)
-				return resp, nil
-			}
-
-			if maps, ok := database.DKMaps[c.Map]; ok {
-				resp, err = c.ChangeMap(maps[1], nil)
-				if err != nil {
-					return nil, err
-				}
-			}
 		case 542:
-			if database.CanJoinWar {
-				if c.Faction == 1 {
-					x := 75.0
-					y := 45.0
-					c.IsinWar = true
-					database.OrderCharacters[c.ID] = c
-					data, _ := c.ChangeMap(230, database.ConvertPointToLocation(fmt.Sprintf("%.1f,%.1f", x, y)))
-					c.Socket.Write(data)
-				} else {
-					x := 81.0
-					y := 475.0
-					c.IsinWar = true
-					database.ShaoCharacters[c.ID] = c
-					data, _ := c.ChangeMap(230, database.ConvertPointToLocation(fmt.Sprintf("%.1f,%.1f", x, y)))
-					c.Socket.Write(data)
-				}
-			}
-		case 559: // Advanced Fusion
-			resp = ADV_FUSION_MENU
+			resp, _ = database.JoinBattleground(c, database.BATTLEGROUND_GREAT_WAR)
 		case 526: //Get Divine Skills
 
-		case 631: // Tactical Space
-			resp = TACTICAL_SPACE
-
-		case 7312: // Flexible Castle Entry
-			f := func(item *database.InventorySlot) bool {
-				return item.Activated
-			}
-			_, item, err := c.FindItemInInventory(f, 15710087)
-			if err != nil {
-				return nil, err
-			} else if item == nil { // You don't have ticket
-				resp := GetNPCMenu(npcID, 999993, 0, nil)
-				return resp, nil
-			}
-
-			if maps, ok := database.DKMaps[c.Map]; ok {
-				resp, err = c.ChangeMap(maps[2], nil)
-				if err != nil {
-					return nil, err
-				}
-			}
-		case 3315: // Premium Map
-			slotID, item, err := c.FindItemInInventory(nil, 99002341)
-			if err != nil {
-				return nil, err
-			} else if item == nil { // You don't have ticket
-				resp := GetNPCMenu(npcID, 23740, 0, nil)
-				return resp, nil
-			} else {
-				data := c.DecrementItem(slotID, 1)
-				c.Socket.Write(*data)
-				resp, _ = c.ChangeMap(244, nil)
-
-			}
-		case 3316: // Premium Map
-			slotID, item, err := c.FindItemInInventory(nil, 990029421)
-			if err != nil {
-				return nil, err
-			} else if item == nil { // You don't have ticket
-				resp := GetNPCMenu(npcID, 23740, 0, nil)
-				return resp, nil
-			} else {
-				data := c.DecrementItem(slotID, 1)
-				c.Socket.Write(*data)
-				resp, _ = c.ChangeMap(151, nil)
-
-			}
-		case 77: // Premium Map
-			slotID, item, err := c.FindItemInInventory(nil, 990029422)
-			if err != nil {
-				return nil, err
-			} else if item == nil { // You don't have ticket
-				resp := GetNPCMenu(npcID, 23740, 0, nil)
-				return resp, nil
-			} else {
-				data := c.DecrementItem(slotID, 1)
-				c.Socket.Write(*data)
-				resp, _ = c.ChangeMap(152, nil)
-
-			}
-		case 737: // Create Socket
-			resp = CREATE_SOCKET_MENU
-
-		case 738: // Upgrade Socket
-			resp = UPGRADE_SOCKET_MENU
-
-		case 739: // Co-production menu
-			resp = CO_PRODUCTION_MENU
-		case 906: //APPEARANCE CHANGE
-			resp = APPEARANCE_MENU
-		case 970: // Consignment
-			resp = CONSIGNMENT_MENU
-		case 3230: //High-grade synthetic
-			resp = HIGH_SYNTHETIC_MENU
-		case 3231: //High-grade synthetic
-			resp = SYNTHESIS_MENU
-		case 3306: // Aid 2hr
-			_, item, err := c.FindItemInInventory(nil, 13000170)
-			if item != nil || err != nil {
-				return nil, nil
-			}
-
-			cost := 20000000
-
-			if c.Gold < uint64(cost) {
-				return nil, nil
-			}
-
-			itemData, _, err := c.AddItem(&database.InventorySlot{ItemID: 13000170, Quantity: 120}, -1, true)
-			if err != nil {
-				return nil, err
-			}
-
-			c.LootGold(-uint64(cost))
-			resp.Concat(*itemData)
-			resp.Concat(c.GetGold())
-		case 3319: // Aid 4hr
-			_, item, err := c.FindItemInInventory(nil, 13000074)
-			if item != nil || err != nil {
-				return nil, nil
-			}
-
-			cost := 40000000
-
-			if c.Gold < uint64(cost) {
-				return nil, nil
-			}
-
-			itemData, _, err := c.AddItem(&database.InventorySlot{ItemID: 13000074, Quantity: 240}, -1, true)
-			if err != nil {
-				return nil, err
-			}
-
-			c.LootGold(-uint64(cost))
-			resp.Concat(*itemData)
-			resp.Concat(c.GetGold())
-		case 3320: // Aid 8hr
-			_, item, err := c.FindItemInInventory(nil, 13000173)
-			if item != nil || err != nil {
-				return nil, nil
-			}
-
-			cost := 180000000
-
-			if c.Gold < uint64(cost) {
-				return nil, nil
-			}
-
-			itemData, _, err := c.AddItem(&database.InventorySlot{ItemID: 13000173, Quantity: 480}, -1, true)
-			if err != nil {
-				return nil, err
-			}
-
-			c.LootGold(-uint64(cost))
-			resp.Concat(*itemData)
-			resp.Concat(c.GetGold())
-		case 3321: // Aid 16hr
-			_, item, err := c.FindItemInInventory(nil, 23000141)
-			if item != nil || err != nil {
-				return nil, nil
-			}
-
-			cost := 11195000000
-
-			if c.Gold < uint64(cost) {
-				return nil, nil
-			}
-
-			itemData, _, err := c.AddItem(&database.InventorySlot{ItemID: 23000141, Quantity: 960}, -1, true)
-			if err != nil {
-				return nil, err
-			}
-
-			c.LootGold(-uint64(cost))
-			resp.Concat(*itemData)
-			resp.Concat(c.GetGold())
-
-		case 3307: // Ncash map
-			resp, _ = c.ChangeMap(231, nil)
-		case 3318: //production2
-			resp = CO_PRODUCTION_MENU
-
-		case 3426: //DISCITEM
-			resp := utils.Packet{0xaa, 0x55, 0x03, 0x00, 0x57, 0x49, 0x01, 0x55, 0xaa}
-			return resp, nil
-		case 3930: //Marbles
-			resp := utils.Packet{0xaa, 0x55, 0x03, 0x00, 0x57, 0x46, 0x01, 0x55, 0xaa}
-			return resp, nil
 		case 3308: //GOLD TO NCASH
 		/*cost := 10000000
 		if c.Gold < uint64(cost) {
@@ -648,69 +298,6 @@ func (h *PressButtonHandler) Handle(s *database.Socket, data []byte) ([]byte, er
 		resp.Concat(c.GetGold())
 		//user.NCash += uint64(1000)
 		//user.Update()*/
-		case 197101: // Move to Marketplace
-			resp, _ = c.ChangeMap(254, nil)
-		//case 753:
-		case 742: //GEUK MAPPOK
-			if c.Level <= 100 {
-				resp, _ = c.ChangeMap(93, nil)
-			}
-		case 743: //GEUK MAPPOK
-			if c.Level <= 100 {
-				resp, _ = c.ChangeMap(94, nil)
-			}
-		case 744: //GEUK MAPPOK
-			if c.Level <= 100 {
-				resp, _ = c.ChangeMap(95, nil)
-			}
-		case 745: //GEUK MAPPOK
-			if c.Level > 100 {
-				resp, _ = c.ChangeMap(96, nil)
-			}
-		case 746: //GEUK MAPPOK
-			if c.Level > 100 {
-				resp, _ = c.ChangeMap(97, nil)
-			}
-		case 747: //GEUK MAPPOK
-			if c.Level > 100 {
-				resp, _ = c.ChangeMap(98, nil)
-			}
-		case 748: //GEUK MAPPOK
-			if c.Level > 100 {
-				resp, _ = c.ChangeMap(99, nil)
-			}
-		case 3233: // NON DIVINE Sawangcheon
-			if c.Level <= 100 {
-				resp, _ = c.ChangeMap(100, nil)
-			}
-		case 3235: //DIVINE Sawangcheon
-			if c.Level > 100 {
-				resp, _ = c.ChangeMap(101, nil)
-			}
-		case 3309: //Become God of War
-			book1, book2, job = 100030020, 100030021, 41
-			book3 := 100032001
-			jobName := "God of War"
-			resp, err = darknessJobPromotion(c, book1, book2, book3, job, npcID, jobName)
-			if err != nil {
-				return nil, err
-			}
-		case 3310: //Become God of Death
-			book1, book2, job = 100030022, 100030023, 42
-			book3 := 100032002
-			jobName := "God of Death"
-			resp, err = darknessJobPromotion(c, book1, book2, book3, job, npcID, jobName)
-			if err != nil {
-				return nil, err
-			}
-		case 3311: //Become God of Blade
-			book1, book2, job = 100030024, 100030025, 43
-			book3 := 100032003
-			jobName := "God of Blade"
-			resp, err = darknessJobPromotion(c, book1, book2, book3, job, npcID, jobName)
-			if err != nil {
-				return nil, err
-			}
 		case 33115: //Ncash Coin to Ncash
 			canChange := true
 			reqCoinCount := uint(10)
@@ -790,19 +377,6 @@ func (h *PressButtonHandler) Handle(s *database.Socket, data []byte) ([]byte, er
 				cresp.Concat(data)
 				s.Conn.Write(cresp)
 			}
-		case 3322: //Teleport To Summer Zone {
-			if c.Faction == 1 {
-				x := 567.0
-				y := 747.0
-				data, _ := c.ChangeMap(37, database.ConvertPointToLocation(fmt.Sprintf("%.1f,%.1f", x, y)))
-				c.Socket.Write(data)
-			} else if c.Faction == 2 {
-				x := 567.0
-				y := 747.0
-				data, _ := c.ChangeMap(37, database.ConvertPointToLocation(fmt.Sprintf("%.1f,%.1f", x, y)))
-				c.Socket.Write(data)
-			}
-
 		case 281: //ATARAXIA
 			if c.Exp >= 233332051410 && c.Level == 100 {
 				if c.Class != 0 {
@@ -879,69 +453,6 @@ func GetNPCMenu(npcID, textID, index int, actions []int) []byte {
 	return resp
 }
 
-func firstJobPromotion(c *database.Character, book, job, npcID int) (utils.Packet, error) {
-	resp := utils.Packet{}
-	if c.Class == 0 && c.Level >= 10 {
-		c.Class = job
-		resp = JOB_PROMOTED
-		resp[6] = byte(job)
-
-		r, _, err := c.AddItem(&database.InventorySlot{ItemID: int64(book), Quantity: 1}, -1, false)
-		if err != nil {
-			return resp, err
-		} else if r == nil {
-			return nil, nil
-		}
-
-		resp.Concat(*r)
-
-	} else if c.Level < 10 {
-		resp = NOT_ENOUGH_LEVEL
-		resp.Insert(utils.IntToBytes(uint64(npcID), 4, true), 6) // npc id
-	} else {
-		resp = INVALID_CLASS
-		resp.Insert(utils.IntToBytes(uint64(npcID), 4, true), 6) // npc id
-	}
-
-	return resp, nil
-}
-
-func secondJobPromotion(c *database.Character, book1, book2, preJob, job, npcID int) (utils.Packet, error) {
-	resp := utils.Packet{}
-	if c.Class == preJob && c.Level >= 50 {
-		c.Class = job
-		resp = JOB_PROMOTED
-		resp[6] = byte(job)
-
-		r, _, err := c.AddItem(&database.InventorySlot{ItemID: int64(book1), Quantity: 1}, -1, false)
-		if err != nil {
-			return resp, err
-		} else if r == nil {
-			return nil, nil
-		}
-
-		resp.Concat(*r)
-
-		r, _, err = c.AddItem(&database.InventorySlot{ItemID: int64(book2), Quantity: 1}, -1, false)
-		if err != nil {
-			return resp, err
-		} else if r == nil {
-			return nil, nil
-		}
-
-		resp.Concat(*r)
-
-	} else if c.Level < 50 {
-		resp := NOT_ENOUGH_LEVEL
-		resp.Insert(utils.IntToBytes(uint64(npcID), 4, true), 6) // npc id
-	} else {
-		resp = INVALID_CLASS
-		resp.Insert(utils.IntToBytes(uint64(npcID), 4, true), 6) // npc id
-	}
-
-	return resp, nil
-}
-
 func divineJobPromotion(c *database.Character, npcID int) (utils.Packet, error) {
 	resp := utils.Packet{}
 	if c.Class != 0 {
@@ -1013,47 +524,3 @@ func divineJobPromotion(c *database.Character, npcID int) (utils.Packet, error)
 	}
 	return resp, nil
 }
-
-func darknessJobPromotion(c *database.Character, book1, book2, book3, job, npcID int, jobName string) (utils.Packet, error) {
-	resp := utils.Packet{}
-	if c.Class >= 30 && c.Class < 40 {
-		c.Class = job
-		c.Update()
-		resp = JOB_PROMOTED
-		resp[6] = byte(c.Class)
-
-		r, _, err := c.AddItem(&database.InventorySlot{ItemID: int64(book1), Quantity: 1}, -1, false)
-		if err != nil {
-			return resp, err
-		} else if r == nil {
-			return nil, nil
-		}
-
-		resp.Concat(*r)
-
-		r, _, err = c.AddItem(&database.InventorySlot{ItemID: int64(book2), Quantity: 1}, -1, false)
-		if err != nil {
-			return resp, err
-		} else if r == nil {
-			return nil, nil
-		}
-
-		resp.Concat(*r)
-
-		r, _, err = c.AddItem(&database.InventorySlot{ItemID: int64(book3), Quantity: 1}, -1, false)
-		if err != nil {
-			return resp, err
-		} else if r == nil {
-			return nil, nil
-		}
-
-		resp.Concat(*r)
-		c.Update()
-		resp.Concat(messaging.InfoMessage(fmt.Sprintf("Promoted as a %s.", jobName))) //NOTICE TO PROMOTE
-		//r = c.ChangeMap(1, nil)
-	} else {
-		resp = INVALID_CLASS
-		resp.Insert(utils.IntToBytes(uint64(npcID), 4, true), 6) // npc id
-	}
-	return resp, nil
-}
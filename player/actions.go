@@ -61,9 +61,28 @@ func (h *BattleModeHandler) Handle(s *database.Socket, data []byte) ([]byte, err
 
 func (h *QuestHandler) Handle(s *database.Socket, data []byte) ([]byte, error) {
 
-	QUEST_MENU := utils.Packet{0xaa, 0x55, 0x13, 0x00, 0x57, 0x02, 0x3d, 0x4e, 0x00, 0x00, 0xb0, 0x5c, 0x56, 0x3d, 0x01, 0x0d, 0x00, 0x00, 0x00, 0x14, 0x5d, 0x56, 0x3d, 0x55, 0xaa}
-	resp := QUEST_MENU
-	return resp, nil
+	c := s.Character
+	if c == nil {
+		return nil, nil
+	}
+
+	if len(data) < 13 {
+		return c.GetQuestList(0)
+	}
+
+	id := int(utils.BytesToInt(data[7:11], true))
+	switch data[6] {
+	case 1: // list, id is the npc
+		return c.GetQuestList(id)
+	case 2: // accept
+		return c.AcceptQuest(id)
+	case 3: // complete
+		return c.CompleteQuest(id)
+	case 4: // abandon
+		return c.AbandonQuest(id)
+	}
+
+	return nil, nil
 }
 
 func (h *MeditationHandler) Handle(s *database.Socket, data []byte) ([]byte, error) {
@@ -97,81 +116,25 @@ func (h *TravelToCastleHandler) Handle(s *database.Socket, data []byte) ([]byte,
 	if s.Character.Map == 233 {
 		resp := CLANCASTLE_MAP
 		index := 7
-		length := 3
-		if database.FiveClans[1].ClanID != 0 {
-			//FLAME, WATERFALL, SKY GARDEN, FOREST,UNDERGROUND
-			resp.Insert([]byte{0x01, 0xdf, 0x04, 0x00, 0x00}, index)
-			index += 5
-			length += 5
-			area, _ := database.FindGuildByID(database.FiveClans[1].ClanID) //FLAME WOLF TEMPLE
-			resp.Insert([]byte{byte(len(area.Name))}, index)                // Guild name length
-			index++
-			resp.Insert([]byte(area.Name), index) // Guild name
-			index += len(area.Name)
-			length += 1 + len(area.Name)
-		}
-		if database.FiveClans[2].ClanID != 0 {
-			resp.Insert([]byte{0x02, 0xeb, 0x00, 0x00, 0x00}, index)
-			index += 5
-			length += 5
-			area, _ := database.FindGuildByID(database.FiveClans[2].ClanID) //OCEAN ARMY
-			resp.Insert([]byte{byte(len(area.Name))}, index)                // Guild name length
-			index++
-			resp.Insert([]byte(area.Name), index) // Guild name
-			index += len(area.Name)
-			length += 1 + len(area.Name)
-		}
-		if database.FiveClans[3].ClanID != 0 {
-			resp.Insert([]byte{0x03, 0x5d, 0x06, 0x00, 0x00}, index)
-			index += 5
-			length += 5
-			area, _ := database.FindGuildByID(database.FiveClans[3].ClanID) //LIGHTNING HILL
-			resp.Insert([]byte{byte(len(area.Name))}, index)                // Guild name length
-			index++
-			resp.Insert([]byte(area.Name), index) // Guild name
-			index += len(area.Name)
-			length += 1 + len(area.Name)
-		}
-		if database.FiveClans[4].ClanID != 0 {
-			resp.Insert([]byte{0x04, 0xf0, 0x06, 0x00, 0x00}, index)
-			index += 5
-			length += 5
-			area, _ := database.FindGuildByID(database.FiveClans[4].ClanID) //SOUTHERN WOOD TEMPLE
-			resp.Insert([]byte{byte(len(area.Name))}, index)                // Guild name length
+		for _, t := range database.TerritoriesBySlot() {
+			owner := database.TerritoryOwner(t.ID)
+			if owner == 0 {
+				continue
+			}
+			area, err := database.FindGuildByID(owner)
+			if err != nil || area == nil {
+				continue
+			}
+
+			resp.Insert([]byte{byte(t.Slot)}, index) // area slot
 			index++
-			resp.Insert([]byte(area.Name), index) // Guild name
-			index += len(area.Name)
-			length += 1 + len(area.Name)
-		}
-		if database.FiveClans[5].ClanID != 0 {
-			resp.Insert([]byte{0x05, 0xd7, 0x05, 0x00, 0x00}, index)
-			index += 5
-			length += 5
-			area, _ := database.FindGuildByID(database.FiveClans[5].ClanID) //WESTERN LAND TEMPLE
-			resp.Insert([]byte{byte(len(area.Name))}, index)                // Guild name length
+			resp.Insert(utils.IntToBytes(uint64(t.MenuID), 4, true), index)
+			index += 4
+			resp.Insert([]byte{byte(len(area.Name))}, index) // Guild name length
 			index++
 			resp.Insert([]byte(area.Name), index) // Guild name
 			index += len(area.Name)
-			length += 1 + len(area.Name)
 		}
-		/*resp.Insert([]byte{0x41, 0x73, 0x63, 0x65, 0x6e, 0x73, 0x69, 0x6f, 0x6e, 0x20, 0x53, 0x6b, 0x79}, index) //FLAME WOLF TEMPLE
-		index += 14
-		resp.Insert([]byte{0x02, 0xeb, 0x00, 0x00, 0x00}, index)
-		index += 5
-		resp.Insert([]byte{0x0d, 0x41, 0x73, 0x63, 0x65, 0x6e, 0x73, 0x69, 0x6f, 0x6e, 0x20, 0x53, 0x6b, 0x79}, index) //OCEAN ARMY
-		index += 14
-		resp.Insert([]byte{0x03, 0x5d, 0x06, 0x00, 0x00}, index)
-		index += 5
-		resp.Insert([]byte{0x0d, 0x41, 0x73, 0x63, 0x65, 0x6e, 0x73, 0x69, 0x6f, 0x6e, 0x20, 0x53, 0x6b, 0x79}, index) //LIGHTNING HILL
-		index += 14
-		resp.Insert([]byte{0x04, 0xf0, 0x06, 0x00, 0x00}, index)
-		index += 5
-		resp.Insert([]byte{0x0d, 0x41, 0x73, 0x63, 0x65, 0x6e, 0x73, 0x69, 0x6f, 0x6e, 0x20, 0x53, 0x6b, 0x79}, index) //SOUTHERN WOOD TEMPLE
-		index += 14
-		resp.Insert([]byte{0x05, 0xd7, 0x05, 0x00, 0x00}, index)
-		index += 5
-		resp.Insert([]byte{0x0d, 0x41, 0x73, 0x63, 0x65, 0x6e, 0x73, 0x69, 0x6f, 0x6e, 0x20, 0x53, 0x6b, 0x79}, index) //WESTERN LAND TEMPLE
-		index += 14*/
 		resp.SetLength(int16(binary.Size(resp) - 6))
 		//fmt.Printf("RESP:\t %x \n", []byte(resp))
 		return resp, nil
@@ -189,52 +152,20 @@ func (h *TacticalSpaceTPHandler) Handle(s *database.Socket, data []byte) ([]byte
 	return s.Character.ChangeMap(mapID, nil)
 }
 func (h *TravelToFiveClanArea) Handle(s *database.Socket, data []byte) ([]byte, error) {
-	areaID := int16(data[7])
-	switch areaID {
-	case 0:
+	areaID := int(data[7])
+	if areaID == 0 {
 		x := "508,564"
 		coord := s.Character.Teleport(database.ConvertPointToLocation(x))
 		s.Conn.Write(coord)
-	case 1: //FLAME WOLF TEMPLE
-		if s.Character.GuildID == database.FiveClans[1].ClanID {
-			x := "243,777"
-			coord := s.Character.Teleport(database.ConvertPointToLocation(x))
-			s.Conn.Write(coord)
-		} else {
-			s.Conn.Write(CANNOT_MOVE)
-		}
-	case 2: //OCEAN ARMY
-		if s.Character.GuildID == database.FiveClans[2].ClanID {
-			x := "131,433"
-			coord := s.Character.Teleport(database.ConvertPointToLocation(x))
-			s.Conn.Write(coord)
-		} else {
-			s.Conn.Write(CANNOT_MOVE)
-		}
-	case 3: //LIGHTNING HILL
-		if s.Character.GuildID == database.FiveClans[3].ClanID {
-			x := "615,171"
-			coord := s.Character.Teleport(database.ConvertPointToLocation(x))
-			s.Conn.Write(coord)
-		} else {
-			s.Conn.Write(CANNOT_MOVE)
-		}
-	case 4: //SOUTHERN WOOD TEMPLE
-		if s.Character.GuildID == database.FiveClans[4].ClanID {
-			x := "863,425"
-			coord := s.Character.Teleport(database.ConvertPointToLocation(x))
-			s.Conn.Write(coord)
-		} else {
-			s.Conn.Write(CANNOT_MOVE)
-		}
-	case 5: //WESTERN LAND TEMPLE
-		if s.Character.GuildID == database.FiveClans[5].ClanID {
-			x := "689,867"
-			coord := s.Character.Teleport(database.ConvertPointToLocation(x))
-			s.Conn.Write(coord)
-		} else {
-			s.Conn.Write(CANNOT_MOVE)
-		}
+		return nil, nil
+	}
+
+	t := database.FindTerritoryBySlot(areaID)
+	if t != nil && s.Character.GuildID > 0 && s.Character.GuildID == database.TerritoryOwner(t.ID) {
+		coord := s.Character.Teleport(database.ConvertPointToLocation(t.Teleport))
+		s.Conn.Write(coord)
+	} else {
+		s.Conn.Write(CANNOT_MOVE)
 	}
 
 	return nil, nil
@@ -344,7 +275,7 @@ func (h *SendPvPRequestHandler) Handle(s *database.Socket, data []byte) ([]byte,
 
 	pseudoID := uint16(utils.BytesToInt(data[6:8], true))
 	opponent := database.FindCharacterByPseudoID(s.User.ConnectedServer, pseudoID)
-	if opponent == nil {
+	if opponent == nil || opponent.IsBlocking(s.Character) {
 		return nil, nil
 
 	} else if opponent.DuelID > 0 {
@@ -56,10 +56,6 @@ func (h *AttackHandler) Handle(s *database.Socket, data []byte) ([]byte, error)
 		}
 
 		if npcPos.Attackable {
-			ai.MovementToken = 0
-			ai.IsMoving = false
-			ai.TargetPlayerID = c.ID
-
 			dmg, err := c.CalculateDamage(ai, false)
 			if err != nil {
 				return nil, err
@@ -135,10 +131,6 @@ func (h *InstantAttackHandler) Handle(s *database.Socket, data []byte) ([]byte,
 		}
 
 		if npcPos.Attackable {
-			ai.MovementToken = 0
-			ai.IsMoving = false
-			ai.TargetPlayerID = c.ID
-
 			dmg := int(utils.RandInt(int64(st.MinATK), int64(st.MaxATK))) - npc.DEF
 			if dmg < 0 {
 				dmg = 0
@@ -332,7 +324,7 @@ func DealDamageToPlayer(s *database.Socket, enemy *database.Character, dmg int)
 		r := messaging.InfoMessage(info)
 
 		servers, _ := database.GetServers()
-		if servers[int16(c.Socket.User.ConnectedServer)-1].IsPVPServer && servers[int16(c.Socket.User.ConnectedServer)-1].CanLoseEXP && servers[int16(enemy.Socket.User.ConnectedServer)-1].CanLoseEXP && !c.IsinWar && !enemy.IsinWar {
+		if servers[int16(c.Socket.User.ConnectedServer)-1].IsPVPServer && servers[int16(c.Socket.User.ConnectedServer)-1].CanLoseEXP && servers[int16(enemy.Socket.User.ConnectedServer)-1].CanLoseEXP && c.BattlegroundID == 0 && enemy.BattlegroundID == 0 {
 			randInt := utils.RandInt(1, 3)
 			exp, _ := enemy.LosePlayerExp(int(randInt))
 			different := int(enemy.Level + 20)
@@ -354,22 +346,8 @@ func DealDamageToPlayer(s *database.Socket, enemy *database.Character, dmg int)
 		}
 		p := nats.CastPacket{CastNear: true, CharacterID: c.ID, Data: r, Type: nats.PVP_FINISHED}
 		p.Cast()
-		if database.WarStarted && c.IsinWar && enemy.IsinWar {
-			c.WarKillCount++
-			if c.Faction == 1 {
-				database.ShaoPoints -= 5
-			} else {
-				database.OrderPoints -= 5
-			}
-		}
-		if enemy.Map == 255 && database.IsFactionWarStarted() {
-			if enemy.Faction == 1 && c.Faction == 2 {
-				database.AddPointsToFactionWarFaction(15, 2)
-			}
-			if enemy.Faction == 2 && c.Faction == 1 {
-				database.AddPointsToFactionWarFaction(15, 1)
-			}
-		}
+		database.BattlegroundKill(c, enemy)
+		database.GuildWarKill(c, enemy)
 	}
 }
 
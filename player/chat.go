@@ -9,15 +9,14 @@ import (
 	"strings"
 	"time"
 
+	"hero-emulator/config"
 	"hero-emulator/database"
-	"hero-emulator/dungeon"
 	"hero-emulator/messaging"
 	"hero-emulator/nats"
 	"hero-emulator/npc"
 	"hero-emulator/server"
 	"hero-emulator/utils"
 
-	"github.com/robfig/cron"
 	"gopkg.in/guregu/null.v3"
 
 	"github.com/thoas/go-funk"
@@ -78,13 +77,11 @@ func (h *ChatHandler) Handle(s *database.Socket, data []byte) ([]byte, error) {
 			return messaging.SystemMessage(messaging.WHISPER_FAILED), nil
 		}
 
-		h.receivers = map[int]*database.Character{c.ID: c}
-
 		messageLen := int(utils.BytesToInt(data[index:index+2], true))
 		index += 2
 
 		h.message = string(data[index : index+messageLen])
-		return h.chatWithReceivers(s, h.createChatMessage)
+		return h.whisper(s, c.Name)
 
 	case 28931: // party chat
 		party := database.FindParty(s.Character)
@@ -116,27 +113,22 @@ func (h *ChatHandler) Handle(s *database.Socket, data []byte) ([]byte, error) {
 			guild, err := database.FindGuildByID(s.Character.GuildID)
 			if err != nil {
 				return nil, err
-			}
-
-			members, err := guild.GetMembers()
-			if err != nil {
-				return nil, err
+			} else if guild == nil {
+				return nil, nil
 			}
 
 			messageLen := int(utils.BytesToInt(data[6:8], true))
 			h.message = string(data[8 : messageLen+8])
-			h.receivers = map[int]*database.Character{}
 
-			for _, m := range members {
-				c, err := database.FindCharacterByID(m.ID)
-				if err != nil || c == nil || !c.IsOnline || c.ID == s.Character.ID {
-					continue
+			ids := []int{} // logged as the receivers, the members online in other processes get it through the subject
+			for _, m := range guild.OnlineMembers() {
+				if m.ID != s.Character.ID {
+					ids = append(ids, m.ID)
 				}
-
-				h.receivers[m.ID] = c
 			}
 
-			return h.chatWithReceivers(s, h.createChatMessage)
+			m := &nats.ChatMessage{ExcludeID: s.Character.ID, GuildID: guild.ID}
+			return h.chatWithSubject(s, nats.GuildSubject(guild.ID), m, ids, h.createChatMessage)
 		}
 
 	case 28933, 28946: // roar chat
@@ -144,26 +136,21 @@ func (h *ChatHandler) Handle(s *database.Socket, data []byte) ([]byte, error) {
 			return nil, nil
 		}
 
-		s.Character.LastRoar = time.Now()
-		characters, err := database.FindCharactersInServer(user.ConnectedServer)
-		if err != nil {
-			log.Println(err)
-			return nil, err
-		}
-
-		//delete(characters, s.Character.ID)
-		h.receivers = characters
-
-		stat.CHI -= 100
-
 		index := 6
 		messageLen := int(utils.BytesToInt(data[index:index+2], true))
 		index += 2
 
 		h.message = string(data[index : index+messageLen])
+		if reply := h.moderate(s); reply != nil { // a refused roar costs nothing
+			return reply, nil
+		}
+
+		s.Character.LastRoar = time.Now()
+		stat.CHI -= 100
 
 		resp := utils.Packet{}
-		_, err = h.chatWithReceivers(s, h.createChatMessage)
+		m := &nats.ChatMessage{Server: user.ConnectedServer}
+		_, err = h.broadcast(s, nats.ServerSubject(user.ConnectedServer), m, nil, h.createChatMessage)
 		if err != nil {
 			log.Println(err)
 			return nil, err
@@ -185,26 +172,15 @@ func (h *ChatHandler) Handle(s *database.Socket, data []byte) ([]byte, error) {
 		return h.Shout(s, data)
 
 	case 28945: // faction chat
-		characters, err := database.FindCharactersInServer(user.ConnectedServer)
-		if err != nil {
-			return nil, err
-		}
-
-		//delete(characters, s.Character.ID)
-		for _, c := range characters {
-			if c.Faction != s.Character.Faction {
-				delete(characters, c.ID)
-			}
-		}
-
-		h.receivers = characters
 		index := 6
 		messageLen := int(utils.BytesToInt(data[index:index+2], true))
 		index += 2
 
 		h.message = string(data[index : index+messageLen])
 		resp := utils.Packet{}
-		_, err = h.chatWithReceivers(s, h.createChatMessage)
+		m := &nats.ChatMessage{Server: user.ConnectedServer, Faction: s.Character.Faction}
+		subject := nats.FactionSubject(user.ConnectedServer, s.Character.Faction)
+		_, err = h.chatWithSubject(s, subject, m, nil, h.createChatMessage)
 		if err != nil {
 			return nil, err
 		}
@@ -222,14 +198,6 @@ func (h *ChatHandler) Shout(s *database.Socket, data []byte) ([]byte, error) {
 		return nil, nil
 	}
 
-	characters, err := database.FindOnlineCharacters()
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	//delete(characters, s.Character.ID)
-
 	slot, _, err := s.Character.FindItemInInventory(nil, 15900001, 17500181, 17502689, 13000131)
 	if err != nil {
 		log.Println(err)
@@ -238,17 +206,18 @@ func (h *ChatHandler) Shout(s *database.Socket, data []byte) ([]byte, error) {
 		return nil, nil
 	}
 
-	resp := s.Character.DecrementItem(slot, 1)
-
 	index := 6
 	messageLen := int(data[index])
 	index++
 
 	h.chatType = 28942
-	h.receivers = characters
 	h.message = string(data[index : index+messageLen])
+	if reply := h.moderate(s); reply != nil {
+		return reply, nil
+	}
 
-	_, err = h.chatWithReceivers(s, h.createShoutMessage)
+	resp := s.Character.DecrementItem(slot, 1)
+	_, err = h.broadcast(s, nats.CHAT_GLOBAL, &nats.ChatMessage{}, nil, h.createShoutMessage)
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -312,34 +281,57 @@ func (h *ChatHandler) createShoutMessage(s *database.Socket) *utils.Packet {
 	return &resp
 }
 
+// moderate checks the mute and the rate limit of the sender and masks the filtered words of the message, it returns
+// the reply to the sender when the message must not be sent.
+func (h *ChatHandler) moderate(s *database.Socket) []byte {
+
+	if m := database.FindChatMute(s.User.ID); m != nil {
+		return m.Message()
+	}
+
+	channel, ok := database.ChatChannels[h.chatType]
+	if ok && s.User.UserType < server.GM_USER && !database.CheckChatRate(s.Character, channel) {
+		if m := database.FindChatMute(s.User.ID); m != nil { // muted for spam
+			return m.Message()
+		}
+		return messaging.InfoMessage("You are sending messages too fast.")
+	}
+
+	h.message = database.FilterChat(h.message)
+	return nil
+}
+
 func (h *ChatHandler) normalChat(s *database.Socket) ([]byte, error) {
 
-	if _, ok := server.MutedPlayers.Get(s.User.ID); ok {
-		msg := "Chatting with this account is prohibited. Please contact our customer support service for more information."
-		return messaging.InfoMessage(msg), nil
+	if reply := h.moderate(s); reply != nil {
+		return reply, nil
 	}
 
 	resp := h.createChatMessage(s)
 	p := &nats.CastPacket{CastNear: true, CharacterID: s.Character.ID, Data: *resp, Type: nats.CHAT_NORMAL}
 	err := p.Cast()
+	if err == nil {
+		database.LogChat(s.Character, h.chatType, h.message, nil)
+	}
 
 	return nil, err
 }
 
 func (h *ChatHandler) chatWithReceivers(s *database.Socket, msgHandler func(*database.Socket) *utils.Packet) ([]byte, error) {
 
-	if _, ok := server.MutedPlayers.Get(s.User.ID); ok {
-		msg := "Chatting with this account is prohibited. Please contact our customer support service for more information."
-		return messaging.InfoMessage(msg), nil
+	if reply := h.moderate(s); reply != nil {
+		return reply, nil
 	}
+	return h.sendToReceivers(s, msgHandler)
+}
+
+func (h *ChatHandler) sendToReceivers(s *database.Socket, msgHandler func(*database.Socket) *utils.Packet) ([]byte, error) {
 
 	resp := msgHandler(s)
 
+	receivers := []int{}
 	for _, c := range h.receivers {
 		if c == nil || !c.IsOnline {
-			if h.chatType == 28930 { // PM
-				return messaging.SystemMessage(messaging.WHISPER_FAILED), nil
-			}
 			continue
 		}
 
@@ -350,9 +342,57 @@ func (h *ChatHandler) chatWithReceivers(s *database.Socket, msgHandler func(*dat
 				log.Println(err)
 				return nil, err
 			}
+			receivers = append(receivers, c.ID)
 		}
 	}
 
+	database.LogChat(s.Character, h.chatType, h.message, receivers)
+	return *resp, nil
+}
+
+// whisper sends the message to the process of the named character, it fails when no process acknowledges it.
+func (h *ChatHandler) whisper(s *database.Socket, name string) ([]byte, error) {
+
+	if reply := h.moderate(s); reply != nil {
+		return reply, nil
+	}
+
+	resp := h.createChatMessage(s)
+	m := &nats.ChatMessage{SenderID: s.Character.ID, SenderName: s.Character.Name, Receiver: name, Data: *resp}
+	timeout := time.Duration(config.Default.Nats.AckMillis) * time.Millisecond
+
+	ack, err := m.Request(nats.CharacterSubject(name), timeout)
+	if err != nil {
+		return nil, err
+	} else if ack == nil || !ack.Delivered {
+		return messaging.SystemMessage(messaging.WHISPER_FAILED), nil
+	}
+
+	database.LogChat(s.Character, h.chatType, h.message, []int{ack.CharacterID})
+	return *resp, nil
+}
+
+func (h *ChatHandler) chatWithSubject(s *database.Socket, subject string, m *nats.ChatMessage, receivers []int,
+	msgHandler func(*database.Socket) *utils.Packet) ([]byte, error) {
+
+	if reply := h.moderate(s); reply != nil {
+		return reply, nil
+	}
+	return h.broadcast(s, subject, m, receivers, msgHandler)
+}
+
+// broadcast publishes the message to the characters of every process routed by the subject, receivers are logged
+// as the characters the message was meant for.
+func (h *ChatHandler) broadcast(s *database.Socket, subject string, m *nats.ChatMessage, receivers []int,
+	msgHandler func(*database.Socket) *utils.Packet) ([]byte, error) {
+
+	resp := msgHandler(s)
+	m.SenderID, m.SenderName, m.Data = s.Character.ID, s.Character.Name, *resp
+	if err := m.Publish(subject); err != nil {
+		return nil, err
+	}
+
+	database.LogChat(s.Character, h.chatType, h.message, receivers)
 	return *resp, nil
 }
 
@@ -554,9 +594,21 @@ func (h *ChatHandler) cmdMessage(s *database.Socket, data []byte) ([]byte, error
 					CHI:   petInfo.BaseChi}
 			}
 
+			if !ch.IsOnline || ch.Socket == nil {
+				if err := database.SendSystemMail(ch.ID, "Game Master", "An item from the game masters.", 0, item); err != nil {
+					return nil, err
+				}
+				return messaging.InfoMessage(fmt.Sprintf("%s is offline, the item was mailed.", ch.Name)), nil
+			}
+
 			r, _, err := ch.AddItem(item, -1, false)
 			if err != nil {
 				return nil, err
+			} else if r == nil {
+				if err := database.SendSystemMail(ch.ID, "Game Master", "An item from the game masters.", 0, item); err != nil {
+					return nil, err
+				}
+				return messaging.InfoMessage(fmt.Sprintf("The inventory of %s is full, the item was mailed.", ch.Name)), nil
 			}
 
 			ch.Socket.Write(*r)
@@ -927,10 +979,375 @@ func (h *ChatHandler) cmdMessage(s *database.Socket, data []byte) ([]byte, error
 				return nil, err
 			}
 			return messaging.InfoMessage(fmt.Sprintf("Clan ID: %d", guild.ID)), nil
+		case "guild":
+			if len(parts) < 2 || s.Character.GuildID <= 0 {
+				return nil, nil
+			}
+			guild, err := database.FindGuildByID(s.Character.GuildID)
+			if err != nil || guild == nil {
+				return nil, err
+			}
+
+			switch strings.ToLower(parts[1]) {
+			case "info":
+				for _, line := range guild.LevelLines() {
+					resp.Concat(messaging.InfoMessage(line))
+				}
+				return resp, nil
+			case "donate":
+				if len(parts) < 3 {
+					return nil, nil
+				}
+				amount, err := strconv.ParseUint(parts[2], 10, 64)
+				if err != nil {
+					return nil, nil
+				}
+				return guild.DonateGold(s.Character, amount)
+			case "history":
+			default:
+				return nil, nil
+			}
+
+			if guild.LeaderID != s.Character.ID {
+				return messaging.InfoMessage("Only the leader of the house can see its history."), nil
+			}
+
+			lines, err := guild.HistoryLines(database.GUILD_LOG_LIMIT)
+			if err != nil {
+				return nil, err
+			}
+			for _, line := range lines {
+				resp.Concat(messaging.InfoMessage(line))
+			}
+			return resp, nil
+		case "territories":
+			for _, line := range database.TerritoryLines() {
+				resp.Concat(messaging.InfoMessage(line))
+			}
+			return resp, nil
+		case "war":
+			if len(parts) < 2 {
+				if s.Character.GuildID <= 0 {
+					return nil, nil
+				}
+				for _, line := range database.GuildWarLines(s.Character.GuildID) {
+					resp.Concat(messaging.InfoMessage(line))
+				}
+				return resp, nil
+			}
+
+			switch strings.ToLower(parts[1]) {
+			case "declare":
+				if len(parts) < 4 {
+					return nil, nil
+				}
+				minutes, err := strconv.Atoi(parts[3])
+				if err != nil {
+					return nil, nil
+				}
+				stakes := uint64(0)
+				if len(parts) >= 5 {
+					if stakes, err = strconv.ParseUint(parts[4], 10, 64); err != nil {
+						return nil, nil
+					}
+				}
+				return database.DeclareGuildWar(s.Character, parts[2], minutes, stakes)
+			case "accept", "decline":
+				if len(parts) < 3 {
+					return nil, nil
+				}
+				return database.RespondGuildWar(s.Character, parts[2], strings.ToLower(parts[1]) == "accept")
+			case "history":
+				if s.Character.GuildID <= 0 {
+					return nil, nil
+				}
+				lines, err := database.GuildWarHistoryLines(s.Character.GuildID)
+				if err != nil {
+					return nil, err
+				}
+				for _, line := range lines {
+					resp.Concat(messaging.InfoMessage(line))
+				}
+				return resp, nil
+			}
+		case "alliance":
+			if len(parts) < 2 {
+				if s.Character.GuildID <= 0 {
+					return nil, nil
+				}
+				names := []string{}
+				for _, id := range database.AllyIDs(s.Character.GuildID) {
+					if g, err := database.FindGuildByID(id); err == nil && g != nil {
+						names = append(names, g.Name)
+					}
+				}
+				if len(names) == 0 {
+					return messaging.InfoMessage("Your house has no allies."), nil
+				}
+				return messaging.InfoMessage("Allies: " + strings.Join(names, ", ")), nil
+			} else if len(parts) < 3 {
+				return nil, nil
+			}
+
+			switch strings.ToLower(parts[1]) {
+			case "invite":
+				return database.InviteAlliance(s.Character, parts[2])
+			case "accept":
+				return database.AcceptAlliance(s.Character, parts[2])
+			case "leave":
+				return database.BreakAlliance(s.Character, parts[2])
+			}
+		case "ally":
+			if len(parts) < 2 || s.Character.GuildID <= 0 {
+				return nil, nil
+			}
+
+			h.chatType = 28932 // guild chat
+			h.message = strings.Join(parts[1:], " ")
+			h.receivers = map[int]*database.Character{}
+			for _, c := range database.AllianceMembers(s.Character.GuildID) {
+				if c.ID != s.Character.ID {
+					h.receivers[c.ID] = c
+				}
+			}
+			return h.chatWithReceivers(s, h.createChatMessage)
+		case "report":
+			if len(parts) < 3 {
+				return messaging.InfoMessage("Usage: /report <name> <reason>"), nil
+			}
+			return s.Character.ReportCharacter(parts[1], strings.Join(parts[2:], " "))
+		case "friend", "block":
+			relType := database.RELATION_FRIEND
+			if cmd == "block" {
+				relType = database.RELATION_BLOCK
+			}
+
+			if len(parts) < 2 || strings.ToLower(parts[1]) == "list" {
+				for _, line := range s.Character.RelationLines(relType) {
+					resp.Concat(messaging.InfoMessage(line))
+				}
+				return resp, nil
+			} else if len(parts) < 3 {
+				return nil, nil
+			}
+
+			switch strings.ToLower(parts[1]) {
+			case "add":
+				return s.Character.AddRelation(parts[2], relType)
+			case "remove":
+				return s.Character.RemoveRelation(parts[2], relType)
+			}
+		case "mail":
+			if len(parts) < 2 || strings.ToLower(parts[1]) == "list" {
+				lines, err := s.Character.MailLines()
+				if err != nil {
+					return nil, err
+				}
+				for _, line := range lines {
+					resp.Concat(messaging.InfoMessage(line))
+				}
+				return resp, nil
+			} else if len(parts) < 3 {
+				return nil, nil
+			}
+
+			switch strings.ToLower(parts[1]) {
+			case "read", "claim", "delete":
+				id, err := strconv.Atoi(strings.TrimPrefix(parts[2], "#"))
+				if err != nil {
+					return nil, nil
+				}
+				switch strings.ToLower(parts[1]) {
+				case "read":
+					return s.Character.ReadMail(id)
+				case "claim":
+					return s.Character.ClaimMail(id)
+				default:
+					return s.Character.DeleteMail(id)
+				}
+			case "send": // /mail send <name> <gold> <bag slot or 0> <text>
+				if len(parts) < 5 {
+					return nil, nil
+				}
+				gold, err := strconv.ParseUint(parts[3], 10, 64)
+				if err != nil {
+					return nil, nil
+				}
+				slotID := int16(-1)
+				if parts[4] != "0" {
+					var ok bool
+					if slotID, ok = bagSlot(parts[4]); !ok {
+						return nil, nil
+					}
+				}
+				return s.Character.SendMail(parts[2], strings.Join(parts[5:], " "), gold, slotID)
+			case "system": // /mail system <name> <gold> <item id or 0> <quantity> <text>
+				if s.User.UserType < server.GM_USER || len(parts) < 6 {
+					return nil, nil
+				}
+				receiver, err := database.FindCharacterByName(parts[2])
+				if err != nil || receiver == nil {
+					return messaging.InfoMessage(fmt.Sprintf("%s does not exist.", parts[2])), err
+				}
+				gold, err := strconv.ParseUint(parts[3], 10, 64)
+				if err != nil {
+					return nil, nil
+				}
+				itemID, err := strconv.ParseInt(parts[4], 10, 64)
+				if err != nil {
+					return nil, nil
+				}
+				quantity, err := strconv.ParseUint(parts[5], 10, 32)
+				if err != nil {
+					return nil, nil
+				}
+
+				var item *database.InventorySlot
+				if _, ok := database.Items[itemID]; ok {
+					item = database.NewSlot()
+					item.ItemID = itemID
+					item.Quantity = uint(quantity)
+				} else if itemID != 0 {
+					return messaging.InfoMessage(fmt.Sprintf("Unknown item %d.", itemID)), nil
+				}
+
+				if err := database.SendSystemMail(receiver.ID, "Game Master", strings.Join(parts[6:], " "), gold, item); err != nil {
+					return nil, err
+				}
+				return messaging.InfoMessage(fmt.Sprintf("Mail sent to %s.", receiver.Name)), nil
+			}
+		case "gbank":
+			if s.Character.GuildID <= 0 {
+				return messaging.InfoMessage("You are not in a house."), nil
+			}
+			guild, err := database.FindGuildByID(s.Character.GuildID)
+			if err != nil || guild == nil {
+				return nil, err
+			}
+
+			var lines []string
+			switch {
+			case len(parts) < 2:
+				lines, err = guild.BankLines()
+			case strings.ToLower(parts[1]) == "deposit" && len(parts) >= 3:
+				slotID, ok := bagSlot(parts[2])
+				if !ok {
+					return nil, nil
+				}
+				return guild.DepositItem(s.Character, slotID)
+			case strings.ToLower(parts[1]) == "withdraw" && len(parts) >= 3:
+				slot, err := strconv.Atoi(parts[2])
+				if err != nil {
+					return nil, nil
+				}
+				return guild.WithdrawItem(s.Character, slot-1)
+			case strings.ToLower(parts[1]) == "gold" && len(parts) >= 4:
+				amount, err := strconv.ParseUint(parts[3], 10, 64)
+				if err != nil {
+					return nil, nil
+				}
+				switch strings.ToLower(parts[2]) {
+				case "deposit":
+					return guild.DepositGold(s.Character, amount)
+				case "withdraw":
+					return guild.WithdrawGold(s.Character, amount)
+				}
+				return nil, nil
+			case strings.ToLower(parts[1]) == "limits":
+				lines, err = guild.BankLimitLines(s.Character)
+			case strings.ToLower(parts[1]) == "log":
+				if guild.LeaderID != s.Character.ID {
+					return messaging.InfoMessage("Only the leader of the house can see the warehouse log."), nil
+				}
+				lines, err = guild.BankLogLines(database.GUILD_LOG_LIMIT)
+			default:
+				return nil, nil
+			}
+
+			if err != nil {
+				return nil, err
+			}
+			for _, line := range lines {
+				resp.Concat(messaging.InfoMessage(line))
+			}
+			return resp, nil
+		case "accept":
+			if len(parts) >= 2 && strings.ToLower(parts[1]) == "war" {
+				return database.AcceptBattleground(s.Character)
+			}
+		case "battleground":
+			if len(parts) >= 2 && strings.ToLower(parts[1]) == "leave" {
+				return database.LeaveBattleground(s.Character)
+			} else if len(parts) >= 3 && strings.ToLower(parts[1]) == "join" {
+				id, err := strconv.Atoi(parts[2])
+				if err != nil {
+					return nil, nil
+				}
+				return database.JoinBattleground(s.Character, id)
+			}
+			if s.User.UserType < server.GM_USER {
+				return nil, nil
+			}
+
+			if len(parts) >= 2 && strings.ToLower(parts[1]) == "list" {
+				lines := database.BattlegroundLines()
+				if len(lines) == 0 {
+					return messaging.InfoMessage("No battleground is running."), nil
+				}
+				for _, line := range lines {
+					resp.Concat(messaging.InfoMessage(line))
+				}
+				return resp, nil
+			} else if len(parts) >= 3 && strings.ToLower(parts[1]) == "stop" {
+				id, err := strconv.Atoi(parts[2])
+				if err != nil {
+					return nil, nil
+				}
+				if err := database.StopBattleground(id); err != nil {
+					return messaging.InfoMessage(err.Error()), nil
+				}
+				return messaging.InfoMessage(fmt.Sprintf("Battleground %d stopped.", id)), nil
+			}
 		case "dungeon":
+			if len(parts) >= 2 && strings.ToLower(parts[1]) == "leave" {
+				return database.LeaveDungeon(s.Character)
+			} else if len(parts) >= 3 && strings.ToLower(parts[1]) == "top" {
+				id, err := strconv.Atoi(parts[2])
+				if err != nil {
+					return nil, nil
+				}
+				weekly := len(parts) >= 4 && strings.ToLower(parts[3]) == "week"
+				lines, err := database.LeaderboardLines(id, weekly)
+				if err != nil {
+					return messaging.InfoMessage(err.Error()), nil
+				}
+				for _, line := range lines {
+					resp.Concat(messaging.InfoMessage(line))
+				}
+				return resp, nil
+			}
 			if s.User.UserType < server.GM_USER {
 				return nil, nil
 			}
+
+			if len(parts) >= 2 && strings.ToLower(parts[1]) == "list" {
+				lines := database.InstanceLines()
+				if len(lines) == 0 {
+					return messaging.InfoMessage("No dungeon is running."), nil
+				}
+				for _, line := range lines {
+					resp.Concat(messaging.InfoMessage(line))
+				}
+				return resp, nil
+			} else if len(parts) >= 3 && strings.ToLower(parts[1]) == "enter" {
+				id, err := strconv.Atoi(parts[2])
+				if err != nil {
+					return nil, nil
+				}
+				data, _, err := database.EnterDungeon(s.Character, id)
+				return data, err
+			}
+
 			data, err := s.Character.ChangeMap(243, nil)
 			if err != nil {
 				return nil, err
@@ -947,48 +1364,118 @@ func (h *ChatHandler) cmdMessage(s *database.Socket, data []byte) ([]byte, error
 			if len(parts) < 2 {
 				return nil, nil
 			}
-			command := parts[1]
-			switch command {
-			case "items":
-				database.RefreshAllItems()
-			case "scripts":
-				database.RefreshScripts()
-			case "htshop":
-				database.RefreshHTItems()
-			case "buffinf":
-				database.RefreshBuffInfections()
-			case "advancedfusions":
-				database.RefreshAdvancedFusions()
-			case "gamblings":
-				database.RefreshGamblingItems()
-			case "craftitems":
-				database.RefreshCraftItem()
-			case "productions":
-				database.RefreshProductions()
-			case "drops":
-				database.RefreshAllDrops()
-			case "shopitems":
-				database.GetAllShopItems()
+			tables := []string{}
+			switch command := strings.ToLower(parts[1]); command {
 			case "npc":
-				database.RefreshScripts()
-				database.GetAllNPCs()
-				database.GetAllNPCPos()
-			case "exp":
-				database.GetExps()
+				tables = []string{database.TABLE_SCRIPTS, database.TABLE_NPCS, database.TABLE_NPC_POS, database.TABLE_NPC_ACTIONS,
+					database.TABLE_NPC_BEHAVIORS}
+			case "all":
+				tables = database.DataTables
 			case "users":
 				database.RefreshUsers()
-			case "npcpos":
-				database.GetAllNPCPos()
+				return nil, nil
+			case "encounters":
+				errs, err := database.LoadEncounters()
+				for _, e := range errs {
+					resp.Concat(messaging.InfoMessage(e))
+				}
+				if err != nil && len(errs) == 0 {
+					resp.Concat(messaging.InfoMessage(err.Error()))
+				} else if err == nil {
+					resp.Concat(messaging.InfoMessage(fmt.Sprintf("%d encounters loaded.", len(database.Encounters))))
+				}
+				return resp, nil
+			case "dungeons":
+				errs, err := database.LoadDungeons()
+				for _, e := range errs {
+					resp.Concat(messaging.InfoMessage(e))
+				}
+				if err != nil && len(errs) == 0 {
+					resp.Concat(messaging.InfoMessage(err.Error()))
+				} else if err == nil {
+					resp.Concat(messaging.InfoMessage(fmt.Sprintf("%d dungeons loaded.", len(database.Dungeons))))
+				}
+				return resp, nil
+			case "battlegrounds":
+				errs, err := database.LoadBattlegrounds()
+				for _, e := range errs {
+					resp.Concat(messaging.InfoMessage(e))
+				}
+				if err != nil && len(errs) == 0 {
+					resp.Concat(messaging.InfoMessage(err.Error()))
+				} else if err == nil {
+					resp.Concat(messaging.InfoMessage(fmt.Sprintf("%d battlegrounds loaded.", len(database.BattlegroundTemplates))))
+				}
+				return resp, nil
+			case "chatfilter":
+				errs, err := database.LoadChatFilter()
+				for _, e := range errs {
+					resp.Concat(messaging.InfoMessage(e))
+				}
+				if err != nil && len(errs) == 0 {
+					resp.Concat(messaging.InfoMessage(err.Error()))
+				} else if err == nil {
+					resp.Concat(messaging.InfoMessage(fmt.Sprintf("%d chat filters loaded.", database.ChatFilterSize())))
+				}
+				return resp, nil
+			case "territories":
+				errs, err := database.LoadTerritories()
+				for _, e := range errs {
+					resp.Concat(messaging.InfoMessage(e))
+				}
+				if err != nil && len(errs) == 0 {
+					resp.Concat(messaging.InfoMessage(err.Error()))
+				} else if err == nil {
+					resp.Concat(messaging.InfoMessage(fmt.Sprintf("%d territories loaded.", len(database.Territories))))
+				}
+				return resp, nil
+			case "maps":
+				count, err := database.LoadNavGrids()
+				if err != nil {
+					return messaging.InfoMessage(err.Error()), nil
+				}
+				return messaging.InfoMessage(fmt.Sprintf("%d map grids loaded.", count)), nil
+			default:
+				tables = []string{command}
+			}
 
-			case "all":
-				callBacks := []func() error{database.RefreshScripts, database.RefreshHTItems, database.RefreshBuffInfections, database.RefreshAdvancedFusions,
-					database.RefreshGamblingItems, database.RefreshCraftItem, database.RefreshProductions, database.RefreshAllDrops, database.GetExps}
-				for _, cb := range callBacks {
-					if err := cb(); err != nil {
-						fmt.Println("Error: ", err)
+			force := len(parts) >= 3 && parts[2] == "force"
+			go func() { // the reload waits for the packet handlers, this one included
+				diff, err := database.ReloadData(force, tables...)
+				if diff != nil {
+					for _, line := range diff.Lines() {
+						resp.Concat(messaging.InfoMessage(line))
 					}
 				}
+				if err != nil {
+					resp.Concat(messaging.InfoMessage(err.Error()))
+				}
+				s.Write(resp)
+			}()
+			return nil, nil
+		case "tick":
+			if s.User.UserType < server.GM_USER {
+				return nil, nil
+			}
+
+			serverID := s.User.ConnectedServer
+			if len(parts) >= 2 {
+				id, err := strconv.Atoi(parts[1])
+				if err != nil {
+					return nil, nil
+				}
+				serverID = id
 			}
+
+			world := database.FindWorld(serverID)
+			if world == nil {
+				return messaging.InfoMessage(fmt.Sprintf("No world is running on server %d.", serverID)), nil
+			}
+
+			for _, line := range world.Lines() {
+				resp.Concat(messaging.InfoMessage(line))
+			}
+			return resp, nil
 		case "charinfo":
 			if s.User.UserType < server.GM_USER {
 				return nil, nil
@@ -1012,22 +1499,6 @@ func (h *ChatHandler) cmdMessage(s *database.Socket, data []byte) ([]byte, error
 			resp.Concat(messaging.InfoMessage(fmt.Sprintf("AID: %d | AID-enabled:%t", c.AidTime, c.AidMode)))
 			resp.Concat(messaging.InfoMessage(fmt.Sprint("SkillPoints: ", c.Socket.Skills.SkillPoints)))
 
-		case "number":
-			if len(parts) < 2 && s.Character.GeneratedNumber != 2 {
-				return nil, nil
-			}
-			number, err := strconv.ParseInt(parts[1], 10, 32)
-			if err != nil {
-				return nil, err
-			}
-			if int(number) == s.Character.GeneratedNumber {
-				s.Conn.Write(messaging.InfoMessage(fmt.Sprintf("You guessed right, Show the boss your power.")))
-				s.Character.DungeonLevel++
-			} else {
-				s.Conn.Write(messaging.InfoMessage(fmt.Sprintf("You guessed poorly, survive & slay again!")))
-				dungeon.MobsCreate([]int{40522}, s.User.ConnectedServer)
-				s.Character.CanTip = 3
-			}
 		case "addmobs":
 			if s.User.UserType < server.HGM_USER {
 				return nil, nil
@@ -1064,24 +1535,21 @@ func (h *ChatHandler) cmdMessage(s *database.Socket, data []byte) ([]byte, error
 						}
 						minCoordinate := database.ConvertPointToLocation(npcPos.MinLocation)
 						maxCoordinate := database.ConvertPointToLocation(npcPos.MaxLocation)
-						targetX := utils.RandFloat(minCoordinate.X, maxCoordinate.X)
-						targetY := utils.RandFloat(minCoordinate.Y, maxCoordinate.Y)
-						target := utils.Location{X: targetX, Y: targetY}
-						newai := &database.AI{ID: len(database.AIs), HP: npc.MaxHp, Map: npcPos.MapID, PosID: npcPos.ID, RunningSpeed: float64(3), Server: k, WalkingSpeed: float64(3), Faction: npcPos.Faction}
+						target := database.RandomWalkablePoint(npcPos.MapID, minCoordinate, maxCoordinate)
+						newai := &database.AI{HP: npc.MaxHp, Map: npcPos.MapID, PosID: npcPos.ID, RunningSpeed: float64(3), Server: k, WalkingSpeed: float64(3), Faction: npcPos.Faction}
 						server.GenerateIDForAI(newai)
 						newai.OnSightPlayers = make(map[int]interface{})
 						newai.Coordinate = target.String()
-						uploadAI := &database.AI{ID: len(database.AIs), PosID: npcPos.ID, Server: k, Faction: npcPos.Faction, Map: npcPos.MapID, Coordinate: newai.Coordinate, WalkingSpeed: float64(3), RunningSpeed: float64(3)}
+						newai.Handler = newai.AIHandler
+						database.RegisterAI(newai)
+
+						uploadAI := &database.AI{ID: newai.ID, PosID: npcPos.ID, Server: k, Faction: npcPos.Faction, Map: npcPos.MapID, Coordinate: newai.Coordinate, WalkingSpeed: float64(3), RunningSpeed: float64(3)}
 						//fmt.Println(newai.Coordinate)
 						aierr := uploadAI.Create()
 						if aierr != nil {
 							fmt.Println("Error: %s", aierr)
 						}
-						newai.Handler = newai.AIHandler
-						database.AIsByMap[newai.Server][npcPos.MapID] = append(database.AIsByMap[newai.Server][npcPos.MapID], newai)
-						database.AIs[newai.ID] = newai
-						fmt.Println("New mob created", len(database.AIs))
-						go newai.Handler()
+						fmt.Println("New mob created", newai.ID)
 					}
 				}
 			}
@@ -1107,22 +1575,19 @@ func (h *ChatHandler) cmdMessage(s *database.Socket, data []byte) ([]byte, error
 				return nil, nil
 			}
 
-			ai := &database.AI{ID: len(database.AIs), HP: npc.MaxHp, Map: npcPos.MapID, PosID: npcPos.ID, RunningSpeed: 10, Server: 1, WalkingSpeed: 5, Once: true}
-			server.GenerateIDForAI(ai)
+			ai := &database.AI{HP: npc.MaxHp, Map: npcPos.MapID, PosID: npcPos.ID, RunningSpeed: 10, Server: 1, WalkingSpeed: 5, Once: true}
 			ai.OnSightPlayers = make(map[int]interface{})
 
 			minLoc := database.ConvertPointToLocation(npcPos.MinLocation)
 			maxLoc := database.ConvertPointToLocation(npcPos.MaxLocation)
-			loc := utils.Location{X: utils.RandFloat(minLoc.X, maxLoc.X), Y: utils.RandFloat(minLoc.Y, maxLoc.Y)}
+			loc := database.RandomWalkablePoint(npcPos.MapID, minLoc, maxLoc)
 			ai.Coordinate = loc.String()
 			fmt.Println(ai.Coordinate)
 			ai.Handler = ai.AIHandler
-			go ai.Handler()
 
 			makeAnnouncement(fmt.Sprintf("%s has been roaring.", npc.Name))
 
-			database.AIsByMap[ai.Server][npcPos.MapID] = append(database.AIsByMap[ai.Server][npcPos.MapID], ai)
-			database.AIs[ai.ID] = ai
+			database.SpawnAI(ai)
 
 		case "droplog":
 			if s.User.UserType < server.GAL_USER {
@@ -1178,7 +1643,7 @@ func (h *ChatHandler) cmdMessage(s *database.Socket, data []byte) ([]byte, error
 				return nil, nil
 			}
 
-			countMaintenance(60)
+			database.CountMaintenance(60)
 
 		case "ban":
 			if s.User.UserType < server.GM_USER {
@@ -1220,9 +1685,87 @@ func (h *ChatHandler) cmdMessage(s *database.Socket, data []byte) ([]byte, error
 			dumb, err := database.FindCharacterByName(parts[1])
 			if err != nil {
 				return nil, err
+			} else if dumb == nil {
+				return messaging.InfoMessage(fmt.Sprintf("%s does not exist.", parts[1])), nil
 			}
 
-			server.MutedPlayers.Set(dumb.UserID, struct{}{})
+			minutes := int64(0) // until it is lifted
+			if len(parts) >= 3 {
+				if minutes, err = strconv.ParseInt(parts[2], 10, 64); err != nil || minutes < 0 {
+					return nil, nil
+				}
+			}
+
+			reason := ""
+			if len(parts) >= 4 {
+				reason = strings.Join(parts[3:], " ")
+			}
+
+			if _, err := database.MuteUser(dumb, s.Character.Name, reason, time.Duration(minutes)*time.Minute, false); err != nil {
+				return nil, err
+			}
+			return messaging.InfoMessage(fmt.Sprintf("%s is muted.", dumb.Name)), nil
+
+		case "mutes":
+			if s.User.UserType < server.GAL_USER {
+				return nil, nil
+			}
+
+			for _, line := range database.MuteLines() {
+				resp.Concat(messaging.InfoMessage(line))
+			}
+			return resp, nil
+
+		case "reports":
+			if s.User.UserType < server.GAL_USER {
+				return nil, nil
+			}
+
+			if len(parts) < 3 {
+				lines, err := database.ChatReportLines()
+				if err != nil {
+					return nil, err
+				}
+				for _, line := range lines {
+					resp.Concat(messaging.InfoMessage(line))
+				}
+				return resp, nil
+			}
+
+			id, err := strconv.Atoi(strings.TrimPrefix(parts[2], "#"))
+			if err != nil {
+				return nil, nil
+			}
+
+			action, note, minutes := strings.ToLower(parts[1]), "", 0
+			switch action {
+			case "view":
+				report, err := database.FindChatReportByID(id)
+				if err != nil {
+					return nil, err
+				} else if report == nil {
+					return messaging.InfoMessage(fmt.Sprintf("There is no report #%d.", id)), nil
+				}
+				for _, line := range report.Lines() {
+					resp.Concat(messaging.InfoMessage(line))
+				}
+				return resp, nil
+			case database.REPORT_MUTE: // /reports mute <id> <minutes> [note]
+				if len(parts) < 4 {
+					return nil, nil
+				}
+				if minutes, err = strconv.Atoi(parts[3]); err != nil || minutes < 0 {
+					return nil, nil
+				}
+				note = strings.Join(parts[4:], " ")
+			default: // /reports dismiss|resolve <id> [note]
+				note = strings.Join(parts[3:], " ")
+			}
+
+			if err := database.ResolveChatReport(id, s.Character.Name, action, note, minutes); err != nil {
+				return messaging.InfoMessage(err.Error()), nil
+			}
+			return messaging.InfoMessage(fmt.Sprintf("Report #%d is closed.", id)), nil
 
 		case "unmute":
 			if s.User.UserType < server.GAL_USER {
@@ -1236,9 +1779,16 @@ func (h *ChatHandler) cmdMessage(s *database.Socket, data []byte) ([]byte, error
 			dumb, err := database.FindCharacterByName(parts[1])
 			if err != nil {
 				return nil, err
+			} else if dumb == nil {
+				return messaging.InfoMessage(fmt.Sprintf("%s does not exist.", parts[1])), nil
 			}
 
-			server.MutedPlayers.Remove(dumb.UserID)
+			if ok, err := database.UnmuteUser(dumb.UserID, s.Character.Name); err != nil {
+				return nil, err
+			} else if !ok {
+				return messaging.InfoMessage(fmt.Sprintf("%s is not muted.", dumb.Name)), nil
+			}
+			return messaging.InfoMessage(fmt.Sprintf("%s can chat again.", dumb.Name)), nil
 
 		case "uid":
 			if s.User.UserType < server.GM_USER {
@@ -1369,39 +1919,49 @@ func (h *ChatHandler) cmdMessage(s *database.Socket, data []byte) ([]byte, error
 			if s.User.UserType < server.HGM_USER {
 				return nil, nil
 			}
-			if len(parts) < 2 {
-				return nil, nil
+			prepare := 0
+			if len(parts) >= 2 {
+				prepare, _ = strconv.Atoi(parts[1])
+			}
+			if err := database.OpenBattleground(database.BATTLEGROUND_GREAT_WAR, prepare, 0, 0); err != nil {
+				return messaging.InfoMessage(err.Error()), nil
 			}
-			time, _ := strconv.ParseInt(parts[1], 10, 32)
-			database.CanJoinWar = true
-			database.StartWarTimer(int(time))
 
 		case "factionwar": //FACTION WAR MISI CSINÁLTA
 			if s.User.UserType < server.HGM_USER {
 				return nil, nil
 			}
-			database.PrepareFactionWar()
+			minLevel, maxLevel := 0, 0
+			if len(parts) >= 3 {
+				minLevel, _ = strconv.Atoi(parts[1])
+				maxLevel, _ = strconv.Atoi(parts[2])
+			}
+			if err := database.OpenBattleground(database.BATTLEGROUND_FACTION_WAR, 0, minLevel, maxLevel); err != nil {
+				return messaging.InfoMessage(err.Error()), nil
+			}
 		case "autogreatwar":
 			if s.User.UserType < server.HGM_USER {
 				return nil, nil
 			}
-			c := cron.New()
-			c.AddFunc("@every 5h", func() {
-				database.CanJoinWar = true
-				database.StartWarTimer(int(600))
-			})
-			c.Start()
-			return messaging.InfoMessage(fmt.Sprintf("Auto Great War activated")), nil
+			e := &database.ScheduledEvent{Type: database.EVENT_GREAT_WAR, Name: "Great War", Schedule: "@every 5h"}
+			if err := database.AddScheduledEvent(e); err != nil {
+				return nil, err
+			}
+			return messaging.InfoMessage(fmt.Sprintf("Auto Great War activated as event %d", e.ID)), nil
 		case "autofactionwar":
 			if s.User.UserType < server.HGM_USER {
 				return nil, nil
 			}
-			c := cron.New()
-			c.AddFunc("@every 5h", func() {
-				database.PrepareFactionWar()
-			})
-			c.Start()
-			return messaging.InfoMessage(fmt.Sprintf("Auto Faction War activated")), nil
+			e := &database.ScheduledEvent{Type: database.EVENT_FACTION_WAR, Name: "Faction War", Schedule: "@every 5h"}
+			if err := database.AddScheduledEvent(e); err != nil {
+				return nil, err
+			}
+			return messaging.InfoMessage(fmt.Sprintf("Auto Faction War activated as event %d", e.ID)), nil
+		case "schedule":
+			if s.User.UserType < server.HGM_USER {
+				return nil, nil
+			}
+			return scheduleCommand(parts[1:])
 		case "speed":
 			if s.User.UserType < server.GM_USER {
 				return nil, nil
@@ -1468,6 +2028,11 @@ func (h *ChatHandler) cmdMessage(s *database.Socket, data []byte) ([]byte, error
 
 			c.Name = parts[2]
 			c.Update()
+			if c.IsOnline {
+				if err := database.SubscribeWhispers(c); err != nil {
+					log.Println(err)
+				}
+			}
 
 		case "role":
 			if s.User.UserType < server.HGM_USER {
@@ -1544,17 +2109,96 @@ func (h *ChatHandler) cmdMessage(s *database.Socket, data []byte) ([]byte, error
 	return resp, err
 }
 
-func countMaintenance(cd int) {
-	msg := fmt.Sprintf("There will be maintenance after %d seconds. Please log out in order to prevent any inconvenience.", cd)
-	makeAnnouncement(msg)
+// scheduleCommand handles /schedule list|types|reload, /schedule pause|resume|run|stop|remove <id> and
+// /schedule add <type> <duration> <announce> <schedule> [key=value...].
+func scheduleCommand(args []string) ([]byte, error) {
+
+	if len(args) == 0 {
+		return messaging.InfoMessage("/schedule list|types|reload|add|pause|resume|run|stop|remove"), nil
+	}
+
+	resp := utils.Packet{}
+	switch command := strings.ToLower(args[0]); command {
+	case "list":
+		lines := database.EventLines()
+		if len(lines) == 0 {
+			return messaging.InfoMessage("No event is scheduled."), nil
+		}
+		for _, line := range lines {
+			resp.Concat(messaging.InfoMessage(line))
+		}
+		return resp, nil
+
+	case "types":
+		return messaging.InfoMessage(strings.Join(database.EventTypes(), ", ")), nil
+
+	case "reload":
+		if err := database.ReloadScheduledEvents(); err != nil {
+			return nil, err
+		}
+		return messaging.InfoMessage(fmt.Sprintf("%d events loaded.", len(database.ScheduledEvents))), nil
+
+	case "add":
+		if len(args) < 5 {
+			return messaging.InfoMessage("/schedule add <type> <duration> <announce> <schedule> [key=value...]"), nil
+		}
+
+		duration, err := strconv.Atoi(args[2])
+		if err != nil {
+			return nil, nil
+		}
+		announce, err := strconv.Atoi(args[3])
+		if err != nil {
+			return nil, nil
+		}
+
+		rest := args[4:]
+		fields := 6
+		if strings.HasPrefix(rest[0], "@every") {
+			fields = 2
+		} else if strings.HasPrefix(rest[0], "@") {
+			fields = 1
+		}
+		if len(rest) < fields {
+			return messaging.InfoMessage("Invalid schedule."), nil
+		}
+
+		e := &database.ScheduledEvent{Type: strings.ToLower(args[1]), Name: args[1], Duration: duration, Announce: announce,
+			Schedule: strings.Join(rest[:fields], " ")}
+		if err := e.SetParams(rest[fields:]); err != nil {
+			return messaging.InfoMessage(err.Error()), nil
+		}
+		if err := database.AddScheduledEvent(e); err != nil {
+			return messaging.InfoMessage(err.Error()), nil
+		}
+		return messaging.InfoMessage(fmt.Sprintf("Event %d added.", e.ID)), nil
+
+	case "pause", "resume", "run", "stop", "remove":
+		if len(args) < 2 {
+			return nil, nil
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return nil, nil
+		}
 
-	if cd > 0 {
-		time.AfterFunc(time.Second*10, func() {
-			countMaintenance(cd - 10)
-		})
-	} else {
-		//os.Exit(0)
+		switch command {
+		case "pause", "resume":
+			err = database.PauseScheduledEvent(id, command == "pause")
+		case "run":
+			err = database.RunScheduledEvent(id)
+		case "stop":
+			err = database.StopScheduledEvent(id)
+		case "remove":
+			err = database.RemoveScheduledEvent(id)
+		}
+		if err != nil {
+			return messaging.InfoMessage(err.Error()), nil
+		}
+		return messaging.InfoMessage(fmt.Sprintf("Event %d: %s done.", id, command)), nil
 	}
+
+	return nil, nil
 }
 
 func cmdSpawnMobs(count, npcID, mapID int, coordinate string) {
@@ -1576,7 +2220,7 @@ func cmdSpawnMobs(count, npcID, mapID int, coordinate string) {
 		npcPos.Create()
 		npc, _ := database.NPCs[npcID]
 
-		newai := &database.AI{ID: len(database.AIs), HP: npc.MaxHp, Map: int16(mapID), PosID: npcPos.ID, RunningSpeed: 10, Server: 1, WalkingSpeed: 5, Once: false}
+		newai := &database.AI{HP: npc.MaxHp, Map: int16(mapID), PosID: npcPos.ID, RunningSpeed: 10, Server: 1, WalkingSpeed: 5, Once: false}
 		newai.OnSightPlayers = make(map[int]interface{})
 
 		loc := utils.Location{X: randomLocX, Y: randomLocY}
@@ -1585,28 +2229,12 @@ func cmdSpawnMobs(count, npcID, mapID int, coordinate string) {
 		newai.Coordinate = loc.String()
 		fmt.Println(newai.Coordinate)
 		newai.Handler = newai.AIHandler
-		database.AIsByMap[newai.Server][newai.Map] = append(database.AIsByMap[newai.Server][newai.Map], newai)
-		database.DungeonsAiByMap[newai.Server][newai.Map] = append(database.AIsByMap[newai.Server][newai.Map], newai)
-		database.AIs[newai.ID] = newai
-		DungeonCount := database.DungeonsByMap[newai.Server][newai.Map] + 1
-		database.DungeonsByMap[newai.Server][newai.Map] = DungeonCount
-		fmt.Println("Mobs Count: ", DungeonCount)
+		database.RegisterAI(newai)
 		server.GenerateIDForAI(newai)
 		newai.Create()
 		//ai.Init()
-		if newai.WalkingSpeed > 0 {
-			go newai.Handler()
-		}
 	}
 }
-func startGuildWar(sourceG, enemyG *database.Guild) []byte {
-	challengerGuild := sourceG
-	enemyGuild := enemyG
-	makeAnnouncement(fmt.Sprintf("%s has declare war to %s.", challengerGuild.Name, enemyGuild.Name))
-
-	return nil
-}
-
 func randFloats(min, max float64) float64 {
 	return min + rand.Float64()*(max-min)
 }
@@ -1617,3 +2245,15 @@ func RemoveIndex(a []string, index int) []string {
 	a = a[:len(a)-1]       // Truncate slice.
 	return a
 }
+
+// bagSlot turns the 1-based position of an item in the bags into its inventory slot.
+func bagSlot(pos string) (int16, bool) {
+	p, err := strconv.Atoi(pos)
+	if err != nil || p < 1 || p > 112 {
+		return -1, false
+	}
+	if p > 56 {
+		return int16(340 + p - 56), true
+	}
+	return int16(10 + p), true
+}
@@ -9,8 +9,6 @@ import (
 	"hero-emulator/nats"
 	"hero-emulator/server"
 	"hero-emulator/utils"
-
-	"github.com/thoas/go-funk"
 )
 
 type (
@@ -73,15 +71,17 @@ func (h *CreateGuildHandler) Handle(s *database.Socket, data []byte) ([]byte, er
 		Recognition:   0,
 	}
 
-	err = g.AddMember(&database.GuildMember{ID: s.Character.ID, Role: database.GROLE_LEADER})
-	if err != nil {
+	if err = g.Create(); err != nil {
 		return nil, err
 	}
 
-	if err = g.Create(); err != nil {
+	err = g.AddMember(&database.GuildMember{ID: s.Character.ID, Role: database.GROLE_LEADER})
+	if err != nil {
 		return nil, err
 	}
 
+	go g.Update()
+
 	s.Character.GuildID = g.ID
 	resp := utils.Packet{}
 
@@ -144,6 +144,8 @@ func (h *GuildRequestHandler) Handle(s *database.Socket, data []byte) ([]byte, e
 
 	if member.Role != database.GROLE_LEADER && member.Role != database.GROLE_SOLDIER && member.Role != database.GROLE_SAGE {
 		return nil, nil
+	} else if guild.MemberCount >= guild.Level().MaxMembers {
+		return messaging.InfoMessage("The house is full, it needs a higher level for more members."), nil
 	}
 
 	leader, err := database.FindCharacterByID(guild.LeaderID)
@@ -191,6 +193,8 @@ func (h *RespondGuildRequestHandler) Handle(s *database.Socket, data []byte) ([]
 			return nil, err
 		} else if guild == nil {
 			return nil, nil
+		} else if guild.MemberCount >= guild.Level().MaxMembers {
+			return messaging.InfoMessage("The house is full."), nil
 		}
 
 		err = guild.AddMember(&database.GuildMember{ID: s.Character.ID, Role: database.GROLE_MEMBER})
@@ -225,19 +229,8 @@ func (h *RespondGuildRequestHandler) Handle(s *database.Socket, data []byte) ([]
 		index += 20
 		r.Insert(utils.IntToBytes(uint64(s.Character.Map), 1, true), index) // character map id
 
-		members, _ := guild.GetMembers()
-		members = funk.Filter(members, func(member *database.GuildMember) bool {
-			c, err := database.FindCharacterByID(member.ID)
-			if err != nil || c == nil {
-				return false
-			}
-
-			return c.IsOnline && c.ID != s.Character.ID
-		}).([]*database.GuildMember)
-
-		for _, member := range members {
-			c, err := database.FindCharacterByID(member.ID)
-			if err != nil || c == nil {
+		for _, c := range guild.OnlineMembers() {
+			if c.ID == s.Character.ID {
 				continue
 			}
 
@@ -277,7 +270,7 @@ func (h *ExpelFromGuildHandler) Handle(s *database.Socket, data []byte) ([]byte,
 		return nil, nil
 	}
 
-	err = guild.RemoveMember(characterID)
+	err = guild.ExpelMember(characterID, s.Character.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -295,13 +288,7 @@ func (h *ExpelFromGuildHandler) Handle(s *database.Socket, data []byte) ([]byte,
 	resp := MEMBER_EXPELLED
 	resp.Insert(utils.IntToBytes(uint64(characterID), 4, true), 6)
 
-	members, _ := guild.GetMembers()
-	for _, member := range members {
-		c, err := database.FindCharacterByID(member.ID)
-		if err != nil || c == nil || !c.IsOnline {
-			continue
-		}
-
+	for _, c := range guild.OnlineMembers() {
 		c.Socket.Write(resp)
 	}
 
@@ -354,13 +341,7 @@ func (h *ChangeGuildLogoHandler) Handle(s *database.Socket, data []byte) ([]byte
 	resp[18] = byte(guild.Faction)                              // guild faction
 	resp.Insert(logo, 20)                                       // guild logo
 
-	members, _ := guild.GetMembers()
-	for _, member := range members {
-		c, err := database.FindCharacterByID(member.ID)
-		if err != nil || c == nil || !c.IsOnline {
-			continue
-		}
-
+	for _, c := range guild.OnlineMembers() {
 		c.Socket.Write(resp)
 	}
 
@@ -377,6 +358,14 @@ func (h *LeaveGuildHandler) Handle(s *database.Socket, data []byte) ([]byte, err
 		return nil, nil
 	}
 
+	if guild.LeaderID == s.Character.ID {
+		if empty, err := guild.BankEmpty(); err != nil {
+			return nil, err
+		} else if !empty {
+			return messaging.InfoMessage("Empty the house warehouse before dissolving the house."), nil
+		}
+	}
+
 	resp := utils.Packet{}
 	s.Character.GuildID = -1
 	if guild.LeaderID == s.Character.ID { // dissolve guild
@@ -420,13 +409,7 @@ func (h *LeaveGuildHandler) Handle(s *database.Socket, data []byte) ([]byte, err
 		r.Insert(utils.IntToBytes(uint64(s.Character.ID), 4, true), 6)
 
 		resp.Concat(r)
-		members, _ := guild.GetMembers()
-		for _, member := range members {
-			c, err := database.FindCharacterByID(member.ID)
-			if err != nil || c == nil || !c.IsOnline {
-				continue
-			}
-
+		for _, c := range guild.OnlineMembers() {
 			c.Socket.Write(r)
 		}
 
@@ -473,8 +456,7 @@ func (h *ChangeRoleHandler) Handle(s *database.Socket, data []byte) ([]byte, err
 		return nil, nil
 	}
 
-	member.Role = database.GuildRole(role)
-	err = guild.SetMember(member)
+	err = guild.ChangeRole(memberID, database.GuildRole(role), s.Character.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,7 @@ package player
 
 import (
 	"hero-emulator/database"
+	"hero-emulator/messaging"
 	"hero-emulator/nats"
 	"hero-emulator/utils"
 )
@@ -26,9 +27,10 @@ func (h *LootHandler) Handle(s *database.Socket, data []byte) ([]byte, error) {
 
 	dropID := uint16(utils.BytesToInt(data[7:9], true))
 	drop := database.GetDrop(s.User.ConnectedServer, s.Character.Map, dropID)
-	//	if drop != nil && drop.Item != nil && (drop.Claimer == nil || drop.Claimer.ID == s.Character.ID) {
-	if drop.Item.ItemID == 0 {
+	if drop == nil || drop.Item == nil || drop.Item.ItemID == 0 {
 		return nil, nil
+	} else if drop.Claimer != nil && drop.Claimer.ID != c.ID {
+		return messaging.InfoMessage("This item belongs to another player."), nil
 	}
 
 	d, _, err := c.AddItem(drop.Item, -1, true)
@@ -40,7 +42,6 @@ func (h *LootHandler) Handle(s *database.Socket, data []byte) ([]byte, error) {
 
 	database.RemoveFromDropRegister(s.User.ConnectedServer, s.Character.Map, dropID)
 	resp.Concat(*d)
-	//	}
 
 	r := database.DROP_DISAPPEARED
 	r.Insert(utils.IntToBytes(uint64(dropID), 2, true), 6) //drop id
@@ -2,8 +2,6 @@ package player
 
 import (
 	"math"
-	"strconv"
-	"strings"
 	"time"
 
 	"hero-emulator/database"
@@ -33,26 +31,9 @@ func (h *MovementHandler) Handle(s *database.Socket, data []byte) ([]byte, error
 	if len(data) < 26 {
 		return nil, nil
 	}
-	if c.Map == 255 && database.IsFactionWarEntranceActive() {
-		parts := strings.Split(c.Coordinate, ",")
-		y := strings.Trim(parts[1], ")")
-		py := strings.Split(y, ".")
-		y = py[0]
-		posY, _ := strconv.ParseInt(y, 10, 64)
-		if c.Faction == 1 && posY < 440 {
-
-			coordinate := &utils.Location{X: 325, Y: 465}
-			gomap, _ := c.ChangeMap(255, coordinate)
-			c.Socket.Write(gomap)
-
-		}
-		if c.Faction == 2 && posY > 80 {
-
-			coordinate := &utils.Location{X: 179, Y: 45}
-			gomap, _ := c.ChangeMap(255, coordinate)
-			c.Socket.Write(gomap)
-
-		}
+	target := &utils.Location{X: utils.BytesToFloat(data[18:22], true), Y: utils.BytesToFloat(data[22:26], true)}
+	if !database.IsWalkable(c.Map, target) { // target is inside a wall or off the terrain
+		return c.Teleport(database.ConvertPointToLocation(c.Coordinate)), nil
 	}
 
 	movType := utils.BytesToInt(data[4:6], false)
@@ -85,29 +66,12 @@ func (h *MovementHandler) Handle(s *database.Socket, data []byte) ([]byte, error
 	token := utils.RandInt(0, math.MaxInt64)
 	c.MovementToken = token
 
-	target := &utils.Location{X: utils.BytesToFloat(data[18:22], true), Y: utils.BytesToFloat(data[22:26], true)}
-	if c.IsinWar && !database.WarStarted {
-		if coordinate.X >= 155 && c.Faction == 1 && target.X > 155 || target.Y > 65 && c.Faction == 1 {
-			target.X = 155
-			target.Y = coordinate.Y
-			if target.Y > 65 {
-				target.Y = 65
-			}
-			c.SetCoordinate(target)
-			mapID, _ := s.Character.ChangeMap(c.Map, target)
-			s.Conn.Write(mapID)
-			return nil, nil
-		} else if coordinate.X >= 147 && c.Faction == 2 && target.X > 147 || target.Y < 457 && c.Faction == 2 {
-			target.X = 147
-			target.Y = coordinate.Y
-			if target.Y < 457 {
-				target.Y = 457
-			}
-			c.SetCoordinate(target)
-			mapID, _ := s.Character.ChangeMap(c.Map, target)
-			s.Conn.Write(mapID)
-			return nil, nil
-		}
+	if area := database.FindBattleground(c.BattlegroundID).HoldArea(c); area != nil && !area.Contains(target) {
+		target = area.Clamp(target) // the team waits in its area until the battleground starts
+		c.SetCoordinate(target)
+		mapID, _ := c.ChangeMap(c.Map, target)
+		s.Conn.Write(mapID)
+		return nil, nil
 	}
 	distance := utils.CalculateDistance(coordinate, target)
 	delay := distance * 1000 / speed // delay (ms)
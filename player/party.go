@@ -15,6 +15,8 @@ type (
 	RespondPartyRequestHandler struct{}
 	LeavePartyHandler          struct{}
 	ExpelFromPartyHandler      struct{}
+	ChangeLootModeHandler      struct{}
+	LootRollHandler            struct{}
 )
 
 var (
@@ -27,7 +29,7 @@ func (h *SendPartyRequestHandler) Handle(s *database.Socket, data []byte) ([]byt
 
 	pseudoID := uint16(utils.BytesToInt(data[6:8], true))
 	member := server.FindCharacter(s.User.ConnectedServer, pseudoID)
-	if member == nil || database.FindParty(member) != nil {
+	if member == nil || database.FindParty(member) != nil || member.IsBlocking(s.Character) {
 		return nil, nil
 	}
 
@@ -158,3 +160,36 @@ func (h *ExpelFromPartyHandler) Handle(s *database.Socket, data []byte) ([]byte,
 
 	return resp, nil
 }
+
+func (h *ChangeLootModeHandler) Handle(s *database.Socket, data []byte) ([]byte, error) {
+
+	party := database.FindParty(s.Character)
+	if party == nil || party.Leader.ID != s.Character.ID || len(data) < 7 { // if no party or no authorization
+		return nil, nil
+	}
+
+	mode := database.LootMode(data[6])
+	if mode > database.LOOT_NEED_GREED {
+		return nil, nil
+	}
+
+	party.SetLootMode(mode)
+	return nil, nil
+}
+
+func (h *LootRollHandler) Handle(s *database.Socket, data []byte) ([]byte, error) {
+
+	party := database.FindParty(s.Character)
+	if party == nil || len(data) < 9 {
+		return nil, nil
+	}
+
+	rollID := int(utils.BytesToInt(data[6:8], true))
+	choice := data[8]
+	if choice > database.ROLL_GREED {
+		return nil, nil
+	}
+
+	party.Roll(s.Character, rollID, choice)
+	return nil, nil
+}
@@ -56,6 +56,9 @@ func (h *SendTradeRequestHandler) Handle(s *database.Socket, data []byte) ([]byt
 
 	} else if !receiver.IsActive {
 		return messaging.SystemMessage(messaging.INVALID_TRADE_REQUEST), nil
+
+	} else if receiver.IsBlocking(s.Character) {
+		return messaging.SystemMessage(messaging.TRADE_REQUEST_REJECTED), nil
 	}
 
 	sock := database.GetSocket(receiver.UserID)
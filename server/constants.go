@@ -3,7 +3,6 @@ package server
 import (
 	"hero-emulator/database"
 
-	cmap "github.com/orcaman/concurrent-map"
 	"github.com/thoas/go-funk"
 )
 
@@ -17,10 +16,6 @@ const (
 	VIP_USER
 )
 
-var (
-	MutedPlayers = cmap.New()
-)
-
 func init() {
 	accUpgrades := []byte{}
 	armorUpgrades := []byte{}
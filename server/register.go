@@ -73,6 +73,15 @@ func init() {
 	database.GenerateID = GenerateID
 	database.FindCharacterByPseudoID = FindCharacter
 	database.GeneratePetID = GenerateIDForPet
+	database.RegisterNPC = ReplaceNPC
+	database.GenerateIDForAI = GenerateIDForAI
+	database.RemoveAIFromRegister = func(AI *database.AI) {
+		mrMutex.Lock()
+		defer mrMutex.Unlock()
+		if MapRegister[AI.Server][AI.Map][AI.PseudoID] == AI {
+			delete(MapRegister[AI.Server][AI.Map], AI.PseudoID)
+		}
+	}
 
 	Init <- true
 }
@@ -137,6 +146,21 @@ func GenerateIDForNPC(NPCPos *database.NpcPosition) {
 	//}
 }
 
+func ReplaceNPC(old, pos *database.NpcPosition) {
+	if old != nil && old.PseudoID > 0 {
+		mrMutex.Lock()
+		delete(MapRegister[1][old.MapID], old.PseudoID)
+		if pos.PseudoID == old.PseudoID {
+			MapRegister[1][pos.MapID][pos.PseudoID] = pos
+		}
+		mrMutex.Unlock()
+	}
+
+	if pos.PseudoID == 0 {
+		GenerateIDForNPC(pos)
+	}
+}
+
 func FindCharacter(server int, ID uint16) *database.Character {
 	prMutex.RLock()
 	defer prMutex.RUnlock()